@@ -42,6 +42,8 @@ import (
 	"github.com/Arriven/db1000n/src/utils"
 	"github.com/Arriven/db1000n/src/utils/metrics"
 	"github.com/Arriven/db1000n/src/utils/ota"
+	"github.com/Arriven/db1000n/src/utils/templates"
+	"github.com/Arriven/db1000n/src/utils/tracing"
 )
 
 func main() {
@@ -54,7 +56,9 @@ func main() {
 	otaConfig := ota.NewConfigWithFlags()
 	countryCheckerConfig := utils.NewCountryCheckerConfigWithFlags()
 	updaterMode, destinationPath := config.NewUpdaterOptionsWithFlags()
-	prometheusOn, prometheusPushGateways := metrics.NewOptionsWithFlags()
+	prometheusOn, prometheusPushGateways, prometheusListenAddress := metrics.NewOptionsWithFlags()
+	statsDAddress, statsDPrefix, statsDInterval := metrics.NewStatsDOptionsWithFlags()
+	metricsFile, metricsFileMode, metricsFileInterval := metrics.NewFileSinkOptionsWithFlags()
 	pprof := flag.String("pprof", utils.GetEnvStringDefault("GO_PPROF_ENDPOINT", ""), "enable pprof")
 	help := flag.Bool("h", false, "print help message and exit")
 	debug := flag.Bool("debug", utils.GetEnvBoolDefault("DEBUG", false), "enable debug level logging")
@@ -72,7 +76,7 @@ func main() {
 		return
 	}
 
-	logger, err := newZapLogger(*debug)
+	logger, err := newZapLogger(jobsGlobalConfig, *debug)
 	if err != nil {
 		log.Fatalf("failed to initialize Zap logger: %v", err)
 	}
@@ -86,23 +90,103 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	metrics.InitOrFail(ctx, logger, *prometheusOn, *prometheusPushGateways, jobsGlobalConfig.ClientID, country)
+	metrics.InitOrFail(ctx, logger, *prometheusOn, *prometheusPushGateways, *prometheusListenAddress, jobsGlobalConfig.ClientID, country)
+
+	var statsDSink *metrics.StatsDSink
+
+	if *statsDAddress != "" {
+		if statsDSink, err = metrics.NewStatsDSink(*statsDAddress, *statsDPrefix); err != nil {
+			log.Fatalf("Invalid value for --statsd_address: %v", err)
+		}
+
+		go statsDSink.Run(ctx, logger, *statsDInterval)
+	}
+
+	var fileSink *metrics.FileSink
+
+	if *metricsFile != "" {
+		if fileSink, err = metrics.NewFileSink(*metricsFile, *metricsFileInterval, *metricsFileMode); err != nil {
+			log.Fatalf("Invalid value for --metrics_file: %v", err)
+		}
+
+		go fileSink.Run(ctx, logger)
+	}
+
+	if jobsGlobalConfig.OTLPEndpoint != "" {
+		shutdownTracing, err := tracing.Setup(ctx, jobsGlobalConfig.OTLPEndpoint)
+		if err != nil {
+			log.Fatalf("Invalid value for --otlp-endpoint: %v", err)
+		}
+
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				logger.Debug("error exporting remaining traces", zap.Error(err))
+			}
+		}()
+	}
+
+	templates.SetFilesDir(jobsGlobalConfig.FilesDir)
+	templates.SetEnvPrefixes(strings.Split(jobsGlobalConfig.TemplateEnvPrefixes, ","))
+
+	if jobsGlobalConfig.RandomSeed != 0 {
+		templates.SetRandomSeed(jobsGlobalConfig.RandomSeed)
+	}
 
 	r, err := job.NewRunner(runnerConfigOptions, jobsGlobalConfig)
 	if err != nil {
 		log.Panicf("Error initializing runner: %v", err)
 	}
 
+	if jobsGlobalConfig.DryRun {
+		if err := r.DryRun(ctx, logger, os.Stdout); err != nil {
+			log.Fatalf("Dry run failed: %v", err)
+		}
+
+		return
+	}
+
+	if jobsGlobalConfig.AlertRules != "" {
+		alerter, err := newAlerter(jobsGlobalConfig)
+		if err != nil {
+			log.Fatalf("Invalid value for --alert-rules: %v", err)
+		}
+
+		go alerter.Run(ctx, logger)
+	}
+
 	go cancelOnSignal(cancel)
 	r.Run(ctx, logger)
+
+	// runner only returns after the jobs are stopped so this is the final snapshot
+	if statsDSink != nil {
+		if err := statsDSink.Close(); err != nil {
+			logger.Debug("error sending final metrics to statsd", zap.Error(err))
+		}
+	}
+
+	if fileSink != nil {
+		if err := fileSink.Close(); err != nil {
+			logger.Debug("error writing final metrics snapshot", zap.Error(err))
+		}
+	}
 }
 
-func newZapLogger(debug bool) (*zap.Logger, error) {
-	if debug {
-		return zap.NewDevelopment()
+func newZapLogger(globalConfig *job.GlobalConfig, debug bool) (*zap.Logger, error) {
+	cfg, err := globalConfig.NewLoggerConfig(debug)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Build()
+}
+
+func newAlerter(globalConfig *job.GlobalConfig) (*metrics.Alerter, error) {
+	rules, err := metrics.LoadAlertRules(globalConfig.AlertRules)
+	if err != nil {
+		return nil, err
 	}
 
-	return zap.NewProduction()
+	return metrics.NewAlerter(rules, globalConfig.AlertInterval)
 }
 
 func setUpPprof(pprof string, debug bool) {
@@ -3,6 +3,7 @@ package dnsblast
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -36,6 +37,7 @@ type Config struct {
 	Delay           time.Duration // The delay between two packets to send
 	ParallelQueries int
 	ClientID        string
+	ReplyTimeout    time.Duration // waits this long for the responses and counts them in db1000n_udp_replies_total when positive
 }
 
 // DNSBlaster is a main worker struct for the package
@@ -69,6 +71,7 @@ func Start(ctx context.Context, logger *zap.Logger, wg *sync.WaitGroup, config *
 		Protocol:        config.Protocol,
 		SeedDomains:     config.SeedDomains,
 		ParallelQueries: config.ParallelQueries,
+		ReplyTimeout:    config.ReplyTimeout,
 	}
 
 	for _, nameserver := range nameservers {
@@ -112,6 +115,7 @@ type StressTestParameters struct {
 	ParallelQueries int
 	Protocol        string
 	SeedDomains     []string
+	ReplyTimeout    time.Duration
 }
 
 // ExecuteStressTest executes a stress test based on parameters
@@ -119,6 +123,9 @@ func (rcv *DNSBlaster) ExecuteStressTest(ctx context.Context, logger *zap.Logger
 	defer utils.PanicHandler(logger)
 
 	sharedDNSClient := newDefaultDNSClient(parameters.Protocol)
+	if parameters.ReplyTimeout > 0 {
+		sharedDNSClient.ReadTimeout = parameters.ReplyTimeout
+	}
 
 	dhhGenerator, err := NewDistinctHeavyHitterGenerator(ctx, parameters.SeedDomains)
 	if err != nil {
@@ -138,6 +145,8 @@ func (rcv *DNSBlaster) ExecuteStressTest(ctx context.Context, logger *zap.Logger
 			HostAndPort: nameserver,
 			QName:       "", // To be generated on each cycle
 			QType:       dns.TypeA,
+
+			TrackReplies: parameters.ReplyTimeout > 0,
 		}
 	)
 
@@ -180,6 +189,8 @@ type QueryParameters struct {
 	HostAndPort string
 	QName       string
 	QType       uint16
+
+	TrackReplies bool // count responses and the queries that timed out waiting for them in db1000n_udp_replies_total
 }
 
 // Response is a dns response struct
@@ -238,7 +249,11 @@ func (rcv *DNSBlaster) SimpleQueryWithNoResponse(logger *zap.Logger, sharedDNSCl
 
 	defer co.Close()
 
-	_, _, err = sharedDNSClient.Exchange(question, parameters.HostAndPort)
+	_, rtt, err := sharedDNSClient.Exchange(question, parameters.HostAndPort)
+	if parameters.TrackReplies {
+		observeReply(parameters.HostAndPort, sharedDNSClient.Net, rtt, err)
+	}
+
 	if err != nil {
 		metrics.IncDNSBlast(parameters.HostAndPort, seedDomain, sharedDNSClient.Net, metrics.StatusFail)
 		logger.Debug("failed to complete the DNS query", zap.Error(err))
@@ -249,6 +264,19 @@ func (rcv *DNSBlaster) SimpleQueryWithNoResponse(logger *zap.Logger, sharedDNSCl
 	metrics.IncDNSBlast(parameters.HostAndPort, seedDomain, sharedDNSClient.Net, metrics.StatusSuccess)
 }
 
+// observeReply counts the response, the client matches it to the query by the transaction id
+func observeReply(nameserver, protocol string, rtt time.Duration, err error) {
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		metrics.IncUDPReply(nameserver, protocol, metrics.StatusSuccess)
+		metrics.Default.ObserveLatency(nameserver, metrics.StatusSuccess, rtt)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		metrics.IncUDPReply(nameserver, protocol, metrics.UDPReplyStatusTimeout)
+	}
+}
+
 const (
 	dialTimeout  = 1 * time.Second        // Let's not wait long if the server cannot be dialled, we all know why
 	writeTimeout = 500 * time.Millisecond // Longer write timeout than read timeout just to make sure the query is uploaded
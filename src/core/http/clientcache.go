@@ -0,0 +1,126 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// sharedClients holds the clients of ClientConfig.Shared configs by the hash of the resolved config
+var sharedClients = struct {
+	sync.Mutex
+	entries map[string]*sharedClient
+}{entries: make(map[string]*sharedClient)}
+
+type sharedClient struct {
+	client Client
+	cancel context.CancelFunc
+	refs   int
+}
+
+// AcquireClient returns a client and the function to call once the caller is done with it. Callers with Shared configs
+// that are equal once the proxy is picked get the same client, and so the same connection pool, it's torn down when
+// the last of them releases it. Other configs get a new client from NewClient and release only cancels its context
+func AcquireClient(ctx context.Context, clientConfig ClientConfig, logger *zap.Logger) (client Client, release func(), err error) {
+	key, ok := sharedClientKey(ctx, &clientConfig, logger)
+	if !ok {
+		clientCtx, cancel := context.WithCancel(ctx)
+
+		if client, err = NewClient(clientCtx, clientConfig, logger); err != nil {
+			cancel()
+
+			return nil, nil, err
+		}
+
+		return client, cancel, nil
+	}
+
+	sharedClients.Lock()
+	defer sharedClients.Unlock()
+
+	entry, ok := sharedClients.entries[key]
+	if !ok {
+		// the client outlives the caller so it only inherits the values of its context
+		clientCtx, cancel := context.WithCancel(utils.DetachedContext{Context: ctx})
+
+		if client, err = NewClient(clientCtx, clientConfig, logger); err != nil {
+			cancel()
+
+			return nil, nil, err
+		}
+
+		entry = &sharedClient{client: client, cancel: cancel}
+		sharedClients.entries[key] = entry
+	}
+
+	entry.refs++
+
+	var once sync.Once
+
+	return entry.client, func() { once.Do(func() { releaseSharedClient(key, entry) }) }, nil
+}
+
+func releaseSharedClient(key string, entry *sharedClient) {
+	sharedClients.Lock()
+	defer sharedClients.Unlock()
+
+	if entry.refs--; entry.refs > 0 {
+		return
+	}
+
+	delete(sharedClients.entries, key)
+	entry.cancel()
+}
+
+// sharedClientKey resolves the proxy of the config (so that the callers sharing the client share the proxy too) and
+// returns the hash of the result, configs with tls_config can't be hashed and aren't shared
+func sharedClientKey(ctx context.Context, clientConfig *ClientConfig, logger *zap.Logger) (string, bool) {
+	if !clientConfig.Shared || clientConfig.TLSClientConfig != nil {
+		return "", false
+	}
+
+	clientConfig.ProxyURLs = templates.ParseAndExecute(logger, clientConfig.ProxyURLs, ctx)
+	if clientConfig.ProxySelection == "" {
+		clientConfig.ProxyURLs = utils.PickProxy(clientConfig.ProxyURLs)
+	}
+
+	content, err := json.Marshal(clientConfig)
+	if err != nil {
+		logger.Debug("error hashing shared client config, the client isn't shared", zap.Error(err))
+
+		return "", false
+	}
+
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:]), true
+}
@@ -0,0 +1,132 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/Arriven/db1000n/src/utils/metrics"
+)
+
+// connectionTracker tells requests sent over freshly dialed connections apart from the ones reused from the pool:
+// fasthttp doesn't report which connection served a request so every successful dial is claimed by the next completed request to the same address
+type connectionTracker struct {
+	pending sync.Map // map by address to the number of unclaimed dials
+}
+
+func (t *connectionTracker) counter(addr string) *int64 {
+	if counter, ok := t.pending.Load(addr); ok {
+		return counter.(*int64)
+	}
+
+	counter, _ := t.pending.LoadOrStore(addr, new(int64))
+
+	return counter.(*int64)
+}
+
+// dial wraps the dial func to count successful dials, key overrides the address they are counted for when not empty
+func (t *connectionTracker) dial(dial fasthttp.DialFunc, key string) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err == nil {
+			if key != "" {
+				addr = key
+			}
+
+			atomic.AddInt64(t.counter(addr), 1)
+		}
+
+		return conn, err
+	}
+}
+
+// claim reports whether there is a dial to the address not yet attributed to any request and attributes it
+func (t *connectionTracker) claim(addr string) bool {
+	counter := t.counter(addr)
+
+	for {
+		pending := atomic.LoadInt64(counter)
+		if pending <= 0 {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt64(counter, pending, pending-1) {
+			return true
+		}
+	}
+}
+
+// connectionTrackingClient reports whether requests were sent over new or reused connections
+type connectionTrackingClient struct {
+	Client
+	tracker *connectionTracker
+	addr    func(req *fasthttp.Request) string
+}
+
+func (c connectionTrackingClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	err := c.Client.Do(req, resp)
+	c.observe(req, err)
+
+	return err
+}
+
+func (c connectionTrackingClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	err := c.Client.DoTimeout(req, resp, timeout)
+	c.observe(req, err)
+
+	return err
+}
+
+func (c connectionTrackingClient) observe(req *fasthttp.Request, err error) {
+	addr := c.addr(req)
+
+	reused := !c.tracker.claim(addr)
+	if reused && err != nil {
+		return // the request might have failed before getting any connection
+	}
+
+	metrics.Default.ObserveConnection(addr, reused)
+	metrics.IncHTTPConnection(addr, reused)
+}
+
+// requestAddr returns the address fasthttp.Client dials for the request
+func requestAddr(req *fasthttp.Request) string {
+	uri := req.URI()
+	addr := string(uri.Host())
+
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+
+	if bytes.EqualFold(uri.Scheme(), []byte("https")) {
+		return addr + ":443"
+	}
+
+	return addr + ":80"
+}
@@ -0,0 +1,111 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/valyala/fasthttp"
+)
+
+// DecodeBody returns the response body with the content encoding undone, supported encodings are gzip, deflate and br.
+// Multiple encodings are undone in the reverse order of the header, the raw body is returned along with the error
+// if it can't be decoded
+func DecodeBody(resp *fasthttp.Response) ([]byte, error) {
+	body := resp.Body()
+
+	encodings := bytes.Split(resp.Header.Peek(fasthttp.HeaderContentEncoding), []byte(","))
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var (
+			decoded []byte
+			err     error
+		)
+
+		switch encoding := string(bytes.ToLower(bytes.TrimSpace(encodings[i]))); encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			decoded, err = fasthttp.AppendGunzipBytes(nil, body)
+		case "deflate":
+			decoded, err = fasthttp.AppendInflateBytes(nil, body)
+		case "br":
+			decoded, err = fasthttp.AppendUnbrotliBytes(nil, body)
+		default:
+			return resp.Body(), fmt.Errorf("unsupported content encoding %q", encoding)
+		}
+
+		if err != nil {
+			return resp.Body(), fmt.Errorf("error decoding %s body: %w", encodings[i], err)
+		}
+
+		body = decoded
+	}
+
+	return body, nil
+}
+
+// WriteDecodedBody writes the response body to w with the content encodings undone like DecodeBody does but without holding
+// the decoded body in memory, so that bodies of any size (or compression bombs) can be saved. No more than limit bytes are written
+// when it's positive, truncated tells whether the body was longer than that
+func WriteDecodedBody(w io.Writer, resp *fasthttp.Response, limit int) (written int64, truncated bool, err error) {
+	var body io.Reader = bytes.NewReader(resp.Body())
+
+	encodings := bytes.Split(resp.Header.Peek(fasthttp.HeaderContentEncoding), []byte(","))
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encoding := string(bytes.ToLower(bytes.TrimSpace(encodings[i]))); encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(body)
+		case "deflate":
+			body, err = zlib.NewReader(body)
+		case "br":
+			body = brotli.NewReader(body)
+		default:
+			return 0, false, fmt.Errorf("unsupported content encoding %q", encoding)
+		}
+
+		if err != nil {
+			return 0, false, fmt.Errorf("error decoding %s body: %w", encodings[i], err)
+		}
+	}
+
+	if limit <= 0 {
+		written, err = io.Copy(w, body)
+	} else if written, err = io.Copy(w, io.LimitReader(body, int64(limit))); err == nil {
+		// a single byte more is enough to tell the body was cut
+		extra, _ := io.ReadFull(body, make([]byte, 1))
+		truncated = extra > 0
+	}
+
+	if err != nil {
+		return written, truncated, fmt.Errorf("error writing decoded body: %w", err)
+	}
+
+	return written, truncated, nil
+}
@@ -0,0 +1,132 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HAREntry is a single request of a HAR export
+type HAREntry struct {
+	Request RequestConfig
+	Offset  time.Duration // since the first request of the export was started
+}
+
+// har holds the parts of the HAR 1.2 format (http://www.softwareishard.com/blog/har-12-spec/) needed to replay the requests
+type har struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method   string         `json:"method"`
+				URL      string         `json:"url"`
+				Headers  []harNameValue `json:"headers"`
+				Cookies  []harNameValue `json:"cookies"`
+				PostData *struct {
+					Text   string         `json:"text"`
+					Params []harNameValue `json:"params"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harSkippedHeaders are set by the client itself, the cookie header is only skipped when the cookies are listed separately
+var harSkippedHeaders = map[string]bool{"content-length": true, "connection": true, "host": true}
+
+// ParseHAR converts the entries of a HAR export into requests, in the order they were recorded
+func ParseHAR(content []byte) ([]HAREntry, error) {
+	var export har
+	if err := json.Unmarshal(content, &export); err != nil {
+		return nil, fmt.Errorf("error parsing har: %w", err)
+	}
+
+	if len(export.Log.Entries) == 0 {
+		return nil, errors.New("har has no entries")
+	}
+
+	entries := make([]HAREntry, 0, len(export.Log.Entries))
+	start := export.Log.Entries[0].StartedDateTime
+
+	for i, entry := range export.Log.Entries {
+		if _, err := url.ParseRequestURI(entry.Request.URL); err != nil {
+			return nil, fmt.Errorf("invalid url of har entry %d: %w", i, err)
+		}
+
+		request := RequestConfig{
+			Path:    entry.Request.URL,
+			Method:  entry.Request.Method,
+			Headers: make(map[string]string, len(entry.Request.Headers)),
+			Cookies: make(map[string]string, len(entry.Request.Cookies)),
+		}
+
+		for _, cookie := range entry.Request.Cookies {
+			request.Cookies[cookie.Name] = cookie.Value
+		}
+
+		for _, header := range entry.Request.Headers {
+			// http/2 pseudo-headers and the headers set by the client aren't replayed
+			name := strings.ToLower(header.Name)
+			if strings.HasPrefix(name, ":") || harSkippedHeaders[name] || (name == "cookie" && len(request.Cookies) > 0) {
+				continue
+			}
+
+			if value, ok := request.Headers[header.Name]; ok {
+				request.Headers[header.Name] = value + ", " + header.Value
+			} else {
+				request.Headers[header.Name] = header.Value
+			}
+		}
+
+		if postData := entry.Request.PostData; postData != nil {
+			request.Body = postData.Text
+
+			if request.Body == "" && len(postData.Params) > 0 {
+				params := url.Values{}
+				for _, param := range postData.Params {
+					params.Add(param.Name, param.Value)
+				}
+
+				request.Body = params.Encode()
+			}
+		}
+
+		offset := entry.StartedDateTime.Sub(start)
+		if offset < 0 || start.IsZero() || entry.StartedDateTime.IsZero() {
+			offset = 0
+		}
+
+		entries = append(entries, HAREntry{Request: request, Offset: offset})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+const testHAR = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "startedDateTime": "2022-03-01T10:00:00.000Z",
+        "request": {
+          "method": "GET",
+          "url": "https://example.com/search?q=test",
+          "headers": [
+            {"name": ":authority", "value": "example.com"},
+            {"name": "Accept", "value": "text/html"},
+            {"name": "Accept", "value": "application/json"},
+            {"name": "Cookie", "value": "session=abc"},
+            {"name": "Host", "value": "example.com"}
+          ],
+          "cookies": [{"name": "session", "value": "abc"}]
+        }
+      },
+      {
+        "startedDateTime": "2022-03-01T10:00:01.500Z",
+        "request": {
+          "method": "POST",
+          "url": "https://example.com/login",
+          "headers": [
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "Content-Length", "value": "17"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"user\":\"alice\"}"}
+        }
+      },
+      {
+        "startedDateTime": "2022-03-01T10:00:02.000Z",
+        "request": {
+          "method": "POST",
+          "url": "https://example.com/form",
+          "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "a", "value": "1 2"}, {"name": "b", "value": "3"}]}
+        }
+      }
+    ]
+  }
+}`
+
+func TestParseHAR(t *testing.T) {
+	t.Parallel()
+
+	entries, err := ParseHAR([]byte(testHAR))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	search := entries[0].Request
+	if search.Method != "GET" || search.Path != "https://example.com/search?q=test" || search.Body != "" || entries[0].Offset != 0 {
+		t.Errorf("unexpected first entry %+v", entries[0])
+	}
+
+	if len(search.Headers) != 1 || search.Headers["Accept"] != "text/html, application/json" {
+		t.Errorf("expected only the repeated accept header to be kept, got %v", search.Headers)
+	}
+
+	if len(search.Cookies) != 1 || search.Cookies["session"] != "abc" {
+		t.Errorf("unexpected cookies %v", search.Cookies)
+	}
+
+	login := entries[1]
+	if login.Request.Method != "POST" || login.Request.Path != "https://example.com/login" || login.Request.Body != `{"user":"alice"}` ||
+		login.Request.Headers["Content-Type"] != "application/json" || len(login.Request.Headers) != 1 || login.Offset != 1500*time.Millisecond {
+		t.Errorf("unexpected second entry %+v", login)
+	}
+
+	if form := entries[2].Request; form.Body != "a=1+2&b=3" {
+		t.Errorf("expected form params to be encoded as the body, got %q", form.Body)
+	}
+}
+
+func TestParseHARErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, content := range []string{
+		`not json`,
+		`{"log": {"entries": []}}`,
+		`{"log": {"entries": [{"request": {"method": "GET", "url": "not a url"}}]}}`,
+	} {
+		if _, err := ParseHAR([]byte(content)); err == nil {
+			t.Errorf("expected an error parsing %v", content)
+		}
+	}
+}
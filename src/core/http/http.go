@@ -26,10 +26,16 @@ package http
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"fmt"
 	"net"
+	nethttp "net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/corpix/uarand"
+	"github.com/google/uuid"
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 
@@ -40,21 +46,71 @@ import (
 
 // RequestConfig is a struct representing the config of a single request
 type RequestConfig struct {
-	Path    string
-	Method  string
-	Body    string
-	Headers map[string]string
-	Cookies map[string]string
+	Path      string
+	Method    string
+	Body      string
+	Headers   map[string]string
+	Cookies   map[string]string
+	Timeout   *time.Duration   // overrides client timeouts for a single request when set
+	Encoding  string           // "gzip", "deflate", or empty to send the body as is
+	Multipart *MultipartConfig // replaces Body with the encoded multipart form when set
+
+	Streaming   bool          // sends the body with chunked transfer encoding instead of Content-Length
+	ChunkSize   int           `mapstructure:"chunk_size"`  // size of streamed body chunks, 1024 by default
+	ChunkDelay  time.Duration `mapstructure:"chunk_delay"` // pause between streamed body chunks
+	OnBodyWrite func(n int)   `mapstructure:"-"`           // called with the size of every streamed body chunk, may be called concurrently
+
+	HeaderSet map[string]string `mapstructure:"-"` // headers of the fingerprint picked by the job, applied before Headers so that those can override them
+
+	// strips the body of the methods that aren't supposed to have one and sets the content type of the bodies sent without it
+	StrictMethods bool `mapstructure:"strict_methods"`
+
+	// set by DecodeRequest when Body has been marshaled from an object, sends it with application/json content type
+	JSONBody bool `mapstructure:"-"`
+
+	// sends "Connection: close", set by the jobs whose client closes the connections anyway (see ClientConfig.ClosesConnections)
+	// so that the header is accounted in the request size
+	ConnectionClose bool `mapstructure:"-"`
 }
 
-// InitRequest is used to populate data from request config to fasthttp.Request
+// Supported values for RequestConfig.Encoding
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// InitRequest is used to populate data from request config to fasthttp.Request. Returns the size of the request on the wire:
+// request line, headers and body as the client serializes them. Streamed body is not included as it's reported with
+// RequestConfig.OnBodyWrite while it's being sent, its chunk framing is added with chunkedBody.framingSize() as the body doesn't report it
 func InitRequest(c RequestConfig, req *fasthttp.Request) int64 {
+	// requests are reused by the jobs, headers of the previous one (i.e. of another header set) must not leak into the next one
+	req.Reset()
 	req.SetRequestURI(c.Path)
 	req.Header.SetMethod(c.Method)
-	req.SetBodyString(c.Body)
+
+	stripBody := c.StrictMethods && !MethodAllowsBody(c.Method)
+
+	switch {
+	case stripBody:
+	case c.Multipart != nil:
+		body, contentType := c.Multipart.build()
+		setBody(req, body, c.Encoding)
+		req.Header.SetContentType(contentType)
+	default:
+		setBody(req, c.Body, c.Encoding)
+
+		if c.JSONBody {
+			req.Header.SetContentType(contentTypeJSON)
+		}
+	}
+
 	// Add random user agent and configured headers
 	req.Header.Set("user-agent", uarand.GetRandom())
 
+	for key, value := range c.HeaderSet {
+		req.Header.Set(key, value)
+	}
+
 	for key, value := range c.Headers {
 		req.Header.Set(key, value)
 	}
@@ -63,13 +119,100 @@ func InitRequest(c RequestConfig, req *fasthttp.Request) int64 {
 		req.Header.SetCookie(key, value)
 	}
 
+	if c.StrictMethods && !stripBody && c.Body != "" && len(req.Header.ContentType()) == 0 {
+		req.Header.SetContentType(detectContentType(c.Body))
+	}
+
+	if c.ConnectionClose {
+		req.SetConnectionClose()
+	}
+
+	// clients parse the uri before sending which makes the request line path-only and takes the host header from the uri,
+	// the request has to be serialized the same way to be measured right
+	req.URI()
+
+	if !c.Streaming || stripBody {
+		dataSize, _ := req.WriteTo(metrics.NopWriter{})
+
+		return dataSize
+	}
+
+	// writing the request would consume the stream so the size is measured with an empty stream (headers and the last chunk),
+	// the chunks only add their framing as the data is reported by the body itself
+	body := newChunkedBody(append([]byte(nil), req.Body()...), c)
+
+	req.SetBodyStream(eofReader{}, -1)
+
 	dataSize, _ := req.WriteTo(metrics.NopWriter{})
 
-	return dataSize
+	req.SetBodyStream(body, -1)
+
+	return dataSize + body.framingSize()
+}
+
+// MethodAllowsBody reports whether the request body has any meaning for the method (GET by default), see RFC 7231
+func MethodAllowsBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodTrace, fasthttp.MethodConnect:
+		return false
+	default:
+		return true
+	}
+}
+
+// CheckMethodBody returns an error if the request has a body its method isn't supposed to have,
+// servers handle such requests inconsistently: the body can be ignored, rejected, or taken for the next request
+func (c RequestConfig) CheckMethodBody() error {
+	if MethodAllowsBody(c.Method) || (c.Body == "" && c.Multipart == nil) {
+		return nil
+	}
+
+	method := c.Method
+	if method == "" {
+		method = fasthttp.MethodGet
+	}
+
+	return fmt.Errorf("%v request has a body", method)
+}
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeForm = "application/x-www-form-urlencoded"
+)
+
+// detectContentType guesses the content type of the body sent without one, fasthttp would send application/octet-stream
+func detectContentType(body string) string {
+	if trimmed := strings.TrimSpace(body); (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return contentTypeJSON
+	}
+
+	if strings.Contains(body, "=") && !strings.ContainsAny(body, " \t\r\n") {
+		if _, err := url.ParseQuery(body); err == nil {
+			return contentTypeForm
+		}
+	}
+
+	return nethttp.DetectContentType([]byte(body))
+}
+
+func setBody(req *fasthttp.Request, body, encoding string) {
+	switch encoding {
+	case EncodingGzip:
+		req.SetBodyRaw(fasthttp.AppendGzipBytes(nil, []byte(body)))
+	case EncodingDeflate:
+		req.SetBodyRaw(fasthttp.AppendDeflateBytes(nil, []byte(body)))
+	default:
+		req.SetBodyString(body)
+
+		return
+	}
+
+	req.Header.Set(fasthttp.HeaderContentEncoding, encoding)
 }
 
 type Client interface {
 	Do(req *fasthttp.Request, resp *fasthttp.Response) error
+	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
 }
 
 type StaticHostConfig struct {
@@ -79,18 +222,144 @@ type StaticHostConfig struct {
 
 // ClientConfig is a http client configuration structure
 type ClientConfig struct {
-	StaticHost      *StaticHostConfig `mapstructure:"static_host"`
-	TLSClientConfig *tls.Config       `mapstructure:"tls_config,omitempty"`
-	Timeout         *time.Duration    `mapstructure:"timeout"`
-	ReadTimeout     *time.Duration    `mapstructure:"read_timeout"`
-	WriteTimeout    *time.Duration    `mapstructure:"write_timeout"`
-	IdleTimeout     *time.Duration    `mapstructure:"idle_timeout"`
-	MaxIdleConns    *int              `mapstructure:"max_idle_connections"`
-	ProxyURLs       string            `mapstructure:"proxy_urls"`
-}
-
-// NewClient creates a fasthttp client based on the config.
-func NewClient(ctx context.Context, clientConfig ClientConfig, logger *zap.Logger) Client {
+	StaticHost           *StaticHostConfig       `mapstructure:"static_host"`
+	TLSClientConfig      *tls.Config             `mapstructure:"tls_config,omitempty"`
+	TLS                  *TLSConfig              `mapstructure:"tls"` // takes precedence over TLSClientConfig
+	Timeout              *time.Duration          `mapstructure:"timeout"`
+	ReadTimeout          *time.Duration          `mapstructure:"read_timeout"`
+	WriteTimeout         *time.Duration          `mapstructure:"write_timeout"`
+	DialTimeout          *time.Duration          `mapstructure:"dial_timeout"`          // of connecting to the target or to the proxy, Timeout by default
+	TLSHandshakeTimeout  *time.Duration          `mapstructure:"tls_handshake_timeout"` // WriteTimeout by default as fasthttp uses it for handshakes
+	ResponseTimeout      *time.Duration          `mapstructure:"response_timeout"`      // of reading the response once the request is sent, takes precedence over ReadTimeout
+	IdleTimeout          *time.Duration          `mapstructure:"idle_timeout"`
+	MaxIdleConns         *int                    `mapstructure:"max_idle_connections"` // deprecated, same as MaxConnsPerHost
+	MaxConnsPerHost      *int                    `mapstructure:"max_connections_per_host"`
+	MaxIdleConnDuration  *time.Duration          `mapstructure:"max_idle_connection_duration"` // same as IdleTimeout, takes precedence over it
+	DisableKeepAlive     bool                    `mapstructure:"disable_keep_alive"`           // sends "Connection: close" with each request
+	ForceFreshConnection bool                    `mapstructure:"force_fresh_connection"`       // dials a new connection for every request, see freshConnectionClient
+	ProxyURLs            string                  `mapstructure:"proxy_urls"`
+	Protocol             string                  `mapstructure:"protocol"` // "h1" (default), "h2", or "h2c"
+	FollowRedirects      bool                    `mapstructure:"follow_redirects"`
+	MaxRedirects         *int                    `mapstructure:"max_redirects"`
+	ProxySelection       string                  `mapstructure:"proxy_selection"`    // picks a proxy per request when set, see utils.NewProxySelector
+	ProxyHealthCheck     *ProxyHealthCheckConfig `mapstructure:"proxy_health_check"` // only applies together with ProxySelection
+	MaxResponseSize      int                     `mapstructure:"max_response_size"`  // responses with larger bodies fail with fasthttp.ErrBodyTooLarge, 0 means no limit
+	LocalAddr            string                  `mapstructure:"local_addr"`         // source ip (or ip:port) of outgoing connections, see utils.ResolveLocalAddr
+	Resolver             *utils.ResolverConfig   `mapstructure:"resolver"`           // overrides the system resolver for target hosts
+	Pipeline             *PipelineConfig         `mapstructure:"pipeline"`           // sends HTTP/1.1 requests without waiting for the previous responses
+
+	Shared bool `mapstructure:"shared"` // share the client with the other jobs with the same config, see AcquireClient
+
+	IPVersion string `mapstructure:"ip_version"` // "auto" (default), "ipv4" or "ipv6", see dialNetwork
+}
+
+// Supported values for ClientConfig.Protocol
+const (
+	ProtocolHTTP1 = "h1"
+	ProtocolHTTP2 = "h2"
+	ProtocolH2C   = "h2c"
+)
+
+// Supported values for ClientConfig.IPVersion
+const (
+	IPVersionAuto = "auto"
+	IPVersionIPv4 = "ipv4"
+	IPVersionIPv6 = "ipv6"
+)
+
+// dialNetwork returns the network the targets are dialed with. Both ip versions are dialed Happy Eyeballs style
+// with "tcp": by net.Dialer for direct connections and by utils.Resolver when it's configured. Proxies resolve
+// the targets themselves so the version can only be enforced for them with a resolver
+func dialNetwork(ipVersion string, viaProxy bool) (string, error) {
+	switch ipVersion {
+	case "", IPVersionAuto:
+		return "tcp", nil
+	case IPVersionIPv4, IPVersionIPv6:
+		if viaProxy {
+			return "tcp", nil
+		}
+
+		return "tcp" + ipVersion[len(ipVersion)-1:], nil
+	default:
+		return "", fmt.Errorf("unsupported ip version %q, expected one of [%q, %q, %q]", ipVersion, IPVersionAuto, IPVersionIPv4, IPVersionIPv6)
+	}
+}
+
+// NewClient creates a fasthttp client based on the config (or an http2 capable one when requested).
+func NewClient(ctx context.Context, clientConfig ClientConfig, logger *zap.Logger) (Client, error) {
+	client, err := newProxySelectionClient(ctx, clientConfig, logger)
+	if err != nil || !clientConfig.FollowRedirects {
+		return client, err
+	}
+
+	const defaultMaxRedirects = 10
+
+	trafficMonitor := metrics.Default.NewWriter(metrics.Traffic, uuid.NewString())
+	go trafficMonitor.Update(ctx, time.Second)
+
+	return &redirectClient{
+		Client:       client,
+		maxRedirects: utils.NonNilIntOrDefault(clientConfig.MaxRedirects, defaultMaxRedirects),
+		onHop:        func(size int64) { trafficMonitor.Add(uint64(size)) },
+	}, nil
+}
+
+func newProxySelectionClient(ctx context.Context, clientConfig ClientConfig, logger *zap.Logger) (Client, error) {
+	if clientConfig.ProxySelection == "" {
+		return newClient(ctx, clientConfig, logger)
+	}
+
+	// clients are created lazily for every proxy so the local address, the ip version and the resolver have to be validated upfront
+	if _, err := utils.ResolveLocalAddr("tcp", clientConfig.LocalAddr); err != nil {
+		return nil, err
+	}
+
+	if _, err := dialNetwork(clientConfig.IPVersion, false); err != nil {
+		return nil, err
+	}
+
+	if _, err := utils.NewResolver(clientConfig.Resolver); err != nil {
+		return nil, fmt.Errorf("error parsing resolver config: %w", err)
+	}
+
+	proxyURLs := templates.ParseAndExecute(logger, clientConfig.ProxyURLs, ctx)
+
+	selector, err := utils.NewProxySelector(proxyURLs, clientConfig.ProxySelection)
+	if err != nil {
+		return nil, err
+	}
+
+	if clientConfig.ProxyHealthCheck != nil {
+		// the list has already been validated by the selector
+		proxies, _, _ := utils.ParseProxyList(proxyURLs)
+		selector = startProxyHealthCheck(ctx, logger, selector, proxies, *clientConfig.ProxyHealthCheck)
+	}
+
+	return newProxyPoolClient(selector, func(proxyURL string) (Client, error) {
+		proxyConfig := clientConfig
+		proxyConfig.ProxyURLs = proxyURL
+
+		return newClient(ctx, proxyConfig, logger)
+	}), nil
+}
+
+// newClient picks a single proxy for the whole client so that its requests can be attributed to the proxy
+func newClient(ctx context.Context, clientConfig ClientConfig, logger *zap.Logger) (Client, error) {
+	proxyURL := utils.PickProxy(templates.ParseAndExecute(logger, clientConfig.ProxyURLs, ctx))
+
+	client, err := newClientVia(clientConfig, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxy := utils.ProxyName(proxyURL); proxy != "" {
+		return proxyClient{Client: client, proxy: proxy}, nil
+	}
+
+	return client, nil
+}
+
+func newClientVia(clientConfig ClientConfig, proxyURL string) (Client, error) {
 	const (
 		defaultMaxConnsPerHost = 1000
 		defaultTimeout         = 90 * time.Second
@@ -105,34 +374,261 @@ func NewClient(ctx context.Context, clientConfig ClientConfig, logger *zap.Logge
 		tlsConfig = clientConfig.TLSClientConfig
 	}
 
+	if clientConfig.TLS != nil {
+		var err error
+		if tlsConfig, err = clientConfig.TLS.Build(); err != nil {
+			return nil, fmt.Errorf("error parsing tls config: %w", err)
+		}
+	}
+
+	localAddr, err := utils.ResolveLocalAddr("tcp", clientConfig.LocalAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := utils.NewResolver(clientConfig.Resolver)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing resolver config: %w", err)
+	}
+
+	network, err := dialNetwork(clientConfig.IPVersion, proxyURL != "" && resolver == nil)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyFunc := resolver.Dial(utils.GetProxyFuncFrom(proxyURL, utils.NonNilDurationOrDefault(clientConfig.DialTimeout, timeout), localAddr))
+	if network != "tcp" {
+		dial := proxyFunc
+		proxyFunc = func(_, addr string) (net.Conn, error) { return dial(network, addr) }
+	}
+
+	readTimeout := utils.NonNilDurationOrDefault(clientConfig.ResponseTimeout, utils.NonNilDurationOrDefault(clientConfig.ReadTimeout, timeout))
+	writeTimeout := utils.NonNilDurationOrDefault(clientConfig.WriteTimeout, timeout)
+	maxConnsPerHost := utils.NonNilIntOrDefault(clientConfig.MaxConnsPerHost, utils.NonNilIntOrDefault(clientConfig.MaxIdleConns, defaultMaxConnsPerHost))
+	maxIdleConnDuration := utils.NonNilDurationOrDefault(clientConfig.MaxIdleConnDuration, utils.NonNilDurationOrDefault(clientConfig.IdleTimeout, timeout))
+
+	if err := checkPipelineConfig(clientConfig); err != nil {
+		return nil, err
+	}
+
+	switch clientConfig.Protocol {
+	case "", ProtocolHTTP1:
+	case ProtocolHTTP2, ProtocolH2C:
+		return newHTTP2Client(clientConfig.Protocol == ProtocolH2C, tlsConfig, timeout, utils.NonNilDurationOrDefault(clientConfig.TLSHandshakeTimeout, 0),
+			clientConfig.MaxResponseSize, proxyFunc), nil
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q, expected one of [%q, %q, %q]", clientConfig.Protocol,
+			ProtocolHTTP1, ProtocolHTTP2, ProtocolH2C)
+	}
+
+	var (
+		tracker     = &connectionTracker{}
+		addr        = requestAddr
+		dialAddr    string // address the dials are tracked for, the one being dialed if empty
+		newFastHTTP func() fastHTTPClient
+	)
+
 	if clientConfig.StaticHost != nil {
-		return &fasthttp.HostClient{
-			Addr:                          clientConfig.StaticHost.Addr,
-			IsTLS:                         clientConfig.StaticHost.IsTLS,
-			MaxConnDuration:               timeout,
-			ReadTimeout:                   utils.NonNilDurationOrDefault(clientConfig.ReadTimeout, timeout),
-			WriteTimeout:                  utils.NonNilDurationOrDefault(clientConfig.WriteTimeout, timeout),
-			MaxIdleConnDuration:           utils.NonNilDurationOrDefault(clientConfig.IdleTimeout, timeout),
-			MaxConns:                      utils.NonNilIntOrDefault(clientConfig.MaxIdleConns, defaultMaxConnsPerHost),
-			NoDefaultUserAgentHeader:      true, // Don't send: User-Agent: fasthttp
-			DisableHeaderNamesNormalizing: true, // If you set the case on your headers correctly you can enable this
-			DisablePathNormalizing:        true,
-			TLSConfig:                     tlsConfig,
-			Dial:                          dialViaProxyFunc(utils.GetProxyFunc(templates.ParseAndExecute(logger, clientConfig.ProxyURLs, ctx), timeout), "tcp"),
+		addr = func(*fasthttp.Request) string { return clientConfig.StaticHost.Addr }
+		dialAddr = clientConfig.StaticHost.Addr
+	}
+
+	dial := dialViaProxyFunc(proxyFunc, "tcp")
+	if clientConfig.ForceFreshConnection {
+		dial = countFreshHandshakes(dial, dialAddr)
+	}
+
+	dial = tracker.dial(dial, dialAddr)
+
+	// host clients get a dial doing the handshake themselves so that it's limited by the handshake timeout
+	configureClient := func(hc *fasthttp.HostClient) error {
+		if hc.IsTLS && clientConfig.TLSHandshakeTimeout != nil {
+			hc.Dial = handshakeDial(hc.Dial, tlsConfig, hc.Addr, *clientConfig.TLSHandshakeTimeout)
 		}
+
+		return nil
+	}
+
+	if clientConfig.StaticHost != nil {
+		newFastHTTP = func() fastHTTPClient {
+			hc := &fasthttp.HostClient{
+				Addr:                          clientConfig.StaticHost.Addr,
+				IsTLS:                         clientConfig.StaticHost.IsTLS,
+				MaxConnDuration:               timeout,
+				ReadTimeout:                   readTimeout,
+				WriteTimeout:                  writeTimeout,
+				MaxIdleConnDuration:           maxIdleConnDuration,
+				MaxConns:                      maxConnsPerHost,
+				MaxResponseBodySize:           clientConfig.MaxResponseSize,
+				NoDefaultUserAgentHeader:      true, // Don't send: User-Agent: fasthttp
+				DisableHeaderNamesNormalizing: true, // If you set the case on your headers correctly you can enable this
+				DisablePathNormalizing:        true,
+				TLSConfig:                     tlsConfig,
+				Dial:                          dial,
+			}
+			_ = configureClient(hc)
+
+			return hc
+		}
+	} else {
+		newFastHTTP = func() fastHTTPClient {
+			return &fasthttp.Client{
+				MaxConnDuration:               timeout,
+				ReadTimeout:                   readTimeout,
+				WriteTimeout:                  writeTimeout,
+				MaxIdleConnDuration:           maxIdleConnDuration,
+				MaxConnsPerHost:               maxConnsPerHost,
+				MaxResponseBodySize:           clientConfig.MaxResponseSize,
+				NoDefaultUserAgentHeader:      true, // Don't send: User-Agent: fasthttp
+				DisableHeaderNamesNormalizing: true, // If you set the case on your headers correctly you can enable this
+				DisablePathNormalizing:        true,
+				TLSConfig:                     tlsConfig,
+				Dial:                          dial,
+				ConfigureClient:               configureClient,
+			}
+		}
+	}
+
+	var client Client
+
+	switch {
+	case clientConfig.Pipeline != nil:
+		client = newPipelineClient(clientConfig.Pipeline, clientConfig.StaticHost, tlsConfig, readTimeout, writeTimeout, maxIdleConnDuration, dial)
+	case clientConfig.ForceFreshConnection:
+		client = freshConnectionClient{newClient: newFastHTTP}
+	default:
+		client = newFastHTTP()
+	}
+
+	client = connectionTrackingClient{Client: client, tracker: tracker, addr: addr}
+
+	if clientConfig.DisableKeepAlive {
+		return connectionCloseClient{Client: client}, nil
+	}
+
+	return client, nil
+}
+
+// ClosesConnections reports whether the clients of the config close the connection after every request
+func (c ClientConfig) ClosesConnections() bool {
+	return c.DisableKeepAlive || c.ForceFreshConnection
+}
+
+// CheckRequest returns an error if the clients of the config can't send the request as configured.
+// http2 clients read the body in one go so a streamed body would be sent without being accounted
+func (c ClientConfig) CheckRequest(req RequestConfig) error {
+	if req.Streaming && (c.Protocol == ProtocolHTTP2 || c.Protocol == ProtocolH2C) {
+		return fmt.Errorf("request streaming is not supported with %q protocol", c.Protocol)
 	}
 
-	return &fasthttp.Client{
-		MaxConnDuration:               timeout,
-		ReadTimeout:                   utils.NonNilDurationOrDefault(clientConfig.ReadTimeout, timeout),
-		WriteTimeout:                  utils.NonNilDurationOrDefault(clientConfig.WriteTimeout, timeout),
-		MaxIdleConnDuration:           utils.NonNilDurationOrDefault(clientConfig.IdleTimeout, timeout),
-		MaxConnsPerHost:               utils.NonNilIntOrDefault(clientConfig.MaxIdleConns, defaultMaxConnsPerHost),
-		NoDefaultUserAgentHeader:      true, // Don't send: User-Agent: fasthttp
-		DisableHeaderNamesNormalizing: true, // If you set the case on your headers correctly you can enable this
-		DisablePathNormalizing:        true,
-		TLSConfig:                     tlsConfig,
-		Dial:                          dialViaProxyFunc(utils.GetProxyFunc(templates.ParseAndExecute(logger, clientConfig.ProxyURLs, ctx), timeout), "tcp"),
+	return nil
+}
+
+// connectionCloseClient asks the server to close the connection after each request so that every request needs a new handshake
+type connectionCloseClient struct {
+	Client
+}
+
+func (c connectionCloseClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	req.SetConnectionClose()
+
+	return c.Client.Do(req, resp)
+}
+
+func (c connectionCloseClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	req.SetConnectionClose()
+
+	return c.Client.DoTimeout(req, resp, timeout)
+}
+
+// fastHTTPClient is implemented by both fasthttp.Client and fasthttp.HostClient
+type fastHTTPClient interface {
+	Client
+	CloseIdleConnections()
+}
+
+// freshConnectionClient sends every request with a new client so that no connection can ever be taken from a pool,
+// unlike connectionCloseClient it doesn't rely on the server to close the connection. Every request pays for
+// a new tcp (and tls) handshake which costs a lot of throughput on both sides
+type freshConnectionClient struct {
+	newClient func() fastHTTPClient
+}
+
+func (c freshConnectionClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	client := c.newClient()
+	defer client.CloseIdleConnections()
+
+	req.SetConnectionClose()
+
+	return client.Do(req, resp)
+}
+
+func (c freshConnectionClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	client := c.newClient()
+	defer client.CloseIdleConnections()
+
+	req.SetConnectionClose()
+
+	return client.DoTimeout(req, resp, timeout)
+}
+
+// countFreshHandshakes wraps the dial func to count successful dials, key overrides the address they are counted for when not empty
+func countFreshHandshakes(dial fasthttp.DialFunc, key string) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err == nil {
+			if key != "" {
+				addr = key
+			}
+
+			metrics.IncHTTPFreshHandshake(addr)
+		}
+
+		return conn, err
+	}
+}
+
+// handshakeDial returns connections that have already completed the tls handshake within the timeout,
+// fasthttp doesn't handshake *tls.Conn again. The config is prepared the same way fasthttp prepares it for the host
+func handshakeDial(dial fasthttp.DialFunc, tlsConfig *tls.Config, addr string, timeout time.Duration) fasthttp.DialFunc {
+	if dial == nil {
+		dial = fasthttp.Dial
+	}
+
+	cfg := tlsConfig.Clone()
+	if cfg.ClientSessionCache == nil {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	if cfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			cfg.ServerName = host
+		} else {
+			cfg.ServerName = addr
+		}
+	}
+
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+
+			if ctx.Err() != nil {
+				return nil, fasthttp.ErrTLSHandshakeTimeout
+			}
+
+			return nil, err
+		}
+
+		return tlsConn, nil
 	}
 }
 
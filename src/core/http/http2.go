@@ -0,0 +1,163 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	nethttp "net/http"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+
+	"github.com/Arriven/db1000n/src/utils"
+)
+
+// http2Client bridges fasthttp requests to the net/http based http2 transport as fasthttp only speaks http/1.1
+type http2Client struct {
+	client      *nethttp.Client
+	maxBodySize int
+}
+
+// handshakeTimeout limits tls handshakes on its own when positive, they are only limited by the timeout of the request otherwise
+func newHTTP2Client(h2c bool, tlsConfig *tls.Config, timeout, handshakeTimeout time.Duration, maxBodySize int, proxyFunc utils.ProxyFunc) *http2Client {
+	return &http2Client{maxBodySize: maxBodySize, client: &nethttp.Client{
+		Timeout: timeout,
+		// redirects are not followed by default to behave the same way as fasthttp clients
+		CheckRedirect: func(*nethttp.Request, []*nethttp.Request) error { return nethttp.ErrUseLastResponse },
+		Transport: &http2.Transport{
+			TLSClientConfig: tlsConfig,
+			AllowHTTP:       h2c,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				conn, err := proxyFunc(network, addr)
+				if err != nil || h2c {
+					return conn, err
+				}
+
+				ctx := context.Background()
+				if handshakeTimeout > 0 {
+					var cancel context.CancelFunc
+
+					ctx, cancel = context.WithTimeout(ctx, handshakeTimeout)
+					defer cancel()
+				}
+
+				tlsConn := tls.Client(conn, cfg)
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					conn.Close()
+
+					return nil, err
+				}
+
+				return tlsConn, nil
+			},
+		},
+	}}
+}
+
+// connection-specific headers are forbidden in http2 and are rejected by the transport
+var http2SkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+func (c *http2Client) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	return c.do(context.Background(), req, resp)
+}
+
+func (c *http2Client) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.do(ctx, req, resp)
+}
+
+// do sends the request converted to net/http. The jobs still account it with its HTTP/1.1 size from InitRequest,
+// which is only an estimate for http2 as the headers are HPACK compressed and framed on the wire. Streamed bodies
+// are rejected with ClientConfig.CheckRequest since the body is read here in one go without OnBodyWrite reporting it
+func (c *http2Client) do(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+	httpReq, err := nethttp.NewRequestWithContext(ctx, string(req.Header.Method()), req.URI().String(), bytes.NewReader(req.Body()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.VisitAll(func(key, value []byte) {
+		if k := nethttp.CanonicalHeaderKey(string(key)); !http2SkippedHeaders[k] {
+			httpReq.Header.Add(k, string(value))
+		}
+	})
+
+	if host := req.Header.Host(); len(host) > 0 {
+		httpReq.Host = string(host)
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+
+	defer httpResp.Body.Close()
+
+	if resp == nil {
+		// the body still has to be consumed in order to reuse the stream
+		_, err = io.Copy(io.Discard, httpResp.Body)
+
+		return err
+	}
+
+	resp.Reset()
+	resp.SetStatusCode(httpResp.StatusCode)
+
+	for key, values := range httpResp.Header {
+		for _, value := range values {
+			resp.Header.Add(key, value)
+		}
+	}
+
+	if c.maxBodySize <= 0 {
+		_, err = io.Copy(resp.BodyWriter(), httpResp.Body)
+
+		return err
+	}
+
+	// keep the first maxBodySize bytes so that the caller can still inspect them
+	n, err := io.Copy(resp.BodyWriter(), io.LimitReader(httpResp.Body, int64(c.maxBodySize)))
+	if err != nil || n < int64(c.maxBodySize) {
+		return err
+	}
+
+	if extra, _ := httpResp.Body.Read(make([]byte, 1)); extra > 0 {
+		return fasthttp.ErrBodyTooLarge
+	}
+
+	return nil
+}
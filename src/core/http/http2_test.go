@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func protoHandler(t *testing.T) nethttp.Handler {
+	t.Helper()
+
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.WriteHeader(nethttp.StatusAccepted)
+		_, _ = w.Write([]byte(r.Header.Get("X-Test")))
+	})
+}
+
+func TestHTTP2Client(t *testing.T) {
+	t.Parallel()
+
+	tlsServer := httptest.NewUnstartedServer(protoHandler(t))
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+
+	plainServer := httptest.NewServer(h2c.NewHandler(protoHandler(t), &http2.Server{}))
+	t.Cleanup(plainServer.Close)
+
+	testCases := []struct {
+		name     string
+		protocol string
+		url      string
+	}{
+		{name: "h2", protocol: ProtocolHTTP2, url: tlsServer.URL},
+		{name: "h2c", protocol: ProtocolH2C, url: plainServer.URL},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewClient(context.Background(), ClientConfig{Protocol: tc.protocol}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			InitRequest(RequestConfig{Path: tc.url, Method: "GET", Headers: map[string]string{"X-Test": "value"}}, req)
+
+			if err := client.Do(req, resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if proto := string(resp.Header.Peek("X-Proto")); proto != "HTTP/2.0" {
+				t.Errorf("expected request to be received over HTTP/2.0, got %q", proto)
+			}
+
+			if resp.StatusCode() != nethttp.StatusAccepted || string(resp.Body()) != "value" {
+				t.Errorf("unexpected response: %d %q", resp.StatusCode(), resp.Body())
+			}
+		})
+	}
+}
+
+func TestUnsupportedProtocol(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewClient(context.Background(), ClientConfig{Protocol: "spdy"}, zap.NewNop()); err == nil {
+		t.Error("expected an error for unsupported protocol")
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(h2c.NewHandler(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 1000)))
+	}), &http2.Server{}))
+	t.Cleanup(server.Close)
+
+	testCases := []struct {
+		name     string
+		protocol string
+		wantBody int
+	}{
+		{name: "h1", protocol: ProtocolHTTP1},
+		{name: "h2c", protocol: ProtocolH2C, wantBody: 100},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewClient(context.Background(), ClientConfig{Protocol: tc.protocol, MaxResponseSize: 100}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			InitRequest(RequestConfig{Path: server.URL, Method: "GET"}, req)
+
+			if err := client.Do(req, resp); !errors.Is(err, fasthttp.ErrBodyTooLarge) {
+				t.Fatalf("expected body too large error, got %v", err)
+			}
+
+			if len(resp.Body()) != tc.wantBody {
+				t.Errorf("expected %d bytes of body to be kept, got %d", tc.wantBody, len(resp.Body()))
+			}
+		})
+	}
+}
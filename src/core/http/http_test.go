@@ -0,0 +1,1654 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+func TestRequestEncoding(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("some highly compressible payload ", 100)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		var (
+			reader io.Reader = r.Body
+			err    error
+		)
+
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			reader, err = gzip.NewReader(r.Body)
+		case "deflate":
+			reader, err = zlib.NewReader(r.Body)
+		}
+
+		if err != nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+
+			return
+		}
+
+		received, err := io.ReadAll(reader)
+		if err != nil || string(received) != body {
+			w.WriteHeader(nethttp.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("X-Content-Encoding", r.Header.Get("Content-Encoding"))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(context.Background(), ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plainReq := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(plainReq)
+
+	plainSize := InitRequest(RequestConfig{Path: server.URL, Method: "POST", Body: body}, plainReq)
+
+	for _, encoding := range []string{"", EncodingGzip, EncodingDeflate} {
+		encoding := encoding
+
+		t.Run("encoding "+encoding, func(t *testing.T) {
+			t.Parallel()
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			dataSize := InitRequest(RequestConfig{Path: server.URL, Method: "POST", Body: body, Encoding: encoding}, req)
+			if encoding != "" && dataSize >= plainSize {
+				t.Errorf("expected compressed request size %d to be less than %d", dataSize, plainSize)
+			}
+
+			if err := client.Do(req, resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode() != nethttp.StatusOK {
+				t.Fatalf("server failed to decode the body, status %d", resp.StatusCode())
+			}
+
+			if got := string(resp.Header.Peek("X-Content-Encoding")); got != encoding {
+				t.Errorf("expected content encoding %q, got %q", encoding, got)
+			}
+		})
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	t.Parallel()
+
+	mux := nethttp.NewServeMux()
+	mux.Handle("/a", nethttp.RedirectHandler("/b", nethttp.StatusMovedPermanently))
+	mux.Handle("/b", nethttp.RedirectHandler("/c", nethttp.StatusFound))
+	mux.HandleFunc("/c", func(w nethttp.ResponseWriter, r *nethttp.Request) { _, _ = w.Write([]byte("target")) })
+	mux.Handle("/loop", nethttp.RedirectHandler("/loop", nethttp.StatusFound))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	baseClient, err := NewClient(context.Background(), ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		path       string
+		follow     bool
+		wantStatus int
+		wantHops   int
+		wantErr    error
+	}{
+		{name: "disabled", path: "/a", wantStatus: nethttp.StatusMovedPermanently},
+		{name: "chain", path: "/a", follow: true, wantStatus: nethttp.StatusOK, wantHops: 2},
+		{name: "loop", path: "/loop", follow: true, wantHops: 3, wantErr: fasthttp.ErrTooManyRedirects},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var hops int
+
+			client := baseClient
+			if tc.follow {
+				client = &redirectClient{Client: baseClient, maxRedirects: 3, onHop: func(size int64) {
+					if size <= 0 {
+						t.Errorf("expected hop size to be positive, got %d", size)
+					}
+
+					hops++
+				}}
+			}
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			InitRequest(RequestConfig{Path: server.URL + tc.path, Method: "GET"}, req)
+
+			if err := client.Do(req, resp); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+
+			if tc.wantErr == nil && resp.StatusCode() != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, resp.StatusCode())
+			}
+
+			if hops != tc.wantHops {
+				t.Errorf("expected %d redirect hops, got %d", tc.wantHops, hops)
+			}
+
+			if path := string(req.URI().Path()); path != tc.path {
+				t.Errorf("original request should not be modified, got path %q", path)
+			}
+		})
+	}
+
+	// the hops go to the caller instead of the traffic writer of the client when it asks for them
+	client, err := NewClient(context.Background(), ClientConfig{FollowRedirects: true}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	InitRequest(RequestConfig{Path: server.URL + "/a", Method: "GET"}, req)
+
+	var redirects []int64
+
+	client.(*redirectClient).onHop = func(int64) { t.Error("expected the hops not to be reported to the client") }
+
+	if _, err := SendReportingRedirects(client, req, resp, nil, func(size int64) { redirects = append(redirects, size) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(redirects) != 2 || resp.StatusCode() != nethttp.StatusOK {
+		t.Errorf("expected 2 redirects to be reported, got %v (status %d)", redirects, resp.StatusCode())
+	}
+}
+
+type stubClient struct {
+	proxyURL string
+	requests *[]string
+}
+
+func (c stubClient) Do(*fasthttp.Request, *fasthttp.Response) error {
+	*c.requests = append(*c.requests, c.proxyURL)
+
+	return nil
+}
+
+func (c stubClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, _ time.Duration) error {
+	return c.Do(req, resp)
+}
+
+func TestProxyPoolClient(t *testing.T) {
+	t.Parallel()
+
+	selector, err := utils.NewProxySelector("http://p1,http://p2", utils.ProxySelectionRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created, requests []string
+
+	client := newProxyPoolClient(selector, func(proxyURL string) (Client, error) {
+		created = append(created, proxyURL)
+
+		return stubClient{proxyURL: proxyURL, requests: &requests}, nil
+	})
+
+	for i := 0; i < 4; i++ {
+		if err := client.Do(nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := strings.Join(requests, ","); got != "http://p1,http://p2,http://p1,http://p2" {
+		t.Errorf("expected proxy to be selected for every request, got %v", got)
+	}
+
+	if len(created) != 2 {
+		t.Errorf("expected a single client per proxy, got %v", created)
+	}
+}
+
+func TestSendReportsProxy(t *testing.T) {
+	t.Parallel()
+
+	selector, err := utils.NewProxySelector("http://user:pass@p1:8080,http://p2:8080", utils.ProxySelectionRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+
+	pool := newProxyPoolClient(selector, func(proxyURL string) (Client, error) {
+		return proxyClient{Client: stubClient{proxyURL: proxyURL, requests: &requests}, proxy: utils.ProxyName(proxyURL)}, nil
+	})
+
+	timeout := time.Second
+
+	for _, want := range []string{"p1:8080", "p2:8080", "p1:8080"} {
+		proxy, err := Send(pool, nil, nil, &timeout)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if proxy != want {
+			t.Errorf("expected the request to be attributed to %v, got %q", want, proxy)
+		}
+	}
+
+	if proxy, err := Send(stubClient{requests: &requests}, nil, nil, nil); err != nil || proxy != "" {
+		t.Errorf("expected direct requests not to report a proxy, got %q (%v)", proxy, err)
+	}
+
+	client, err := NewClient(context.Background(), ClientConfig{ProxyURLs: "socks5://127.0.0.1:1080", FollowRedirects: true}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := client.(ProxiedClient); !ok {
+		t.Error("expected clients with a proxy to report it")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(r.TLS.ServerName))
+	}))
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12}
+
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	verify := false
+
+	testCases := []struct {
+		name           string
+		config         *TLSConfig
+		wantServerName string
+		wantConfigErr  bool
+		wantErr        bool
+	}{
+		{name: "default"},
+		{name: "max version", config: &TLSConfig{MaxVersion: "1.2"}},
+		{name: "min version", config: &TLSConfig{MinVersion: "1.3"}, wantErr: true},
+		{name: "server name", config: &TLSConfig{ServerName: "example.com"}, wantServerName: "example.com"},
+		{name: "verify", config: &TLSConfig{InsecureSkipVerify: &verify}, wantErr: true},
+		{name: "unknown version", config: &TLSConfig{MinVersion: "2.0"}, wantConfigErr: true},
+		{name: "unknown cipher suite", config: &TLSConfig{CipherSuites: []string{"TLS_NOPE"}}, wantConfigErr: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewClient(context.Background(), ClientConfig{TLS: tc.config}, zap.NewNop())
+			if (err != nil) != tc.wantConfigErr {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+
+			if tc.wantConfigErr {
+				return
+			}
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			InitRequest(RequestConfig{Path: server.URL, Method: "GET"}, req)
+
+			if err := client.Do(req, resp); (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected request error: %v", err)
+			}
+
+			if !tc.wantErr && string(resp.Body()) != tc.wantServerName {
+				t.Errorf("expected server name %q, got %q", tc.wantServerName, resp.Body())
+			}
+		})
+	}
+}
+
+// newTestCertificate issues a certificate signed by the parent, the certificate is self-signed if the parent is nil
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *tls.Certificate) (cert tls.Certificate, certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	if cert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, certPEM, keyPEM
+}
+
+func TestClientCertificate(t *testing.T) { //nolint:paralleltest // Modifies the global files directory
+	validity := time.Hour
+
+	ca, _, _ := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-validity),
+		NotAfter:              time.Now().Add(validity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+
+	_, certPEM, keyPEM := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-validity),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	_, _, otherKeyPEM := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		NotBefore:    time.Now().Add(-validity),
+		NotAfter:     time.Now().Add(validity),
+	}, &ca)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Leaf)
+
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs, MinVersion: tls.VersionTLS12}
+
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	filesDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(filesDir, "client.crt"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(filesDir, "client.key"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates.SetFilesDir(filesDir)
+	defer templates.SetFilesDir("")
+
+	testCases := []struct {
+		name          string
+		config        *TLSConfig
+		wantConfigErr bool
+		wantErr       bool
+	}{
+		{name: "inline", config: &TLSConfig{ClientCert: string(certPEM), ClientKey: string(keyPEM)}},
+		{name: "files", config: &TLSConfig{ClientCert: "client.crt", ClientKey: "client.key"}},
+		{name: "no certificate", config: &TLSConfig{}, wantErr: true},
+		{name: "missing key", config: &TLSConfig{ClientCert: string(certPEM)}, wantConfigErr: true},
+		{name: "mismatched key", config: &TLSConfig{ClientCert: string(certPEM), ClientKey: string(otherKeyPEM)}, wantConfigErr: true},
+		{name: "missing file", config: &TLSConfig{ClientCert: "missing.crt", ClientKey: "client.key"}, wantConfigErr: true},
+	}
+
+	for _, tc := range testCases {
+		client, err := NewClient(context.Background(), ClientConfig{TLS: tc.config}, zap.NewNop())
+		if (err != nil) != tc.wantConfigErr {
+			t.Fatalf("%v: unexpected client error: %v", tc.name, err)
+		}
+
+		if tc.wantConfigErr {
+			continue
+		}
+
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+
+		InitRequest(RequestConfig{Path: server.URL, Method: "GET"}, req)
+
+		err = client.Do(req, resp)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%v: unexpected request error: %v", tc.name, err)
+		}
+
+		if err == nil && string(resp.Body()) != "test client" {
+			t.Errorf("%v: expected the server to see the client certificate, got %q", tc.name, resp.Body())
+		}
+
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+}
+
+// connCountingServer starts a server that tracks the total and peak amount of open connections
+func connCountingServer(t *testing.T, handler nethttp.HandlerFunc) (server *httptest.Server, total, peak *int64) {
+	t.Helper()
+
+	var open int64
+
+	total, peak = new(int64), new(int64)
+	server = httptest.NewUnstartedServer(handler)
+	server.Config.ConnState = func(_ net.Conn, state nethttp.ConnState) {
+		switch state {
+		case nethttp.StateNew:
+			atomic.AddInt64(total, 1)
+
+			current := atomic.AddInt64(&open, 1)
+			for {
+				if max := atomic.LoadInt64(peak); current <= max || atomic.CompareAndSwapInt64(peak, max, current) {
+					break
+				}
+			}
+		case nethttp.StateClosed, nethttp.StateHijacked:
+			atomic.AddInt64(&open, -1)
+		case nethttp.StateActive, nethttp.StateIdle:
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return server, total, peak
+}
+
+func TestMaxConnsPerHost(t *testing.T) {
+	t.Parallel()
+
+	server, _, peak := connCountingServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	maxConns := 2
+
+	client, err := NewClient(context.Background(), ClientConfig{MaxConnsPerHost: &maxConns}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		succeeded int64
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			req.SetRequestURI(server.URL)
+
+			switch err := client.Do(req, resp); {
+			case err == nil:
+				atomic.AddInt64(&succeeded, 1)
+			case !errors.Is(err, fasthttp.ErrNoFreeConns):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := atomic.LoadInt64(peak); got > int64(maxConns) {
+		t.Errorf("expected at most %d concurrent connections, got %d", maxConns, got)
+	}
+
+	if atomic.LoadInt64(&succeeded) == 0 {
+		t.Error("expected some requests to succeed")
+	}
+}
+
+func TestDisableKeepAlive(t *testing.T) {
+	t.Parallel()
+
+	const requests = 3
+
+	testCases := []struct {
+		name             string
+		disableKeepAlive bool
+		expectedConns    int64
+	}{
+		{name: "keep-alive", expectedConns: 1},
+		{name: "connection close", disableKeepAlive: true, expectedConns: requests},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, total, _ := connCountingServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {})
+
+			client, err := NewClient(context.Background(), ClientConfig{DisableKeepAlive: tc.disableKeepAlive}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < requests; i++ {
+				req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+				req.SetRequestURI(server.URL)
+
+				if err := client.Do(req, resp); err != nil {
+					t.Fatal(err)
+				}
+
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(resp)
+			}
+
+			if got := atomic.LoadInt64(total); got != tc.expectedConns {
+				t.Errorf("expected %d connections, got %d", tc.expectedConns, got)
+			}
+		})
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	t.Parallel()
+
+	const requests = 20
+
+	testCases := []struct {
+		name        string
+		config      func(addr string) ClientConfig
+		expectedNew func(uint64) bool
+	}{
+		{
+			name:        "keep-alive",
+			config:      func(string) ClientConfig { return ClientConfig{} },
+			expectedNew: func(n uint64) bool { return n >= 1 && n <= 2 },
+		},
+		{
+			name:        "static host",
+			config:      func(addr string) ClientConfig { return ClientConfig{StaticHost: &StaticHostConfig{Addr: addr}} },
+			expectedNew: func(n uint64) bool { return n >= 1 && n <= 2 },
+		},
+		{
+			name:        "connection close",
+			config:      func(string) ClientConfig { return ClientConfig{DisableKeepAlive: true} },
+			expectedNew: func(n uint64) bool { return n == requests },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, _, _ := connCountingServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {})
+			addr := server.Listener.Addr().String()
+
+			client, err := NewClient(context.Background(), tc.config(addr), zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < requests; i++ {
+				req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+				req.SetRequestURI(server.URL)
+
+				if err := client.Do(req, resp); err != nil {
+					t.Fatal(err)
+				}
+
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(resp)
+			}
+
+			for _, summary := range metrics.Default.ConnectionSummaries() {
+				if summary.Address != addr {
+					continue
+				}
+
+				if summary.New+summary.Reused != requests || !tc.expectedNew(summary.New) {
+					t.Errorf("unexpected connection stats: %d new, %d reused", summary.New, summary.Reused)
+				}
+
+				return
+			}
+
+			t.Errorf("no connection stats for %v", addr)
+		})
+	}
+}
+
+func TestForceFreshConnection(t *testing.T) {
+	t.Parallel()
+
+	const requests = 5
+
+	testCases := []struct {
+		name   string
+		tls    bool
+		config func(addr string) ClientConfig
+	}{
+		{name: "client", config: func(string) ClientConfig { return ClientConfig{ForceFreshConnection: true} }},
+		{name: "tls", tls: true, config: func(string) ClientConfig { return ClientConfig{ForceFreshConnection: true} }},
+		{
+			name: "static host",
+			config: func(addr string) ClientConfig {
+				return ClientConfig{ForceFreshConnection: true, StaticHost: &StaticHostConfig{Addr: addr}}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu    sync.Mutex
+				ports = make(map[string]bool)
+			)
+
+			server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				ports[r.RemoteAddr] = true
+			}))
+
+			if tc.tls {
+				server.StartTLS()
+			} else {
+				server.Start()
+			}
+
+			t.Cleanup(server.Close)
+
+			addr := server.Listener.Addr().String()
+
+			client, err := NewClient(context.Background(), tc.config(addr), zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < requests; i++ {
+				req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+				req.SetRequestURI(server.URL)
+
+				if err := client.Do(req, resp); err != nil {
+					t.Fatal(err)
+				}
+
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(resp)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if len(ports) != requests {
+				t.Errorf("expected %d distinct remote addresses, got %v", requests, ports)
+			}
+
+			for _, summary := range metrics.Default.ConnectionSummaries() {
+				if summary.Address == addr && (summary.New != requests || summary.Reused != 0) {
+					t.Errorf("unexpected connection stats: %d new, %d reused", summary.New, summary.Reused)
+				}
+			}
+		})
+	}
+}
+
+func TestMultipartRequest(t *testing.T) {
+	t.Parallel()
+
+	const fileContent = "file \"content\"\r\n--not-a-boundary"
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+
+			return
+		}
+
+		file, header, err := r.FormFile("upload")
+		if err != nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+
+			return
+		}
+		defer file.Close()
+
+		content, err := io.ReadAll(file)
+		if err != nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("X-Name", r.FormValue("name"))
+		w.Header().Set("X-Comment", r.FormValue("comment"))
+		w.Header().Set("X-Filename", header.Filename)
+		w.Header().Set("X-Content-Type", header.Header.Get("Content-Type"))
+
+		if string(content) != fileContent {
+			w.WriteHeader(nethttp.StatusConflict)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var requestConfig RequestConfig
+	if err := utils.Decode(map[string]interface{}{
+		"path":   server.URL,
+		"method": "POST",
+		"multipart": map[string]interface{}{
+			"fields": map[string]interface{}{"name": "value", "comment": "with spaces & symbols"},
+			"files": map[string]interface{}{
+				"upload": map[string]interface{}{"filename": "data.txt", "content": fileContent, "content_type": "text/plain"},
+			},
+		},
+	}, &requestConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(context.Background(), ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	dataSize := InitRequest(requestConfig, req)
+	if expected := int64(len(req.Header.Header()) + len(req.Body())); dataSize != expected {
+		t.Errorf("expected data size %d to include the whole body, got %d", expected, dataSize)
+	}
+
+	if err := client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != nethttp.StatusOK {
+		t.Fatalf("server failed to parse the form, status %d", resp.StatusCode())
+	}
+
+	for header, expected := range map[string]string{
+		"X-Name":         "value",
+		"X-Comment":      "with spaces & symbols",
+		"X-Filename":     "data.txt",
+		"X-Content-Type": "text/plain",
+	} {
+		if got := string(resp.Header.Peek(header)); got != expected {
+			t.Errorf("expected %v to be %q, got %q", header, expected, got)
+		}
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		sources = make(map[string]bool)
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		sources[host] = true
+	}))
+	t.Cleanup(server.Close)
+
+	for _, localAddr := range []string{"127.0.0.2", "127.0.0.3"} {
+		client, err := NewClient(context.Background(), ClientConfig{LocalAddr: localAddr, DisableKeepAlive: true}, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+		req.SetRequestURI(server.URL)
+
+		if err := client.Do(req, resp); err != nil {
+			t.Fatal(err)
+		}
+
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(sources) != 2 || !sources["127.0.0.2"] || !sources["127.0.0.3"] {
+		t.Errorf("expected requests from 127.0.0.2 and 127.0.0.3, got %v", sources)
+	}
+
+	for _, clientConfig := range []ClientConfig{
+		{LocalAddr: "not-an-ip"},
+		{LocalAddr: "192.0.2.1"},
+		{LocalAddr: "192.0.2.1", ProxySelection: utils.ProxySelectionRandom, ProxyURLs: "socks5://127.0.0.1:1080"},
+	} {
+		if _, err := NewClient(context.Background(), clientConfig, zap.NewNop()); err == nil {
+			t.Errorf("expected an error for local address %q", clientConfig.LocalAddr)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	var hosts sync.Map
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		hosts.Store(r.Host, true)
+	}))
+	t.Cleanup(server.Close)
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// the domain is resolved to the chosen ip while the request still carries it in the host header
+	client, err := NewClient(context.Background(), ClientConfig{
+		Resolver: &utils.ResolverConfig{Hosts: map[string]string{"origin.example": "127.0.0.1"}},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://origin.example:" + port + "/")
+
+	if err := client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := hosts.Load("origin.example:" + port); !ok {
+		t.Error("expected the request to reach the server resolved from the hosts override")
+	}
+
+	for _, clientConfig := range []ClientConfig{
+		{Resolver: &utils.ResolverConfig{Servers: []string{"dns.example"}}},
+		{Resolver: &utils.ResolverConfig{Hosts: map[string]string{"origin.example": "origin"}}, ProxySelection: utils.ProxySelectionRandom, ProxyURLs: "socks5://127.0.0.1:1080"},
+	} {
+		if _, err := NewClient(context.Background(), clientConfig, zap.NewNop()); err == nil {
+			t.Errorf("expected an error for resolver config %+v", clientConfig.Resolver)
+		}
+	}
+}
+
+func TestIPVersion(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	t.Cleanup(server.Close)
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	testCases := []struct {
+		ipVersion string
+		expectErr bool
+	}{
+		{ipVersion: ""},
+		{ipVersion: IPVersionAuto},
+		{ipVersion: IPVersionIPv4},
+		{ipVersion: IPVersionIPv6, expectErr: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.ipVersion, func(t *testing.T) {
+			t.Parallel()
+
+			// the server only listens on ipv4 so the ipv6 preference has nothing to dial
+			client, err := NewClient(context.Background(), ClientConfig{
+				Resolver:  &utils.ResolverConfig{Hosts: map[string]string{"origin.example": "127.0.0.1"}},
+				IPVersion: tc.ipVersion,
+			}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			req.SetRequestURI("http://origin.example:" + port + "/")
+
+			if err := client.Do(req, resp); (err != nil) != tc.expectErr {
+				t.Errorf("expected error: %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+
+	if _, err := NewClient(context.Background(), ClientConfig{IPVersion: "ipv5"}, zap.NewNop()); err == nil {
+		t.Error("expected an error for an unknown ip version")
+	}
+}
+func TestStreamingBody(t *testing.T) {
+	t.Parallel()
+
+	const (
+		body       = "0123456789abcdefghijklmnopqrstuvwxyz"
+		chunkSize  = 10
+		chunks     = 4
+		chunkDelay = 20 * time.Millisecond
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		received, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if len(r.TransferEncoding) != 1 || r.TransferEncoding[0] != "chunked" || r.ContentLength != -1 {
+			t.Errorf("expected chunked body, got transfer encoding %v and content length %d", r.TransferEncoding, r.ContentLength)
+		}
+
+		if string(received) != body {
+			t.Errorf("expected body %q, got %q", body, received)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(context.Background(), ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var writes []int
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	dataSize := InitRequest(RequestConfig{
+		Path:        server.URL,
+		Method:      "POST",
+		Body:        body,
+		Streaming:   true,
+		ChunkSize:   chunkSize,
+		ChunkDelay:  chunkDelay,
+		OnBodyWrite: func(n int) { writes = append(writes, n) },
+	}, req)
+	if dataSize <= 0 || dataSize >= int64(len(req.Header.Header())+len(body)) {
+		t.Errorf("expected data size %d to only include the headers", dataSize)
+	}
+
+	start := time.Now()
+
+	if err := client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < (chunks-1)*chunkDelay {
+		t.Errorf("expected chunks to be delayed, request took %v", elapsed)
+	}
+
+	if fmt.Sprint(writes) != "[10 10 10 6]" {
+		t.Errorf("expected %d chunks of at most %d bytes, got %v", chunks, chunkSize, writes)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+
+	const requests = 20
+
+	server, total, _ := connCountingServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(r.URL.Query().Get("n")))
+	})
+
+	client, err := NewClient(context.Background(), ClientConfig{Pipeline: &PipelineConfig{Depth: 4}}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		succeeded int64
+	)
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+
+		go func(n string) {
+			defer wg.Done()
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			req.SetRequestURI(server.URL + "/?n=" + n)
+
+			if err := client.DoTimeout(req, resp, time.Second); err != nil {
+				t.Error(err)
+
+				return
+			}
+
+			// responses have to be matched with the requests they were sent for
+			if body := string(resp.Body()); body != n {
+				t.Errorf("expected the response to request %v, got %q", n, body)
+
+				return
+			}
+
+			atomic.AddInt64(&succeeded, 1)
+		}(strconv.Itoa(i))
+	}
+
+	wg.Wait()
+
+	if succeeded != requests {
+		t.Errorf("expected %d requests to succeed, got %d", requests, succeeded)
+	}
+
+	if got := atomic.LoadInt64(total); got != 1 {
+		t.Errorf("expected all the requests to share one connection, got %d connections", got)
+	}
+}
+
+func TestPipelineConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []ClientConfig{
+		{Pipeline: &PipelineConfig{}, Protocol: ProtocolHTTP2},
+		{Pipeline: &PipelineConfig{}, DisableKeepAlive: true},
+		{Pipeline: &PipelineConfig{}, ForceFreshConnection: true},
+		{Pipeline: &PipelineConfig{}, MaxResponseSize: 1024},
+	} {
+		if _, err := NewClient(context.Background(), c, zap.NewNop()); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func TestStrictMethods(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		config      RequestConfig
+		wantBody    string
+		contentType string
+		wantErr     bool // from CheckMethodBody
+	}{
+		{name: "get with body", config: RequestConfig{Method: "GET", Body: "data"}, wantErr: true},
+		{name: "default method with body", config: RequestConfig{Body: "data"}, wantErr: true},
+		{name: "head with body", config: RequestConfig{Method: "head", Body: "data"}, wantErr: true},
+		{
+			name:    "get with multipart",
+			config:  RequestConfig{Method: "GET", Multipart: &MultipartConfig{Fields: map[string]string{"a": "b"}}},
+			wantErr: true,
+		},
+		{name: "get with streamed body", config: RequestConfig{Method: "TRACE", Body: "data", Streaming: true}, wantErr: true},
+		{name: "get without body", config: RequestConfig{Method: "GET"}},
+		{name: "post json", config: RequestConfig{Method: "POST", Body: ` {"a": [1]}`}, wantBody: ` {"a": [1]}`, contentType: "application/json"},
+		{name: "put json array", config: RequestConfig{Method: "PUT", Body: `[1, 2]`}, wantBody: `[1, 2]`, contentType: "application/json"},
+		{name: "post form", config: RequestConfig{Method: "POST", Body: "a=1&b=2"}, wantBody: "a=1&b=2", contentType: "application/x-www-form-urlencoded"},
+		{name: "patch text", config: RequestConfig{Method: "PATCH", Body: "hello world"}, wantBody: "hello world", contentType: "text/plain; charset=utf-8"},
+		{name: "post invalid json", config: RequestConfig{Method: "POST", Body: "{oops"}, wantBody: "{oops", contentType: "text/plain; charset=utf-8"},
+		{
+			name:        "post with content type",
+			config:      RequestConfig{Method: "POST", Body: `{"a": 1}`, Headers: map[string]string{"Content-Type": "text/xml"}},
+			wantBody:    `{"a": 1}`,
+			contentType: "text/xml",
+		},
+		{name: "delete without body", config: RequestConfig{Method: "DELETE"}},
+	}
+
+	for _, tc := range testCases {
+		req := fasthttp.AcquireRequest()
+
+		if err := tc.config.CheckMethodBody(); (err != nil) != tc.wantErr {
+			t.Errorf("%v: expected method body error %v, got %v", tc.name, tc.wantErr, err)
+		}
+
+		// bodies are sent as is unless methods are strict
+		InitRequest(tc.config, req)
+
+		if tc.config.Body != "" && !tc.config.Streaming && string(req.Body()) != tc.config.Body {
+			t.Errorf("%v: expected the body to be kept without strict methods, got %q", tc.name, req.Body())
+		}
+
+		tc.config.StrictMethods = true
+		InitRequest(tc.config, req)
+
+		if body := string(req.Body()); body != tc.wantBody || (tc.wantBody == "" && req.IsBodyStream()) {
+			t.Errorf("%v: expected body %q, got %q", tc.name, tc.wantBody, body)
+		}
+
+		if got := string(req.Header.ContentType()); got != tc.contentType {
+			t.Errorf("%v: expected content type %q, got %q", tc.name, tc.contentType, got)
+		}
+
+		fasthttp.ReleaseRequest(req)
+	}
+}
+
+func TestJSONBody(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := templates.ParseMapStruct(map[string]interface{}{
+		"path":   "http://localhost/api",
+		"method": "POST",
+		"body": map[string]interface{}{
+			"id":      "{{ add 40 2 }}",
+			"enabled": "{{ if true }}true{{ end }}",
+			"name":    "user-{{ add 1 1 }}",
+			"code":    `"{{ add 0 7 }}"`,
+			"literal": 1.5,
+			"tags":    []interface{}{"a", "2"},
+			"nested":  map[string]interface{}{"big": "9007199254740993"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c RequestConfig
+	if err := DecodeRequest(tpl.Execute(zap.NewNop(), nil), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	InitRequest(c, req)
+
+	if got := string(req.Header.ContentType()); got != "application/json" {
+		t.Errorf("expected json content type, got %q", got)
+	}
+
+	const want = `{"code":"7","enabled":true,"id":42,"literal":1.5,"name":"user-2","nested":{"big":9007199254740993},"tags":["a",2]}`
+	if got := string(req.Body()); got != want {
+		t.Errorf("expected body %v, got %v", want, got)
+	}
+
+	c.Headers = map[string]string{"Content-Type": "application/vnd.api+json"}
+	InitRequest(c, req)
+
+	if got := string(req.Header.ContentType()); got != "application/vnd.api+json" {
+		t.Errorf("expected the headers to override the content type, got %q", got)
+	}
+
+	var plain RequestConfig
+	if err := DecodeRequest(map[string]interface{}{"body": "42"}, &plain); err != nil || plain.Body != "42" || plain.JSONBody {
+		t.Errorf("expected string bodies to be sent as is, got %+v: %v", plain, err)
+	}
+}
+
+// timedRequest sends a request with a new client and returns the error along with how long it took
+func timedRequest(t *testing.T, clientConfig ClientConfig, uri string) (time.Duration, error) {
+	t.Helper()
+
+	client, err := NewClient(context.Background(), clientConfig, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(uri)
+
+	start := time.Now()
+	err = client.Do(req, resp)
+
+	return time.Since(start), err
+}
+
+func stageTimeouts(stage time.Duration) ClientConfig {
+	overall := 10 * time.Second
+
+	return ClientConfig{Timeout: &overall, ReadTimeout: &overall, WriteTimeout: &overall, TLSHandshakeTimeout: &stage, ResponseTimeout: &stage}
+}
+
+func TestTLSHandshakeTimeout(t *testing.T) {
+	t.Parallel()
+
+	// accepts connections and never answers the client hello
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	for _, staticHost := range []*StaticHostConfig{nil, {Addr: listener.Addr().String(), IsTLS: true}} {
+		config := stageTimeouts(100 * time.Millisecond)
+		config.StaticHost = staticHost
+
+		elapsed, err := timedRequest(t, config, "https://"+listener.Addr().String())
+		if !errors.Is(err, fasthttp.ErrTLSHandshakeTimeout) || elapsed > 5*time.Second {
+			t.Errorf("expected the handshake to time out, got %v after %v", err, elapsed)
+		}
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	// the handshake is completed in time so only the response can time out
+	elapsed, err := timedRequest(t, stageTimeouts(300*time.Millisecond), server.URL)
+	if !errors.Is(err, fasthttp.ErrTimeout) || elapsed > 5*time.Second {
+		t.Errorf("expected the response to time out, got %v after %v", err, elapsed)
+	}
+}
+
+func TestHTTPSThroughConnectProxy(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = io.WriteString(w, "tunneled")
+	}))
+	t.Cleanup(server.Close)
+
+	// a CONNECT-only proxy, the tls session to the target goes through the tunnel untouched
+	var connects []string
+
+	var mu sync.Mutex
+
+	proxy := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method != nethttp.MethodConnect {
+			w.WriteHeader(nethttp.StatusMethodNotAllowed)
+
+			return
+		}
+
+		mu.Lock()
+		connects = append(connects, r.Host)
+		mu.Unlock()
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(nethttp.StatusBadGateway)
+
+			return
+		}
+		defer target.Close()
+
+		conn, buf, err := w.(nethttp.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+			return
+		}
+
+		go func() { _, _ = io.Copy(target, buf) }()
+		_, _ = io.Copy(conn, target)
+	}))
+	t.Cleanup(proxy.Close)
+
+	client, err := NewClient(context.Background(), ClientConfig{ProxyURLs: proxy.URL}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(server.URL)
+
+	if err := client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "tunneled" {
+		t.Errorf("unexpected response %q", resp.Body())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(connects) != 1 || connects[0] != server.Listener.Addr().String() {
+		t.Errorf("expected a single tunnel to %v, got %v", server.Listener.Addr(), connects)
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	t.Parallel()
+
+	const text = "hello"
+
+	testCases := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     string
+		wantErr  bool
+	}{
+		{name: "identity", body: []byte(text), want: text},
+		{name: "brotli", encoding: "br", body: fasthttp.AppendBrotliBytes(nil, []byte(text)), want: text},
+		{name: "chained", encoding: "deflate, GZIP", body: fasthttp.AppendGzipBytes(nil, fasthttp.AppendDeflateBytes(nil, []byte(text))), want: text},
+		{name: "unsupported", encoding: "zstd", body: []byte("zstd"), want: "zstd", wantErr: true},
+		{name: "corrupted", encoding: "gzip", body: []byte("gzip"), want: "gzip", wantErr: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var resp fasthttp.Response
+
+			resp.Header.Set(fasthttp.HeaderContentEncoding, tc.encoding)
+			resp.SetBody(tc.body)
+
+			body, err := DecodeBody(&resp)
+			if string(body) != tc.want || (err != nil) != tc.wantErr {
+				t.Errorf("expected %q (error %v), got %q with %v", tc.want, tc.wantErr, body, err)
+			}
+
+			var written bytes.Buffer
+
+			_, _, err = WriteDecodedBody(&written, &resp, 0)
+			if (err != nil) != tc.wantErr || (!tc.wantErr && written.String() != tc.want) {
+				t.Errorf("expected %q to be written (error %v), got %q with %v", tc.want, tc.wantErr, written.String(), err)
+			}
+
+			written.Reset()
+
+			if n, truncated, err := WriteDecodedBody(&written, &resp, 2); !tc.wantErr && (err != nil || n != 2 || !truncated || written.String() != tc.want[:2]) {
+				t.Errorf("expected the body to be cut to %q, got %q (%v, %v)", tc.want[:2], written.String(), truncated, err)
+			}
+		})
+	}
+}
+
+func TestAcquireClient(t *testing.T) {
+	t.Parallel()
+
+	timeout := 3 * time.Second
+	shared := ClientConfig{Shared: true, Timeout: &timeout}
+	other := ClientConfig{Shared: true, Timeout: &timeout, DisableKeepAlive: true}
+
+	// the wrapping clients aren't comparable so the cache entries are compared instead
+	entry := func(clientConfig ClientConfig) *sharedClient {
+		key, ok := sharedClientKey(context.Background(), &clientConfig, zap.NewNop())
+		if !ok {
+			t.Fatal("expected the config to be shared")
+		}
+
+		sharedClients.Lock()
+		defer sharedClients.Unlock()
+
+		return sharedClients.entries[key]
+	}
+
+	acquire := func(clientConfig ClientConfig) func() {
+		_, release, err := AcquireClient(context.Background(), clientConfig, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return release
+	}
+
+	releaseFirst := acquire(shared)
+	first := entry(shared)
+	releaseSecond := acquire(shared)
+
+	if entry(shared) != first || first.refs != 2 {
+		t.Fatalf("expected identically configured callers to share the client, got %+v", entry(shared))
+	}
+
+	defer acquire(other)()
+
+	if entry(other) == first {
+		t.Error("expected a different config to get its own client")
+	}
+
+	if _, ok := sharedClientKey(context.Background(), &ClientConfig{Timeout: &timeout}, zap.NewNop()); ok {
+		t.Error("expected configs that aren't shared to get their own client")
+	}
+
+	// releasing twice doesn't drop the reference of the other caller
+	releaseFirst()
+	releaseFirst()
+
+	releaseThird := acquire(shared)
+
+	if entry(shared) != first || first.refs != 2 {
+		t.Error("expected the client to be kept while it's still used")
+	}
+
+	releaseSecond()
+	releaseThird()
+
+	if entry(shared) != nil {
+		t.Error("expected the client to be evicted once it's released by every caller")
+	}
+
+	defer acquire(shared)()
+
+	if fresh := entry(shared); fresh == nil || fresh == first {
+		t.Error("expected a new client after the eviction")
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// wireSizeServer answers every request with an empty response and reports how many bytes the request took on the wire
+func wireSizeServer(t *testing.T) (addr string, sizes <-chan int64) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	results := make(chan int64, 1)
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+
+		counter := &countingReader{r: conn}
+		reader := bufio.NewReader(counter)
+
+		for {
+			start := counter.n - int64(reader.Buffered())
+
+			req, err := nethttp.ReadRequest(reader)
+			if err != nil {
+				return
+			}
+
+			_, _ = io.Copy(io.Discard, req.Body)
+
+			results <- counter.n - int64(reader.Buffered()) - start
+
+			if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+				return
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serve(conn)
+		}
+	}()
+
+	return listener.Addr().String(), results
+}
+
+func TestRequestSizeOnTheWire(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		client  ClientConfig
+		request RequestConfig
+	}{
+		{name: "get", request: RequestConfig{Path: "/path?query=value", Method: "GET"}},
+		{
+			name: "post with headers and cookies",
+			request: RequestConfig{
+				Path: "/", Method: "POST", Body: "key=value", Headers: map[string]string{"X-Test": "value"},
+				Cookies: map[string]string{"a": "1", "b": "2"}, StrictMethods: true,
+			},
+		},
+		{name: "encoded body", request: RequestConfig{Path: "/", Method: "POST", Body: strings.Repeat("body ", 100), Encoding: EncodingGzip}},
+		{name: "multipart", request: RequestConfig{Path: "/", Method: "POST", Multipart: &MultipartConfig{Fields: map[string]string{"field": "value"}}}},
+		{name: "host header", request: RequestConfig{Path: "/", Method: "GET", Headers: map[string]string{"Host": "example.com"}}},
+		{name: "connection close", client: ClientConfig{DisableKeepAlive: true}, request: RequestConfig{Path: "/", Method: "GET"}},
+		{name: "fresh connection", client: ClientConfig{ForceFreshConnection: true}, request: RequestConfig{Path: "/", Method: "GET"}},
+		{name: "streamed", request: RequestConfig{Path: "/", Method: "POST", Body: strings.Repeat("x", 2500), Streaming: true, ChunkSize: 1000}},
+		{name: "streamed big chunks", request: RequestConfig{Path: "/", Method: "POST", Body: strings.Repeat("x", 10000), Streaming: true, ChunkSize: 8192}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			addr, sizes := wireSizeServer(t)
+
+			client, err := NewClient(context.Background(), tc.client, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var streamed int64
+
+			tc.request.Path = "http://" + addr + tc.request.Path
+			tc.request.ConnectionClose = tc.client.ClosesConnections()
+			tc.request.OnBodyWrite = func(n int) { atomic.AddInt64(&streamed, int64(n)) }
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			// the request is reused the way the jobs reuse it
+			for attempt := 0; attempt < 2; attempt++ {
+				dataSize := InitRequest(tc.request, req)
+
+				if _, err := Send(client, req, resp, nil); err != nil {
+					t.Fatal(err)
+				}
+
+				if reported, received := dataSize+atomic.SwapInt64(&streamed, 0), <-sizes; reported != received {
+					t.Errorf("expected the reported size %d to match the %d bytes received", reported, received)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Arriven/db1000n/src/utils"
+)
+
+// DecodeRequest decodes the rendered request config. Body can be either a string that is sent as is or an object
+// that is marshaled to json, string values of the object that are valid json themselves (i.e. rendered numbers and booleans)
+// keep their type so that "{{ random_int_n 10 }}" is sent as a number. Values that have to stay strings can be quoted: '"007"'
+func DecodeRequest(input map[string]interface{}, c *RequestConfig) error {
+	object, ok := input["body"].(map[string]interface{})
+	if !ok {
+		return utils.Decode(input, c)
+	}
+
+	rest := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		if key != "body" {
+			rest[key] = value
+		}
+	}
+
+	if err := utils.Decode(rest, c); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(typedJSONValue(object))
+	if err != nil {
+		return fmt.Errorf("error marshaling json body: %w", err)
+	}
+
+	c.Body, c.JSONBody = string(body), true
+
+	return nil
+}
+
+// typedJSONValue replaces the strings that are valid json values with the decoded values
+func typedJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = typedJSONValue(item)
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = typedJSONValue(item)
+		}
+
+		return result
+	case string:
+		if typed, ok := decodeJSONValue(v); ok {
+			return typed
+		}
+
+		return v
+	default:
+		return v
+	}
+}
+
+// decodeJSONValue decodes the whole string as a single json value, numbers are kept as json.Number to not lose precision
+func decodeJSONValue(s string) (interface{}, bool) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, false
+	}
+
+	return value, true
+}
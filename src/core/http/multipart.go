@@ -0,0 +1,98 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// MultipartConfig describes a multipart/form-data body, every value can be templated
+type MultipartConfig struct {
+	Fields map[string]string
+	Files  map[string]MultipartFileConfig // by field name
+}
+
+// MultipartFileConfig is a single file part of a multipart body, content is usually read with the file template function
+type MultipartFileConfig struct {
+	Filename    string
+	Content     string
+	ContentType string `mapstructure:"content_type"` // defaults to application/octet-stream
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// build encodes the config into a multipart body and returns it along with the matching content type
+func (c MultipartConfig) build() (body, contentType string) {
+	const defaultFileContentType = "application/octet-stream"
+
+	var buf bytes.Buffer
+
+	writer := multipart.NewWriter(&buf)
+
+	// sort parts to get consistent bodies for the same config
+	fields := make([]string, 0, len(c.Fields))
+	for name := range c.Fields {
+		fields = append(fields, name)
+	}
+
+	sort.Strings(fields)
+
+	// writing into bytes.Buffer never fails so errors are safe to ignore
+	for _, name := range fields {
+		_ = writer.WriteField(name, c.Fields[name])
+	}
+
+	fileFields := make([]string, 0, len(c.Files))
+	for name := range c.Files {
+		fileFields = append(fileFields, name)
+	}
+
+	sort.Strings(fileFields)
+
+	for _, name := range fileFields {
+		file := c.Files[name]
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(name), quoteEscaper.Replace(file.Filename)))
+
+		if file.ContentType != "" {
+			header.Set("Content-Type", file.ContentType)
+		} else {
+			header.Set("Content-Type", defaultFileContentType)
+		}
+
+		if part, err := writer.CreatePart(header); err == nil {
+			_, _ = part.Write([]byte(file.Content))
+		}
+	}
+
+	_ = writer.Close()
+
+	return buf.String(), writer.FormDataContentType()
+}
@@ -0,0 +1,153 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// PipelineConfig enables HTTP/1.1 pipelining: requests are written to the connection without waiting for the responses
+// to the previous ones, the responses are still read (and matched to the requests) in the order the requests were sent
+type PipelineConfig struct {
+	Depth       int `mapstructure:"depth"`       // requests in flight per connection, 8 by default
+	Connections int `mapstructure:"connections"` // pipelined connections per host, 1 by default
+}
+
+const (
+	defaultPipelineDepth       = 8
+	defaultPipelineConnections = 1
+)
+
+// GetDepth returns the amount of requests in flight per connection
+func (c *PipelineConfig) GetDepth() int {
+	if c == nil || c.Depth <= 0 {
+		return defaultPipelineDepth
+	}
+
+	return c.Depth
+}
+
+// GetConnections returns the amount of pipelined connections per host
+func (c *PipelineConfig) GetConnections() int {
+	if c == nil || c.Connections <= 0 {
+		return defaultPipelineConnections
+	}
+
+	return c.Connections
+}
+
+// pipelineClient sends requests with a fasthttp.PipelineClient per host as unlike fasthttp.Client
+// the pipeline client is bound to a single address
+type pipelineClient struct {
+	newClient func(addr string, isTLS bool) *fasthttp.PipelineClient
+	addr      func(req *fasthttp.Request) string
+	isTLS     func(req *fasthttp.Request) bool
+
+	mu      sync.Mutex
+	clients map[string]*fasthttp.PipelineClient
+}
+
+func newPipelineClient(c *PipelineConfig, staticHost *StaticHostConfig, tlsConfig *tls.Config,
+	readTimeout, writeTimeout, maxIdleConnDuration time.Duration, dial fasthttp.DialFunc,
+) *pipelineClient {
+	client := &pipelineClient{
+		newClient: func(addr string, isTLS bool) *fasthttp.PipelineClient {
+			return &fasthttp.PipelineClient{
+				Addr:                          addr,
+				IsTLS:                         isTLS,
+				MaxConns:                      c.GetConnections(),
+				MaxPendingRequests:            c.GetDepth() * c.GetConnections(),
+				ReadTimeout:                   readTimeout,
+				WriteTimeout:                  writeTimeout,
+				MaxIdleConnDuration:           maxIdleConnDuration,
+				NoDefaultUserAgentHeader:      true, // Don't send: User-Agent: fasthttp
+				DisableHeaderNamesNormalizing: true, // If you set the case on your headers correctly you can enable this
+				DisablePathNormalizing:        true,
+				TLSConfig:                     tlsConfig,
+				Dial:                          dial,
+			}
+		},
+		addr: requestAddr,
+		isTLS: func(req *fasthttp.Request) bool {
+			return bytes.EqualFold(req.URI().Scheme(), []byte("https"))
+		},
+		clients: make(map[string]*fasthttp.PipelineClient),
+	}
+
+	if staticHost != nil {
+		client.addr = func(*fasthttp.Request) string { return staticHost.Addr }
+		client.isTLS = func(*fasthttp.Request) bool { return staticHost.IsTLS }
+	}
+
+	return client
+}
+
+func (c *pipelineClient) client(req *fasthttp.Request) *fasthttp.PipelineClient {
+	addr, isTLS := c.addr(req), c.isTLS(req)
+	key := addr
+	if isTLS {
+		key = "tls:" + addr
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, ok := c.clients[key]
+	if !ok {
+		client = c.newClient(addr, isTLS)
+		c.clients[key] = client
+	}
+
+	return client
+}
+
+func (c *pipelineClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	return c.client(req).Do(req, resp)
+}
+
+func (c *pipelineClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	return c.client(req).DoTimeout(req, resp, timeout)
+}
+
+// checkPipelineConfig rejects the options that can't be combined with pipelining
+func checkPipelineConfig(c ClientConfig) error {
+	switch {
+	case c.Pipeline == nil:
+		return nil
+	case c.Protocol != "" && c.Protocol != ProtocolHTTP1:
+		return fmt.Errorf("pipeline is only supported with the %q protocol", ProtocolHTTP1)
+	case c.DisableKeepAlive, c.ForceFreshConnection:
+		return errors.New("pipeline can't be combined with disable_keep_alive or force_fresh_connection")
+	case c.MaxResponseSize > 0:
+		return errors.New("pipeline can't be combined with max_response_size")
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+)
+
+// ProxyHealthCheckConfig describes how proxies from ClientConfig.ProxyURLs are probed
+type ProxyHealthCheckConfig struct {
+	Interval         *time.Duration `mapstructure:"interval"`
+	Timeout          *time.Duration `mapstructure:"timeout"`
+	Target           string         `mapstructure:"target"` // address to connect to through the proxy
+	FailureThreshold *int           `mapstructure:"failure_threshold"`
+}
+
+// proxyProbe returns an error if the proxy can't be used
+type proxyProbe func(proxyURL string) error
+
+func dialProbe(target string, timeout time.Duration) proxyProbe {
+	return func(proxyURL string) error {
+		conn, err := utils.GetProxyFunc(proxyURL, timeout)("tcp", target)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+}
+
+// healthCheckedSelector skips proxies that failed the last health checks
+type healthCheckedSelector struct {
+	selector  utils.ProxySelector
+	proxies   []string
+	threshold int
+
+	mu       sync.RWMutex
+	failures map[string]int
+	live     []string
+}
+
+// startProxyHealthCheck wraps the selector to skip dead proxies and probes them in background until the context is done
+func startProxyHealthCheck(ctx context.Context, logger *zap.Logger, selector utils.ProxySelector, proxies []string, c ProxyHealthCheckConfig,
+) *healthCheckedSelector {
+	const (
+		defaultInterval         = 30 * time.Second
+		defaultTimeout          = 5 * time.Second
+		defaultTarget           = "1.1.1.1:443"
+		defaultFailureThreshold = 3
+	)
+
+	target := c.Target
+	if target == "" {
+		target = defaultTarget
+	}
+
+	s := newHealthCheckedSelector(selector, proxies, utils.NonNilIntOrDefault(c.FailureThreshold, defaultFailureThreshold))
+
+	go s.run(ctx, logger, utils.NonNilDurationOrDefault(c.Interval, defaultInterval),
+		dialProbe(target, utils.NonNilDurationOrDefault(c.Timeout, defaultTimeout)))
+
+	return s
+}
+
+func newHealthCheckedSelector(selector utils.ProxySelector, proxies []string, threshold int) *healthCheckedSelector {
+	return &healthCheckedSelector{
+		selector:  selector,
+		proxies:   proxies,
+		threshold: threshold,
+		failures:  make(map[string]int),
+		live:      proxies,
+	}
+}
+
+// Next picks a proxy with the underlying selector and replaces it with a random live one if it's dead.
+// All proxies are used as usual when none of them are live
+func (s *healthCheckedSelector) Next() string {
+	proxy := s.selector.Next()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.failures[proxy] < s.threshold || len(s.live) == 0 {
+		return proxy
+	}
+
+	return s.live[rand.Intn(len(s.live))] //nolint:gosec // Cryptographically secure random not required
+}
+
+func (s *healthCheckedSelector) run(ctx context.Context, logger *zap.Logger, interval time.Duration, probe proxyProbe) {
+	metrics.AddProxies(metrics.ProxyStateLive, len(s.proxies))
+
+	defer func() {
+		live := s.liveCount()
+
+		metrics.AddProxies(metrics.ProxyStateLive, -live)
+		metrics.AddProxies(metrics.ProxyStateDead, live-len(s.proxies))
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.check(logger, probe)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check probes all the proxies concurrently and updates the live set
+func (s *healthCheckedSelector) check(logger *zap.Logger, probe proxyProbe) {
+	var wg sync.WaitGroup
+
+	wg.Add(len(s.proxies))
+
+	for _, proxy := range s.proxies {
+		go func(proxy string) {
+			defer wg.Done()
+
+			err := probe(proxy)
+			if err != nil {
+				logger.Debug("proxy health check failed", zap.String("proxy", proxy), zap.Error(err))
+			}
+
+			s.record(logger, proxy, err)
+		}(proxy)
+	}
+
+	wg.Wait()
+}
+
+func (s *healthCheckedSelector) record(logger *zap.Logger, proxy string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wasLive := s.failures[proxy] < s.threshold
+
+	if err != nil {
+		s.failures[proxy]++
+	} else {
+		delete(s.failures, proxy)
+	}
+
+	switch isLive := s.failures[proxy] < s.threshold; {
+	case wasLive && !isLive:
+		logger.Debug("evicting dead proxy", zap.String("proxy", proxy))
+		metrics.AddProxies(metrics.ProxyStateLive, -1)
+		metrics.AddProxies(metrics.ProxyStateDead, 1)
+	case !wasLive && isLive:
+		logger.Debug("proxy recovered", zap.String("proxy", proxy))
+		metrics.AddProxies(metrics.ProxyStateDead, -1)
+		metrics.AddProxies(metrics.ProxyStateLive, 1)
+	default:
+		return
+	}
+
+	live := make([]string, 0, len(s.proxies))
+
+	for _, p := range s.proxies {
+		if s.failures[p] < s.threshold {
+			live = append(live, p)
+		}
+	}
+
+	s.live = live
+}
+
+func (s *healthCheckedSelector) liveCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.live)
+}
@@ -0,0 +1,218 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+)
+
+// toggleProbe fails for the proxies marked as broken
+type toggleProbe struct {
+	mu     sync.Mutex
+	broken map[string]bool
+}
+
+func (p *toggleProbe) set(proxy string, broken bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.broken[proxy] = broken
+}
+
+func (p *toggleProbe) probe(proxy string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.broken[proxy] {
+		return errors.New("broken proxy")
+	}
+
+	return nil
+}
+
+func TestProxyHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	proxies := []string{"http://p1", "http://p2", "http://p3"}
+
+	selector, err := utils.NewProxySelector("http://p1,http://p2,http://p3", utils.ProxySelectionRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const threshold = 2
+
+	s := newHealthCheckedSelector(selector, proxies, threshold)
+	probe := &toggleProbe{broken: map[string]bool{"http://p2": true}}
+
+	selected := func() map[string]int {
+		result := make(map[string]int)
+		for i := 0; i < 30; i++ {
+			result[s.Next()]++
+		}
+
+		return result
+	}
+
+	s.check(zap.NewNop(), probe.probe)
+
+	if got := selected(); got["http://p2"] == 0 || s.liveCount() != len(proxies) {
+		t.Errorf("expected proxy to stay live below the failure threshold, got %v", got)
+	}
+
+	s.check(zap.NewNop(), probe.probe)
+
+	if got := selected(); got["http://p2"] != 0 || got["http://p1"] == 0 || got["http://p3"] == 0 || s.liveCount() != len(proxies)-1 {
+		t.Errorf("expected dead proxy to be evicted, got %v", got)
+	}
+
+	probe.set("http://p2", false)
+	s.check(zap.NewNop(), probe.probe)
+
+	if got := selected(); got["http://p2"] == 0 || s.liveCount() != len(proxies) {
+		t.Errorf("expected recovered proxy to be selected again, got %v", got)
+	}
+
+	for _, proxy := range proxies {
+		probe.set(proxy, true)
+	}
+
+	s.check(zap.NewNop(), probe.probe)
+	s.check(zap.NewNop(), probe.probe)
+
+	if got := selected(); len(got) != len(proxies) || s.liveCount() != 0 {
+		t.Errorf("expected all proxies to be used when none of them are live, got %v", got)
+	}
+}
+
+// startSOCKS5Stub starts a socks5 proxy that accepts clients without authentication and connects them to the requested address
+func startSOCKS5Stub(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSOCKS5Stub(conn)
+		}
+	}()
+
+	return listener
+}
+
+func serveSOCKS5Stub(conn net.Conn) {
+	defer conn.Close()
+
+	// greeting: version, amount of methods, methods
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+		return
+	}
+
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+
+	// request: version, command, reserved, address type (only ipv4 is supported here), address, port
+	request := make([]byte, 10)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+
+	target, err := net.Dial("tcp", (&net.TCPAddr{IP: request[4:8], Port: int(request[8])<<8 | int(request[9])}).String())
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+
+		return
+	}
+	defer target.Close()
+
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	go func() { _, _ = io.Copy(target, conn) }()
+
+	_, _ = io.Copy(conn, target)
+}
+
+func TestProxyHealthCheckDialProbe(t *testing.T) {
+	t.Parallel()
+
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { target.Close() })
+
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	working := "socks5://" + startSOCKS5Stub(t).Addr().String()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := "socks5://" + closed.Addr().String()
+	closed.Close()
+
+	selector, err := utils.NewProxySelector(working+","+broken, utils.ProxySelectionRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	interval, threshold := 10*time.Millisecond, 1
+
+	s := startProxyHealthCheck(ctx, zap.NewNop(), selector, []string{working, broken}, ProxyHealthCheckConfig{
+		Interval:         &interval,
+		Target:           target.Addr().String(),
+		FailureThreshold: &threshold,
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for s.liveCount() != 1 && time.Now().Before(deadline) {
+		time.Sleep(interval)
+	}
+
+	for i := 0; i < 10; i++ {
+		if proxy := s.Next(); proxy != working {
+			t.Fatalf("expected only the working proxy to be selected, got %v", proxy)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/Arriven/db1000n/src/utils"
+)
+
+// ProxiedClient is implemented by the clients that know which proxy a request has been sent through
+type ProxiedClient interface {
+	DoVia(req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration) (proxy string, err error)
+}
+
+// Send sends the request with the client, with the timeout when it's not nil, and returns the address of the proxy
+// that served it (see utils.ProxyName) or an empty string when the request has been sent directly
+func Send(client Client, req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration) (proxy string, err error) {
+	if proxied, ok := client.(ProxiedClient); ok {
+		return proxied.DoVia(req, resp, timeout)
+	}
+
+	return "", do(client, req, resp, timeout)
+}
+
+// SendReportingRedirects is Send that reports the size of every redirect the client follows to onRedirect instead of
+// the traffic writer of the client, i.e. so that the redirects count towards the traffic budget of the job sending the request
+func SendReportingRedirects(client Client, req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration,
+	onRedirect func(size int64),
+) (proxy string, err error) {
+	if redirecting, ok := client.(*redirectClient); ok && onRedirect != nil {
+		return redirecting.sendVia(req, resp, timeout, onRedirect)
+	}
+
+	return Send(client, req, resp, timeout)
+}
+
+func do(client Client, req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration) error {
+	if timeout != nil {
+		return client.DoTimeout(req, resp, *timeout)
+	}
+
+	return client.Do(req, resp)
+}
+
+// proxyClient sends all the requests through the same proxy
+type proxyClient struct {
+	Client
+	proxy string
+}
+
+func (c proxyClient) DoVia(req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration) (string, error) {
+	return c.proxy, do(c.Client, req, resp, timeout)
+}
+
+// proxyPoolClient selects a proxy for every request and sends it with a client dedicated to that proxy,
+// so that connections to each of the proxies are still reused
+type proxyPoolClient struct {
+	selector  utils.ProxySelector
+	newClient func(proxyURL string) (Client, error)
+
+	mu      sync.Mutex
+	clients map[string]Client
+}
+
+func newProxyPoolClient(selector utils.ProxySelector, newClient func(proxyURL string) (Client, error)) *proxyPoolClient {
+	return &proxyPoolClient{selector: selector, newClient: newClient, clients: make(map[string]Client)}
+}
+
+func (c *proxyPoolClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	client, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	return client.Do(req, resp)
+}
+
+func (c *proxyPoolClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	client, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	return client.DoTimeout(req, resp, timeout)
+}
+
+func (c *proxyPoolClient) DoVia(req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration) (string, error) {
+	client, err := c.next()
+	if err != nil {
+		return "", err
+	}
+
+	return Send(client, req, resp, timeout)
+}
+
+func (c *proxyPoolClient) next() (Client, error) {
+	proxyURL := c.selector.Next()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[proxyURL]; ok {
+		return client, nil
+	}
+
+	client, err := c.newClient(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.clients[proxyURL] = client
+
+	return client, nil
+}
@@ -0,0 +1,107 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/Arriven/db1000n/src/utils/metrics"
+)
+
+// redirectClient follows redirects manually (instead of using fasthttp DoRedirects) to account for the traffic of every hop
+type redirectClient struct {
+	Client
+	maxRedirects int
+	onHop        func(size int64)
+}
+
+func (c *redirectClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	return c.follow(req, resp, c.Client.Do, c.onHop)
+}
+
+func (c *redirectClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	return c.follow(req, resp, func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		return c.Client.DoTimeout(req, resp, timeout)
+	}, c.onHop)
+}
+
+// DoVia reports the proxy of the last hop as every hop can go through a different one when the proxy is picked per request
+func (c *redirectClient) DoVia(req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration) (proxy string, err error) {
+	return c.sendVia(req, resp, timeout, c.onHop)
+}
+
+func (c *redirectClient) sendVia(req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration, onHop func(size int64),
+) (proxy string, err error) {
+	err = c.follow(req, resp, func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		var hopErr error
+
+		proxy, hopErr = Send(c.Client, req, resp, timeout)
+
+		return hopErr
+	}, onHop)
+
+	return proxy, err
+}
+
+func (c *redirectClient) follow(req *fasthttp.Request, resp *fasthttp.Response, do func(*fasthttp.Request, *fasthttp.Response) error,
+	onHop func(size int64),
+) error {
+	if resp == nil {
+		resp = fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+	}
+
+	if err := do(req, resp); err != nil || !fasthttp.StatusCodeIsRedirect(resp.StatusCode()) {
+		return err
+	}
+
+	// callers reuse the original request so redirects are followed with a copy of it
+	redirectReq := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(redirectReq)
+
+	req.CopyTo(redirectReq)
+
+	for redirects := 1; fasthttp.StatusCodeIsRedirect(resp.StatusCode()); redirects++ {
+		if redirects > c.maxRedirects {
+			return fasthttp.ErrTooManyRedirects
+		}
+
+		location := resp.Header.Peek(fasthttp.HeaderLocation)
+		if len(location) == 0 {
+			return fasthttp.ErrMissingLocation
+		}
+
+		redirectReq.URI().UpdateBytes(location)
+
+		size, _ := redirectReq.WriteTo(metrics.NopWriter{})
+		onHop(size)
+
+		if err := do(redirectReq, resp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
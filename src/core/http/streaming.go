@@ -0,0 +1,101 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package http
+
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultChunkSize = 1024
+	maxChunkSize     = 4096 // size of the buffer fasthttp reads the stream into, bigger chunks would be split anyway
+	chunkCRLFs       = 4    // after the hex size and after the data
+)
+
+// chunkedBody emits the body in chunks of fixed size, fasthttp sends every read as a separate chunk
+// with chunked transfer encoding when the body size is unknown
+type chunkedBody struct {
+	data      []byte
+	chunkSize int
+	delay     time.Duration // pause before every chunk but the first one
+	onWrite   func(n int)
+	started   bool
+}
+
+func newChunkedBody(data []byte, c RequestConfig) *chunkedBody {
+	chunkSize := c.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	if chunkSize > maxChunkSize {
+		chunkSize = maxChunkSize
+	}
+
+	return &chunkedBody{data: data, chunkSize: chunkSize, delay: c.ChunkDelay, onWrite: c.OnBodyWrite}
+}
+
+func (b *chunkedBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, io.EOF
+	}
+
+	if b.started && b.delay > 0 {
+		time.Sleep(b.delay)
+	}
+
+	b.started = true
+
+	if len(p) > b.chunkSize {
+		p = p[:b.chunkSize]
+	}
+
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+
+	if b.onWrite != nil {
+		b.onWrite(n)
+	}
+
+	return n, nil
+}
+
+// framingSize returns the bytes the chunk sizes and delimiters of the remaining data take on the wire, excluding the last empty chunk
+func (b *chunkedBody) framingSize() int64 {
+	chunkFraming := func(n int) int64 { return int64(len(strconv.FormatInt(int64(n), 16)) + chunkCRLFs) }
+
+	size := int64(len(b.data)/b.chunkSize) * chunkFraming(b.chunkSize)
+	if rest := len(b.data) % b.chunkSize; rest > 0 {
+		size += chunkFraming(rest)
+	}
+
+	return size
+}
+
+// eofReader is an empty body stream
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+package http
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDialTimeout(t *testing.T) {
+	t.Parallel()
+
+	// the accept queue of a listener with zero backlog fits a single connection, syns of the following ones are dropped
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	raw, err := listener.(*net.TCPListener).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listenErr error
+	if err := raw.Control(func(fd uintptr) { listenErr = syscall.Listen(int(fd), 0) }); err != nil || listenErr != nil {
+		t.Fatal(err, listenErr)
+	}
+
+	queued, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queued.Close() })
+
+	config := stageTimeouts(time.Second)
+	dialTimeout := 100 * time.Millisecond
+	config.DialTimeout = &dialTimeout
+
+	var netErr net.Error
+
+	elapsed, err := timedRequest(t, config, "http://"+listener.Addr().String())
+	if !errors.As(err, &netErr) || !netErr.Timeout() || elapsed > 5*time.Second {
+		t.Errorf("expected the dial to time out, got %v after %v", err, elapsed)
+	}
+}
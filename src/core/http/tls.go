@@ -0,0 +1,134 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package http
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// TLSConfig is a human-friendly subset of tls.Config settings
+type TLSConfig struct {
+	MinVersion         string   `mapstructure:"min_version"`          // "1.0", "1.1", "1.2", or "1.3"
+	MaxVersion         string   `mapstructure:"max_version"`          // "1.0", "1.1", "1.2", or "1.3"
+	CipherSuites       []string `mapstructure:"cipher_suites"`        // names as defined in crypto/tls, i.e. TLS_RSA_WITH_AES_128_CBC_SHA
+	ServerName         string   `mapstructure:"server_name"`          // overrides SNI and the name used for verification
+	InsecureSkipVerify *bool    `mapstructure:"insecure_skip_verify"` // true by default like for the rest of the clients
+	ClientCert         string   `mapstructure:"client_cert"`          // pem encoded certificate chain or path to it in the files directory
+	ClientKey          string   `mapstructure:"client_key"`           // pem encoded private key of the certificate or path to it in the files directory
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Build converts the config to tls.Config
+func (c *TLSConfig) Build() (*tls.Config, error) {
+	result := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify == nil || *c.InsecureSkipVerify, //nolint:gosec // This is intentional
+	}
+
+	var err error
+
+	if result.MinVersion, err = parseTLSVersion(c.MinVersion); err != nil {
+		return nil, err
+	}
+
+	if result.MaxVersion, err = parseTLSVersion(c.MaxVersion); err != nil {
+		return nil, err
+	}
+
+	if c.ClientCert != "" || c.ClientKey != "" {
+		result.Certificates = make([]tls.Certificate, 1)
+		if result.Certificates[0], err = c.loadClientCertificate(); err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+	}
+
+	if len(c.CipherSuites) == 0 {
+		return result, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[suite.Name] = suite.ID
+	}
+
+	for _, name := range c.CipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+
+		result.CipherSuites = append(result.CipherSuites, id)
+	}
+
+	return result, nil
+}
+
+func (c *TLSConfig) loadClientCertificate() (tls.Certificate, error) {
+	if c.ClientCert == "" || c.ClientKey == "" {
+		return tls.Certificate{}, errors.New("both client_cert and client_key have to be set")
+	}
+
+	cert, err := readPEM(c.ClientCert)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	key, err := readPEM(c.ClientKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.X509KeyPair(cert, key)
+}
+
+// readPEM returns the value itself if it's pem encoded, otherwise it's read from the file it points to
+func readPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+
+	return templates.ReadFile(value)
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+
+	if v, ok := tlsVersions[version]; ok {
+		return v, nil
+	}
+
+	return 0, fmt.Errorf("unknown tls version %q, expected one of [1.0, 1.1, 1.2, 1.3]", version)
+}
@@ -0,0 +1,346 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// Statuses of the jobs reported by the admin api
+const (
+	jobStatusRunning  = "running"
+	jobStatusPaused   = "paused"
+	jobStatusStopped  = "stopped"  // cancelled with the admin api
+	jobStatusFinished = "finished" // all the instances have returned
+)
+
+// jobControl lets the admin api pause, resume and stop a job and tracks the progress of its iterations
+type jobControl struct {
+	mu      sync.Mutex
+	resumed chan struct{} // nil while the job isn't paused, closed on resume
+
+	stopped  int32 // atomic
+	running  int32 // atomic, number of instances that haven't returned yet
+	progress utils.Progress
+}
+
+const jobControlContextKey = "job_control"
+
+func withJobControl(ctx context.Context, control *jobControl) context.Context {
+	return context.WithValue(ctx, templates.ContextKey(jobControlContextKey), control)
+}
+
+// getJobControl returns nil if the job isn't controlled by the runner
+func getJobControl(ctx context.Context) *jobControl {
+	control, _ := ctx.Value(templates.ContextKey(jobControlContextKey)).(*jobControl)
+
+	return control
+}
+
+// wait blocks while the job is paused, returns false if the job has to stop
+func (c *jobControl) wait(ctx context.Context, stop <-chan struct{}) bool {
+	if c == nil {
+		return true
+	}
+
+	c.mu.Lock()
+	resumed := c.resumed
+	c.mu.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return true
+	case <-stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *jobControl) iterate() {
+	if c != nil {
+		c.progress.Done()
+	}
+}
+
+// countIterations adds the iterations of a job instance to the total, count that isn't positive means they aren't limited
+func (c *jobControl) countIterations(count int) {
+	if c != nil {
+		c.progress.AddTotal(count)
+	}
+}
+
+// trackInstance counts the job instance as running until the returned func is called
+func (c *jobControl) trackInstance() (done func()) {
+	atomic.AddInt32(&c.running, 1)
+
+	return func() { atomic.AddInt32(&c.running, -1) }
+}
+
+func (c *jobControl) pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+	}
+}
+
+func (c *jobControl) resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+func (c *jobControl) status() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case atomic.LoadInt32(&c.stopped) != 0:
+		return jobStatusStopped
+	case atomic.LoadInt32(&c.running) == 0:
+		return jobStatusFinished
+	case c.resumed != nil:
+		return jobStatusPaused
+	default:
+		return jobStatusRunning
+	}
+}
+
+// jobInfo is the representation of a job in the admin api, name and type of encrypted jobs are not disclosed
+type jobInfo struct {
+	ID               string                 `json:"id"`
+	Name             string                 `json:"name,omitempty"`
+	Type             string                 `json:"type,omitempty"`
+	Encrypted        bool                   `json:"encrypted"`
+	Status           string                 `json:"status"`
+	Instances        int                    `json:"instances"`
+	RunningInstances int32                  `json:"running_instances"`
+	Iterations       uint64                 `json:"iterations"`
+	Progress         utils.ProgressSnapshot `json:"progress"`
+	Started          time.Time              `json:"started"`
+}
+
+// proxyInfo is the representation of a proxy in the admin api leaderboard
+type proxyInfo struct {
+	Proxy         string  `json:"proxy"`
+	Success       uint64  `json:"success"`
+	Fail          uint64  `json:"fail"`
+	SuccessRate   float64 `json:"success_rate"`
+	P50Ms         float64 `json:"p50_ms"`
+	P90Ms         float64 `json:"p90_ms"`
+	P99Ms         float64 `json:"p99_ms"`
+	BytesSent     uint64  `json:"bytes_sent"`
+	BytesReceived uint64  `json:"bytes_received"`
+}
+
+func proxyInfos(summaries []metrics.ProxySummary) []proxyInfo {
+	milliseconds := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	infos := make([]proxyInfo, 0, len(summaries))
+	for _, s := range summaries {
+		infos = append(infos, proxyInfo{
+			Proxy: s.Proxy, Success: s.Success, Fail: s.Fail, SuccessRate: s.SuccessRate,
+			P50Ms: milliseconds(s.P50), P90Ms: milliseconds(s.P90), P99Ms: milliseconds(s.P99),
+			BytesSent: s.BytesSent, BytesReceived: s.BytesReceived,
+		})
+	}
+
+	return infos
+}
+
+func (job runningJob) info() jobInfo {
+	info := jobInfo{
+		ID:               job.id,
+		Encrypted:        job.encrypted,
+		Status:           job.control.status(),
+		Instances:        job.instances,
+		RunningInstances: atomic.LoadInt32(&job.control.running),
+		Progress:         job.control.progress.Snapshot(),
+		Started:          job.started,
+	}
+
+	info.Iterations = info.Progress.Completed
+
+	if !job.encrypted {
+		info.Name, info.Type = job.cfg.Name, job.cfg.Type
+	}
+
+	return info
+}
+
+// jobInfos returns the info of the currently applied jobs sorted by id
+func (r *Runner) jobInfos() []jobInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]jobInfo, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		infos = append(infos, job.info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		a, _ := strconv.Atoi(infos[i].ID)
+		b, _ := strconv.Atoi(infos[j].ID)
+
+		return a < b
+	})
+
+	return infos
+}
+
+var errJobNotFound = errors.New("job not found")
+
+// controlJob applies the action to the job with the given id and returns its updated info
+func (r *Runner) controlJob(id, action string) (jobInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range r.jobs {
+		if job.id != id {
+			continue
+		}
+
+		switch action {
+		case "pause":
+			job.control.pause()
+		case "resume":
+			job.control.resume()
+		case "stop":
+			atomic.StoreInt32(&job.control.stopped, 1)
+			job.cancel()
+		}
+
+		return job.info(), nil
+	}
+
+	return jobInfo{}, errJobNotFound
+}
+
+// adminHandler serves GET /jobs, POST /jobs/{id}/pause|resume|stop and GET /proxies
+func (r *Runner) adminHandler(logger *zap.Logger) nethttp.Handler {
+	writeJSON := func(w nethttp.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			logger.Debug("error writing admin api response", zap.Error(err))
+		}
+	}
+
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("/jobs", func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		if req.Method != nethttp.MethodGet {
+			nethttp.Error(w, "method not allowed", nethttp.StatusMethodNotAllowed)
+
+			return
+		}
+
+		writeJSON(w, r.jobInfos())
+	})
+	mux.HandleFunc("/proxies", func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		if req.Method != nethttp.MethodGet {
+			nethttp.Error(w, "method not allowed", nethttp.StatusMethodNotAllowed)
+
+			return
+		}
+
+		writeJSON(w, proxyInfos(metrics.Default.ProxyLeaderboard()))
+	})
+	mux.HandleFunc("/jobs/", func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		const pathParts = 2
+
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/jobs/"), "/")
+		if len(parts) != pathParts || (parts[1] != "pause" && parts[1] != "resume" && parts[1] != "stop") {
+			nethttp.NotFound(w, req)
+
+			return
+		}
+
+		if req.Method != nethttp.MethodPost {
+			nethttp.Error(w, "method not allowed", nethttp.StatusMethodNotAllowed)
+
+			return
+		}
+
+		info, err := r.controlJob(parts[0], parts[1])
+		if err != nil {
+			nethttp.Error(w, err.Error(), nethttp.StatusNotFound)
+
+			return
+		}
+
+		logger.Info("job controlled via admin api", zap.String("id", info.ID), zap.String("action", parts[1]))
+		writeJSON(w, info)
+	})
+
+	return mux
+}
+
+// serveAdmin starts the admin api server on the configured address, the returned func shuts it down
+func (r *Runner) serveAdmin(logger *zap.Logger) (shutdown func()) {
+	listener, err := net.Listen("tcp", r.globalJobsCfg.AdminAddr)
+	if err != nil {
+		logger.Warn("error starting admin api", zap.Error(err))
+
+		return func() {}
+	}
+
+	const readHeaderTimeout = 10 * time.Second
+
+	server := &nethttp.Server{Handler: r.adminHandler(logger), ReadHeaderTimeout: readHeaderTimeout}
+
+	logger.Info("admin api is listening", zap.Stringer("address", listener.Addr()))
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+			logger.Warn("admin api server failed", zap.Error(err))
+		}
+	}()
+
+	return func() { server.Close() }
+}
@@ -0,0 +1,241 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/job/config"
+)
+
+func adminRequest(t *testing.T, method, url string, wantStatus int, result interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%v %v: expected status %d, got %d", method, url, wantStatus, resp.StatusCode)
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(target.Close)
+
+	runner, err := NewRunner(&ConfigOptions{}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{
+		{Name: "flood", Type: "http", Args: config.Args{"request": map[string]interface{}{"path": target.URL}, "interval_ms": 5}},
+		{Name: "hello", Type: "log", Args: config.Args{"text": "hello"}},
+	}}, false)
+
+	admin := httptest.NewServer(runner.adminHandler(zap.NewNop()))
+	t.Cleanup(admin.Close)
+
+	jobs := func() map[string]jobInfo {
+		var infos []jobInfo
+
+		adminRequest(t, http.MethodGet, admin.URL+"/jobs", http.StatusOK, &infos)
+
+		result := make(map[string]jobInfo, len(infos))
+		for _, info := range infos {
+			result[info.Name] = info
+		}
+
+		return result
+	}
+
+	waitFor(t, func() bool { return jobs()["hello"].Status == jobStatusFinished })
+
+	flood := jobs()["flood"]
+	if flood.ID == "" || flood.Type != "http" || flood.Status != jobStatusRunning || flood.Instances != 1 || flood.RunningInstances != 1 {
+		t.Fatalf("unexpected job info %+v", flood)
+	}
+
+	waitFor(t, func() bool { return jobs()["flood"].Iterations > 0 })
+
+	var info jobInfo
+
+	adminRequest(t, http.MethodPost, admin.URL+"/jobs/"+flood.ID+"/pause", http.StatusOK, &info)
+
+	if info.Status != jobStatusPaused {
+		t.Errorf("expected the job to be paused, got %v", info.Status)
+	}
+
+	// at most the iteration that has already passed the gate can still go through
+	time.Sleep(50 * time.Millisecond)
+
+	paused := atomic.LoadInt32(&requests)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&requests); got != paused {
+		t.Errorf("expected no requests while paused, got %d more", got-paused)
+	}
+
+	for _, job := range runner.runningJobs() {
+		if job.id == flood.ID && job.ctx.Err() != nil {
+			t.Error("pausing shouldn't cancel the job context")
+		}
+	}
+
+	adminRequest(t, http.MethodPost, admin.URL+"/jobs/"+flood.ID+"/resume", http.StatusOK, &info)
+
+	if info.Status != jobStatusRunning {
+		t.Errorf("expected the job to be running, got %v", info.Status)
+	}
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&requests) > paused })
+
+	adminRequest(t, http.MethodPost, admin.URL+"/jobs/"+flood.ID+"/stop", http.StatusOK, &info)
+
+	if info.Status != jobStatusStopped {
+		t.Errorf("expected the job to be stopped, got %v", info.Status)
+	}
+
+	waitFor(t, func() bool { return jobs()["flood"].RunningInstances == 0 })
+
+	for _, job := range runner.runningJobs() {
+		if job.id == flood.ID && job.ctx.Err() == nil {
+			t.Error("stopping should cancel the job context")
+		}
+	}
+
+	adminRequest(t, http.MethodGet, admin.URL+"/jobs/"+flood.ID+"/pause", http.StatusMethodNotAllowed, nil)
+	adminRequest(t, http.MethodPost, admin.URL+"/jobs", http.StatusMethodNotAllowed, nil)
+	adminRequest(t, http.MethodPost, admin.URL+"/jobs/100/stop", http.StatusNotFound, nil)
+	adminRequest(t, http.MethodPost, admin.URL+"/jobs/"+flood.ID+"/kill", http.StatusNotFound, nil)
+}
+
+func TestAdminAPIEncrypted(t *testing.T) {
+	t.Parallel()
+
+	runner, err := NewRunner(&ConfigOptions{}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{
+		{Name: "secret", Type: "log", Args: config.Args{"text": "hello"}},
+	}}, true)
+
+	infos := runner.jobInfos()
+	if len(infos) != 1 || !infos[0].Encrypted || infos[0].Name != "" || infos[0].Type != "" {
+		t.Errorf("expected the name and type of encrypted jobs to be hidden, got %+v", infos)
+	}
+}
+
+func TestJobControlWait(t *testing.T) {
+	t.Parallel()
+
+	var control *jobControl
+	if !control.wait(context.Background(), nil) {
+		t.Error("jobs without control shouldn't block")
+	}
+
+	control = &jobControl{}
+	control.pause()
+
+	resumed := make(chan bool, 1)
+
+	go func() { resumed <- control.wait(context.Background(), nil) }()
+
+	select {
+	case <-resumed:
+		t.Fatal("paused job shouldn't pass the gate")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	control.resume()
+
+	if !<-resumed {
+		t.Error("resumed job should pass the gate")
+	}
+
+	control.pause()
+
+	stop := make(chan struct{})
+	close(stop)
+
+	if control.wait(context.Background(), stop) {
+		t.Error("stopped job shouldn't pass the gate")
+	}
+}
+
+func TestJobProgress(t *testing.T) {
+	t.Parallel()
+
+	const count = 40
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(target.Close)
+
+	runner, err := NewRunner(&ConfigOptions{}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{
+		{Name: "counted", Type: "http", Args: config.Args{"request": map[string]interface{}{"path": target.URL}, "interval_ms": 10, "count": count}},
+		{Name: "endless", Type: "http", Args: config.Args{"request": map[string]interface{}{"path": target.URL}, "interval_ms": 10}},
+	}}, false)
+	t.Cleanup(func() { runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{}, false) })
+
+	infos := func() map[string]jobInfo {
+		result := make(map[string]jobInfo)
+		for _, info := range runner.jobInfos() {
+			result[info.Name] = info
+		}
+
+		return result
+	}
+
+	waitFor(t, func() bool {
+		return infos()["counted"].Progress.Completed > 0 && infos()["endless"].Progress.Completed > 0
+	})
+
+	started := infos()["counted"].Progress
+	if started.Total != count || started.ETA == nil || *started.ETA <= 0 {
+		t.Errorf("expected a finite eta for the counted job, got %+v", started)
+	}
+
+	if endless := infos()["endless"].Progress; endless.Total != 0 || endless.ETA != nil {
+		t.Errorf("expected the endless job to report the rate only, got %+v", endless)
+	}
+
+	waitFor(t, func() bool { return infos()["counted"].Status == jobStatusFinished })
+
+	if done := infos()["counted"].Progress; done.Completed != count || done.Completed <= started.Completed || done.ETA == nil || *done.ETA != 0 {
+		t.Errorf("expected the progress to advance to the total, got %+v", done)
+	}
+}
@@ -26,6 +26,7 @@ package job
 import (
 	"context"
 	"flag"
+	"math/rand"
 	"time"
 
 	"github.com/google/uuid"
@@ -33,6 +34,7 @@ import (
 
 	"github.com/Arriven/db1000n/src/job/config"
 	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
 )
 
 // GlobalConfig passes commandline arguments to every job.
@@ -45,8 +47,30 @@ type GlobalConfig struct {
 	ScaleFactor         int
 	MinInterval         time.Duration
 	Backoff             utils.BackoffConfig
+	FilesDir            string
+	ShutdownGracePeriod time.Duration
+	OTLPEndpoint        string
+
+	MaxConcurrentPerHost int
+	ConfigPublicKey      string
+	RandomSeed           int64  // seeds random template functions when not zero, see templates.SetRandomSeed
+	AdminAddr            string // address of the admin api to control the jobs at runtime, disabled if empty
+	CoordinationBackend  string // redis address to share rate limits with the other instances, limits are local if empty
+	TemplateEnvPrefixes  string // comma-separated prefixes of the variables env template function can read, any variable if empty
+	LogFormat            string // "json" or "console", depends on the debug mode if empty, see NewLoggerConfig
+	LogLevel             string // minimum level of the logs, depends on the debug mode if empty
+	DryRun               bool   // validate the config and print the first requests instead of running the jobs, see Runner.DryRun
+
+	ResponsesDir string // directory http-request jobs are allowed to save response bodies to, see parseResponseSink
+
+	ReloadInterval time.Duration // how often the runner polls the config and restarts the changed jobs, refresh interval if not positive
+
+	AlertRules    string        // path to the file with the metric thresholds to call webhooks on, see metrics.LoadAlertRules
+	AlertInterval time.Duration // between the evaluations of the alert rules
 }
 
+const defaultShutdownGracePeriod = 5 * time.Second
+
 // NewGlobalConfigWithFlags returns a GlobalConfig initialized with command line flags.
 func NewGlobalConfigWithFlags() *GlobalConfig {
 	res := GlobalConfig{
@@ -63,6 +87,38 @@ func NewGlobalConfigWithFlags() *GlobalConfig {
 		"used to scale the amount of jobs being launched, effect is similar to launching multiple instances at once")
 	flag.DurationVar(&res.MinInterval, "min-interval", utils.GetEnvDurationDefault("MIN_INTERVAL", 0),
 		"minimum interval between job iterations")
+	flag.DurationVar(&res.ShutdownGracePeriod, "shutdown-grace-period", utils.GetEnvDurationDefault("SHUTDOWN_GRACE_PERIOD", defaultShutdownGracePeriod),
+		"how long to wait for in-flight requests to finish on shutdown before cancelling them")
+	flag.StringVar(&res.FilesDir, "files-dir", utils.GetEnvStringDefault("FILES_DIR", ""),
+		"directory to allow reading files from in templates (file and file_base64 functions are disabled if empty)")
+	flag.StringVar(&res.ConfigPublicKey, "config-public-key", utils.GetEnvStringDefault("CONFIG_PUBLIC_KEY", ""),
+		"base64 encoded ed25519 public key, fetched configs that aren't signed with the matching private key are rejected (not checked if empty)")
+	flag.IntVar(&res.MaxConcurrentPerHost, "max-concurrent-per-host", utils.GetEnvIntDefault("MAX_CONCURRENT_PER_HOST", 0),
+		"maximum amount of http requests in flight to a single host across all jobs (unlimited if not positive)")
+	flag.Int64Var(&res.RandomSeed, "random-seed", int64(utils.GetEnvIntDefault("RANDOM_SEED", 0)),
+		"seed random template functions to replay the same sequence of values, i.e. to reproduce a run (seeded from the current time if 0)")
+	flag.StringVar(&res.AdminAddr, "admin-addr", utils.GetEnvStringDefault("ADMIN_ADDR", ""),
+		"address to serve the admin api to list, pause, resume and stop jobs at runtime on, i.e. 127.0.0.1:8081 (disabled if empty)")
+	flag.StringVar(&res.CoordinationBackend, "coordination-backend", utils.GetEnvStringDefault("COORDINATION_BACKEND", ""),
+		"redis address (host:port or redis:// url) to share shared_rate_limit of http jobs with the other instances (limited locally if empty)")
+	flag.StringVar(&res.TemplateEnvPrefixes, "template-env-prefixes", utils.GetEnvStringDefault("TEMPLATE_ENV_PREFIXES", ""),
+		"comma-separated prefixes of the environment variables env template function is allowed to read, i.e. DB1000N_ (any variable if empty)")
+	flag.StringVar(&res.LogFormat, "log-format", utils.GetEnvStringDefault("LOG_FORMAT", ""),
+		"format of the logs, json or console (json unless debug is enabled if empty)")
+	flag.StringVar(&res.LogLevel, "log-level", utils.GetEnvStringDefault("LOG_LEVEL", ""),
+		"minimum level of the logs: debug, info, warn, or error (info unless debug is enabled if empty)")
+	flag.BoolVar(&res.DryRun, "dry-run", utils.GetEnvBoolDefault("DRY_RUN", false),
+		"validate the config and print the first request of every http job without sending anything, then exit")
+	flag.StringVar(&res.ResponsesDir, "responses-dir", utils.GetEnvStringDefault("RESPONSES_DIR", ""),
+		"directory to allow saving response bodies of http-request jobs to, response_sink directories are relative to it (file sink is disabled if empty)")
+	flag.DurationVar(&res.ReloadInterval, "reload-interval", utils.GetEnvDurationDefault("RELOAD_INTERVAL", 0),
+		"how often to poll the config and restart only the jobs that changed in it (same as -refresh-interval if 0)")
+	flag.StringVar(&res.AlertRules, "alert-rules", utils.GetEnvStringDefault("ALERT_RULES", ""),
+		"path to the yaml or json file with the metric thresholds to call webhooks on (alerting is disabled if empty)")
+	flag.DurationVar(&res.AlertInterval, "alert-interval", utils.GetEnvDurationDefault("ALERT_INTERVAL", metrics.DefaultAlertInterval),
+		"how often to evaluate the alert rules, rates are calculated over this interval")
+	flag.StringVar(&res.OTLPEndpoint, "otlp-endpoint", utils.GetEnvStringDefault("OTLP_ENDPOINT", ""),
+		"export request traces to the OpenTelemetry collector http endpoint, i.e. http://localhost:4318 (tracing is disabled if empty)")
 
 	flag.IntVar(&res.Backoff.Limit, "backoff-limit", utils.GetEnvIntDefault("BACKOFF_LIMIT", utils.DefaultBackoffConfig().Limit),
 		"how much exponential backoff can be scaled")
@@ -70,6 +126,12 @@ func NewGlobalConfigWithFlags() *GlobalConfig {
 		"how much exponential backoff is scaled with each new error")
 	flag.DurationVar(&res.Backoff.Timeout, "backoff-timeout", utils.GetEnvDurationDefault("BACKOFF_TIMEOUT", utils.DefaultBackoffConfig().Timeout),
 		"initial exponential backoff timeout")
+	flag.StringVar(&res.Backoff.Jitter, "backoff-jitter", utils.GetEnvStringDefault("BACKOFF_JITTER", utils.DefaultBackoffConfig().Jitter),
+		"randomize backoff timeouts to avoid synchronized retries, can be full or equal (disabled if empty)")
+	flag.DurationVar(&res.Backoff.MaxTimeout, "backoff-max-timeout", utils.GetEnvDurationDefault("BACKOFF_MAX_TIMEOUT", 0),
+		"maximum exponential backoff timeout (no limit if 0)")
+	flag.IntVar(&res.Backoff.ResetThreshold, "backoff-reset-threshold", utils.GetEnvIntDefault("BACKOFF_RESET_THRESHOLD", 1),
+		"how many consecutive successes reset exponential backoff")
 
 	return &res
 }
@@ -85,12 +147,32 @@ func Get(t string) Job {
 		return fastHTTPJob
 	case "http-request":
 		return singleRequestJob
+	case "har":
+		return harJob
+	case "throughput":
+		return throughputJob
 	case "tcp":
 		return tcpJob
 	case "udp":
 		return udpJob
 	case "slow-loris":
 		return slowLorisJob
+	case "slow-headers":
+		return slowHeadersJob
+	case "websocket":
+		return websocketJob
+	case "mqtt":
+		return mqttJob
+	case "smtp":
+		return smtpJob
+	case "grpc":
+		return grpcJob
+	case "ntp":
+		return ntpJob
+	case "icmp":
+		return icmpJob
+	case "tls-handshake":
+		return tlsHandshakeJob
 	case "packetgen":
 		return packetgenJob
 	case "dns-blast":
@@ -116,6 +198,7 @@ func Get(t string) Job {
 
 type Config interface {
 	FromGlobal(GlobalConfig)
+	Init() error
 }
 
 func ParseConfig(c Config, args config.Args, global GlobalConfig) error {
@@ -125,15 +208,38 @@ func ParseConfig(c Config, args config.Args, global GlobalConfig) error {
 
 	c.FromGlobal(global)
 
-	return nil
+	return c.Init()
 }
 
 // BasicJobConfig comment for linter
 type BasicJobConfig struct {
 	IntervalMs int            `mapstructure:"interval_ms,omitempty"`
 	Interval   *time.Duration `mapstructure:"interval"`
+	Schedule   string         `mapstructure:"schedule"` // cron expression, the job only runs during the minutes it matches
 	utils.Counter
 	*utils.BackoffConfig
+
+	MaxBytes uint64 `mapstructure:"max_bytes"` // the job stops once it has sent this many bytes, not limited if zero
+
+	Delay       time.Duration `mapstructure:"delay"`        // waited before every iteration on top of the interval
+	DelayJitter time.Duration `mapstructure:"delay_jitter"` // the delay is picked uniformly from [delay - jitter, delay + jitter]
+
+	Labels map[string]string `mapstructure:"labels"` // tag the logs and the metrics of the job, see parseJobLabels
+
+	schedule *utils.Schedule
+	started  bool // the count has been added to the progress of the job
+	traffic  *metrics.Writer
+}
+
+// parseJobLabels reads the labels before the job parses its config so that the runner can attach them to everything the job emits,
+// invalid configs are reported by the job itself
+func parseJobLabels(args config.Args) map[string]string {
+	var c BasicJobConfig
+	if err := utils.Decode(args, &c); err != nil {
+		return nil
+	}
+
+	return c.Labels
 }
 
 func (c *BasicJobConfig) FromGlobal(global GlobalConfig) {
@@ -146,11 +252,83 @@ func (c *BasicJobConfig) FromGlobal(global GlobalConfig) {
 	}
 }
 
-func (c BasicJobConfig) GetInterval() time.Duration {
+// Init parses the schedule
+func (c *BasicJobConfig) Init() (err error) {
+	c.schedule, err = utils.ParseSchedule(c.Schedule)
+
+	return err
+}
+
+func (c *BasicJobConfig) GetInterval() time.Duration {
 	return utils.NonNilDurationOrDefault(c.Interval, time.Duration(c.IntervalMs)*time.Millisecond)
 }
 
+// newTrafficMonitor returns the writer for the bytes sent by the job instance, Next stops once they reach MaxBytes
+func (c *BasicJobConfig) newTrafficMonitor() *metrics.Writer {
+	c.traffic = metrics.Default.NewWriter(metrics.Traffic, uuid.NewString())
+
+	return c.traffic
+}
+
+// nextDelay returns the delay before the next iteration randomized with the jitter, it's never negative
+func (c *BasicJobConfig) nextDelay() time.Duration {
+	delay := c.Delay
+	if c.DelayJitter > 0 {
+		delay += time.Duration(rand.Int63n(2*int64(c.DelayJitter)+1)) - c.DelayJitter //nolint:gosec // Cryptographically secure random not required
+	}
+
+	if delay < 0 {
+		return 0
+	}
+
+	return delay
+}
+
 // Next comment for linter
 func (c *BasicJobConfig) Next(ctx context.Context) bool {
-	return utils.Sleep(ctx, c.GetInterval()) && c.Counter.Next()
+	return !c.exhausted(ctx) && c.wait(ctx) && c.take(ctx)
+}
+
+// exhausted tells whether the job has already sent MaxBytes, the budget is checked between iterations
+// so the job can overshoot it by a single iteration
+func (c *BasicJobConfig) exhausted(ctx context.Context) bool {
+	if !c.started {
+		c.started = true
+		getJobControl(ctx).countIterations(c.Count)
+	}
+
+	return c.MaxBytes > 0 && c.traffic != nil && c.traffic.Value() >= c.MaxBytes
+}
+
+// wait blocks for the interval and the delay of the next iteration, outside of the schedule and while the job is paused.
+// It doesn't modify the config so the goroutines sharing it can wait concurrently
+func (c *BasicJobConfig) wait(ctx context.Context) bool {
+	stop := stopChannel(ctx)
+
+	select {
+	case <-stop:
+		return false
+	case <-ctx.Done():
+		return false
+	case <-time.After(c.GetInterval() + c.nextDelay()):
+	}
+
+	// the schedule gates the job before anything else so only iterations within the window are counted
+	if !c.schedule.Wait(ctx, stop) {
+		return false
+	}
+
+	// paused jobs keep their context and in-flight state, they just don't start new iterations
+	return getJobControl(ctx).wait(ctx, stop)
+}
+
+// take counts the iteration against the count and the progress of the job
+func (c *BasicJobConfig) take(ctx context.Context) bool {
+	if !c.Counter.Next() {
+		return false
+	}
+
+	getJobControl(ctx).iterate()
+
+	return true
 }
@@ -0,0 +1,207 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	// pick a minute that is far from now so the test doesn't run into the window
+	inactive := fmt.Sprintf("%d * * * *", (time.Now().Minute()+30)%60)
+
+	testCases := []struct {
+		name     string
+		schedule string
+		want     int
+	}{
+		{name: "no schedule", want: 3},
+		{name: "active", schedule: "* * * * *", want: 3},
+		{name: "inactive", schedule: inactive, want: 0},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var jobConfig BasicJobConfig
+			if err := ParseConfig(&jobConfig, map[string]interface{}{"schedule": tc.schedule, "count": 3}, GlobalConfig{}); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			var iterations int
+
+			for jobConfig.Next(ctx) {
+				iterations++
+			}
+
+			if iterations != tc.want {
+				t.Errorf("expected %d iterations, got %d", tc.want, iterations)
+			}
+		})
+	}
+}
+
+func TestInvalidSchedule(t *testing.T) {
+	t.Parallel()
+
+	if _, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":  map[string]interface{}{"path": "http://localhost", "method": "GET"},
+		"schedule": "every minute",
+	}); err == nil {
+		t.Error("expected invalid schedule to fail the job")
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	var packets int32
+
+	go func() {
+		buf := make([]byte, 1024)
+
+		for {
+			if _, _, err := listener.ReadFrom(buf); err != nil {
+				return
+			}
+
+			atomic.AddInt32(&packets, 1)
+		}
+	}()
+
+	// 72 bytes of payload and 28 bytes of udp and ip headers make up 100 bytes per packet
+	if _, err := udpJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address":   listener.LocalAddr().String(),
+		"payload":   strings.Repeat("x", 72),
+		"max_bytes": 1000,
+	}); err != nil || ctx.Err() != nil {
+		t.Fatalf("expected the job to stop on its own, got %v (%v)", err, ctx.Err())
+	}
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&packets) >= 10 })
+
+	if got := atomic.LoadInt32(&packets); got != 10 {
+		t.Errorf("expected 10 packets within the budget, got %d", got)
+	}
+
+	const (
+		bodySize = 1000
+		budget   = 10 * bodySize
+	)
+
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":   map[string]interface{}{"path": server.URL, "method": "POST", "body": strings.Repeat("x", bodySize)},
+		"max_bytes": budget,
+	}); err != nil || ctx.Err() != nil {
+		t.Fatalf("expected the job to stop on its own, got %v (%v)", err, ctx.Err())
+	}
+
+	// requests are bigger than the body because of the headers, the last one can exceed the budget
+	if got := atomic.LoadInt32(&requests); got > budget/bodySize || got < budget/(bodySize+512) {
+		t.Errorf("expected about %d requests within the budget, got %d", budget/bodySize, got)
+	}
+}
+
+func TestDelayJitter(t *testing.T) {
+	t.Parallel()
+
+	const (
+		delay      = 40 * time.Millisecond
+		jitter     = 20 * time.Millisecond
+		iterations = 8
+		slack      = 30 * time.Millisecond // for scheduling and the request itself
+	)
+
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		times = append(times, time.Now())
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":      map[string]interface{}{"path": server.URL, "method": "GET"},
+		"count":        iterations,
+		"delay":        delay.String(),
+		"delay_jitter": jitter.String(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(times) != iterations {
+		t.Fatalf("expected %d requests, got %d", iterations, len(times))
+	}
+
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < delay-jitter || gap > delay+jitter+slack {
+			t.Errorf("expected the gap between requests within %v ± %v, got %v", delay, jitter, gap)
+		}
+	}
+
+	// the delay is never negative even if the jitter is bigger than the delay
+	config := BasicJobConfig{Delay: time.Millisecond, DelayJitter: 10 * time.Millisecond}
+	for i := 0; i < 1000; i++ {
+		if d := config.nextDelay(); d < 0 || d > 11*time.Millisecond {
+			t.Fatalf("delay %v is out of range", d)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+
+	if _, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{"path": server.URL, "method": "GET"},
+		"delay":   time.Hour.String(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected the delay to be interrupted by the context, the job took %v", elapsed)
+	}
+}
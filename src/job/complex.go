@@ -59,6 +59,7 @@ func sequenceJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalCo
 		}
 
 		ctx = context.WithValue(ctx, templates.ContextKey("data."+cfg.Name), data)
+		ctx = withJobExtracted(ctx, data)
 	}
 
 	return nil, nil
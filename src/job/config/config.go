@@ -25,6 +25,7 @@ package config
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"io"
 	"log"
@@ -47,24 +48,33 @@ type Config struct {
 	Count  int    `mapstructure:"count"`
 	Filter string `mapstructure:"filter"`
 	Args   Args   `mapstructure:"args"`
+
+	Include string `mapstructure:"include"` // name of the fragment the job is based on, the rest of the fields override the ones of the fragment
+	Params  Args   `mapstructure:"params"`  // values of [[ .name ]] placeholders in the name and args of the fragment
 }
 
 // MultiConfig for all jobs.
 type MultiConfig struct {
 	Jobs []Config
+
+	Fragments map[string]Config // reusable jobs the jobs can include, expanded by Unmarshal
 }
 
 type RawMultiConfig struct {
 	Body         []byte
 	Encrypted    bool
 	lastModified string
-	etag         string
+	etag         string // generation of the object for gs:// configs
 }
 
-// fetch tries to read a config from the list of mirrors until it succeeds
-func fetch(paths []string, lastKnownConfig *RawMultiConfig) *RawMultiConfig {
+// fetch tries to read a config from the list of mirrors until it succeeds, configs that fail signature verification are skipped
+func fetch(paths []string, lastKnownConfig *RawMultiConfig, publicKey ed25519.PublicKey) *RawMultiConfig {
 	for i := range paths {
 		config, err := fetchSingle(paths[i], lastKnownConfig)
+		if err == nil && config != lastKnownConfig {
+			config.Body, err = verifySignature(config.Body, publicKey)
+		}
+
 		if err != nil {
 			log.Printf("Failed to fetch config from %q: %v", paths[i], err)
 
@@ -97,13 +107,23 @@ func fetchSingle(path string, lastKnownConfig *RawMultiConfig) (*RawMultiConfig,
 	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
 	defer cancel()
 
+	if store, ok := objectStores[configURL.Scheme]; ok {
+		return fetchObject(ctx, store, configURL, lastKnownConfig)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, configURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("If-None-Match", lastKnownConfig.etag)
-	req.Header.Add("If-Modified-Since", lastKnownConfig.lastModified)
+	// Let the server answer with 304 if the config hasn't changed since the last fetch
+	if lastKnownConfig.etag != "" {
+		req.Header.Set("If-None-Match", lastKnownConfig.etag)
+	}
+
+	if lastKnownConfig.lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastKnownConfig.lastModified)
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -132,8 +152,10 @@ func fetchSingle(path string, lastKnownConfig *RawMultiConfig) (*RawMultiConfig,
 }
 
 // FetchRawMultiConfig retrieves the current config using a list of paths. Falls back to the last known config in case of errors.
-func FetchRawMultiConfig(paths []string, lastKnownConfig *RawMultiConfig) *RawMultiConfig {
-	newConfig := fetch(paths, lastKnownConfig)
+// The last known config is returned as is when the server reports it as not modified so callers can skip parsing it again.
+// Fetched configs have to be signed with the private key matching publicKey unless it's nil, see Sign
+func FetchRawMultiConfig(paths []string, lastKnownConfig *RawMultiConfig, publicKey ed25519.PublicKey) *RawMultiConfig {
+	newConfig := fetch(paths, lastKnownConfig, publicKey)
 
 	if utils.IsEncrypted(newConfig.Body) {
 		decryptedConfig, err := utils.Decrypt(newConfig.Body)
@@ -164,5 +186,11 @@ func Unmarshal(body []byte, format string) *MultiConfig {
 		return nil
 	}
 
+	if err := config.expandIncludes(); err != nil {
+		log.Printf("Can't expand config includes: %v", err)
+
+		return nil
+	}
+
 	return &config
 }
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchConditional(t *testing.T) {
+	t.Parallel()
+
+	const (
+		etag = `"v1"`
+		body = `{"jobs":[{"type":"log","args":{"text":"test"}}]}`
+	)
+
+	var requests, notModified int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every second request is answered with a fresh copy regardless of the validators
+		if atomic.AddInt32(&requests, 1)%2 == 1 || r.Header.Get("If-None-Match") != etag {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+			_, _ = w.Write([]byte(body))
+
+			return
+		}
+
+		if r.Header.Get("If-Modified-Since") == "" {
+			t.Error("If-Modified-Since header is missing")
+		}
+
+		atomic.AddInt32(&notModified, 1)
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	const fetches = 6
+
+	var parsed int
+
+	lastKnownConfig := &RawMultiConfig{}
+
+	for i := 0; i < fetches; i++ {
+		rawConfig := FetchRawMultiConfig([]string{server.URL}, lastKnownConfig, nil)
+		if rawConfig == lastKnownConfig {
+			continue
+		}
+
+		if string(rawConfig.Body) != body {
+			t.Fatalf("unexpected config body %q", rawConfig.Body)
+		}
+
+		if Unmarshal(rawConfig.Body, "json") == nil {
+			t.Fatal("failed to parse config")
+		}
+
+		parsed++
+		lastKnownConfig = rawConfig
+	}
+
+	if parsed != fetches/2 || int(notModified) != fetches/2 {
+		t.Errorf("expected %d parses and %d not modified responses, got %d and %d", fetches/2, fetches/2, parsed, notModified)
+	}
+}
+
+func TestFetchNoValidators(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Header["If-None-Match"]; ok {
+			t.Error("unexpected If-None-Match header")
+		}
+
+		if _, ok := r.Header["If-Modified-Since"]; ok {
+			t.Error("unexpected If-Modified-Since header")
+		}
+
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	if rawConfig := FetchRawMultiConfig([]string{server.URL}, &RawMultiConfig{}, nil); string(rawConfig.Body) != `{}` {
+		t.Errorf("unexpected config body %q", rawConfig.Body)
+	}
+}
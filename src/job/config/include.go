@@ -0,0 +1,192 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// fragment params use their own delimiters so that they don't clash with the templates executed by the jobs at runtime
+const (
+	paramsLeftDelim  = "[["
+	paramsRightDelim = "]]"
+)
+
+// expandIncludes replaces the jobs that include fragments with the concrete jobs, see Config.Include
+func (c *MultiConfig) expandIncludes() error {
+	for i, job := range c.Jobs {
+		if job.Include == "" {
+			continue
+		}
+
+		expanded, params, err := c.resolve(job, nil)
+		if err != nil {
+			return fmt.Errorf("error expanding job %d: %w", i, err)
+		}
+
+		if expanded.Name, err = expandParams(expanded.Name, params); err != nil {
+			return fmt.Errorf("error expanding job %d: %w", i, err)
+		}
+
+		args, err := expandParamsValue(expanded.Args, params)
+		if err != nil {
+			return fmt.Errorf("error expanding job %q: %w", expanded.Name, err)
+		}
+
+		expanded.Args, _ = args.(Args)
+		c.Jobs[i] = expanded
+	}
+
+	return nil
+}
+
+// resolve merges the job with the fragments it includes, the fields and params of the including job take precedence.
+// stack holds the names of the fragments being resolved to detect circular includes
+func (c *MultiConfig) resolve(job Config, stack []string) (Config, Args, error) {
+	if job.Include == "" {
+		return job, job.Params, nil
+	}
+
+	for _, name := range stack {
+		if name == job.Include {
+			return Config{}, nil, fmt.Errorf("circular include %v", strings.Join(append(stack, job.Include), " -> "))
+		}
+	}
+
+	fragment, ok := c.Fragments[job.Include]
+	if !ok {
+		return Config{}, nil, fmt.Errorf("unknown fragment %q", job.Include)
+	}
+
+	base, params, err := c.resolve(fragment, append(stack, job.Include))
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	result := Config{
+		Name:   nonEmptyOrDefault(job.Name, base.Name),
+		Type:   nonEmptyOrDefault(job.Type, base.Type),
+		Count:  base.Count,
+		Filter: nonEmptyOrDefault(job.Filter, base.Filter),
+		Args:   mergeArgs(base.Args, job.Args),
+	}
+
+	if job.Count != 0 {
+		result.Count = job.Count
+	}
+
+	return result, mergeArgs(params, job.Params), nil
+}
+
+// mergeArgs returns a deep copy of base with the values from override, nested objects are merged key by key
+func mergeArgs(base, override Args) Args {
+	if base == nil && override == nil {
+		return nil
+	}
+
+	result := make(Args, len(base)+len(override))
+
+	for key, value := range base {
+		if nested, ok := value.(Args); ok {
+			value = mergeArgs(nested, nil)
+		}
+
+		result[key] = value
+	}
+
+	for key, value := range override {
+		baseNested, baseOK := result[key].(Args)
+		nested, ok := value.(Args)
+
+		if baseOK && ok {
+			value = mergeArgs(baseNested, nested)
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
+
+// expandParamsValue renders [[ .name ]] placeholders in all the strings of the value
+func expandParamsValue(value interface{}, params Args) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return expandParams(v, params)
+	case Args:
+		result := make(Args, len(v))
+
+		for key, nested := range v {
+			expanded, err := expandParamsValue(nested, params)
+			if err != nil {
+				return nil, err
+			}
+
+			result[key] = expanded
+		}
+
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+
+		for i, nested := range v {
+			expanded, err := expandParamsValue(nested, params)
+			if err != nil {
+				return nil, err
+			}
+
+			result[i] = expanded
+		}
+
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+func expandParams(input string, params Args) (string, error) {
+	if !strings.Contains(input, paramsLeftDelim) {
+		return input, nil
+	}
+
+	tpl, err := template.New("params").Delims(paramsLeftDelim, paramsRightDelim).Option("missingkey=error").Parse(input)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	if err := tpl.Execute(&output, params); err != nil {
+		return "", err
+	}
+
+	return output.String(), nil
+}
+
+func nonEmptyOrDefault(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+
+	return defaultValue
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	t.Parallel()
+
+	const body = `
+fragments:
+  flood:
+    type: http
+    count: 2
+    params:
+      method: GET
+    args:
+      interval_ms: 100
+      request:
+        method: "[[ .method ]]"
+        path: "https://[[ .host ]]/{{ random_path_segment }}"
+        headers:
+          User-Agent: "{{ random_user_agent }}"
+  login:
+    include: flood
+    params:
+      method: POST
+    args:
+      request:
+        path: "https://[[ .host ]]/login"
+jobs:
+  - name: "flood-[[ .host ]]"
+    include: flood
+    params:
+      host: a.example.com
+  - include: login
+    count: 1
+    params:
+      host: b.example.com
+    args:
+      interval_ms: 50
+  - type: log
+    args:
+      text: "[[ not a param ]]"
+`
+
+	cfg := Unmarshal([]byte(body), "yaml")
+	if cfg == nil {
+		t.Fatal("failed to parse config")
+	}
+
+	expected := []Config{
+		{Name: "flood-a.example.com", Type: "http", Count: 2, Args: Args{
+			"interval_ms": 100,
+			"request": Args{
+				"method":  "GET",
+				"path":    "https://a.example.com/{{ random_path_segment }}",
+				"headers": Args{"User-Agent": "{{ random_user_agent }}"},
+			},
+		}},
+		{Type: "http", Count: 1, Args: Args{
+			"interval_ms": 50,
+			"request": Args{
+				"method":  "POST",
+				"path":    "https://b.example.com/login",
+				"headers": Args{"User-Agent": "{{ random_user_agent }}"},
+			},
+		}},
+		{Type: "log", Args: Args{"text": "[[ not a param ]]"}},
+	}
+
+	if !reflect.DeepEqual(cfg.Jobs, expected) {
+		t.Errorf("expected jobs %+v, got %+v", expected, cfg.Jobs)
+	}
+
+	// expanding the jobs shouldn't modify the fragments shared by them
+	if path := cfg.Fragments["flood"].Args["request"].(Args)["path"]; path != "https://[[ .host ]]/{{ random_path_segment }}" {
+		t.Errorf("fragment was modified: %v", path)
+	}
+}
+
+func TestExpandIncludesErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		config  MultiConfig
+		wantErr string
+	}{
+		{
+			name: "cycle",
+			config: MultiConfig{
+				Jobs: []Config{{Include: "a"}},
+				Fragments: map[string]Config{
+					"a": {Include: "b"},
+					"b": {Include: "c"},
+					"c": {Include: "a"},
+				},
+			},
+			wantErr: "circular include a -> b -> c -> a",
+		},
+		{
+			name:    "self",
+			config:  MultiConfig{Jobs: []Config{{Include: "a"}}, Fragments: map[string]Config{"a": {Include: "a"}}},
+			wantErr: "circular include a -> a",
+		},
+		{
+			name:    "unknown fragment",
+			config:  MultiConfig{Jobs: []Config{{Include: "missing"}}},
+			wantErr: `unknown fragment "missing"`,
+		},
+		{
+			name: "missing param",
+			config: MultiConfig{
+				Jobs:      []Config{{Include: "a"}},
+				Fragments: map[string]Config{"a": {Type: "log", Args: Args{"text": "[[ .text ]]"}}},
+			},
+			wantErr: "text",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tc.config.expandIncludes(); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	if Unmarshal([]byte(`{"jobs":[{"include":"a"}],"fragments":{"a":{"include":"a"}}}`), "json") != nil {
+		t.Error("configs with circular includes should be rejected")
+	}
+}
@@ -0,0 +1,188 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"google.golang.org/api/option"
+)
+
+const defaultS3Region = "us-east-1" // used when the region isn't set in the environment or the shared config
+
+// objectStore fetches configs from the buckets of an object storage, unchanged objects aren't downloaded again
+type objectStore interface {
+	fetch(ctx context.Context, bucket, key string, lastKnownConfig *RawMultiConfig) (*RawMultiConfig, error)
+}
+
+// objectStores by the url scheme, the clients are created on the first fetch
+var objectStores = map[string]objectStore{
+	"s3": &s3Store{},
+	"gs": &gcsStore{},
+}
+
+// fetchObject reads a config from a bucket://key url of the object storage
+func fetchObject(ctx context.Context, store objectStore, configURL *url.URL, lastKnownConfig *RawMultiConfig) (*RawMultiConfig, error) {
+	key := strings.TrimPrefix(configURL.Path, "/")
+	if configURL.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid object url, expected %v://bucket/key", configURL.Scheme)
+	}
+
+	return store.fetch(ctx, configURL.Host, key, lastKnownConfig)
+}
+
+// s3Store takes the credentials and the region from the standard aws chain: environment, shared config and instance metadata
+type s3Store struct {
+	optFns []func(*s3.Options) // applied on top of the default config
+
+	mu     sync.Mutex
+	client *s3.Client
+}
+
+func (s *s3Store) getClient(ctx context.Context) (*s3.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading aws config: %w", err)
+		}
+
+		if cfg.Region == "" {
+			cfg.Region = defaultS3Region
+		}
+
+		s.client = s3.NewFromConfig(cfg, s.optFns...)
+	}
+
+	return s.client, nil
+}
+
+// fetch lets s3 answer with 304 if the etag of the object hasn't changed since the last fetch
+func (s *s3Store) fetch(ctx context.Context, bucket, key string, lastKnownConfig *RawMultiConfig) (*RawMultiConfig, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if lastKnownConfig.etag != "" {
+		input.IfNoneMatch = aws.String(lastKnownConfig.etag)
+	}
+
+	output, err := client.GetObject(ctx, input)
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotModified {
+		return lastKnownConfig, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer output.Body.Close()
+
+	res, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RawMultiConfig{Body: res, etag: aws.ToString(output.ETag)}, nil
+}
+
+// gcsStore takes the credentials from the application default credentials: environment and instance metadata,
+// public buckets can be read without any
+type gcsStore struct {
+	opts []option.ClientOption // applied on top of the default options
+
+	mu     sync.Mutex
+	client *storage.Client
+}
+
+func (s *gcsStore) getClient(ctx context.Context) (*storage.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		// the client outlives the fetch it's created for
+		client, err := storage.NewClient(context.Background(), s.opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating gcs client: %w", err)
+		}
+
+		s.client = client
+	}
+
+	return s.client, nil
+}
+
+// fetch compares the generation of the object to the one of the last fetch and only downloads new generations,
+// the generation is kept in the etag of the config
+func (s *gcsStore) fetch(ctx context.Context, bucket, key string, lastKnownConfig *RawMultiConfig) (*RawMultiConfig, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	object := client.Bucket(bucket).Object(key)
+
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	generation := strconv.FormatInt(attrs.Generation, 10)
+	if generation == lastKnownConfig.etag {
+		return lastKnownConfig, nil
+	}
+
+	// the object could have been replaced since the attrs were read
+	reader, err := object.Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	res, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RawMultiConfig{Body: res, etag: generation}, nil
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"google.golang.org/api/option"
+)
+
+// mockObject is a versioned object of a mock object store, every update bumps the version
+type mockObject struct {
+	mu        sync.Mutex
+	version   int64
+	downloads int
+}
+
+func (o *mockObject) update() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.version++
+}
+
+func (o *mockObject) current() (version int64, body string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.version, fmt.Sprintf(`{"jobs":[{"type":"log","args":{"text":"v%d"}}]}`, o.version)
+}
+
+func (o *mockObject) download() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.downloads++
+}
+
+func (o *mockObject) downloaded() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.downloads
+}
+
+// testObjectFetches fetches the config a few times updating the object in between and checks that only the updates are downloaded
+func testObjectFetches(t *testing.T, path string, object *mockObject) {
+	t.Helper()
+
+	lastKnownConfig := &RawMultiConfig{}
+
+	for update := 1; update <= 2; update++ {
+		object.update()
+
+		_, body := object.current()
+
+		rawConfig := FetchRawMultiConfig([]string{path}, lastKnownConfig, nil)
+		if rawConfig == lastKnownConfig || string(rawConfig.Body) != body {
+			t.Fatalf("expected the updated config %q, got %q", body, rawConfig.Body)
+		}
+
+		if Unmarshal(rawConfig.Body, "json") == nil {
+			t.Fatal("failed to parse config")
+		}
+
+		lastKnownConfig = rawConfig
+
+		if unchanged := FetchRawMultiConfig([]string{path}, lastKnownConfig, nil); unchanged != lastKnownConfig {
+			t.Errorf("expected the unchanged config to be reported as the last known one, got %q", unchanged.Body)
+		}
+
+		if downloads := object.downloaded(); downloads != update {
+			t.Errorf("expected %d downloads after %d updates, got %d", update, update, downloads)
+		}
+	}
+}
+
+//nolint:paralleltest // Replaces the s3 store used by all the fetches
+func TestFetchS3(t *testing.T) {
+	object := &mockObject{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/configs/db1000n/config.json" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		version, body := object.current()
+		etag := strconv.Quote(strconv.FormatInt(version, 10))
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		object.download()
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	defaultStore := objectStores["s3"]
+	defer func() { objectStores["s3"] = defaultStore }()
+
+	objectStores["s3"] = &s3Store{optFns: []func(*s3.Options){func(o *s3.Options) {
+		o.Region = defaultS3Region
+		o.Credentials = aws.AnonymousCredentials{}
+		o.EndpointResolver = s3.EndpointResolverFromURL(server.URL)
+		o.UsePathStyle = true
+	}}}
+
+	testObjectFetches(t, "s3://configs/db1000n/config.json", object)
+
+	if rawConfig := FetchRawMultiConfig([]string{"s3://configs/missing.json"}, &RawMultiConfig{}, nil); rawConfig.Body != nil {
+		t.Errorf("expected no config for a missing object, got %q", rawConfig.Body)
+	}
+}
+
+//nolint:paralleltest // Replaces the gcs store used by all the fetches
+func TestFetchGCS(t *testing.T) {
+	object := &mockObject{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, body := object.current()
+
+		switch r.URL.Path {
+		case "/storage/v1/b/configs/o/db1000n/config.json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"bucket":"configs","name":"db1000n/config.json","generation":"%d","size":"%d"}`, version, len(body))
+		case "/configs/db1000n/config.json":
+			if r.URL.Query().Get("generation") != strconv.FormatInt(version, 10) {
+				t.Errorf("expected the generation %d to be read, got %q", version, r.URL.RawQuery)
+			}
+
+			object.download()
+			_, _ = w.Write([]byte(body))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defaultStore := objectStores["gs"]
+	defer func() { objectStores["gs"] = defaultStore }()
+
+	objectStores["gs"] = &gcsStore{opts: []option.ClientOption{option.WithEndpoint(server.URL + "/storage/v1/"), option.WithoutAuthentication()}}
+
+	testObjectFetches(t, "gs://configs/db1000n/config.json", object)
+
+	if rawConfig := FetchRawMultiConfig([]string{"gs://configs"}, &RawMultiConfig{}, nil); rawConfig.Body != nil {
+		t.Errorf("expected no config for an url without the key, got %q", rawConfig.Body)
+	}
+}
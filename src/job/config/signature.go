@@ -0,0 +1,96 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// SignaturePrefix starts the first line of a signed config, it's followed by base64 encoded ed25519 signature of the rest of the config.
+// The line is a comment in yaml so signed yaml configs can still be read by clients that don't check signatures
+const SignaturePrefix = "# db1000n-signature: "
+
+var (
+	errUnsigned         = errors.New("config is not signed")
+	errInvalidSignature = errors.New("config signature is invalid")
+)
+
+// ParsePublicKey decodes base64 encoded ed25519 public key, returns nil if it's empty
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	res, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding public key: %w", err)
+	}
+
+	if len(res) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size %d, expected %d", len(res), ed25519.PublicKeySize)
+	}
+
+	return res, nil
+}
+
+// Sign prepends the signature line to the config
+func Sign(body []byte, key ed25519.PrivateKey) []byte {
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, body))
+
+	return append([]byte(SignaturePrefix+signature+"\n"), body...)
+}
+
+// verifySignature strips the signature line from the config and checks the signature if the key is set
+func verifySignature(body []byte, key ed25519.PublicKey) ([]byte, error) {
+	signed := bytes.HasPrefix(body, []byte(SignaturePrefix))
+
+	var signature []byte
+
+	if signed {
+		line := body[len(SignaturePrefix):]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line, body = line[:i], line[i+1:]
+		} else {
+			body = nil
+		}
+
+		var err error
+		if signature, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(line))); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidSignature, err)
+		}
+	}
+
+	switch {
+	case key == nil:
+		return body, nil
+	case !signed:
+		return nil, errUnsigned
+	case !ed25519.Verify(key, body, signature):
+		return nil, errInvalidSignature
+	default:
+		return body, nil
+	}
+}
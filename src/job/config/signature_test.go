@@ -0,0 +1,100 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	t.Parallel()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const body = "jobs:\n  - type: log\n    args:\n      text: test\n"
+
+	signed := Sign([]byte(body), privateKey)
+
+	tampered := append([]byte(nil), signed...)
+	tampered[len(tampered)-2] = 'x'
+
+	testCases := []struct {
+		name      string
+		config    []byte
+		publicKey ed25519.PublicKey
+		wantErr   bool
+	}{
+		{name: "valid", config: signed, publicKey: publicKey},
+		{name: "tampered", config: tampered, publicKey: publicKey, wantErr: true},
+		{name: "signed by another key", config: Sign([]byte(body), otherKey), publicKey: publicKey, wantErr: true},
+		{name: "unsigned", config: []byte(body), publicKey: publicKey, wantErr: true},
+		{name: "garbage signature", config: []byte(SignaturePrefix + "not base64\n" + body), publicKey: publicKey, wantErr: true},
+		{name: "not verified", config: signed},
+		{name: "unsigned and not verified", config: []byte(body)},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, tc.config, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			lastKnownConfig := &RawMultiConfig{Body: []byte("jobs: []\n")}
+
+			rawConfig := FetchRawMultiConfig([]string{path}, lastKnownConfig, tc.publicKey)
+			if tc.wantErr {
+				if rawConfig != lastKnownConfig {
+					t.Errorf("expected the config to be rejected, got %q", rawConfig.Body)
+				}
+
+				return
+			}
+
+			if string(rawConfig.Body) != body {
+				t.Errorf("expected config without the signature, got %q", rawConfig.Body)
+			}
+
+			if cfg := Unmarshal(rawConfig.Body, "yaml"); cfg == nil || len(cfg.Jobs) != 1 {
+				t.Errorf("failed to parse the config: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	t.Parallel()
+
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(publicKey)); err != nil || !publicKey.Equal(key) {
+		t.Errorf("failed to parse the key: %v", err)
+	}
+
+	if key, err := ParsePublicKey(""); key != nil || err != nil {
+		t.Errorf("expected no key, got %v, %v", key, err)
+	}
+
+	for _, key := range []string{"not base64", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParsePublicKey(key); err == nil {
+			t.Errorf("expected %q to be rejected", key)
+		}
+	}
+}
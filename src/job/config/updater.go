@@ -21,7 +21,7 @@ func UpdateLocal(destinationPath string, configPaths []string, backupConfig []by
 	lastKnownConfig := &RawMultiConfig{Body: backupConfig}
 
 	for {
-		if rawConfig := FetchRawMultiConfig(configPaths, lastKnownConfig); !bytes.Equal(lastKnownConfig.Body, rawConfig.Body) {
+		if rawConfig := FetchRawMultiConfig(configPaths, lastKnownConfig, nil); !bytes.Equal(lastKnownConfig.Body, rawConfig.Body) {
 			if err := writeConfig(rawConfig.Body, destinationPath); err != nil {
 				log.Printf("Error writing config: %v", err)
 
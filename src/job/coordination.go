@@ -0,0 +1,115 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+)
+
+// coordination backend clients are shared by all the jobs, they are keyed by address as configs can be reloaded
+var (
+	coordinationMu      sync.Mutex
+	coordinationClients = make(map[string]*redis.Client)
+)
+
+func coordinationClient(addr string) (*redis.Client, error) {
+	coordinationMu.Lock()
+	defer coordinationMu.Unlock()
+
+	if client, ok := coordinationClients[addr]; ok {
+		return client, nil
+	}
+
+	client, err := utils.NewCoordinationClient(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	coordinationClients[addr] = client
+
+	return client, nil
+}
+
+// newHTTPRateLimiter combines the rate limit of the job instance with the one shared by all the instances of the fleet,
+// the shared one is only enforced locally if there is no coordination backend
+func newHTTPRateLimiter(logger *zap.Logger, globalConfig *GlobalConfig, jobConfig *httpJobConfig) (utils.Limiter, error) {
+	local := utils.NewRampedRateLimiter(jobConfig.RateLimit, jobConfig.RampUp)
+	if jobConfig.SharedRateLimit <= 0 {
+		return local, nil
+	}
+
+	var shared utils.Limiter = utils.NewRateLimiter(jobConfig.SharedRateLimit)
+
+	if globalConfig.CoordinationBackend != "" {
+		client, err := coordinationClient(globalConfig.CoordinationBackend)
+		if err != nil {
+			return nil, err
+		}
+
+		key := jobConfig.SharedRateLimitKey
+		if key == "" {
+			key = targetKey(jobConfig.Request["path"])
+		}
+
+		shared = utils.NewSharedRateLimiter(client, key, jobConfig.SharedRateLimit, logger)
+	}
+
+	if local == nil {
+		return shared, nil
+	}
+
+	return combinedLimiter{local, shared}, nil
+}
+
+// targetKey returns the host of the request path template so that the jobs hitting the same target share the limit
+func targetKey(path interface{}) string {
+	raw := fmt.Sprint(path)
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return raw
+}
+
+// combinedLimiter waits for the slowest of the limiters
+type combinedLimiter []utils.Limiter
+
+func (l combinedLimiter) Reserve() time.Duration {
+	var wait time.Duration
+
+	for _, limiter := range l {
+		if w := limiter.Reserve(); w > wait {
+			wait = w
+		}
+	}
+
+	return wait
+}
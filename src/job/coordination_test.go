@@ -0,0 +1,89 @@
+package job
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+)
+
+func TestSharedRateLimit(t *testing.T) {
+	t.Parallel()
+
+	const (
+		rate     = 20
+		duration = time.Second
+	)
+
+	var requests int32
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(server.Close)
+
+	backend := miniredis.RunT(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	// two instances with the same backend share the limit
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{CoordinationBackend: backend.Addr()}, map[string]interface{}{
+				"request":           map[string]interface{}{"path": server.URL},
+				"shared_rate_limit": rate,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// the first request of the schedule is allowed right away
+	if got := atomic.LoadInt32(&requests); got < rate/2 || got > rate+1 {
+		t.Errorf("expected about %d requests across both instances, got %d", rate, got)
+	}
+}
+
+func TestSharedRateLimitFallback(t *testing.T) {
+	t.Parallel()
+
+	limiter, err := newHTTPRateLimiter(zap.NewNop(), &GlobalConfig{}, &httpJobConfig{SharedRateLimit: 4, RateLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a backend the shared limit is enforced locally together with the job one, the slowest one wins
+	limiter.Reserve()
+
+	if got := limiter.Reserve(); got < 400*time.Millisecond || got > 500*time.Millisecond {
+		t.Errorf("expected a wait of the job rate limit, got %v", got)
+	}
+
+	if limiter, err := newHTTPRateLimiter(zap.NewNop(), &GlobalConfig{}, &httpJobConfig{}); err != nil || limiter.Reserve() != 0 {
+		t.Errorf("expected no limit by default, got %v", err)
+	}
+
+	if _, err := newHTTPRateLimiter(zap.NewNop(), &GlobalConfig{CoordinationBackend: "redis://:invalid"}, &httpJobConfig{SharedRateLimit: 1}); err == nil {
+		t.Error("expected an error for an invalid backend address")
+	}
+
+	if got := targetKey("https://example.com:8443/{{ random_path_segment }}"); got != "example.com:8443" {
+		t.Errorf("expected the host of the path template, got %q", got)
+	}
+}
@@ -0,0 +1,247 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Arriven/db1000n/src/utils/metrics"
+)
+
+const (
+	dashboardInterval = time.Second
+	dashboardLogLines = 10 // last lines of the log shown below the stats
+	dashboardMaxRows  = 10 // of the hosts and the proxies, the busiest hosts and the best proxies go first
+
+	// moves the cursor to the top left corner and clears the screen
+	dashboardClearScreen = "\033[H\033[2J"
+)
+
+// dashboardSnapshot is the state of the metrics rendered by the dashboard at some point
+type dashboardSnapshot struct {
+	At            time.Time
+	Generated     uint64  // bytes
+	Processed     uint64  // bytes
+	GeneratedRate float64 // bytes per second since the previous snapshot
+	ProcessedRate float64 // bytes per second since the previous snapshot
+	Hosts         []dashboardHost
+	Jobs          []jobInfo // only the running and paused ones
+	Proxies       []metrics.ProxySummary
+}
+
+// dashboardHost holds the amounts of requests to a single host by their result
+type dashboardHost struct {
+	Host          string
+	Success, Fail uint64
+}
+
+// newDashboardSnapshot computes the snapshot from the current metrics, rates are derived from the previous snapshot if any
+func newDashboardSnapshot(prev *dashboardSnapshot, at time.Time, generated, processed uint64,
+	latencies []metrics.LatencySummary, jobs []jobInfo, proxies []metrics.ProxySummary,
+) dashboardSnapshot {
+	snapshot := dashboardSnapshot{At: at, Generated: generated, Processed: processed, Proxies: proxies}
+
+	if prev != nil {
+		if elapsed := at.Sub(prev.At).Seconds(); elapsed > 0 {
+			snapshot.GeneratedRate = float64(delta(prev.Generated, generated)) / elapsed
+			snapshot.ProcessedRate = float64(delta(prev.Processed, processed)) / elapsed
+		}
+	}
+
+	hosts := make(map[string]*dashboardHost)
+
+	for _, s := range latencies {
+		host, ok := hosts[s.Host]
+		if !ok {
+			host = &dashboardHost{Host: s.Host}
+			hosts[s.Host] = host
+		}
+
+		if s.Status == metrics.StatusSuccess {
+			host.Success += s.Count
+		} else {
+			host.Fail += s.Count
+		}
+	}
+
+	for _, host := range hosts {
+		snapshot.Hosts = append(snapshot.Hosts, *host)
+	}
+
+	sort.Slice(snapshot.Hosts, func(i, j int) bool {
+		a, b := snapshot.Hosts[i], snapshot.Hosts[j]
+		if a.Success+a.Fail != b.Success+b.Fail {
+			return a.Success+a.Fail > b.Success+b.Fail
+		}
+
+		return a.Host < b.Host
+	})
+
+	for _, job := range jobs {
+		if job.Status == jobStatusRunning || job.Status == jobStatusPaused {
+			snapshot.Jobs = append(snapshot.Jobs, job)
+		}
+	}
+
+	return snapshot
+}
+
+// delta returns how much the counter has grown, the counters are reset when a new config is applied
+// so the current value is all that has been added since then
+func delta(prev, current uint64) uint64 {
+	if current < prev {
+		return current
+	}
+
+	return current - prev
+}
+
+// dashboard renders live stats in the terminal in place of the periodic stats dump.
+// Lines written to it (the standard logger output while it's running) are shown below the stats
+type dashboard struct {
+	out io.Writer
+
+	mu   sync.Mutex
+	logs []string
+}
+
+func newDashboard(out io.Writer) *dashboard {
+	return &dashboard{out: out}
+}
+
+// Write keeps the last dashboardLogLines lines
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logs = append(d.logs, strings.Split(strings.TrimRight(string(p), "\n"), "\n")...)
+	if len(d.logs) > dashboardLogLines {
+		d.logs = d.logs[len(d.logs)-dashboardLogLines:]
+	}
+
+	return len(p), nil
+}
+
+func (d *dashboard) lastLogs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string(nil), d.logs...)
+}
+
+func (d *dashboard) run(ctx context.Context, r *Runner) {
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+
+	var prev *dashboardSnapshot
+
+	for {
+		snapshot := newDashboardSnapshot(prev, time.Now(),
+			metrics.Default.Read(metrics.Traffic), metrics.Default.Read(metrics.ProcessedTraffic),
+			metrics.Default.LatencySummaries(), r.jobInfos(), metrics.Default.ProxyLeaderboard())
+		prev = &snapshot
+
+		d.render(snapshot)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *dashboard) render(s dashboardSnapshot) {
+	const (
+		BytesInMegabyte             = 1024 * 1024
+		PercentConversionMultilpier = 100
+	)
+
+	w := tabwriter.NewWriter(d.out, 1, 1, 1, ' ', 0)
+
+	fmt.Fprint(w, dashboardClearScreen)
+	fmt.Fprintf(w, "db1000n dashboard\t%s\n\n", s.At.Format(time.RFC1123))
+
+	fmt.Fprint(w, "---------Traffic---------\n")
+	fmt.Fprintf(w, "Generated\t%.2f MB\t%.2f MB/s\n", float64(s.Generated)/BytesInMegabyte, s.GeneratedRate/BytesInMegabyte)
+	fmt.Fprintf(w, "Received\t%.2f MB\t%.2f MB/s\n\n", float64(s.Processed)/BytesInMegabyte, s.ProcessedRate/BytesInMegabyte)
+
+	fmt.Fprintf(w, "---------Jobs (%d active)---------\n", len(s.Jobs))
+	fmt.Fprint(w, "ID\tName\tType\tStatus\tInstances\tIterations\tRate\n")
+
+	for _, job := range s.Jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d/%d\t%d\t%.1f/s\n", job.ID, job.Name, job.Type, job.Status,
+			job.RunningInstances, job.Instances, job.Iterations, job.Progress.Rate)
+	}
+
+	fmt.Fprint(w, "\n---------Hosts---------\n")
+	fmt.Fprint(w, "Host\tSuccess\tFail\tSuccess rate\n")
+
+	for i, host := range s.Hosts {
+		if i == dashboardMaxRows {
+			fmt.Fprintf(w, "... %d more\n", len(s.Hosts)-dashboardMaxRows)
+
+			break
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f %%\n", host.Host, host.Success, host.Fail,
+			float64(host.Success)/float64(host.Success+host.Fail)*PercentConversionMultilpier)
+	}
+
+	if len(s.Proxies) > 0 {
+		fmt.Fprint(w, "\n---------Proxies---------\n")
+		fmt.Fprint(w, "Proxy\tSuccess rate\tRequests\tp50\n")
+
+		for i, proxy := range s.Proxies {
+			if i == dashboardMaxRows {
+				fmt.Fprintf(w, "... %d more\n", len(s.Proxies)-dashboardMaxRows)
+
+				break
+			}
+
+			fmt.Fprintf(w, "%s\t%.1f %%\t%d\t%v\n", proxy.Proxy, proxy.SuccessRate*PercentConversionMultilpier, proxy.Success+proxy.Fail, proxy.P50)
+		}
+	}
+
+	fmt.Fprint(w, "\n---------Log---------\n")
+
+	for _, line := range d.lastLogs() {
+		fmt.Fprintln(w, line)
+	}
+
+	w.Flush()
+}
+
+// isTerminal reports whether the file is a terminal rather than a pipe or a regular file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,79 @@
+package job
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Arriven/db1000n/src/utils/metrics"
+)
+
+func TestDashboardSnapshot(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	latencies := []metrics.LatencySummary{
+		{Host: "b.example.com", Status: metrics.StatusSuccess, Count: 5},
+		{Host: "a.example.com", Status: metrics.StatusSuccess, Count: 3},
+		{Host: "a.example.com", Status: metrics.StatusFail, Count: 2},
+		{Host: "c.example.com", Status: metrics.StatusFail, Count: 10},
+	}
+	jobs := []jobInfo{
+		{ID: "1", Status: jobStatusRunning},
+		{ID: "2", Status: jobStatusPaused},
+		{ID: "3", Status: jobStatusStopped},
+		{ID: "4", Status: jobStatusFinished},
+	}
+	proxies := []metrics.ProxySummary{{Proxy: "127.0.0.1:8080", Success: 1}}
+
+	first := newDashboardSnapshot(nil, start, 1000, 500, latencies, jobs, proxies)
+	if first.GeneratedRate != 0 || first.ProcessedRate != 0 {
+		t.Errorf("expected no rates without the previous snapshot, got %+v", first)
+	}
+
+	expectedHosts := []dashboardHost{
+		{Host: "c.example.com", Fail: 10},
+		{Host: "a.example.com", Success: 3, Fail: 2},
+		{Host: "b.example.com", Success: 5},
+	}
+	if !reflect.DeepEqual(first.Hosts, expectedHosts) {
+		t.Errorf("expected hosts %+v, got %+v", expectedHosts, first.Hosts)
+	}
+
+	if len(first.Jobs) != 2 || first.Jobs[0].ID != "1" || first.Jobs[1].ID != "2" {
+		t.Errorf("expected only the active jobs, got %+v", first.Jobs)
+	}
+
+	if !reflect.DeepEqual(first.Proxies, proxies) {
+		t.Errorf("expected proxies %+v, got %+v", proxies, first.Proxies)
+	}
+
+	second := newDashboardSnapshot(&first, start.Add(2*time.Second), 5000, 1500, nil, nil, nil)
+	if second.GeneratedRate != 2000 || second.ProcessedRate != 500 {
+		t.Errorf("expected rates of 2000 and 500 bytes per second, got %v and %v", second.GeneratedRate, second.ProcessedRate)
+	}
+
+	// the counters start over when a new config is applied
+	reset := newDashboardSnapshot(&second, start.Add(3*time.Second), 100, 0, nil, nil, nil)
+	if reset.GeneratedRate != 100 || reset.ProcessedRate != 0 {
+		t.Errorf("expected rates after the reset to count from zero, got %v and %v", reset.GeneratedRate, reset.ProcessedRate)
+	}
+}
+
+func TestDashboardLogs(t *testing.T) {
+	t.Parallel()
+
+	d := newDashboard(nil)
+
+	for i := 0; i < dashboardLogLines; i++ {
+		fmt.Fprintf(d, "line %d\n", i)
+	}
+
+	fmt.Fprint(d, "first\nsecond\n")
+
+	logs := d.lastLogs()
+	if len(logs) != dashboardLogLines || logs[0] != "line 2" || logs[len(logs)-2] != "first" || logs[len(logs)-1] != "second" {
+		t.Errorf("expected the last %d lines, got %q", dashboardLogLines, logs)
+	}
+}
@@ -33,6 +33,7 @@ import (
 
 	"github.com/Arriven/db1000n/src/core/dnsblast"
 	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
 )
 
 type dnsBlastConfig struct {
@@ -41,6 +42,7 @@ type dnsBlastConfig struct {
 	Protocol        string   `mapstructure:"protocol"` // "udp", "tcp", "tcp-tls"
 	SeedDomains     []string `mapstructure:"seed_domains"`
 	ParallelQueries int      `mapstructure:"parallel_queries"`
+	Replies         *replyConfig
 }
 
 func dnsBlastJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
@@ -49,6 +51,11 @@ func dnsBlastJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalCo
 		return nil, err
 	}
 
+	var replyTimeout time.Duration
+	if jobConfig.Replies != nil {
+		replyTimeout = utils.NonNilDurationOrDefault(jobConfig.Replies.Timeout, defaultReplyTimeout)
+	}
+
 	var wg sync.WaitGroup
 
 	err = dnsblast.Start(ctx, logger, &wg, &dnsblast.Config{
@@ -58,6 +65,7 @@ func dnsBlastJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalCo
 		ParallelQueries: jobConfig.ParallelQueries,
 		Delay:           time.Duration(jobConfig.IntervalMs) * time.Millisecond,
 		ClientID:        globalConfig.ClientID,
+		ReplyTimeout:    replyTimeout,
 	})
 
 	wg.Wait()
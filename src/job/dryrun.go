@@ -0,0 +1,260 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// dryRunRequest is the first request an http job would send
+type dryRunRequest struct {
+	Method string
+	URL    string
+	Size   int64 // without the streamed body
+}
+
+// dryRunResult is the outcome of validating a single job of the config
+type dryRunResult struct {
+	Name    string
+	Type    string
+	Request *dryRunRequest // nil for the jobs that aren't http jobs
+	Err     error
+}
+
+// DryRun fetches the config once and validates every job of it without running them: job types, templates of the args,
+// and for http jobs the whole request pipeline up to the first request which is rendered but not sent.
+// The report is written to w, returns an error if any of the jobs is invalid
+func (r *Runner) DryRun(ctx context.Context, logger *zap.Logger, w io.Writer) error {
+	rawConfig := config.FetchRawMultiConfig(strings.Split(r.cfgOptions.PathsCSV, ","), &config.RawMultiConfig{
+		Body: []byte(nonEmptyStringOrDefault(r.cfgOptions.BackupConfig, config.DefaultConfig)),
+	}, r.publicKey)
+
+	if rawConfig.Encrypted {
+		return errors.New("encrypted configs can't be dry run")
+	}
+
+	cfg := config.Unmarshal(rawConfig.Body, r.cfgOptions.Format)
+	if cfg == nil {
+		return errors.New("error parsing the config")
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, templates.ContextKey("global"), r.globalJobsCfg))
+	defer cancel()
+
+	results := make([]dryRunResult, 0, len(cfg.Jobs))
+	for _, job := range cfg.Jobs {
+		request, err := dryRunJob(ctx, logger, r.globalJobsCfg, job)
+		results = append(results, dryRunResult{Name: job.Name, Type: job.Type, Request: request, Err: err})
+	}
+
+	return printDryRun(w, results)
+}
+
+func printDryRun(w io.Writer, results []dryRunResult) error {
+	const padding = 2
+
+	tw := tabwriter.NewWriter(w, 0, 0, padding, ' ', 0)
+	fmt.Fprintln(tw, "#\tName\tType\tMethod\tURL\tSize\tResult")
+
+	var invalid int
+
+	for i, result := range results {
+		method, url, size, status := "-", "-", "-", "ok"
+		if result.Request != nil {
+			method, url, size = result.Request.Method, result.Request.URL, fmt.Sprint(result.Request.Size)
+		}
+
+		if result.Err != nil {
+			invalid++
+			status = result.Err.Error()
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i, nonEmptyStringOrDefault(result.Name, "-"), result.Type, method, url, size, status)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d jobs are invalid", invalid, len(results))
+	}
+
+	return nil
+}
+
+// dryRunJob validates the job, template execution errors that are only logged while the jobs run are returned as errors
+func dryRunJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, cfg config.Config) (*dryRunRequest, error) {
+	if Get(cfg.Type) == nil {
+		return nil, fmt.Errorf("unknown job type %q", cfg.Type)
+	}
+
+	if _, err := templates.Parse(cfg.Filter); err != nil {
+		return nil, fmt.Errorf("error parsing filter: %w", err)
+	}
+
+	if _, err := templates.ParseMapStruct(cfg.Args); err != nil {
+		return nil, fmt.Errorf("error parsing args: %w", err)
+	}
+
+	switch cfg.Type {
+	case "http", "http-flood", "http-request":
+	default:
+		return nil, nil
+	}
+
+	core, warnings := observer.New(zapcore.WarnLevel)
+	logger = zap.New(zapcore.NewTee(logger.Core(), core))
+
+	request, err := dryRunHTTPJob(ctx, logger, globalConfig, cfg.Args)
+	if err != nil {
+		return request, err
+	}
+
+	if entries := warnings.All(); len(entries) > 0 {
+		if err, ok := entries[0].ContextMap()["error"].(string); ok {
+			return request, fmt.Errorf("%v: %v", entries[0].Message, err)
+		}
+
+		return request, errors.New(entries[0].Message)
+	}
+
+	return request, nil
+}
+
+// dryRunHTTPJob goes through the same steps as runFastHTTPJob up to sending the first request
+func dryRunHTTPJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (*dryRunRequest, error) {
+	jobConfig, clientConfig, requestTpl, err := getHTTPJobConfigs(ctx, args, *globalConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := parseExpectation(jobConfig.Expect); err != nil {
+		return nil, err
+	}
+
+	if _, err := parseExtractRules(jobConfig.Extract); err != nil {
+		return nil, err
+	}
+
+	headerSets, err := parseHeaderSets(jobConfig.HeaderSets, jobConfig.HeaderSetSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := parseTargets(jobConfig.Targets, jobConfig.TargetSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := parseRequestHook(ctx, logger, jobConfig.Hook)
+	if err != nil {
+		return nil, err
+	}
+	defer hook.close()
+
+	dataCursor, err := templates.OpenDataFile(jobConfig.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("error opening datafile: %w", err)
+	}
+
+	if dataCursor != nil {
+		if requestTpl, err = parseRequestTemplate(jobConfig.Request, dataCursor.Funcs()); err != nil {
+			return nil, fmt.Errorf("error parsing request config: %w", err)
+		}
+
+		dataCursor.Next()
+	}
+
+	if err := checkProxyList(clientConfig.ProxyURLs); err != nil {
+		return nil, err
+	}
+
+	// health checks would connect to the proxies
+	clientConfig.ProxyHealthCheck = nil
+
+	// clients only connect when the first request is sent
+	if _, err := http.NewClient(ctx, *clientConfig, logger); err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+
+	var requestConfig http.RequestConfig
+	if err := http.DecodeRequest(requestTpl.Execute(ctx, logger), &requestConfig); err != nil {
+		return nil, fmt.Errorf("error executing request template: %w", err)
+	}
+
+	if requestConfig.HeaderSet, err = headerSets.pick(logger, ctx); err != nil {
+		return nil, err
+	}
+
+	if requestConfig.Path, err = targets.apply(requestConfig.Path, targets.pick()); err != nil {
+		return nil, err
+	}
+
+	if err := hook.apply(ctx, &requestConfig); err != nil {
+		return nil, err
+	}
+
+	if err := clientConfig.CheckRequest(requestConfig); err != nil {
+		return nil, err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	requestConfig.ConnectionClose = clientConfig.ClosesConnections()
+	size := http.InitRequest(requestConfig, req)
+
+	return &dryRunRequest{Method: string(req.Header.Method()), URL: req.URI().String(), Size: size}, nil
+}
+
+// checkProxyList reports the proxies that clients would silently replace with direct connections
+func checkProxyList(proxyURLs string) error {
+	proxies, _, err := utils.ParseProxyList(proxyURLs)
+	if err != nil {
+		return fmt.Errorf("error parsing proxy list: %w", err)
+	}
+
+	for _, proxy := range proxies {
+		if u, err := url.Parse(proxy); err != nil || u.Host == "" {
+			return fmt.Errorf("error parsing proxy list: invalid proxy url %q", proxy)
+		}
+	}
+
+	return nil
+}
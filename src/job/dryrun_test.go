@@ -0,0 +1,117 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/job/config"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	body := `jobs:
+  - name: flood
+    type: http
+    args:
+      request:
+        method: POST
+        path: "` + server.URL + `/{{ add 40 2 }}"
+        body: "hello"
+  - name: greeting
+    type: log
+    args:
+      text: hello
+`
+
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewRunner(&ConfigOptions{PathsCSV: path, Format: "yaml"}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report strings.Builder
+	if err := runner.DryRun(context.Background(), zap.NewNop(), &report); err != nil {
+		t.Fatalf("expected the config to be valid, got %v\n%v", err, report.String())
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 0 {
+		t.Errorf("expected no requests to be sent, got %d", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and a line per job, got %q", report.String())
+	}
+
+	if fields := strings.Fields(lines[1]); len(fields) != 7 || fields[3] != http.MethodPost || fields[4] != server.URL+"/42" || fields[6] != "ok" {
+		t.Errorf("expected the first request of the http job, got %q", lines[1])
+	}
+
+	if fields := strings.Fields(lines[2]); len(fields) != 7 || fields[3] != "-" || fields[6] != "ok" {
+		t.Errorf("expected the log job to be validated only, got %q", lines[2])
+	}
+}
+
+func TestDryRunErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		job     config.Config
+		wantErr string
+	}{
+		{name: "unknown type", job: config.Config{Type: "teleport"}, wantErr: "unknown job type"},
+		{name: "args syntax", job: config.Config{Type: "log", Args: config.Args{"text": "{{ oops"}}, wantErr: "error parsing args"},
+		{name: "filter syntax", job: config.Config{Type: "log", Filter: "{{ if }}"}, wantErr: "error parsing filter"},
+		{
+			name:    "template execution",
+			job:     config.Config{Type: "http", Args: config.Args{"request": map[string]interface{}{"path": `http://localhost/{{ datacol "id" }}`}}},
+			wantErr: "error executing template",
+		},
+		{
+			name:    "proxy list",
+			job:     config.Config{Type: "http-request", Args: config.Args{"request": map[string]interface{}{"path": "http://localhost"}, "proxy_urls": "socks5://a:1,localhost"}},
+			wantErr: "error parsing proxy list",
+		},
+		{
+			name: "header sets",
+			job: config.Config{Type: "http", Args: config.Args{
+				"request": map[string]interface{}{"path": "http://localhost"}, "header_set_selection": "sideways",
+				"header_sets": map[string]map[string]interface{}{"a": {"X-A": "1"}},
+			}},
+			wantErr: "sideways",
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := dryRunJob(context.Background(), zap.NewNop(), &GlobalConfig{}, tc.job)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%v: expected an error containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+
+	var report strings.Builder
+	if err := printDryRun(&report, []dryRunResult{{Type: "log"}, {Type: "teleport", Err: context.Canceled}}); err == nil || err.Error() != "1 of 2 jobs are invalid" {
+		t.Errorf("expected the invalid jobs to be counted, got %v", err)
+	}
+}
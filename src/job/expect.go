@@ -0,0 +1,106 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// responseExpectation describes a response that proves the target has actually processed the request
+type responseExpectation struct {
+	Status       *int
+	BodyContains string            `mapstructure:"body_contains"`
+	Header       map[string]string // only presence of the header is checked for empty values
+}
+
+// expectationTemplate is executed before each check so that expected values can be dynamic, nil means no expectations
+type expectationTemplate struct {
+	tpl *templates.MapStruct
+}
+
+func parseExpectation(expect map[string]interface{}) (*expectationTemplate, error) {
+	if expect == nil {
+		return nil, nil
+	}
+
+	tpl, err := templates.ParseMapStruct(expect)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expect config: %w", err)
+	}
+
+	return &expectationTemplate{tpl: tpl}, nil
+}
+
+// validate checks the response and records the outcome in metrics
+func (t *expectationTemplate) validate(ctx context.Context, logger *zap.Logger, req *fasthttp.Request, resp *fasthttp.Response, body []byte) error {
+	if t == nil {
+		return nil
+	}
+
+	var expectation responseExpectation
+	if err := utils.Decode(t.tpl.Execute(logger, ctx), &expectation); err != nil {
+		return fmt.Errorf("error executing expect template: %w", err)
+	}
+
+	err := expectation.check(resp, body)
+	if err != nil {
+		metrics.IncHTTPValidation(getJobID(ctx), string(req.Host()), metrics.StatusFail)
+	} else {
+		metrics.IncHTTPValidation(getJobID(ctx), string(req.Host()), metrics.StatusSuccess)
+	}
+
+	return err
+}
+
+// check compares the response with the expectation, the body is checked instead of the raw one of the response
+func (e responseExpectation) check(resp *fasthttp.Response, body []byte) error {
+	if e.Status != nil && resp.StatusCode() != *e.Status {
+		return fmt.Errorf("expected status code %d, got %d", *e.Status, resp.StatusCode())
+	}
+
+	if e.BodyContains != "" && !bytes.Contains(body, []byte(e.BodyContains)) {
+		return fmt.Errorf("expected body to contain %q", e.BodyContains)
+	}
+
+	for name, expected := range e.Header {
+		value := resp.Header.Peek(name)
+
+		switch {
+		case value == nil:
+			return fmt.Errorf("expected header %q to be present", name)
+		case expected != "" && string(value) != expected:
+			return fmt.Errorf("expected header %q to be %q, got %q", name, expected, value)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// extractedContextKey holds values extracted from the responses, they are accessible in templates
+// as {{ (.Value (ctx_key "extracted")).name }}
+const extractedContextKey = templates.ContextKey("extracted")
+
+// responseExtractor pulls values out of json responses by path, nil means nothing to extract
+type responseExtractor map[string][]interface{} // map by variable name to path steps, either string keys or int indices
+
+// parseExtractRules parses paths like "$.data.items[0].id", both "$." and "$" prefixes are optional
+func parseExtractRules(rules map[string]string) (responseExtractor, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	extractor := make(responseExtractor, len(rules))
+
+	for name, path := range rules {
+		steps, err := parseJSONPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing extract path %q of %q: %w", path, name, err)
+		}
+
+		extractor[name] = steps
+	}
+
+	return extractor, nil
+}
+
+func parseJSONPath(path string) ([]interface{}, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	if rest == "" {
+		return nil, nil
+	}
+
+	if rest[0] != '[' {
+		rest = strings.TrimPrefix(rest, ".")
+		rest = "." + rest
+	}
+
+	var steps []interface{}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+
+			if end == 1 {
+				return nil, fmt.Errorf("empty key at %q", rest)
+			}
+
+			steps, rest = append(steps, rest[1:end]), rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket at %q", rest)
+			}
+
+			step, err := parseJSONPathBracket(rest[1:end])
+			if err != nil {
+				return nil, err
+			}
+
+			steps, rest = append(steps, step), rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected character at %q", rest)
+		}
+	}
+
+	return steps, nil
+}
+
+// parseJSONPathBracket parses either an array index or a quoted key
+func parseJSONPathBracket(s string) (interface{}, error) {
+	if unquoted, err := strconv.Unquote(strings.ReplaceAll(s, "'", `"`)); err == nil {
+		return unquoted, nil
+	}
+
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 {
+		return nil, fmt.Errorf("expected array index or quoted key, got %q", s)
+	}
+
+	return index, nil
+}
+
+// extract stores the values found in the body into the map, values that aren't found are left as they were
+func (e responseExtractor) extract(logger *zap.Logger, body []byte, into map[string]interface{}) {
+	if e == nil {
+		return
+	}
+
+	// keeps numbers as they were sent so that ids don't turn into floats
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		logger.Debug("error parsing response to extract values", zap.Error(err))
+
+		return
+	}
+
+	for name, path := range e {
+		value, ok := lookupJSONPath(document, path)
+		if !ok {
+			logger.Debug("value to extract is not found in the response", zap.String("name", name))
+
+			continue
+		}
+
+		into[name] = value
+	}
+}
+
+func lookupJSONPath(document interface{}, path []interface{}) (interface{}, bool) {
+	for _, step := range path {
+		switch step := step.(type) {
+		case string:
+			object, ok := document.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+
+			if document, ok = object[step]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := document.([]interface{})
+			if !ok || step >= len(array) {
+				return nil, false
+			}
+
+			document = array[step]
+		}
+	}
+
+	return document, true
+}
+
+// extractedValues returns a copy of the values extracted by the previous jobs of the sequence
+func extractedValues(ctx context.Context) map[string]interface{} {
+	values := make(map[string]interface{})
+
+	if extracted, ok := ctx.Value(extractedContextKey).(map[string]interface{}); ok {
+		for name, value := range extracted {
+			values[name] = value
+		}
+	}
+
+	return values
+}
+
+func withExtracted(ctx context.Context, values map[string]interface{}) context.Context {
+	return context.WithValue(ctx, extractedContextKey, values)
+}
+
+// withJobExtracted shares the values extracted by http-request job with all the following jobs of the sequence
+func withJobExtracted(ctx context.Context, data interface{}) context.Context {
+	result, ok := data.(map[string]interface{})
+	if !ok {
+		return ctx
+	}
+
+	values, ok := result["extracted"].(map[string]interface{})
+	if !ok || len(values) == 0 {
+		return ctx
+	}
+
+	extracted := extractedValues(ctx)
+	for name, value := range values {
+		extracted[name] = value
+	}
+
+	return withExtracted(ctx, extracted)
+}
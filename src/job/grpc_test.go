@@ -0,0 +1,157 @@
+package job
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// startGRPCServer starts a server with the health service and records the status codes of handled health checks
+func startGRPCServer(t *testing.T, withReflection bool) (addr string, calls func() []codes.Code) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		handled []codes.Code
+	)
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("x-test")) == 0 {
+			err = status.Error(codes.InvalidArgument, "missing metadata")
+		}
+
+		mu.Lock()
+		handled = append(handled, status.Code(err))
+		mu.Unlock()
+
+		return resp, err
+	}))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	if withReflection {
+		reflection.Register(server)
+	}
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String(), func() []codes.Code {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]codes.Code(nil), handled...)
+	}
+}
+
+func TestGRPCJob(t *testing.T) {
+	t.Parallel()
+
+	descriptorSet, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptorSetPath := filepath.Join(t.TempDir(), "health.protoset")
+	if err := os.WriteFile(descriptorSetPath, descriptorSet, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		reflection    bool
+		descriptorSet string
+		request       string
+		wantCode      codes.Code
+	}{
+		{name: "reflection", reflection: true, request: `{"service": "{{ "" }}"}`, wantCode: codes.OK},
+		{name: "error status", reflection: true, request: `{"service": "missing"}`, wantCode: codes.NotFound},
+		{name: "descriptor set", descriptorSet: descriptorSetPath, request: `{}`, wantCode: codes.OK},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			addr, calls := startGRPCServer(t, tc.reflection)
+
+			const requestsCount = 3
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := grpcJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+				"address":        addr,
+				"method":         "grpc.health.v1.Health/Check",
+				"request":        tc.request,
+				"metadata":       map[string]interface{}{"x-test": "value"},
+				"descriptor_set": tc.descriptorSet,
+				"count":          requestsCount,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := calls()
+			if len(got) != requestsCount {
+				t.Fatalf("expected %d requests, got %d", requestsCount, len(got))
+			}
+
+			for _, code := range got {
+				if code != tc.wantCode {
+					t.Errorf("expected status %v, got %v", tc.wantCode, code)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCJobUnknownMethod(t *testing.T) {
+	t.Parallel()
+
+	addr, calls := startGRPCServer(t, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := grpcJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address": addr,
+		"method":  "grpc.health.v1.Health/Missing",
+		"count":   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := calls(); len(got) != 0 {
+		t.Errorf("expected no requests to be sent, got %v", got)
+	}
+}
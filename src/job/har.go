@@ -0,0 +1,190 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// harVarsContextKey holds the rendered vars of the har job, they are accessible in the templated entries
+// as {{ (.Value (ctx_key "vars")).name }}
+const harVarsContextKey = templates.ContextKey("vars")
+
+type harJobConfig struct {
+	BasicJobConfig
+
+	File           string                 // HAR export in the files directory, see templates.SetFilesDir
+	Client         map[string]interface{} // See HTTPClientConfig
+	ProxyURLs      string                 `mapstructure:"proxy_urls"`
+	PreserveTiming bool                   `mapstructure:"preserve_timing"` // wait between the entries as long as it was waited when recording
+	Templated      bool                   // render urls, headers, cookies and bodies of the entries as templates
+	Vars           map[string]interface{} // rendered once per replay, see harVarsContextKey
+	SLAThreshold   time.Duration          `mapstructure:"sla_threshold"` // same as sla_threshold of http job
+}
+
+// harJob replays all the requests of a HAR export in order on every iteration
+func harJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig harJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	content, err := templates.ReadFile(jobConfig.File)
+	if err != nil {
+		return nil, fmt.Errorf("error reading har: %w", err)
+	}
+
+	entries, err := http.ParseHAR(content)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := parseHAREntries(entries, jobConfig.Templated)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := templates.ParseMapStruct(jobConfig.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing vars: %w", err)
+	}
+
+	clientConfig, err := parseHTTPClientConfig(ctx, logger, jobConfig.Client, jobConfig.ProxyURLs, *globalConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client, release, err := http.AcquireClient(ctx, *clientConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+	defer release()
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	defer trafficMonitor.Flush()
+	defer processedTrafficMonitor.Flush()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	for jobConfig.Next(ctx) {
+		tplCtx := context.WithValue(ctx, harVarsContextKey, vars.Execute(logger, ctx))
+		start := time.Now()
+
+		for i, entry := range entries {
+			if jobConfig.PreserveTiming && !utils.Sleep(ctx, time.Until(start.Add(entry.Offset))) {
+				return nil, nil
+			}
+
+			requestConfig, err := requests[i](logger, tplCtx)
+			if err != nil {
+				logger.Debug("error rendering har entry", zap.Int("entry", i), zap.Error(err))
+
+				continue
+			}
+
+			req.Reset()
+			resp.Reset()
+
+			requestConfig.ConnectionClose = clientConfig.ClosesConnections()
+			dataSize := http.InitRequest(requestConfig, req)
+			trafficMonitor.Add(uint64(dataSize))
+
+			if err := sendFastHTTPRequest(ctx, client, req, resp, requestConfig.Timeout, jobConfig.SLAThreshold, addTraffic(trafficMonitor)); err != nil {
+				logger.Debug("error sending har entry", zap.Int("entry", i), zap.String("url", requestConfig.Path), zap.Error(err))
+
+				continue
+			}
+
+			processedTrafficMonitor.Add(uint64(dataSize))
+		}
+	}
+
+	return nil, nil
+}
+
+// parseHAREntries returns funcs producing the requests of the entries, either as recorded or rendered as templates
+func parseHAREntries(entries []http.HAREntry, templated bool) ([]func(*zap.Logger, context.Context) (http.RequestConfig, error), error) {
+	requests := make([]func(*zap.Logger, context.Context) (http.RequestConfig, error), 0, len(entries))
+
+	for i := range entries {
+		request := entries[i].Request
+
+		if !templated {
+			requests = append(requests, func(*zap.Logger, context.Context) (http.RequestConfig, error) { return request, nil })
+
+			continue
+		}
+
+		tpl, err := templates.ParseMapStruct(map[string]interface{}{
+			"path":    request.Path,
+			"method":  request.Method,
+			"body":    request.Body,
+			"headers": stringsToInterfaces(request.Headers),
+			"cookies": stringsToInterfaces(request.Cookies),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error parsing har entry %d: %w", i, err)
+		}
+
+		requests = append(requests, func(logger *zap.Logger, ctx context.Context) (http.RequestConfig, error) {
+			var requestConfig http.RequestConfig
+
+			return requestConfig, utils.Decode(tpl.Execute(logger, ctx), &requestConfig)
+		})
+	}
+
+	return requests, nil
+}
+
+// stringsToInterfaces converts the values so that they are parsed as templates by templates.ParseMapStruct
+func stringsToInterfaces(m map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		result[key] = value
+	}
+
+	return result
+}
@@ -0,0 +1,105 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+func TestHARJob(t *testing.T) { //nolint:paralleltest // Modifies the global files directory
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Token"), body))
+	}))
+	t.Cleanup(server.Close)
+
+	har := fmt.Sprintf(`{"log": {"entries": [
+  {"startedDateTime": "2022-03-01T10:00:00.000Z", "request": {"method": "GET", "url": "%[1]s/page?id=1",
+    "headers": [{"name": "X-Token", "value": "{{ (.Value (ctx_key \"vars\")).token }}"}]}},
+  {"startedDateTime": "2022-03-01T10:00:00.100Z", "request": {"method": "POST", "url": "%[1]s/submit",
+    "postData": {"text": "token={{ (.Value (ctx_key \"vars\")).token }}"}}}
+]}}`, server.URL)
+
+	baseDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(baseDir, "session.har"), []byte(har), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates.SetFilesDir(baseDir)
+	defer templates.SetFilesDir("")
+
+	testCases := []struct {
+		name        string
+		args        config.Args
+		want        []string
+		wantAtLeast time.Duration
+	}{
+		{
+			name: "as recorded",
+			args: config.Args{"file": "session.har", "count": 1},
+			want: []string{
+				`GET /page?id=1 {{ (.Value (ctx_key "vars")).token }} `,
+				`POST /submit  token={{ (.Value (ctx_key "vars")).token }}`,
+			},
+		},
+		{
+			name: "templated and looped",
+			args: config.Args{"file": "session.har", "count": 2, "templated": true, "vars": map[string]interface{}{"token": "secret"}, "preserve_timing": true},
+			want: []string{
+				"GET /page?id=1 secret ", "POST /submit  token=secret",
+				"GET /page?id=1 secret ", "POST /submit  token=secret",
+			},
+			wantAtLeast: 2 * 100 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		mu.Lock()
+		requests = nil
+		mu.Unlock()
+
+		start := time.Now()
+
+		if _, err := harJob(context.Background(), zap.NewNop(), &GlobalConfig{}, tc.args); err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+
+		if elapsed := time.Since(start); elapsed < tc.wantAtLeast {
+			t.Errorf("%v: expected the timing to be preserved, took %v", tc.name, elapsed)
+		}
+
+		mu.Lock()
+		got := strings.Join(requests, "\n")
+		mu.Unlock()
+
+		if want := strings.Join(tc.want, "\n"); got != want {
+			t.Errorf("%v: expected requests\n%v\ngot\n%v", tc.name, want, got)
+		}
+	}
+
+	if _, err := harJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{"file": "missing.har"}); err == nil {
+		t.Error("expected an error for a missing har")
+	}
+}
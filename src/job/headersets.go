@@ -0,0 +1,102 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// Supported values for httpJobConfig.HeaderSetSelection, same as the ones of proxy selection
+const (
+	headerSetRandom     = "random"
+	headerSetRoundRobin = "round_robin"
+)
+
+// headerSets picks one of the named groups of headers for every request so that the headers that only make sense
+// together (i.e. user agent and accept headers of a browser fingerprint) are always sent together
+type headerSets struct {
+	names      []string // sorted to make round robin order stable
+	sets       map[string]*templates.MapStruct
+	roundRobin bool
+	next       uint64 // atomic
+}
+
+// parseHeaderSets returns nil if there are no sets
+func parseHeaderSets(sets map[string]map[string]interface{}, selection string) (*headerSets, error) {
+	if len(sets) == 0 {
+		return nil, nil
+	}
+
+	result := &headerSets{sets: make(map[string]*templates.MapStruct, len(sets))}
+
+	switch selection {
+	case "", headerSetRandom:
+	case headerSetRoundRobin:
+		result.roundRobin = true
+	default:
+		return nil, fmt.Errorf("unsupported header set selection %q, expected one of [%q, %q]", selection, headerSetRandom, headerSetRoundRobin)
+	}
+
+	for name, headers := range sets {
+		tpl, err := templates.ParseMapStruct(headers)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing header set %q: %w", name, err)
+		}
+
+		result.names = append(result.names, name)
+		result.sets[name] = tpl
+	}
+
+	sort.Strings(result.names)
+
+	return result, nil
+}
+
+// pick returns the executed headers of the next set, nil receiver returns no headers
+func (s *headerSets) pick(logger *zap.Logger, data interface{}) (map[string]string, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	var i int
+	if s.roundRobin {
+		i = int((atomic.AddUint64(&s.next, 1) - 1) % uint64(len(s.names)))
+	} else {
+		i = rand.Intn(len(s.names)) //nolint:gosec // Cryptographically secure random not required
+	}
+
+	var headers map[string]string
+	if err := utils.Decode(s.sets[s.names[i]].Execute(logger, data), &headers); err != nil {
+		return nil, fmt.Errorf("error executing header set %q: %w", s.names[i], err)
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,245 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	lua "github.com/yuin/gopher-lua"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+)
+
+const (
+	requestHookFunction       = "on_request"
+	defaultRequestHookTimeout = 50 * time.Millisecond
+	requestHookCallStackSize  = 64
+	requestHookRegistrySize   = 1024
+	requestHookRegistryMax    = 64 * 1024
+)
+
+// requestHookConfig is a lua script defining on_request(request, iteration, response) that is called before every request
+// of the http job. The request is a table with path, method, body, headers and cookies of the rendered request that the function
+// can modify, iteration counts the requests of the request loop from 0 and response holds status, headers and body of the previous
+// response or nil if there is none (i.e. the previous request has failed)
+type requestHookConfig struct {
+	Script  string        `mapstructure:"script"`
+	Timeout time.Duration `mapstructure:"timeout"` // of a single call, defaultRequestHookTimeout if zero
+}
+
+// requestHook runs the script in its own sandbox: only the base, string, table and math libraries are available
+// and the functions loading files or modules are removed. It's not safe for concurrent use, every request loop has its own
+type requestHook struct {
+	state     *lua.LState
+	function  lua.LValue
+	timeout   time.Duration
+	iteration int
+	response  lua.LValue
+}
+
+// parseRequestHook returns nil if there is no script, the hook has to be closed once it's no longer needed
+func parseRequestHook(ctx context.Context, logger *zap.Logger, c *requestHookConfig) (*requestHook, error) {
+	if c == nil || c.Script == "" {
+		return nil, nil
+	}
+
+	if c.Timeout < 0 {
+		return nil, fmt.Errorf("invalid request hook timeout %v", c.Timeout)
+	}
+
+	hook := &requestHook{
+		state:    newSandbox(logger),
+		timeout:  c.Timeout,
+		response: lua.LNil,
+	}
+
+	if hook.timeout == 0 {
+		hook.timeout = defaultRequestHookTimeout
+	}
+
+	if err := hook.call(ctx, func() error { return hook.state.DoString(c.Script) }); err != nil {
+		hook.close()
+
+		return nil, fmt.Errorf("error loading request hook: %w", err)
+	}
+
+	if hook.function = hook.state.GetGlobal(requestHookFunction); hook.function.Type() != lua.LTFunction {
+		hook.close()
+
+		return nil, fmt.Errorf("request hook has to define %v(request, iteration, response) function", requestHookFunction)
+	}
+
+	return hook, nil
+}
+
+func newSandbox(logger *zap.Logger) *lua.LState {
+	state := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   requestHookCallStackSize,
+		RegistrySize:    requestHookRegistrySize,
+		RegistryMaxSize: requestHookRegistryMax,
+	})
+
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+
+	for _, name := range []string{"dofile", "loadfile", "module", "require"} {
+		state.SetGlobal(name, lua.LNil)
+	}
+
+	// the output of the script goes to the debug log rather than to stdout
+	state.SetGlobal("print", state.NewFunction(func(l *lua.LState) int {
+		args := make([]string, 0, l.GetTop())
+		for i := 1; i <= l.GetTop(); i++ {
+			args = append(args, l.ToStringMeta(l.Get(i)).String())
+		}
+
+		logger.Debug("request hook", zap.String("output", strings.Join(args, "\t")))
+
+		return 0
+	}))
+
+	return state
+}
+
+// call runs f with the hook timeout, the script is interrupted once it runs out of time
+func (h *requestHook) call(ctx context.Context, f func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	h.state.SetContext(ctx)
+	defer h.state.RemoveContext()
+
+	err := f()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("script has exceeded the %v timeout: %w", h.timeout, err)
+	}
+
+	return err
+}
+
+// apply calls the hook with the request and updates the request with the fields the hook has set
+func (h *requestHook) apply(ctx context.Context, c *http.RequestConfig) error {
+	if h == nil {
+		return nil
+	}
+
+	request := h.state.NewTable()
+	request.RawSetString("path", lua.LString(c.Path))
+	request.RawSetString("method", lua.LString(c.Method))
+	request.RawSetString("body", lua.LString(c.Body))
+	request.RawSetString("headers", h.stringTable(c.Headers))
+	request.RawSetString("cookies", h.stringTable(c.Cookies))
+
+	iteration := h.iteration
+	h.iteration++
+
+	if err := h.call(ctx, func() error {
+		return h.state.CallByParam(lua.P{Fn: h.function, Protect: true}, request, lua.LNumber(iteration), h.response)
+	}); err != nil {
+		return fmt.Errorf("error running request hook: %w", err)
+	}
+
+	c.Path = lua.LVAsString(request.RawGetString("path"))
+	c.Method = lua.LVAsString(request.RawGetString("method"))
+	c.Body = lua.LVAsString(request.RawGetString("body"))
+	c.Headers = stringMap(request.RawGetString("headers"))
+	c.Cookies = stringMap(request.RawGetString("cookies"))
+
+	return nil
+}
+
+// observe passes the response to the next call of the hook, nil response means that the request has failed
+func (h *requestHook) observe(resp *fasthttp.Response, body []byte) {
+	if h == nil {
+		return
+	}
+
+	if resp == nil {
+		h.response = lua.LNil
+
+		return
+	}
+
+	headers := h.state.NewTable()
+	resp.Header.VisitAll(func(key, value []byte) {
+		headers.RawSetString(string(key), lua.LString(value))
+	})
+
+	response := h.state.NewTable()
+	response.RawSetString("status", lua.LNumber(resp.StatusCode()))
+	response.RawSetString("headers", headers)
+	response.RawSetString("body", lua.LString(body))
+
+	h.response = response
+}
+
+func (h *requestHook) close() {
+	if h != nil {
+		h.state.Close()
+	}
+}
+
+func (h *requestHook) stringTable(m map[string]string) *lua.LTable {
+	table := h.state.NewTable()
+	for key, value := range m {
+		table.RawSetString(key, lua.LString(value))
+	}
+
+	return table
+}
+
+// stringMap converts the string keys of the table and their values to a map, anything but a table is an empty map
+func stringMap(value lua.LValue) map[string]string {
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil
+	}
+
+	result := make(map[string]string)
+
+	table.ForEach(func(key, value lua.LValue) {
+		if key.Type() == lua.LTString {
+			result[key.String()] = lua.LVAsString(value)
+		}
+	})
+
+	return result
+}
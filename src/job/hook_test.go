@@ -0,0 +1,111 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+)
+
+func TestRequestHook(t *testing.T) {
+	t.Parallel()
+
+	const count = 5
+
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, fmt.Sprintf("%v %v %v %v", r.URL.Path, r.Header.Get("X-Iteration"), r.Header.Get("X-Previous"), r.Header.Get("X-Template")))
+
+		w.Header().Set("X-Seen", fmt.Sprint(len(requests)))
+		_, _ = w.Write([]byte("seen " + fmt.Sprint(len(requests))))
+	}))
+	t.Cleanup(server.Close)
+
+	script := `
+function on_request(request, iteration, response)
+	request.headers["X-Iteration"] = iteration
+	if iteration % 2 == 1 then
+		request.path = request.path .. "/odd"
+	end
+	if response then
+		request.headers["X-Previous"] = response.status .. ":" .. response.headers["X-Seen"] .. ":" .. response.body
+	end
+end`
+
+	if _, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{
+		"request": map[string]interface{}{"path": server.URL + "/", "headers": map[string]interface{}{"X-Template": "kept"}},
+		"count":   count,
+		"hook":    map[string]interface{}{"script": script},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{
+		"/ 0  kept",
+		"//odd 1 200:1:seen 1 kept",
+		"/ 2 200:2:seen 2 kept",
+		"//odd 3 200:3:seen 3 kept",
+		"/ 4 200:4:seen 4 kept",
+	}
+
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected requests:\n%v\ngot:\n%v", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+}
+
+func TestRequestHookSandbox(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{name: "no function", script: `x = 1`, err: "has to define on_request"},
+		{name: "syntax error", script: `function on_request(`, err: "error loading request hook"},
+		{name: "no io", script: `function on_request(request) io.open("/etc/passwd") end`, err: "error running request hook"},
+		{name: "no os", script: `function on_request(request) os.execute("true") end`, err: "error running request hook"},
+		{name: "no files", script: `function on_request(request) dofile("/etc/passwd") end`, err: "error running request hook"},
+		{name: "no modules", script: `function on_request(request) require("socket") end`, err: "error running request hook"},
+		{name: "endless loop", script: `function on_request(request) while true do end end`, err: "exceeded the 20ms timeout"},
+		{name: "endless load", script: `while true do end`, err: "exceeded the 20ms timeout"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			hook, err := parseRequestHook(context.Background(), zap.NewNop(), &requestHookConfig{Script: tc.script, Timeout: 20 * time.Millisecond})
+			if err == nil {
+				defer hook.close()
+
+				err = hook.apply(context.Background(), &http.RequestConfig{Path: "http://localhost"})
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
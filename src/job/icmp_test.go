@@ -0,0 +1,48 @@
+package job
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/icmp"
+)
+
+func TestICMPJob(t *testing.T) {
+	t.Parallel()
+
+	if conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0"); err != nil {
+		t.Skipf("raw sockets are not available: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	const count = 5
+
+	data, err := icmpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address": "127.0.0.1",
+		"payload": "{{ random_payload 32 }}",
+		"count":   count,
+		"timeout": "500ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, ok := data.(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected result %v", data)
+	}
+
+	if result["sent"] != uint64(count) || result["received"] != uint64(count) {
+		t.Errorf("expected %d echo requests and replies, got %v", count, result)
+	}
+}
+
+func TestICMPJobNoAddress(t *testing.T) {
+	t.Parallel()
+
+	if _, err := icmpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{}); err == nil {
+		t.Error("expected the job to fail without address")
+	}
+}
@@ -0,0 +1,66 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Supported values of GlobalConfig.LogFormat
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// NewLoggerConfig returns the config of the application logger: production config (json with info level) or development one
+// (console with debug level) in debug mode, with the format and the level overridden by LogFormat and LogLevel when they are set
+func (c *GlobalConfig) NewLoggerConfig(debug bool) (zap.Config, error) {
+	cfg := zap.NewProductionConfig()
+	if debug {
+		cfg = zap.NewDevelopmentConfig()
+	}
+
+	// encoder configs go along with the format so that json logs have the same keys regardless of the debug mode
+	switch c.LogFormat {
+	case "":
+	case LogFormatJSON:
+		cfg.Encoding, cfg.EncoderConfig = c.LogFormat, zap.NewProductionEncoderConfig()
+	case LogFormatConsole:
+		cfg.Encoding, cfg.EncoderConfig = c.LogFormat, zap.NewDevelopmentEncoderConfig()
+	default:
+		return cfg, fmt.Errorf("unsupported log format %q, expected one of [%q, %q]", c.LogFormat, LogFormatJSON, LogFormatConsole)
+	}
+
+	if c.LogLevel != "" {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			return cfg, fmt.Errorf("unsupported log level: %w", err)
+		}
+
+		cfg.Level = zap.NewAtomicLevelAt(level)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,104 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Arriven/db1000n/src/job/config"
+)
+
+func TestLoggerConfig(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "log.json")
+
+	cfg, err := (&GlobalConfig{LogFormat: LogFormatJSON, LogLevel: "warn"}).NewLoggerConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.OutputPaths = []string{path}
+
+	logger, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("filtered")
+	logger.Warn("kept", zap.String("key", "value"))
+	_ = logger.Sync()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var messages []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("expected json logs, got %q: %v", scanner.Text(), err)
+		}
+
+		messages = append(messages, entry["msg"].(string))
+
+		if entry["key"] != "value" {
+			t.Errorf("expected the fields to be logged, got %v", entry)
+		}
+	}
+
+	if len(messages) != 1 || messages[0] != "kept" {
+		t.Errorf("expected only the warning to be logged, got %v", messages)
+	}
+
+	if cfg, _ := (&GlobalConfig{}).NewLoggerConfig(false); cfg.Encoding != LogFormatJSON || cfg.Level.Level() != zapcore.InfoLevel {
+		t.Errorf("expected json info logs by default, got %v %v", cfg.Encoding, cfg.Level)
+	}
+
+	if cfg, _ := (&GlobalConfig{LogFormat: LogFormatConsole}).NewLoggerConfig(false); cfg.Encoding != LogFormatConsole {
+		t.Errorf("expected console logs, got %v", cfg.Encoding)
+	}
+
+	for _, c := range []GlobalConfig{{LogFormat: "xml"}, {LogLevel: "loud"}} {
+		if _, err := c.NewLoggerConfig(false); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func TestHTTPJobLogs(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	args := config.Args{"request": map[string]interface{}{"path": server.URL}, "count": 1}
+
+	if _, err := fastHTTPJob(context.Background(), zap.New(core), &GlobalConfig{}, args); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := singleRequestJob(context.Background(), zap.New(core), &GlobalConfig{}, args); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []string{"attacking", "sent single http request"} {
+		if entries := logs.FilterMessage(message).All(); len(entries) != 1 || entries[0].ContextMap()["path"] != server.URL {
+			t.Errorf("expected %q to be logged with the path, got %v", message, entries)
+		}
+	}
+}
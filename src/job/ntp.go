@@ -0,0 +1,206 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// ntpMonlistRequest is a mode 7 (private) MON_GETLIST_1 request
+const ntpMonlistRequest = "\x17\x00\x03\x2a\x00\x00\x00\x00"
+
+const defaultNTPPort = "123"
+
+type ntpJobConfig struct {
+	BasicJobConfig
+
+	Servers []string       // host or host:port, port 123 is used by default
+	Payload string         // request to send, monlist by default
+	Timeout *time.Duration // how long to wait for response packets after sending the request
+	Replies *replyConfig   // counts the requests that got the first response packet within replies timeout as successes
+}
+
+// ntpStats keeps bytes sent to and received from a single server
+type ntpStats struct {
+	sent, received uint64
+}
+
+func (s ntpStats) amplification() float64 {
+	if s.sent == 0 {
+		return 0
+	}
+
+	return float64(s.received) / float64(s.sent)
+}
+
+// ntpJob measures how much response data ntp servers send back for a request (amplification factor) without spoofing the source.
+// Returns the amplification factor by server
+func ntpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig ntpJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	if len(jobConfig.Servers) == 0 {
+		return nil, errors.New("no servers provided, at least one is required")
+	}
+
+	payloadTpl, err := templates.Parse(jobConfig.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing payload template: %w", err)
+	}
+
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Measuring amplification of %d ntp servers", len(jobConfig.Servers))
+	}
+
+	stats := make(map[string]*ntpStats, len(jobConfig.Servers))
+
+	for i := 0; jobConfig.Next(ctx); i++ {
+		addr := templates.ParseAndExecute(logger, jobConfig.Servers[i%len(jobConfig.Servers)], ctx)
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, defaultNTPPort)
+		}
+
+		payload := nonEmptyStringOrDefault(templates.Execute(logger, payloadTpl, ctx), ntpMonlistRequest)
+
+		sent, received, firstReply, err := queryNTP(ctx, addr, []byte(payload), utils.NonNilDurationOrDefault(jobConfig.Timeout, 2*time.Second))
+
+		trafficMonitor.Add(sent)
+		processedTrafficMonitor.Add(received)
+
+		if stats[addr] == nil {
+			stats[addr] = &ntpStats{}
+		}
+
+		stats[addr].sent += sent
+		stats[addr].received += received
+		metrics.SetNTPAmplification(addr, stats[addr].amplification())
+
+		if jobConfig.Replies != nil {
+			observeNTPReply(addr, utils.NonNilDurationOrDefault(jobConfig.Replies.Timeout, defaultReplyTimeout), received, firstReply)
+		}
+
+		if err != nil || received == 0 {
+			logger.Debug("no response from ntp server", zap.String("addr", addr), zap.Error(err))
+			metrics.IncRawnetUDP(addr, metrics.StatusFail)
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+
+			continue
+		}
+
+		metrics.IncRawnetUDP(addr, metrics.StatusSuccess)
+		backoffController.Reset()
+	}
+
+	result := make(map[string]interface{}, len(stats))
+	for addr, s := range stats {
+		result[addr] = s.amplification()
+	}
+
+	return result, nil
+}
+
+func observeNTPReply(addr string, timeout time.Duration, received uint64, firstReply time.Duration) {
+	if received == 0 || firstReply > timeout {
+		metrics.IncUDPReply(addr, "ntp", metrics.UDPReplyStatusTimeout)
+
+		return
+	}
+
+	metrics.IncUDPReply(addr, "ntp", metrics.StatusSuccess)
+	metrics.Default.ObserveLatency(addr, metrics.StatusSuccess, firstReply)
+}
+
+// queryNTP sends the request and reads the response packets until none arrive within the timeout,
+// firstReply is the time it took to get the first response packet
+func queryNTP(ctx context.Context, addr string, payload []byte, timeout time.Duration) (sent, received uint64, firstReply time.Duration, err error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	defer conn.Close()
+
+	start := time.Now()
+
+	n, err := conn.Write(payload)
+	sent = uint64(n)
+
+	if err != nil {
+		return sent, 0, 0, err
+	}
+
+	// monlist responses are split into many packets
+	const maxPacketSize = 65535
+
+	buf := make([]byte, maxPacketSize)
+
+	for ctx.Err() == nil {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return sent, received, firstReply, err
+		}
+
+		n, err := conn.Read(buf)
+		if n > 0 && received == 0 {
+			firstReply = time.Since(start)
+		}
+
+		received += uint64(n)
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return sent, received, firstReply, nil
+		} else if err != nil {
+			return sent, received, firstReply, err
+		}
+	}
+
+	return sent, received, firstReply, nil
+}
@@ -0,0 +1,95 @@
+package job
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startNTPStub starts a udp server replying to every request with the given amount of packets
+func startNTPStub(t *testing.T, packets, packetSize int) (addr string, requests <-chan string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	received := make(chan string, 100)
+
+	go func() {
+		buf := make([]byte, 1024)
+		response := make([]byte, packetSize)
+
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			received <- string(buf[:n])
+
+			for i := 0; i < packets; i++ {
+				_, _ = conn.WriteTo(response, from)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String(), received
+}
+
+func TestNTPJob(t *testing.T) {
+	t.Parallel()
+
+	const (
+		packets    = 10
+		packetSize = 400
+	)
+
+	reflector, requests := startNTPStub(t, packets, packetSize)
+	silent, _ := startNTPStub(t, 0, 0)
+
+	data, err := ntpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"servers": []interface{}{reflector, silent},
+		"timeout": "200ms",
+		"count":   4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amplification, _ := data.(map[string]interface{})
+
+	want := float64(packets*packetSize) / float64(len(ntpMonlistRequest))
+	if got := amplification[reflector]; got != want {
+		t.Errorf("expected amplification of %v for the reflector, got %v", want, got)
+	}
+
+	if got := amplification[silent]; got != float64(0) {
+		t.Errorf("expected no amplification for the silent server, got %v", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case request := <-requests:
+			if request != ntpMonlistRequest {
+				t.Errorf("expected monlist request by default, got %q", request)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("reflector did not receive the request")
+		}
+	}
+}
+
+func TestNTPJobNoServers(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ntpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{}); err == nil {
+		t.Error("expected an error when no servers are provided")
+	}
+}
@@ -24,18 +24,18 @@ package job
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	"github.com/google/gopacket"
-	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/Arriven/db1000n/src/core/packetgen"
 	"github.com/Arriven/db1000n/src/job/config"
 	"github.com/Arriven/db1000n/src/utils"
-	"github.com/Arriven/db1000n/src/utils/metrics"
 	"github.com/Arriven/db1000n/src/utils/templates"
 )
 
@@ -54,10 +54,14 @@ func packetgenJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalC
 	}
 
 	rawConn, err := packetgen.OpenRawConnection(jobConfig.Connection)
-	if err != nil {
+	if errors.Is(err, os.ErrPermission) {
+		return nil, fmt.Errorf("packetgen job requires raw socket privileges (run as root or grant CAP_NET_RAW): %w", err)
+	} else if err != nil {
 		return nil, fmt.Errorf("error building raw connection: %w", err)
 	}
 
+	defer rawConn.Close()
+
 	packetTpl, err := templates.ParseMapStruct(jobConfig.Packet)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing packet: %w", err)
@@ -65,7 +69,7 @@ func packetgenJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalC
 
 	payloadBuf := gopacket.NewSerializeBuffer()
 
-	trafficMonitor := metrics.Default.NewWriter(metrics.Traffic, uuid.New().String())
+	trafficMonitor := jobConfig.newTrafficMonitor()
 	go trafficMonitor.Update(ctx, time.Second)
 
 	for jobConfig.Next(ctx) {
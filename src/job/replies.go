@@ -0,0 +1,263 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+)
+
+// Supported values for replyConfig.Key
+const (
+	replyKeySource = "source" // replies are matched to the oldest packet waiting for one, connected sockets only get replies from the target
+	replyKeyDNS    = "dns"    // replies are matched by the transaction id, the first two bytes of dns messages
+)
+
+const (
+	defaultReplyTimeout = 2 * time.Second
+	maxUDPPacketSize    = 65535
+)
+
+// replyConfig enables waiting for replies to the sent packets, jobs don't wait for any when it's nil
+type replyConfig struct {
+	Timeout *time.Duration // how long a packet waits for its reply, 2s by default
+	Key     string         // how the replies are matched to the packets, replyKeySource by default
+}
+
+// replyTracker matches the replies to the sent packets, packets that got a reply within the timeout are counted
+// as successes and the rest as timeouts. All methods are safe to call on a nil tracker that doesn't track anything
+type replyTracker struct {
+	addr     string
+	protocol string
+	timeout  time.Duration
+	key      func(packet []byte) (string, bool)
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[string][]time.Time // send times by key, oldest first
+
+	replies, timeouts uint64 // atomic
+}
+
+// newReplyTracker returns nil if the config is nil
+func newReplyTracker(c *replyConfig, addr, protocol string) (*replyTracker, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	t := &replyTracker{
+		addr:     addr,
+		protocol: protocol,
+		timeout:  utils.NonNilDurationOrDefault(c.Timeout, defaultReplyTimeout),
+		now:      time.Now,
+		pending:  make(map[string][]time.Time),
+	}
+
+	switch c.Key {
+	case "", replyKeySource:
+		t.key = func([]byte) (string, bool) { return "", true }
+	case replyKeyDNS:
+		t.key = dnsReplyKey
+	default:
+		return nil, fmt.Errorf("unsupported replies key %q, expected one of [%q, %q]", c.Key, replyKeySource, replyKeyDNS)
+	}
+
+	return t, nil
+}
+
+func dnsReplyKey(packet []byte) (string, bool) {
+	const idSize = 2
+
+	if len(packet) < idSize {
+		return "", false
+	}
+
+	return string(packet[:idSize]), true
+}
+
+// sent registers the packet as waiting for a reply, packets without a key aren't tracked
+func (t *replyTracker) sent(packet []byte) {
+	if t == nil {
+		return
+	}
+
+	key, ok := t.key(packet)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[key] = append(t.pending[key], t.now())
+}
+
+// unsent withdraws the packet registered last with the same key when it couldn't be sent
+func (t *replyTracker) unsent(packet []byte) {
+	if t == nil {
+		return
+	}
+
+	key, ok := t.key(packet)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if queue := t.pending[key]; len(queue) > 1 {
+		t.pending[key] = queue[:len(queue)-1]
+	} else {
+		delete(t.pending, key)
+	}
+}
+
+// received matches the reply to the oldest packet waiting with the same key, replies that don't match any are ignored
+func (t *replyTracker) received(packet []byte) {
+	key, ok := t.key(packet)
+	if !ok {
+		return
+	}
+
+	now := t.now()
+
+	t.mu.Lock()
+	t.expireLocked(now)
+
+	queue := t.pending[key]
+	if len(queue) == 0 {
+		t.mu.Unlock()
+
+		return
+	}
+
+	if t.pending[key] = queue[1:]; len(queue) == 1 {
+		delete(t.pending, key)
+	}
+
+	t.mu.Unlock()
+
+	atomic.AddUint64(&t.replies, 1)
+	metrics.IncUDPReply(t.addr, t.protocol, metrics.StatusSuccess)
+	metrics.Default.ObserveLatency(t.addr, metrics.StatusSuccess, now.Sub(queue[0]))
+}
+
+// expireLocked counts the packets that have been waiting for longer than the timeout, all of them if now is zero
+func (t *replyTracker) expireLocked(now time.Time) {
+	for key, queue := range t.pending {
+		expired := len(queue)
+		if !now.IsZero() {
+			expired = 0
+			for expired < len(queue) && now.Sub(queue[expired]) > t.timeout {
+				expired++
+			}
+		}
+
+		if expired == 0 {
+			continue
+		}
+
+		if t.pending[key] = queue[expired:]; len(t.pending[key]) == 0 {
+			delete(t.pending, key)
+		}
+
+		atomic.AddUint64(&t.timeouts, uint64(expired))
+
+		for i := 0; i < expired; i++ {
+			metrics.IncUDPReply(t.addr, t.protocol, metrics.UDPReplyStatusTimeout)
+		}
+	}
+}
+
+// listen reads the replies from the connection until it's closed or the context is done, onReply is called with the size of every reply
+func (t *replyTracker) listen(ctx context.Context, conn net.Conn, onReply func(size int)) {
+	if t == nil {
+		return
+	}
+
+	buf := make([]byte, maxUDPPacketSize)
+
+	for ctx.Err() == nil {
+		// wake up at least once per timeout to count the packets that didn't get a reply
+		if err := conn.SetReadDeadline(t.now().Add(t.timeout)); err != nil {
+			return
+		}
+
+		n, err := conn.Read(buf)
+		if n > 0 {
+			t.received(buf[:n])
+			onReply(n)
+		}
+
+		// reads of connected sockets also fail once the target reports the port as unreachable, that's not a reason to stop
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		t.mu.Lock()
+		t.expireLocked(t.now())
+		t.mu.Unlock()
+	}
+}
+
+// flush waits for the replies to the packets sent last and counts the packets that haven't got any as timeouts,
+// the connection has to be still listened to while it waits
+func (t *replyTracker) flush(ctx context.Context) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	waiting := len(t.pending) > 0
+	t.mu.Unlock()
+
+	// packets in flight when the job is stopped are neither successes nor timeouts
+	if waiting && !utils.Sleep(ctx, t.timeout) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expireLocked(time.Time{})
+}
+
+// stats returns the amounts of packets that got a reply and that timed out
+func (t *replyTracker) stats() map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"replies":  atomic.LoadUint64(&t.replies),
+		"timeouts": atomic.LoadUint64(&t.timeouts),
+	}
+}
@@ -0,0 +1,114 @@
+package job
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestUDPReplies(t *testing.T) {
+	t.Parallel()
+
+	echo, _ := startNTPStub(t, 1, 8)
+	silent, _ := startNTPStub(t, 0, 0)
+
+	const count = 5
+
+	for _, tc := range []struct {
+		name         string
+		addr         string
+		wantReplies  uint64
+		wantTimeouts uint64
+	}{
+		{name: "echo", addr: echo, wantReplies: count},
+		{name: "silent", addr: silent, wantTimeouts: count},
+	} {
+		data, err := udpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"address": tc.addr,
+			"body":    "ping",
+			"count":   count,
+			"replies": map[string]interface{}{"timeout": "200ms"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats, _ := data.(map[string]interface{})
+		if stats["replies"] != tc.wantReplies || stats["timeouts"] != tc.wantTimeouts {
+			t.Errorf("%v: expected %d replies and %d timeouts, got %v", tc.name, tc.wantReplies, tc.wantTimeouts, stats)
+		}
+	}
+
+	data, err := udpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{"address": silent, "body": "ping", "count": 1})
+	if err != nil || data != nil {
+		t.Errorf("expected packets to be sent without waiting for replies by default, got %v (%v)", data, err)
+	}
+
+	if _, err := udpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address": silent, "replies": map[string]interface{}{"key": "unknown"},
+	}); err == nil {
+		t.Error("expected an error for an unknown replies key")
+	}
+}
+
+func TestReplyTrackerDNS(t *testing.T) {
+	t.Parallel()
+
+	timeout := time.Second
+
+	tracker, err := newReplyTracker(&replyConfig{Timeout: &timeout, Key: replyKeyDNS}, "127.0.0.1:53", "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	query := func(id byte) []byte { return []byte{0, id, 1, 0} }
+
+	tracker.sent(query(1))
+	tracker.sent(query(2))
+	tracker.sent(query(3))
+	tracker.sent([]byte{1}) // too short to have an id
+
+	// replies are matched by the id regardless of the order
+	now = now.Add(timeout / 2)
+	tracker.received(query(3))
+	tracker.received(query(1))
+	tracker.received(query(4))
+
+	now = now.Add(timeout)
+	tracker.mu.Lock()
+	tracker.expireLocked(now)
+	tracker.mu.Unlock()
+
+	// too late for the query that has already timed out
+	tracker.received(query(2))
+
+	if stats := tracker.stats(); stats["replies"] != uint64(2) || stats["timeouts"] != uint64(1) {
+		t.Errorf("expected 2 replies and 1 timeout, got %v", stats)
+	}
+}
+
+func TestReplyTrackerNil(t *testing.T) {
+	t.Parallel()
+
+	tracker, err := newReplyTracker(nil, "", "udp")
+	if err != nil || tracker != nil {
+		t.Fatalf("expected no tracker without a config, got %v (%v)", tracker, err)
+	}
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	tracker.sent([]byte("ping"))
+	tracker.listen(context.Background(), conn, nil)
+	tracker.flush(context.Background())
+
+	if tracker.stats() != nil {
+		t.Error("expected no stats without a tracker")
+	}
+}
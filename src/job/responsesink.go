@@ -0,0 +1,171 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+)
+
+// supported response sink modes
+const (
+	responseSinkInline     = "inline"
+	responseSinkDiscard    = "discard"
+	responseSinkFilePrefix = "file:"
+)
+
+// responseSink decides what to do with the response body of a single request:
+// return it inline (default), discard it, or save it to a uniquely named file in dir
+type responseSink struct {
+	discard bool
+	dir     string // within the responses directory, see GlobalConfig.ResponsesDir
+}
+
+// parseResponseSink parses the sink mode, directories of the file mode come from the remote configs so they are only allowed
+// to point within the local responses directory (and saving files is disabled if it's empty)
+func parseResponseSink(mode, responsesDir string) (*responseSink, error) {
+	switch {
+	case mode == "" || mode == responseSinkInline:
+		return &responseSink{}, nil
+	case mode == responseSinkDiscard:
+		return &responseSink{discard: true}, nil
+	case strings.HasPrefix(mode, responseSinkFilePrefix) && len(mode) > len(responseSinkFilePrefix):
+		dir := strings.TrimPrefix(mode, responseSinkFilePrefix)
+
+		if responsesDir == "" {
+			return nil, errors.New("saving responses to files is disabled, set the responses directory to enable it")
+		}
+
+		if filepath.IsAbs(dir) || strings.HasPrefix(dir, "/") || hasDotDotSegment(dir) {
+			return nil, fmt.Errorf("response directory %q has to be a relative path within the responses directory", dir)
+		}
+
+		return &responseSink{dir: filepath.Join(responsesDir, dir)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported response sink %q, expected one of [%q, %q, %q]", mode,
+			responseSinkInline, responseSinkDiscard, responseSinkFilePrefix+"<dir>")
+	}
+}
+
+func hasDotDotSegment(path string) bool {
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == filepath.Separator }) {
+		if segment == ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inline tells if the sink needs the body in memory, the other modes write it straight from the response
+func (s *responseSink) inline() bool {
+	return !s.discard && s.dir == ""
+}
+
+// store puts the body into the response map according to the sink mode. The body is only used inline, the other modes
+// write the one of the response instead (decoded unless raw, limited to limit bytes if it's positive) without holding
+// it in memory as a whole. Returns whether the written body was cut
+func (s *responseSink) store(logger *zap.Logger, response map[string]interface{}, resp *fasthttp.Response, body []byte,
+	raw bool, limit int,
+) (truncated bool, err error) {
+	switch {
+	case s.discard:
+		size, truncated, err := writeResponseBody(logger, io.Discard, resp, raw, limit, func() error { return nil })
+		response["body_size"] = int(size)
+
+		return truncated, err
+	case s.dir != "":
+		const dirPermissions = 0o750
+
+		if err := os.MkdirAll(s.dir, dirPermissions); err != nil {
+			return false, fmt.Errorf("error creating response directory: %w", err)
+		}
+
+		f, err := os.CreateTemp(s.dir, "response-*")
+		if err != nil {
+			return false, fmt.Errorf("error creating response file: %w", err)
+		}
+
+		size, truncated, err := writeResponseBody(logger, f, resp, raw, limit, func() error {
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+
+			_, err := f.Seek(0, io.SeekStart)
+
+			return err
+		})
+		if err != nil {
+			f.Close()
+
+			return false, fmt.Errorf("error writing response file: %w", err)
+		}
+
+		if err = f.Close(); err != nil {
+			return false, fmt.Errorf("error writing response file: %w", err)
+		}
+
+		response["body_path"], response["body_size"] = f.Name(), int(size)
+
+		return truncated, nil
+	default:
+		response["body"] = string(body)
+
+		return false, nil
+	}
+}
+
+// writeResponseBody writes the response body to w, it's written as received when the body can't be decoded (like responseBody does)
+// after undoing the partial write with reset
+func writeResponseBody(logger *zap.Logger, w io.Writer, resp *fasthttp.Response, raw bool, limit int, reset func() error,
+) (written int64, truncated bool, err error) {
+	if !raw {
+		if written, truncated, err = http.WriteDecodedBody(w, resp, limit); err == nil {
+			return written, truncated, nil
+		}
+
+		logger.Debug("error decoding response body", zap.Error(err))
+
+		if err = reset(); err != nil {
+			return 0, false, err
+		}
+	}
+
+	body := resp.Body()
+	if limit > 0 && len(body) > limit {
+		body, truncated = body[:limit], true
+	}
+
+	n, err := w.Write(body)
+
+	return int64(n), truncated, err
+}
@@ -25,11 +25,15 @@ package job
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"time"
 
@@ -47,6 +51,7 @@ type ConfigOptions struct {
 	BackupConfig   string        // Raw backup config
 	Format         string        // json or yaml
 	RefreshTimeout time.Duration // How often to refresh config
+	Dashboard      bool          // Render live stats in the terminal instead of dumping them on every refresh
 }
 
 // NewConfigOptionsWithFlags returns ConfigOptions initialized with command line flags.
@@ -55,11 +60,13 @@ func NewConfigOptionsWithFlags() *ConfigOptions {
 
 	flag.StringVar(&res.PathsCSV, "c",
 		utils.GetEnvStringDefault("CONFIG", "https://raw.githubusercontent.com/db1000n-coordinators/LoadTestConfig/main/config.v0.7.json"),
-		"path to config files, separated by a comma, each path can be a web endpoint")
+		"path to config files, separated by a comma, each path can be a web endpoint or an s3:// or gs:// object url")
 	flag.StringVar(&res.BackupConfig, "b", "", "raw backup config in case the primary one is unavailable")
 	flag.StringVar(&res.Format, "format", utils.GetEnvStringDefault("CONFIG_FORMAT", "yaml"), "config format")
 	flag.DurationVar(&res.RefreshTimeout, "refresh-interval", utils.GetEnvDurationDefault("REFRESH_INTERVAL", time.Minute),
 		"refresh timeout for updating the config")
+	flag.BoolVar(&res.Dashboard, "dashboard", utils.GetEnvBoolDefault("DASHBOARD", false),
+		"render live traffic, jobs, hosts and proxies stats in the terminal, log lines are shown below them (disabled if stdout isn't a terminal)")
 
 	return &res
 }
@@ -68,27 +75,45 @@ func NewConfigOptionsWithFlags() *ConfigOptions {
 type Runner struct {
 	cfgOptions    *ConfigOptions
 	globalJobsCfg *GlobalConfig
+	publicKey     ed25519.PublicKey // to verify config signatures with
+
+	mu        sync.Mutex            // guards jobs as they are also accessed by the admin api
+	jobs      map[string]runningJob // jobs from the currently applied config by their keys
+	lastJobID int                   // jobs are numbered for the admin api as their keys are too long to type
 }
 
 // NewRunner according to the config
 func NewRunner(cfgOptions *ConfigOptions, globalJobsCfg *GlobalConfig) (*Runner, error) {
+	publicKey, err := config.ParsePublicKey(globalJobsCfg.ConfigPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config public key: %w", err)
+	}
+
 	return &Runner{
 		cfgOptions:    cfgOptions,
 		globalJobsCfg: globalJobsCfg,
+		publicKey:     publicKey,
 	}, nil
 }
 
 // Run the runner and block until Stop() is called
 func (r *Runner) Run(ctx context.Context, logger *zap.Logger) {
-	ctx = context.WithValue(ctx, templates.ContextKey("global"), r.globalJobsCfg)
+	// jobs get a context that outlives ctx so that they can finish in-flight requests on shutdown
+	jobsCtx, cancelJobs := context.WithCancel(utils.DetachedContext{Context: context.WithValue(ctx, templates.ContextKey("global"), r.globalJobsCfg)})
+	defer cancelJobs()
 
 	metrics.IncClient()
 
-	refreshTimer := time.NewTicker(r.cfgOptions.RefreshTimeout)
+	if r.globalJobsCfg.AdminAddr != "" {
+		defer r.serveAdmin(logger)()
+	}
 
-	defer refreshTimer.Stop()
+	statsOutput, stopDashboard := r.startDashboard(ctx)
+	defer stopDashboard()
 
-	var cancel context.CancelFunc
+	refreshTimer := time.NewTicker(r.reloadInterval())
+
+	defer refreshTimer.Stop()
 
 	lastKnownConfig := &config.RawMultiConfig{}
 
@@ -96,26 +121,28 @@ func (r *Runner) Run(ctx context.Context, logger *zap.Logger) {
 		rawConfig := config.FetchRawMultiConfig(strings.Split(r.cfgOptions.PathsCSV, ","),
 			nonNilConfigOrDefault(lastKnownConfig, &config.RawMultiConfig{
 				Body: []byte(nonEmptyStringOrDefault(r.cfgOptions.BackupConfig, config.DefaultConfig)),
-			}))
-		cfg := config.Unmarshal(rawConfig.Body, r.cfgOptions.Format)
+			}), r.publicKey)
+
+		var cfg *config.MultiConfig
+
+		// Getting the same instance back means the config wasn't modified (or couldn't be fetched), no need to parse it again
+		if rawConfig != lastKnownConfig {
+			cfg = config.Unmarshal(rawConfig.Body, r.cfgOptions.Format)
+		}
 
-		if !bytes.Equal(lastKnownConfig.Body, rawConfig.Body) && cfg != nil { // Only restart jobs if the new config differs from the current one
+		if !bytes.Equal(lastKnownConfig.Body, rawConfig.Body) && cfg != nil { // Only touch jobs if the new config differs from the current one
 			log.Println("New config received, applying")
 
 			lastKnownConfig = rawConfig
 
 			metrics.Default.ResetAll()
 
-			if cancel != nil {
-				cancel()
-			}
-
 			if rawConfig.Encrypted {
 				log.Println("Config is encrypted, disabling logs")
 
-				cancel = r.runJobs(EncryptedContext(ctx), zap.NewNop(), cfg)
+				r.applyConfig(EncryptedContext(jobsCtx), zap.NewNop(), cfg, true)
 			} else {
-				cancel = r.runJobs(ctx, logger, cfg)
+				r.applyConfig(jobsCtx, logger, cfg, false)
 			}
 		} else {
 			log.Println("The config has not changed. Keep calm and carry on!")
@@ -125,19 +152,69 @@ func (r *Runner) Run(ctx context.Context, logger *zap.Logger) {
 		select {
 		case <-refreshTimer.C:
 		case <-ctx.Done():
-			if cancel != nil {
-				cancel()
+			r.shutdown(jobsCtx, logger)
+			stopDashboard()
+
+			if err := dumpMetrics(logger, os.Stdout, r.globalJobsCfg.ClientID); err != nil {
+				logger.Debug("error reporting statistics", zap.Error(err))
 			}
 
 			return
 		}
 
-		if err := dumpMetrics(logger, r.globalJobsCfg.ClientID); err != nil {
+		if err := dumpMetrics(logger, statsOutput, r.globalJobsCfg.ClientID); err != nil {
 			logger.Debug("error reporting statistics", zap.Error(err))
 		}
 	}
 }
 
+// startDashboard renders the dashboard until the returned function is called if it's enabled and stdout is a terminal,
+// the standard logger is redirected to the dashboard meanwhile. Returns where the periodic stats dump should go
+func (r *Runner) startDashboard(ctx context.Context) (statsOutput io.Writer, stop func()) {
+	if !r.cfgOptions.Dashboard {
+		return os.Stdout, func() {}
+	}
+
+	if !isTerminal(os.Stdout) {
+		log.Println("Stdout is not a terminal, dashboard is disabled")
+
+		return os.Stdout, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	d := newDashboard(os.Stdout)
+	logOutput := log.Writer()
+	done := make(chan struct{})
+
+	log.SetOutput(d)
+
+	go func() {
+		defer close(done)
+
+		d.run(ctx, r)
+	}()
+
+	var once sync.Once
+
+	// the dashboard already shows everything the dump would
+	return io.Discard, func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			log.SetOutput(logOutput)
+		})
+	}
+}
+
+// reloadInterval returns how often the config is polled, the global reload interval takes precedence over the refresh one
+func (r *Runner) reloadInterval() time.Duration {
+	if r.globalJobsCfg.ReloadInterval > 0 {
+		return r.globalJobsCfg.ReloadInterval
+	}
+
+	return r.cfgOptions.RefreshTimeout
+}
+
 func nonEmptyStringOrDefault(s, defaultString string) string {
 	if s != "" {
 		return s
@@ -154,68 +231,275 @@ func nonNilConfigOrDefault(c, defaultConfig *config.RawMultiConfig) *config.RawM
 	return defaultConfig
 }
 
-func (r *Runner) runJobs(ctx context.Context, logger *zap.Logger, cfg *config.MultiConfig) (cancel context.CancelFunc) {
-	ctx, cancel = context.WithCancel(ctx)
+// applyConfig stops the jobs that are no longer present in the config and starts the new ones,
+// jobs that didn't change are left running
+func (r *Runner) applyConfig(ctx context.Context, logger *zap.Logger, cfg *config.MultiConfig, encrypted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	var jobInstancesCount int
+	keys := make([]string, len(cfg.Jobs))
+	wanted := make(map[string]bool, len(cfg.Jobs))
 
 	for i := range cfg.Jobs {
-		if len(cfg.Jobs[i].Filter) != 0 && strings.TrimSpace(templates.ParseAndExecute(logger, cfg.Jobs[i].Filter, ctx)) != "true" {
-			logger.Info("There is a filter defined for a job but this client doesn't pass it - skip the job")
+		// identical job entries are allowed so they have to be numbered to be told apart
+		key := jobKey(cfg.Jobs[i], encrypted)
+		for n := 1; wanted[key]; n++ {
+			key = fmt.Sprintf("%s#%d", jobKey(cfg.Jobs[i], encrypted), n)
+		}
 
-			continue
+		keys[i] = key
+		wanted[key] = true
+	}
+
+	// stop outdated jobs and wait for them to return before starting anything so that we never run two versions of the same job
+	stopped := make(map[string]runningJob)
+
+	for key, job := range r.jobs {
+		if !wanted[key] {
+			job.cancel()
+
+			stopped[key] = job
 		}
+	}
 
-		job := Get(cfg.Jobs[i].Type)
-		if job == nil {
-			logger.Warn("unknown job", zap.String("type", cfg.Jobs[i].Type))
+	starting := false
 
-			continue
+	for _, key := range keys {
+		if _, ok := r.jobs[key]; !ok {
+			starting = true
 		}
+	}
+
+	// nothing to wait for when the jobs are only stopped, i.e. on shutdown
+	if starting && !waitJobs(stopped, stoppedJobsTimeout) {
+		log.Printf("Stopped jobs are still running after %v, starting the new ones anyway", stoppedJobsTimeout)
+	}
+
+	var jobInstancesCount, keptCount int
+
+	running := make(map[string]runningJob, len(cfg.Jobs))
+
+	for i := range cfg.Jobs {
+		if job, ok := r.jobs[keys[i]]; ok {
+			running[keys[i]] = job
+			keptCount++
 
-		if cfg.Jobs[i].Count < 1 {
-			cfg.Jobs[i].Count = 1
+			continue
 		}
 
-		if r.globalJobsCfg.ScaleFactor > 0 {
-			cfg.Jobs[i].Count *= r.globalJobsCfg.ScaleFactor
+		if job, instances := r.startJob(ctx, logger, cfg.Jobs[i], strconv.Itoa(r.lastJobID+1)); instances > 0 {
+			r.lastJobID++
+			job.encrypted = encrypted
+			running[keys[i]] = job
+			jobInstancesCount += instances
+
+			go job.exportProgress()
 		}
+	}
+
+	r.jobs = running
+
+	log.Printf("%d job instances (re)started, %d jobs stopped, %d jobs kept running", jobInstancesCount, len(stopped), keptCount)
+}
+
+// shutdown lets the jobs finish their in-flight requests for up to the grace period and cancels them afterwards
+func (r *Runner) shutdown(ctx context.Context, logger *zap.Logger) {
+	r.mu.Lock()
+
+	for _, job := range r.jobs {
+		close(job.stop)
+	}
 
-		cfgMap := make(map[string]interface{})
-		if err := utils.Decode(cfg.Jobs[i], &cfgMap); err != nil {
-			logger.Fatal("failed to encode cfg map")
+	r.mu.Unlock()
+
+	if waitJobs(r.runningJobs(), r.globalJobsCfg.ShutdownGracePeriod) {
+		log.Println("All jobs finished")
+	} else {
+		log.Println("Shutdown grace period is over, cancelling the remaining jobs")
+	}
+
+	r.applyConfig(ctx, logger, &config.MultiConfig{}, false)
+}
+
+// stoppedJobsTimeout bounds the wait for the cancelled jobs to return when the config is applied, in case some of them
+// are stuck in a call that doesn't respect the context
+const stoppedJobsTimeout = 10 * time.Second
+
+// waitJobs waits for all the instances of the jobs to return for up to timeout, returns whether they did
+func waitJobs(jobs map[string]runningJob, timeout time.Duration) bool {
+	done := make(chan struct{})
+
+	go func() {
+		for _, job := range jobs {
+			job.wg.Wait()
 		}
 
-		ctx := context.WithValue(ctx, templates.ContextKey("config"), cfgMap)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+type runningJob struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}   // closed to stop iterations without cancelling ctx
+	wg     *sync.WaitGroup // running job instances
+
+	// used by the admin api
+	id        string
+	cfg       config.Config
+	encrypted bool
+	instances int
+	started   time.Time
+	control   *jobControl
+	labels    map[string]string
+}
+
+// exportProgress updates the progress metrics of the job until it's cancelled, the final progress
+// of the finished jobs is kept until the config is updated
+func (job runningJob) exportProgress() {
+	const interval = 5 * time.Second
+
+	var name string
+	if !job.encrypted {
+		name = job.cfg.Name
+	}
+
+	defer metrics.DeleteJobProgress(job.id, name)
+
+	if !job.encrypted {
+		metrics.SetJobLabels(job.id, name, job.labels)
+		defer metrics.DeleteJobLabels(job.id, name, job.labels)
+	}
 
-		for j := 0; j < cfg.Jobs[i].Count; j++ {
-			go func(i int) {
-				defer utils.PanicHandler(logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-				_, err := job(ctx, logger, r.globalJobsCfg, cfg.Jobs[i].Args)
-				if err != nil {
-					logger.Error("error running job one of the jobs",
-						zap.String("name", cfg.Jobs[i].Name),
-						zap.String("type", cfg.Jobs[i].Type),
-						zap.Error(err))
-				}
-			}(i)
+	for {
+		progress := job.control.progress.Snapshot()
 
-			jobInstancesCount++
+		var total *uint64
+		if progress.Total > 0 {
+			total = &progress.Total
 		}
+
+		metrics.SetJobProgress(job.id, name, progress.Completed, total, progress.Rate, progress.ETA)
+
+		select {
+		case <-job.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runningJobs returns a copy of the currently applied jobs
+func (r *Runner) runningJobs() map[string]runningJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := make(map[string]runningJob, len(r.jobs))
+	for key, job := range r.jobs {
+		jobs[key] = job
+	}
+
+	return jobs
+}
+
+func jobKey(cfg config.Config, encrypted bool) string {
+	// fmt prints maps sorted by key so the result is stable for equal configs
+	return fmt.Sprintf("%t/%+v", encrypted, cfg)
+}
+
+// labelsContextKey is the context key of the job labels, templates can read them as {{ (.Value (ctx_key "labels")).campaign }}
+const labelsContextKey = templates.ContextKey("labels")
+
+// jobIDContextKey is the context key of the id the job is exported with, the request metrics of the job carry it
+// so that they can be joined with the job labels
+const jobIDContextKey = templates.ContextKey("job_id")
+
+// getJobID returns an empty id if the job isn't run by the runner
+func getJobID(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDContextKey).(string)
+
+	return id
+}
+
+func (r *Runner) startJob(ctx context.Context, logger *zap.Logger, cfg config.Config, id string) (job runningJob, instances int) {
+	if len(cfg.Filter) != 0 && strings.TrimSpace(templates.ParseAndExecute(logger, cfg.Filter, ctx)) != "true" {
+		logger.Info("There is a filter defined for a job but this client doesn't pass it - skip the job")
+
+		return job, 0
+	}
+
+	jobFunc := Get(cfg.Type)
+	if jobFunc == nil {
+		logger.Warn("unknown job", zap.String("type", cfg.Type))
+
+		return job, 0
+	}
+
+	if cfg.Count < 1 {
+		cfg.Count = 1
+	}
+
+	if r.globalJobsCfg.ScaleFactor > 0 {
+		cfg.Count *= r.globalJobsCfg.ScaleFactor
+	}
+
+	cfgMap := make(map[string]interface{})
+	if err := utils.Decode(cfg, &cfgMap); err != nil {
+		logger.Fatal("failed to encode cfg map")
 	}
 
-	log.Printf("%d job instances (re)started", jobInstancesCount)
+	job.id = id
+	ctx = context.WithValue(ctx, jobIDContextKey, id)
+
+	job.labels = parseJobLabels(cfg.Args)
+	if len(job.labels) > 0 {
+		logger = logger.With(zap.Any("labels", job.labels))
+		ctx = context.WithValue(ctx, labelsContextKey, job.labels)
+	}
+
+	job.stop, job.wg = make(chan struct{}), &sync.WaitGroup{}
+	job.cfg, job.instances, job.started, job.control = cfg, cfg.Count, time.Now(), &jobControl{}
+	job.ctx, job.cancel = context.WithCancel(withJobControl(withStopChannel(context.WithValue(ctx, templates.ContextKey("config"), cfgMap), job.stop), job.control))
+
+	for j := 0; j < cfg.Count; j++ {
+		job.wg.Add(1)
 
-	return cancel
+		done := job.control.trackInstance()
+
+		go func() {
+			defer job.wg.Done()
+			defer done()
+			defer utils.PanicHandler(logger)
+
+			_, err := jobFunc(job.ctx, logger, r.globalJobsCfg, cfg.Args)
+			if err != nil {
+				logger.Error("error running job one of the jobs",
+					zap.String("name", cfg.Name),
+					zap.String("type", cfg.Type),
+					zap.Error(err))
+			}
+		}()
+	}
+
+	return job, cfg.Count
 }
 
-func dumpMetrics(logger *zap.Logger, clientID string) error {
+func dumpMetrics(logger *zap.Logger, w io.Writer, clientID string) error {
 	defer utils.PanicHandler(logger)
 
 	bytesGenerated := metrics.Default.Read(metrics.Traffic)
 	bytesProcessed := metrics.Default.Read(metrics.ProcessedTraffic)
-	networkStatsWriter := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', tabwriter.AlignRight)
+	networkStatsWriter := tabwriter.NewWriter(w, 1, 1, 1, ' ', tabwriter.AlignRight)
 
 	if bytesGenerated > 0 {
 		fmt.Fprintln(networkStatsWriter, "\n\n!Атака проводиться успішно! Русскій воєнний корабль іди нахуй!")
@@ -231,6 +515,11 @@ func dumpMetrics(logger *zap.Logger, clientID string) error {
 		fmt.Fprintf(networkStatsWriter, "[\tReceived\t]\t%.2f\tMB\t|\t%v \tbytes\n", float64(bytesProcessed)/BytesInMegabyte, bytesProcessed)
 		fmt.Fprintf(networkStatsWriter, "[\tResponse rate\t]\t%.1f\t%%\n", float64(bytesProcessed)/float64(bytesGenerated)*PercentConversionMultilpier)
 		fmt.Fprint(networkStatsWriter, "-------------------------------\n\n")
+
+		printLatencies(networkStatsWriter, metrics.Default.LatencySummaries())
+		printConnections(networkStatsWriter, metrics.Default.ConnectionSummaries())
+		printProxies(networkStatsWriter, metrics.Default.ProxyLeaderboard())
+		printSLABreaches(networkStatsWriter, metrics.Default.SLABreachSummaries())
 	} else {
 		fmt.Fprintln(networkStatsWriter, "[Error] No traffic generated. If you see this message a lot - contact admins")
 	}
@@ -239,3 +528,74 @@ func dumpMetrics(logger *zap.Logger, clientID string) error {
 
 	return metrics.ReportStatistics(int64(bytesGenerated), clientID)
 }
+
+func printLatencies(w io.Writer, summaries []metrics.LatencySummary) {
+	if len(summaries) == 0 {
+		return
+	}
+
+	fmt.Fprint(w, "---------Latency stats---------\n")
+	fmt.Fprint(w, "[\tHost\t]\tStatus\t|\tRequests\t|\tp50\t|\tp90\t|\tp99\t\n")
+
+	for _, s := range summaries {
+		fmt.Fprintf(w, "[\t%s\t]\t%s\t|\t%d\t|\t%v\t|\t%v\t|\t%v\t\n", s.Host, s.Status, s.Count, s.P50, s.P90, s.P99)
+	}
+
+	fmt.Fprint(w, "-------------------------------\n\n")
+}
+
+func printSLABreaches(w io.Writer, summaries []metrics.SLABreachSummary) {
+	if len(summaries) == 0 {
+		return
+	}
+
+	fmt.Fprint(w, "----------SLA breaches---------\n")
+	fmt.Fprint(w, "[\tHost\t]\tBreaches\t\n")
+
+	for _, s := range summaries {
+		fmt.Fprintf(w, "[\t%s\t]\t%d\t\n", s.Host, s.Breaches)
+	}
+
+	fmt.Fprint(w, "-------------------------------\n\n")
+}
+
+func printConnections(w io.Writer, summaries []metrics.ConnectionSummary) {
+	if len(summaries) == 0 {
+		return
+	}
+
+	const PercentConversionMultilpier = 100
+
+	fmt.Fprint(w, "-------Connection stats--------\n")
+	fmt.Fprint(w, "[\tAddress\t]\tNew\t|\tReused\t|\tReuse rate\t\n")
+
+	for _, s := range summaries {
+		fmt.Fprintf(w, "[\t%s\t]\t%d\t|\t%d\t|\t%.1f\t%%\n", s.Address, s.New, s.Reused,
+			float64(s.Reused)/float64(s.New+s.Reused)*PercentConversionMultilpier)
+	}
+
+	fmt.Fprint(w, "-------------------------------\n\n")
+}
+
+// printProxies prints the proxy leaderboard, the best performing proxies first
+func printProxies(w io.Writer, summaries []metrics.ProxySummary) {
+	if len(summaries) == 0 {
+		return
+	}
+
+	const (
+		BytesInMegabyte             = 1024 * 1024
+		PercentConversionMultilpier = 100
+	)
+
+	fmt.Fprint(w, "----------Proxy stats----------\n")
+	fmt.Fprint(w, "[\tProxy\t]\tSuccess rate\t|\tRequests\t|\tp50\t|\tp90\t|\tSent\t|\tReceived\t\n")
+
+	for _, s := range summaries {
+		fmt.Fprintf(w, "[\t%s\t]\t%.1f\t%%\t|\t%d\t|\t%v\t|\t%v\t|\t%.2f\tMB\t|\t%.2f\tMB\n", s.Proxy,
+			s.SuccessRate*PercentConversionMultilpier, s.Success+s.Fail, s.P50, s.P90,
+			float64(s.BytesSent)/BytesInMegabyte, float64(s.BytesReceived)/BytesInMegabyte)
+	}
+
+	fmt.Fprint(w, "-------------------------------\n\n")
+}
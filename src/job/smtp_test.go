@@ -0,0 +1,268 @@
+package job
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testSMTPServer records commands of every session, hold is called before replying to RCPT TO if set
+type testSMTPServer struct {
+	startTLS *tls.Config // STARTTLS is only advertised when set
+	hold     func()
+
+	mu       sync.Mutex
+	sessions [][]string
+	finished int
+}
+
+func startSMTPStub(t *testing.T, server *testSMTPServer) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go server.serve(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func (s *testSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s.mu.Lock()
+	session := len(s.sessions)
+	s.sessions = append(s.sessions, nil)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.finished++
+		s.mu.Unlock()
+	}()
+
+	tp := textproto.NewConn(conn)
+	if err := tp.PrintfLine("220 stub ESMTP"); err != nil {
+		return
+	}
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.sessions[session] = append(s.sessions[session], line)
+		s.mu.Unlock()
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch {
+		case verb == "EHLO" && s.startTLS != nil:
+			err = tp.PrintfLine("250-stub\r\n250 STARTTLS")
+		case verb == "STARTTLS" && s.startTLS != nil:
+			if err = tp.PrintfLine("220 ready"); err == nil {
+				tlsConn := tls.Server(conn, s.startTLS)
+				err = tlsConn.Handshake()
+				tp = textproto.NewConn(tlsConn)
+			}
+		case verb == "RCPT" && s.hold != nil:
+			s.hold()
+
+			err = tp.PrintfLine("250 ok")
+		case verb == "QUIT":
+			_ = tp.PrintfLine("221 bye")
+
+			return
+		case verb == "STARTTLS":
+			err = tp.PrintfLine("502 not implemented")
+		default:
+			err = tp.PrintfLine("250 ok")
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *testSMTPServer) snapshot() (sessions [][]string, finished int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		sessions = append(sessions, append([]string(nil), session...))
+	}
+
+	return sessions, s.finished
+}
+
+func testSMTPCertificates(t *testing.T) []tls.Certificate {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	server.Close()
+
+	return server.TLS.Certificates
+}
+
+func TestSMTPJob(t *testing.T) {
+	t.Parallel()
+
+	certificates := testSMTPCertificates(t)
+
+	testCases := []struct {
+		name     string
+		startTLS bool
+		args     map[string]interface{}
+		want     []string
+	}{
+		{name: "greeting only", args: map[string]interface{}{}},
+		{
+			name: "envelope",
+			args: map[string]interface{}{
+				"helo":      "client.test",
+				"mail_from": `{{ print "from" "@test" }}`,
+				"rcpt_to":   []string{"a@test", "b@test"},
+			},
+			want: []string{"EHLO client.test", "MAIL FROM:<from@test>", "RCPT TO:<a@test>", "RCPT TO:<b@test>"},
+		},
+		{
+			name:     "starttls",
+			startTLS: true,
+			args:     map[string]interface{}{"mail_from": "from@test", "starttls": true},
+			want:     []string{"EHLO localhost", "STARTTLS", "EHLO localhost", "MAIL FROM:<from@test>"},
+		},
+		{
+			name: "starttls not supported",
+			args: map[string]interface{}{"helo": "client.test", "mail_from": "from@test", "starttls": true},
+			want: []string{"EHLO client.test"},
+		},
+	}
+
+	const count = 3
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := &testSMTPServer{}
+			if tc.startTLS {
+				server.startTLS = &tls.Config{Certificates: certificates, MinVersion: tls.VersionTLS12}
+			}
+
+			tc.args["address"] = startSMTPStub(t, server)
+			tc.args["count"] = count
+
+			if _, err := smtpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			waitFor(t, func() bool {
+				_, finished := server.snapshot()
+
+				return finished == count
+			})
+
+			sessions, _ := server.snapshot()
+			for _, session := range sessions {
+				if strings.Join(session, "\n") != strings.Join(tc.want, "\n") {
+					t.Errorf("expected commands %q, got %q", tc.want, session)
+				}
+			}
+		})
+	}
+}
+
+func TestSMTPJobQuit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		held    = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	server := &testSMTPServer{hold: func() {
+		close(held)
+		<-release
+	}}
+	addr := startSMTPStub(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+
+	go func() {
+		_, err := smtpJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+			"address":   addr,
+			"mail_from": "from@test",
+			"rcpt_to":   []string{"to@test"},
+		})
+		done <- err
+	}()
+
+	select {
+	case <-held:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session didn't reach RCPT TO")
+	}
+
+	cancel()
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		_, finished := server.snapshot()
+
+		return finished == 1
+	})
+
+	want := []string{"EHLO localhost", "MAIL FROM:<from@test>", "RCPT TO:<to@test>", "QUIT"}
+	if sessions, _ := server.snapshot(); len(sessions) != 1 || strings.Join(sessions[0], "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected a single session with commands %q, got %q", want, sessions)
+	}
+}
+
+func TestSMTPJobConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range []map[string]interface{}{
+		{"address": "no-port"},
+		{"address": "127.0.0.1:25", "helo": "{{ unclosed"},
+		{"address": "127.0.0.1:25", "rcpt_to": []string{"{{ unclosed"}},
+		{"address": "127.0.0.1:25", "tls": map[string]interface{}{"min_version": "0.9"}},
+	} {
+		if _, err := smtpJob(context.Background(), zap.NewNop(), &GlobalConfig{}, args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync/atomic"
+)
+
+// Supported values for httpJobConfig.TargetSelection
+const (
+	targetRoundRobin  = "round_robin"
+	targetRandom      = "random"
+	targetLeastErrors = "least_errors"
+)
+
+// targetList spreads the requests of a job over several equivalent destinations. Every target counts its consecutive
+// failures so that least_errors selection moves away from the targets that keep failing
+type targetList struct {
+	targets   []*url.URL
+	failures  []int64 // atomic, consecutive failures by target index
+	selection string
+	next      uint64 // atomic
+}
+
+// parseTargets returns nil if there are no targets
+func parseTargets(targets []string, selection string) (*targetList, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	switch selection {
+	case "":
+		selection = targetRoundRobin
+	case targetRoundRobin, targetRandom, targetLeastErrors:
+	default:
+		return nil, fmt.Errorf("unsupported target selection %q, expected one of [%q, %q, %q]", selection,
+			targetRoundRobin, targetRandom, targetLeastErrors)
+	}
+
+	result := &targetList{targets: make([]*url.URL, 0, len(targets)), failures: make([]int64, len(targets)), selection: selection}
+
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return nil, fmt.Errorf("invalid target %q: expected scheme://host[:port]", target)
+		}
+
+		result.targets = append(result.targets, u)
+	}
+
+	return result, nil
+}
+
+// pick returns the index of the target for the next request, nil receiver returns -1
+func (l *targetList) pick() int {
+	if l == nil {
+		return -1
+	}
+
+	n := len(l.targets)
+
+	switch l.selection {
+	case targetRandom:
+		return rand.Intn(n) //nolint:gosec // Cryptographically secure random not required
+	case targetLeastErrors:
+		// ties are broken in round robin order so that healthy targets share the load
+		start := int((atomic.AddUint64(&l.next, 1) - 1) % uint64(n))
+		best, bestFailures := start, atomic.LoadInt64(&l.failures[start])
+
+		for i := 1; i < n && bestFailures > 0; i++ {
+			j := (start + i) % n
+			if failures := atomic.LoadInt64(&l.failures[j]); failures < bestFailures {
+				best, bestFailures = j, failures
+			}
+		}
+
+		return best
+	default:
+		return int((atomic.AddUint64(&l.next, 1) - 1) % uint64(n))
+	}
+}
+
+// apply replaces the scheme and host of the request path with the ones of the target, the rest of the url is kept
+func (l *targetList) apply(path string, i int) (string, error) {
+	if i < 0 {
+		return path, nil
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("error applying target to request path %q: %w", path, err)
+	}
+
+	u.Scheme, u.Host = l.targets[i].Scheme, l.targets[i].Host
+
+	return u.String(), nil
+}
+
+// record updates the failure counter of the target with the result of the request
+func (l *targetList) record(i int, failed bool) {
+	switch {
+	case i < 0:
+	case failed:
+		atomic.AddInt64(&l.failures[i], 1)
+	default:
+		atomic.StoreInt64(&l.failures[i], 0)
+	}
+}
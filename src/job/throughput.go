@@ -0,0 +1,356 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	nethttp "net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// Supported values of throughputJobConfig.Direction
+const (
+	throughputDownload = "download"
+	throughputUpload   = "upload"
+)
+
+const (
+	throughputChunkSize      = 32 * 1024
+	throughputReportInterval = time.Second
+	bytesInMegabyte          = 1024 * 1024
+)
+
+type throughputJobConfig struct {
+	BasicJobConfig
+
+	URL       string
+	Direction string            // "download" (default) streams the response body, "upload" streams the request body
+	Method    string            // GET for downloads and POST for uploads by default
+	Headers   map[string]string // templated once per job
+	Duration  time.Duration     // of the whole job, streams are cut when it's over, not limited if zero
+	ProxyURLs string            `mapstructure:"proxy_urls"`
+	TLS       *http.TLSConfig   `mapstructure:"tls"`
+	Timeout   *time.Duration    // for connecting and for the response headers
+}
+
+// throughputJob streams long-lived requests one per iteration and reports the throughput achieved in either direction.
+// The amount of bytes streamed by all the requests is limited by MaxBytes
+func throughputJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	const defaultTimeout = 10 * time.Second
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig throughputJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	switch jobConfig.Direction {
+	case "":
+		jobConfig.Direction = throughputDownload
+	case throughputDownload, throughputUpload:
+	default:
+		return nil, fmt.Errorf("unsupported direction %q, expected one of [%q, %q]", jobConfig.Direction, throughputDownload, throughputUpload)
+	}
+
+	if jobConfig.Duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, jobConfig.Duration)
+		defer cancel()
+	}
+
+	target := templates.ParseAndExecute(logger, jobConfig.URL, ctx)
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url %q: %w", target, err)
+	}
+
+	if jobConfig.TLS == nil {
+		jobConfig.TLS = &http.TLSConfig{}
+	}
+
+	tlsConfig, err := jobConfig.TLS.Build()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tls config: %w", err)
+	}
+
+	if globalConfig.ProxyURLs != "" && jobConfig.ProxyURLs == "" {
+		jobConfig.ProxyURLs = globalConfig.ProxyURLs
+	}
+
+	timeout := utils.NonNilDurationOrDefault(jobConfig.Timeout, defaultTimeout)
+	proxyFunc := utils.GetProxyFunc(templates.ParseAndExecute(logger, jobConfig.ProxyURLs, ctx), timeout)
+	client := &nethttp.Client{Transport: &nethttp.Transport{
+		DialContext:           func(_ context.Context, network, addr string) (net.Conn, error) { return proxyFunc(network, addr) },
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		DisableCompression:    true, // the throughput is measured on the wire
+	}}
+	defer client.CloseIdleConnections()
+
+	headers := make(map[string]string, len(jobConfig.Headers))
+	for key, value := range jobConfig.Headers {
+		headers[key] = templates.ParseAndExecute(logger, value, ctx)
+	}
+
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	defer trafficMonitor.Flush()
+	defer processedTrafficMonitor.Flush()
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Measuring %v throughput of %v", jobConfig.Direction, u.Host)
+	}
+
+	meter := &throughputMeter{}
+
+	for jobConfig.Next(ctx) && !meter.exhausted(jobConfig.MaxBytes) {
+		stream := &throughputStream{
+			meter:     meter,
+			remaining: meter.remaining(jobConfig.MaxBytes),
+			monitor:   processedTrafficMonitor,
+		}
+		if jobConfig.Direction == throughputUpload {
+			stream.monitor = trafficMonitor
+		}
+
+		reportCtx, stopReporting := context.WithCancel(ctx)
+		go meter.report(reportCtx, u.Host, jobConfig.Direction)
+
+		err := stream.run(ctx, client, jobConfig.Method, target, headers, jobConfig.Direction)
+
+		stopReporting()
+		metrics.SetThroughput(u.Host, jobConfig.Direction, meter.rate(time.Now()))
+
+		// streams cut by the duration or the job shutdown aren't failures
+		if err != nil && ctx.Err() == nil {
+			logger.Debug("error streaming", zap.String("url", target), zap.Error(err))
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+
+			continue
+		}
+
+		backoffController.Reset()
+	}
+
+	elapsed := meter.elapsed(time.Now())
+
+	return map[string]interface{}{
+		"bytes":         meter.total(),
+		"seconds":       elapsed.Seconds(),
+		"mb_per_second": throughput(meter.total(), elapsed),
+	}, nil
+}
+
+// throughputMeter counts the bytes streamed by all the requests of the job since the first one has started
+type throughputMeter struct {
+	bytes   uint64 // atomic
+	started int64  // unix nanoseconds, atomic
+}
+
+func (m *throughputMeter) add(n int) {
+	atomic.CompareAndSwapInt64(&m.started, 0, time.Now().UnixNano())
+	atomic.AddUint64(&m.bytes, uint64(n))
+}
+
+func (m *throughputMeter) total() uint64 {
+	return atomic.LoadUint64(&m.bytes)
+}
+
+func (m *throughputMeter) elapsed(now time.Time) time.Duration {
+	started := atomic.LoadInt64(&m.started)
+	if started == 0 {
+		return 0
+	}
+
+	return now.Sub(time.Unix(0, started))
+}
+
+// rate returns the average throughput in megabytes per second
+func (m *throughputMeter) rate(now time.Time) float64 {
+	return throughput(m.total(), m.elapsed(now))
+}
+
+// remaining returns how many bytes can still be streamed within the limit, -1 if there is no limit
+func (m *throughputMeter) remaining(limit uint64) int64 {
+	if limit == 0 {
+		return -1
+	}
+
+	if total := m.total(); total < limit {
+		return int64(limit - total)
+	}
+
+	return 0
+}
+
+func (m *throughputMeter) exhausted(limit uint64) bool {
+	return m.remaining(limit) == 0
+}
+
+func (m *throughputMeter) report(ctx context.Context, host, direction string) {
+	ticker := time.NewTicker(throughputReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			metrics.SetThroughput(host, direction, m.rate(now))
+		}
+	}
+}
+
+// throughput converts the amount of bytes streamed during the time to megabytes per second
+func throughput(bytes uint64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(bytes) / bytesInMegabyte / elapsed.Seconds()
+}
+
+// throughputStream is a single request of the throughput job, it's also the request body of uploads
+type throughputStream struct {
+	meter     *throughputMeter
+	remaining int64 // bytes left to stream, -1 if not limited
+	monitor   *metrics.Writer
+	chunk     []byte
+}
+
+func (s *throughputStream) run(ctx context.Context, client *nethttp.Client, method, target string, headers map[string]string, direction string) error {
+	var body io.Reader
+
+	if direction == throughputUpload {
+		method = nonEmptyStringOrDefault(method, nethttp.MethodPost)
+		s.chunk = []byte(templates.RandomPayload(throughputChunkSize))
+		body = s
+	}
+
+	req, err := nethttp.NewRequestWithContext(ctx, nonEmptyStringOrDefault(method, nethttp.MethodGet), target, body)
+	if err != nil {
+		return err
+	}
+
+	if body != nil && s.remaining >= 0 {
+		req.ContentLength = s.remaining
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= nethttp.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	if direction == throughputUpload {
+		_, err = io.Copy(io.Discard, resp.Body)
+
+		return err
+	}
+
+	buf := make([]byte, throughputChunkSize)
+
+	for s.remaining != 0 {
+		if s.remaining > 0 && int64(len(buf)) > s.remaining {
+			buf = buf[:s.remaining]
+		}
+
+		n, err := resp.Body.Read(buf)
+		s.count(n)
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read generates the upload body until the limit is reached
+func (s *throughputStream) Read(p []byte) (int, error) {
+	if s.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if s.remaining > 0 && int64(len(p)) > s.remaining {
+		p = p[:s.remaining]
+	}
+
+	n := 0
+	for n < len(p) {
+		n += copy(p[n:], s.chunk)
+	}
+
+	s.count(n)
+
+	return n, nil
+}
+
+func (s *throughputStream) count(n int) {
+	if n == 0 {
+		return
+	}
+
+	s.meter.add(n)
+	s.monitor.Add(uint64(n))
+
+	if s.remaining > 0 {
+		s.remaining -= int64(n)
+	}
+}
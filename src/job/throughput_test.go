@@ -0,0 +1,135 @@
+package job
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/job/config"
+)
+
+func TestThroughputMeter(t *testing.T) {
+	t.Parallel()
+
+	meter := &throughputMeter{}
+	if rate := meter.rate(time.Now()); rate != 0 {
+		t.Errorf("expected zero rate before the first byte, got %v", rate)
+	}
+
+	meter.add(bytesInMegabyte)
+	meter.add(bytesInMegabyte)
+
+	started := time.Unix(0, atomic.LoadInt64(&meter.started))
+	if rate := meter.rate(started.Add(4 * time.Second)); rate != 0.5 {
+		t.Errorf("expected 0.5 MB/s, got %v", rate)
+	}
+
+	if remaining := meter.remaining(0); remaining != -1 {
+		t.Errorf("expected no limit, got %d", remaining)
+	}
+
+	if remaining := meter.remaining(3 * bytesInMegabyte); remaining != bytesInMegabyte {
+		t.Errorf("expected %d bytes left, got %d", bytesInMegabyte, remaining)
+	}
+
+	if !meter.exhausted(bytesInMegabyte) {
+		t.Error("expected the limit to be exhausted")
+	}
+}
+
+func TestThroughputDownload(t *testing.T) {
+	t.Parallel()
+
+	const size = 1 << 20
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		_, _ = w.Write(make([]byte, size))
+	}))
+	t.Cleanup(server.Close)
+
+	data, err := throughputJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{"url": server.URL, "count": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := data.(map[string]interface{})
+	if result["bytes"] != uint64(size) {
+		t.Fatalf("expected %d bytes, got %v", size, result["bytes"])
+	}
+
+	seconds := result["seconds"].(float64)
+	if expected := float64(size) / bytesInMegabyte / seconds; math.Abs(result["mb_per_second"].(float64)-expected) > 1e-9 {
+		t.Errorf("expected %v MB/s, got %v", expected, result["mb_per_second"])
+	}
+}
+
+func TestThroughputUpload(t *testing.T) {
+	t.Parallel()
+
+	const maxBytes = 100000
+
+	var received int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		atomic.AddInt64(&received, n)
+	}))
+	t.Cleanup(server.Close)
+
+	data, err := throughputJob(context.Background(), zap.NewNop(), &GlobalConfig{},
+		config.Args{"url": server.URL, "direction": "upload", "max_bytes": maxBytes, "interval_ms": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt64(&received); got != maxBytes {
+		t.Errorf("expected the server to receive %d bytes, got %d", maxBytes, got)
+	}
+
+	if result := data.(map[string]interface{}); result["bytes"] != uint64(maxBytes) {
+		t.Errorf("expected %d bytes, got %v", maxBytes, result["bytes"])
+	}
+}
+
+func TestThroughputDuration(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, 1024)
+
+		for r.Context().Err() == nil {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	start := time.Now()
+
+	data, err := throughputJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{"url": server.URL, "duration": "200ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the endless stream to stop after the duration, took %v", elapsed)
+	}
+
+	if result := data.(map[string]interface{}); result["bytes"].(uint64) == 0 {
+		t.Error("expected some bytes to be streamed")
+	}
+
+	if _, err := throughputJob(context.Background(), zap.NewNop(), &GlobalConfig{}, config.Args{"url": server.URL, "direction": "sideways"}); err == nil {
+		t.Error("expected an error for an unsupported direction")
+	}
+}
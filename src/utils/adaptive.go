@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveConcurrencyConfig describes bounds and signals of the AdaptiveConcurrency controller
+type AdaptiveConcurrencyConfig struct {
+	Min           int           `mapstructure:"min"`            // Lower bound and initial concurrency
+	Max           int           `mapstructure:"max"`            // Upper bound of concurrency
+	TargetLatency time.Duration `mapstructure:"target_latency"` // Concurrency is decreased when average latency of successful requests is above it
+	MaxErrorRate  float64       `mapstructure:"max_error_rate"` // Concurrency is decreased when the share of failed requests is above it
+	Interval      time.Duration `mapstructure:"interval"`       // How often to adjust concurrency
+}
+
+// AdaptiveConcurrency is an AIMD controller: concurrency is increased by one while the target keeps up
+// and halved once it gets overloaded. All methods are safe to call concurrently and on a nil controller
+type AdaptiveConcurrency struct {
+	AdaptiveConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    int
+	requests int
+	failures int
+	latency  time.Duration // total latency of successful requests
+}
+
+// NewAdaptiveConcurrency returns a controller starting at the minimal concurrency or nil if the config is nil
+func NewAdaptiveConcurrency(c *AdaptiveConcurrencyConfig) *AdaptiveConcurrency {
+	const (
+		defaultMax           = 10
+		defaultTargetLatency = time.Second
+		defaultMaxErrorRate  = 0.1
+		defaultInterval      = time.Second
+	)
+
+	if c == nil {
+		return nil
+	}
+
+	a := &AdaptiveConcurrency{AdaptiveConcurrencyConfig: *c}
+
+	if a.Min <= 0 {
+		a.Min = 1
+	}
+
+	if a.Max <= 0 {
+		a.Max = defaultMax
+	}
+
+	if a.Max < a.Min {
+		a.Max = a.Min
+	}
+
+	if a.TargetLatency <= 0 {
+		a.TargetLatency = defaultTargetLatency
+	}
+
+	if a.MaxErrorRate <= 0 {
+		a.MaxErrorRate = defaultMaxErrorRate
+	}
+
+	if a.Interval <= 0 {
+		a.Interval = defaultInterval
+	}
+
+	a.limit = a.Min
+
+	return a
+}
+
+// Limit returns the current concurrency
+func (a *AdaptiveConcurrency) Limit() int {
+	if a == nil {
+		return 1
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.limit
+}
+
+// Observe records the outcome of a single request
+func (a *AdaptiveConcurrency) Observe(latency time.Duration, failed bool) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests++
+
+	if failed {
+		a.failures++
+	} else {
+		a.latency += latency
+	}
+}
+
+// Adjust updates the concurrency based on the requests observed since the previous adjustment and returns it
+func (a *AdaptiveConcurrency) Adjust() int {
+	if a == nil {
+		return 1
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.requests == 0 {
+		return a.limit
+	}
+
+	overloaded := float64(a.failures)/float64(a.requests) > a.MaxErrorRate
+	if succeeded := a.requests - a.failures; succeeded > 0 && a.latency/time.Duration(succeeded) > a.TargetLatency {
+		overloaded = true
+	}
+
+	const decreaseDivisor = 2
+
+	if overloaded {
+		a.limit -= a.limit / decreaseDivisor
+		if a.limit < a.Min {
+			a.limit = a.Min
+		}
+	} else if a.limit < a.Max {
+		a.limit++
+	}
+
+	a.requests, a.failures, a.latency = 0, 0, 0
+
+	return a.limit
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	t.Parallel()
+
+	a := NewAdaptiveConcurrency(&AdaptiveConcurrencyConfig{Min: 2, Max: 5, TargetLatency: 100 * time.Millisecond, MaxErrorRate: 0.5})
+
+	steps := []struct {
+		name      string
+		latencies []time.Duration
+		failures  int
+		want      int
+	}{
+		{name: "no requests", want: 2},
+		{name: "fast", latencies: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, want: 3},
+		{name: "still fast", latencies: []time.Duration{50 * time.Millisecond}, want: 4},
+		{name: "slow", latencies: []time.Duration{50 * time.Millisecond, 200 * time.Millisecond}, want: 2},
+		{name: "ramp up", latencies: []time.Duration{time.Millisecond}, want: 3},
+		{name: "ramp up again", latencies: []time.Duration{time.Millisecond}, want: 4},
+		{name: "up to max", latencies: []time.Duration{time.Millisecond}, want: 5},
+		{name: "capped", latencies: []time.Duration{time.Millisecond}, want: 5},
+		{name: "errors", latencies: []time.Duration{time.Millisecond}, failures: 2, want: 3},
+		{name: "floored", failures: 1, want: 2},
+	}
+
+	for _, step := range steps {
+		for _, latency := range step.latencies {
+			a.Observe(latency, false)
+		}
+
+		for i := 0; i < step.failures; i++ {
+			a.Observe(time.Minute, true)
+		}
+
+		if got := a.Adjust(); got != step.want || a.Limit() != step.want {
+			t.Errorf("%v: expected concurrency %d, got %d", step.name, step.want, got)
+		}
+	}
+}
+
+func TestAdaptiveConcurrencyDefaults(t *testing.T) {
+	t.Parallel()
+
+	if a := NewAdaptiveConcurrency(nil); a != nil {
+		t.Fatal("expected nil controller without config")
+	}
+
+	var a *AdaptiveConcurrency
+
+	a.Observe(time.Second, true)
+
+	if a.Adjust() != 1 || a.Limit() != 1 {
+		t.Error("expected nil controller to run a single loop")
+	}
+
+	a = NewAdaptiveConcurrency(&AdaptiveConcurrencyConfig{Min: 4, Max: 2})
+	if a.Limit() != 4 || a.Max != 4 || a.TargetLatency != time.Second || a.Interval != time.Second {
+		t.Errorf("unexpected defaults %+v", a.AdaptiveConcurrencyConfig)
+	}
+}
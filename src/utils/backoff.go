@@ -2,6 +2,8 @@ package utils
 
 import (
 	"context"
+	"math"
+	"math/rand"
 	"time"
 )
 
@@ -15,11 +17,20 @@ func Sleep(ctx context.Context, t time.Duration) bool {
 }
 
 type BackoffConfig struct {
-	Multiplier int           `mapstructure:"backoff_multiplier"`
-	Limit      int           `mapstructure:"backoff_limit"`
-	Timeout    time.Duration `mapstructure:"backoff_timeout"`
+	Multiplier     int           `mapstructure:"backoff_multiplier"`
+	Limit          int           `mapstructure:"backoff_limit"`
+	Timeout        time.Duration `mapstructure:"backoff_timeout"`
+	Jitter         string        `mapstructure:"backoff_jitter"`          // "full", "equal", or empty to disable
+	MaxTimeout     time.Duration `mapstructure:"backoff_max_timeout"`     // caps the timeout, no cap if not positive
+	ResetThreshold int           `mapstructure:"backoff_reset_threshold"` // consecutive successes needed to reset the backoff, 1 if not positive
 }
 
+// Supported values for BackoffConfig.Jitter
+const (
+	JitterFull  = "full"  // pick a random timeout in [0, timeout]
+	JitterEqual = "equal" // pick a random timeout in [timeout/2, timeout]
+)
+
 func DefaultBackoffConfig() BackoffConfig {
 	const (
 		defaultMultiplier = 10
@@ -29,33 +40,83 @@ func DefaultBackoffConfig() BackoffConfig {
 	return BackoffConfig{Multiplier: defaultMultiplier, Limit: defaultLimit, Timeout: time.Microsecond}
 }
 
+// NonNilBackoffConfigOrDefault returns a copy of the config if it's set, its optional settings (jitter, max timeout and reset threshold)
+// are inherited from the default config when not set
 func NonNilBackoffConfigOrDefault(c *BackoffConfig, defaultConfig BackoffConfig) *BackoffConfig {
-	if c != nil {
-		return c
+	if c == nil {
+		return &defaultConfig
+	}
+
+	result := *c
+
+	if result.Jitter == "" {
+		result.Jitter = defaultConfig.Jitter
+	}
+
+	if result.MaxTimeout <= 0 {
+		result.MaxTimeout = defaultConfig.MaxTimeout
+	}
+
+	if result.ResetThreshold <= 0 {
+		result.ResetThreshold = defaultConfig.ResetThreshold
 	}
 
-	return &defaultConfig
+	return &result
 }
 
 type BackoffController struct {
 	BackoffConfig
-	count int
+	count     int
+	successes int // consecutive successes since the last failure
 }
 
 func NewBackoffController(c *BackoffConfig) BackoffController {
 	return BackoffController{BackoffConfig: *NonNilBackoffConfigOrDefault(c, DefaultBackoffConfig())}
 }
 
+// GetTimeout returns the current timeout, it never exceeds MaxTimeout when it's set
 func (c BackoffController) GetTimeout() time.Duration {
 	result := c.Timeout
-	for i := 0; i < c.count; i++ {
+	for i := 0; i < c.count && !c.capped(result); i++ {
+		if c.Multiplier > 0 && result > math.MaxInt64/time.Duration(c.Multiplier) {
+			result = math.MaxInt64 // don't let the timeout overflow
+
+			break
+		}
+
 		result *= time.Duration(c.Multiplier)
 	}
 
-	return result
+	if c.capped(result) {
+		result = c.MaxTimeout
+	}
+
+	switch c.Jitter {
+	case JitterFull:
+		return randomDuration(result)
+	case JitterEqual:
+		return result/2 + randomDuration(result-result/2)
+	default:
+		return result
+	}
+}
+
+func (c BackoffController) capped(d time.Duration) bool {
+	return c.MaxTimeout > 0 && d >= c.MaxTimeout
+}
+
+// randomDuration returns a random duration in [0, d]
+func randomDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1)) //nolint:gosec // Cryptographically secure random not required
 }
 
 func (c *BackoffController) Increment() *BackoffController {
+	c.successes = 0
+
 	if c.count < c.Limit {
 		c.count++
 	}
@@ -64,8 +125,14 @@ func (c *BackoffController) Increment() *BackoffController {
 	return c
 }
 
+// Reset is called on success, the backoff is only reset once ResetThreshold consecutive successes are reached
+// so that a single success doesn't make it oscillate against a flaky target
 func (c *BackoffController) Reset() {
-	c.count = 0
+	c.successes++
+
+	if c.successes >= c.ResetThreshold {
+		c.count, c.successes = 0, 0
+	}
 }
 
 type Counter struct {
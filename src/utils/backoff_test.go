@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffJitter(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		jitter string
+		min    func(time.Duration) time.Duration
+	}{
+		{jitter: "", min: func(d time.Duration) time.Duration { return d }},
+		{jitter: JitterFull, min: func(time.Duration) time.Duration { return 0 }},
+		{jitter: JitterEqual, min: func(d time.Duration) time.Duration { return d / 2 }},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.jitter, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewBackoffController(&BackoffConfig{Multiplier: 2, Limit: 3, Timeout: time.Second, Jitter: tc.jitter})
+
+			for i := 0; i < 100; i++ {
+				controller.Increment()
+
+				limit := time.Second << controller.count
+				if timeout := controller.GetTimeout(); timeout < tc.min(limit) || timeout > limit {
+					t.Fatalf("timeout %v out of bounds [%v, %v]", timeout, tc.min(limit), limit)
+				}
+			}
+
+			if controller.count != 3 {
+				t.Errorf("expected count to be capped at 3, got %d", controller.count)
+			}
+
+			controller.Reset()
+
+			if timeout := controller.GetTimeout(); timeout < tc.min(time.Second) || timeout > time.Second {
+				t.Errorf("timeout %v out of bounds after reset", timeout)
+			}
+		})
+	}
+}
+
+func TestBackoffJitterDiverges(t *testing.T) {
+	t.Parallel()
+
+	config := BackoffConfig{Multiplier: 10, Limit: 6, Timeout: time.Millisecond, Jitter: JitterFull}
+	a, b := NewBackoffController(&config), NewBackoffController(&config)
+	a.Increment()
+	b.Increment()
+
+	for i := 0; i < 10; i++ {
+		if a.GetTimeout() != b.GetTimeout() {
+			return
+		}
+	}
+
+	t.Error("expected jittered timeouts of identical controllers to diverge")
+}
+
+func TestNonNilBackoffConfigOrDefaultJitter(t *testing.T) {
+	t.Parallel()
+
+	defaultConfig := DefaultBackoffConfig()
+	defaultConfig.Jitter = JitterEqual
+
+	if c := NonNilBackoffConfigOrDefault(&BackoffConfig{Timeout: time.Second}, defaultConfig); c.Jitter != JitterEqual || c.Timeout != time.Second {
+		t.Errorf("expected jitter to be inherited from the default config, got %+v", c)
+	}
+
+	if c := NonNilBackoffConfigOrDefault(&BackoffConfig{Jitter: JitterFull}, defaultConfig); c.Jitter != JitterFull {
+		t.Errorf("expected explicit jitter to be kept, got %+v", c)
+	}
+}
+
+func TestBackoffMaxTimeout(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		config BackoffConfig
+		jitter bool
+	}{
+		{name: "capped", config: BackoffConfig{Multiplier: 10, Limit: 6, Timeout: time.Millisecond, MaxTimeout: 50 * time.Millisecond}},
+		{name: "timeout above cap", config: BackoffConfig{Multiplier: 2, Limit: 3, Timeout: time.Second, MaxTimeout: 100 * time.Millisecond}},
+		{name: "jitter", config: BackoffConfig{Multiplier: 10, Limit: 6, Timeout: time.Millisecond, MaxTimeout: 50 * time.Millisecond, Jitter: JitterEqual}},
+		{name: "huge limit", config: BackoffConfig{Multiplier: 10, Limit: 1000, Timeout: time.Second, MaxTimeout: time.Minute}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewBackoffController(&tc.config)
+
+			for i := 0; i <= tc.config.Limit; i++ {
+				if timeout := controller.GetTimeout(); timeout > tc.config.MaxTimeout || timeout < 0 {
+					t.Fatalf("timeout %v after %d failures exceeds the cap of %v", timeout, i, tc.config.MaxTimeout)
+				}
+
+				controller.Increment()
+			}
+
+			if tc.config.Jitter == "" && controller.GetTimeout() != tc.config.MaxTimeout {
+				t.Errorf("expected the timeout to reach the cap of %v, got %v", tc.config.MaxTimeout, controller.GetTimeout())
+			}
+		})
+	}
+
+	// without the cap the timeout grows up to the limit but doesn't overflow
+	controller := NewBackoffController(&BackoffConfig{Multiplier: 10, Limit: 100, Timeout: time.Second})
+	for i := 0; i < 100; i++ {
+		controller.Increment()
+	}
+
+	if timeout := controller.GetTimeout(); timeout <= 0 {
+		t.Errorf("expected the timeout not to overflow, got %v", timeout)
+	}
+}
+
+func TestBackoffResetThreshold(t *testing.T) {
+	t.Parallel()
+
+	const threshold = 3
+
+	controller := NewBackoffController(&BackoffConfig{Multiplier: 2, Limit: 5, Timeout: time.Millisecond, ResetThreshold: threshold})
+	controller.Increment().Increment()
+
+	backedOff := controller.GetTimeout()
+
+	for i := 0; i < threshold-1; i++ {
+		controller.Reset()
+
+		if controller.GetTimeout() != backedOff {
+			t.Fatalf("expected the backoff to be kept after %d successes, got %v", i+1, controller.GetTimeout())
+		}
+	}
+
+	// a failure in between starts counting the successes over
+	controller.Increment()
+	backedOff = controller.GetTimeout()
+
+	for i := 0; i < threshold-1; i++ {
+		controller.Reset()
+	}
+
+	if controller.GetTimeout() != backedOff {
+		t.Fatalf("expected the backoff to be kept after a failure interrupted the successes, got %v", controller.GetTimeout())
+	}
+
+	controller.Reset()
+
+	if controller.GetTimeout() != time.Millisecond {
+		t.Errorf("expected the backoff to be reset after %d consecutive successes, got %v", threshold, controller.GetTimeout())
+	}
+
+	// the default threshold resets on the first success
+	controller = NewBackoffController(&BackoffConfig{Multiplier: 2, Limit: 5, Timeout: time.Millisecond})
+	controller.Increment().Reset()
+
+	if controller.GetTimeout() != time.Millisecond {
+		t.Errorf("expected the backoff to be reset after a single success by default, got %v", controller.GetTimeout())
+	}
+}
+
+func TestNonNilBackoffConfigOrDefaultInherits(t *testing.T) {
+	t.Parallel()
+
+	defaultConfig := DefaultBackoffConfig()
+	defaultConfig.MaxTimeout, defaultConfig.ResetThreshold = time.Second, 3
+
+	c := NonNilBackoffConfigOrDefault(&BackoffConfig{Timeout: time.Millisecond}, defaultConfig)
+	if c.MaxTimeout != time.Second || c.ResetThreshold != 3 || c.Timeout != time.Millisecond {
+		t.Errorf("expected max timeout and reset threshold to be inherited from the default config, got %+v", c)
+	}
+
+	c = NonNilBackoffConfigOrDefault(&BackoffConfig{MaxTimeout: time.Minute, ResetThreshold: 5}, defaultConfig)
+	if c.MaxTimeout != time.Minute || c.ResetThreshold != 5 {
+		t.Errorf("expected explicit max timeout and reset threshold to be kept, got %+v", c)
+	}
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// CircuitState is a state of the CircuitBreaker
+type CircuitState string
+
+// Possible CircuitBreaker states
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"` // Consecutive failures required to open the circuit
+	Window           time.Duration `mapstructure:"window"`            // Failures have to happen within this window to be counted as consecutive
+	CoolDown         time.Duration `mapstructure:"cool_down"`         // How long to wait before probing the target again
+}
+
+// CircuitBreaker stops sending requests to the target that keeps failing for a cool-down period.
+// All methods are safe to call on a nil breaker which is always closed
+type CircuitBreaker struct {
+	CircuitBreakerConfig
+
+	state        CircuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+
+	now          func() time.Time
+	onTransition func(from, to CircuitState)
+}
+
+// NewCircuitBreaker returns a closed circuit breaker or nil if the config is nil
+func NewCircuitBreaker(c *CircuitBreakerConfig, onTransition func(from, to CircuitState)) *CircuitBreaker {
+	const (
+		defaultFailureThreshold = 10
+		defaultWindow           = time.Minute
+		defaultCoolDown         = 30 * time.Second
+	)
+
+	if c == nil {
+		return nil
+	}
+
+	b := &CircuitBreaker{CircuitBreakerConfig: *c, state: CircuitClosed, now: time.Now, onTransition: onTransition}
+
+	if b.FailureThreshold <= 0 {
+		b.FailureThreshold = defaultFailureThreshold
+	}
+
+	if b.Window <= 0 {
+		b.Window = defaultWindow
+	}
+
+	if b.CoolDown <= 0 {
+		b.CoolDown = defaultCoolDown
+	}
+
+	return b
+}
+
+// State returns current state of the breaker
+func (b *CircuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+
+	return b.state
+}
+
+// Wait blocks until the breaker allows the next request, returns false if the context is done first
+func (b *CircuitBreaker) Wait(ctx context.Context) bool {
+	if b == nil || b.state != CircuitOpen {
+		return ctx.Err() == nil
+	}
+
+	if !Sleep(ctx, b.openedAt.Add(b.CoolDown).Sub(b.now())) {
+		return false
+	}
+
+	b.transition(CircuitHalfOpen)
+
+	return true
+}
+
+// Success records a successful request
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+
+	b.failures = 0
+
+	if b.state == CircuitHalfOpen {
+		b.transition(CircuitClosed)
+	}
+}
+
+// Failure records a failed request
+func (b *CircuitBreaker) Failure() {
+	if b == nil {
+		return
+	}
+
+	now := b.now()
+
+	if b.state == CircuitHalfOpen {
+		b.open(now)
+
+		return
+	}
+
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.Window {
+		b.failures, b.firstFailure = 0, now
+	}
+
+	if b.failures++; b.failures >= b.FailureThreshold {
+		b.open(now)
+	}
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.failures = 0
+	b.openedAt = now
+	b.transition(CircuitOpen)
+}
+
+func (b *CircuitBreaker) transition(to CircuitState) {
+	from := b.state
+	b.state = to
+
+	if b.onTransition != nil {
+		b.onTransition(from, to)
+	}
+}
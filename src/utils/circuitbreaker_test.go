@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var transitions []string
+
+	breaker := NewCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 3, Window: time.Minute, CoolDown: time.Second},
+		func(from, to CircuitState) { transitions = append(transitions, string(from)+"->"+string(to)) })
+
+	clock := time.Now()
+	breaker.now = func() time.Time { return clock }
+
+	ctx := context.Background()
+
+	// failures outside of the window and interrupted by successes don't trip the breaker
+	breaker.Failure()
+	breaker.Failure()
+	breaker.Success()
+	breaker.Failure()
+	breaker.Failure()
+
+	clock = clock.Add(2 * time.Minute)
+
+	breaker.Failure()
+
+	if breaker.State() != CircuitClosed {
+		t.Fatalf("expected breaker to stay closed, got %v", breaker.State())
+	}
+
+	breaker.Failure()
+	breaker.Failure()
+
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("expected breaker to open, got %v", breaker.State())
+	}
+
+	// cool-down has passed already
+	clock = clock.Add(time.Second)
+
+	if !breaker.Wait(ctx) || breaker.State() != CircuitHalfOpen {
+		t.Fatalf("expected breaker to be half-open, got %v", breaker.State())
+	}
+
+	// failed probe opens the circuit again
+	breaker.Failure()
+
+	clock = clock.Add(time.Second)
+
+	if !breaker.Wait(ctx) {
+		t.Fatal("expected wait to succeed")
+	}
+
+	breaker.Success()
+
+	if breaker.State() != CircuitClosed {
+		t.Fatalf("expected breaker to close, got %v", breaker.State())
+	}
+
+	const expected = "closed->open,open->half_open,half_open->open,open->half_open,half_open->closed"
+	if got := strings.Join(transitions, ","); got != expected {
+		t.Errorf("expected transitions %v, got %v", expected, got)
+	}
+}
+
+func TestCircuitBreakerWaitCanceled(t *testing.T) {
+	t.Parallel()
+
+	breaker := NewCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Hour}, nil)
+	breaker.Failure()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if breaker.Wait(ctx) {
+		t.Error("expected wait to be interrupted by the context")
+	}
+}
+
+func TestNilCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var breaker *CircuitBreaker
+
+	breaker.Failure()
+	breaker.Success()
+
+	if !breaker.Wait(context.Background()) || breaker.State() != CircuitClosed {
+		t.Error("nil breaker should always be closed")
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"math/rand"
+	"net"
+	"time"
+)
+
+// happyEyeballsDelay is the connection attempt delay recommended by RFC 8305
+const happyEyeballsDelay = 250 * time.Millisecond
+
+// happyEyeballsOrder shuffles the addresses of each ip version to spread the connections over them and interleaves
+// the versions starting with the version of the first address as resolvers list the preferred addresses first
+func happyEyeballsOrder(ips []net.IP) []net.IP {
+	if len(ips) < 2 {
+		return ips
+	}
+
+	var preferred, other []net.IP
+
+	firstIsV4 := ips[0].To4() != nil
+
+	for _, ip := range ips {
+		if (ip.To4() != nil) == firstIsV4 {
+			preferred = append(preferred, ip)
+		} else {
+			other = append(other, ip)
+		}
+	}
+
+	for _, family := range [][]net.IP{preferred, other} {
+		family := family
+		rand.Shuffle(len(family), func(i, j int) { family[i], family[j] = family[j], family[i] }) //nolint:gosec // Cryptographically secure random not required
+	}
+
+	result := make([]net.IP, 0, len(ips))
+
+	for i := 0; len(result) < len(ips); i++ {
+		if i < len(preferred) {
+			result = append(result, preferred[i])
+		}
+
+		if i < len(other) {
+			result = append(result, other[i])
+		}
+	}
+
+	return result
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+	i    int // of the dialed address
+}
+
+// dialHappyEyeballs dials the addresses in order as described by RFC 8305: the next attempt starts as soon as the previous one
+// fails or hasn't connected within the delay so that an unreachable address (i.e. an ipv6 one on a host without ipv6
+// connectivity) doesn't stall the dial. The first connection wins, the attempts that connect later are closed.
+// The error of the first attempt is returned if none of them connects
+func dialHappyEyeballs(dial ProxyFunc, network string, addrs []string, delay time.Duration) (net.Conn, error) {
+	if len(addrs) == 1 {
+		return dial(network, addrs[0])
+	}
+
+	// buffered so that the attempts that lost never block
+	results := make(chan dialResult, len(addrs))
+
+	var next, pending int
+
+	start := func() <-chan time.Time {
+		go func(i int) {
+			conn, err := dial(network, addrs[i])
+			results <- dialResult{conn: conn, err: err, i: i}
+		}(next)
+
+		next++
+		pending++
+
+		return time.After(delay)
+	}
+
+	attemptDelay := start()
+	errs := make([]error, len(addrs))
+
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+
+			if res.err == nil {
+				go closeLateConns(results, pending)
+
+				return res.conn, nil
+			}
+
+			errs[res.i] = res.err
+
+			if next < len(addrs) {
+				attemptDelay = start()
+			}
+		case <-attemptDelay:
+			if next < len(addrs) {
+				attemptDelay = start()
+			}
+		}
+	}
+
+	return nil, errs[0]
+}
+
+// closeLateConns closes the connections of the pending attempts once they are done
+func closeLateConns(results <-chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.conn != nil {
+			res.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHappyEyeballsOrder(t *testing.T) {
+	t.Parallel()
+
+	ips := []net.IP{net.ParseIP("::1"), net.ParseIP("::2"), net.ParseIP("10.0.0.1"), net.ParseIP("::3"), net.ParseIP("10.0.0.2")}
+
+	ordered := happyEyeballsOrder(ips)
+	if len(ordered) != len(ips) {
+		t.Fatalf("expected all the %d addresses, got %v", len(ips), ordered)
+	}
+
+	// v6 (preferred as the first one), v4, v6, v4, v6
+	for i, ip := range ordered {
+		if isV4 := ip.To4() != nil; isV4 != (i%2 == 1) {
+			t.Errorf("expected the ip versions to be interleaved starting with ipv6, got %v", ordered)
+
+			break
+		}
+	}
+}
+
+func TestDialHappyEyeballs(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	// nothing listens on the port of the ipv6 address so it's refused (or unreachable without ipv6 connectivity)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	addrs := []string{net.JoinHostPort("::1", "1"), listener.Addr().String()}
+
+	const delay = 500 * time.Millisecond
+
+	unreachable := make(chan struct{})
+	t.Cleanup(func() { close(unreachable) })
+
+	testCases := []struct {
+		name     string
+		dial     ProxyFunc
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{name: "refused", dial: net.Dial, maxDelay: delay / 2},
+		{
+			name: "unreachable",
+			dial: func(network, addr string) (net.Conn, error) {
+				if strings.HasPrefix(addr, "[") {
+					<-unreachable
+
+					return nil, errors.New("unreachable")
+				}
+
+				return net.Dial(network, addr)
+			},
+			minDelay: delay,
+			maxDelay: 2 * delay,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := time.Now()
+
+			conn, err := dialHappyEyeballs(tc.dial, "tcp", addrs, delay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if elapsed := time.Since(start); elapsed < tc.minDelay || elapsed > tc.maxDelay {
+				t.Errorf("expected ipv4 to be dialed in [%v, %v], took %v", tc.minDelay, tc.maxDelay, elapsed)
+			}
+
+			if remote := conn.RemoteAddr().String(); remote != net.JoinHostPort("127.0.0.1", port) {
+				t.Errorf("expected a connection to ipv4 address, got %v", remote)
+			}
+		})
+	}
+
+	if _, err := dialHappyEyeballs(net.Dial, "tcp", []string{addrs[0], "127.0.0.1:1"}, delay); err == nil {
+		t.Error("expected an error when none of the addresses connects")
+	}
+}
+
+func TestResolverDialIPVersion(t *testing.T) {
+	t.Parallel()
+
+	handler := &stubDNSHandler{}
+	addr := startStubDNSServer(t, handler)
+
+	resolver, err := NewResolver(&ResolverConfig{Servers: []string{addr}, Hosts: map[string]string{"origin.example": "192.0.2.10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dialed []string
+
+	dial := resolver.Dial(func(network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+" "+addr)
+
+		return nil, errors.New("refused")
+	})
+
+	for _, tc := range []struct {
+		network, addr string
+		dialed        string
+	}{
+		{network: "tcp", addr: "target.test:80", dialed: "tcp 127.0.0.1:80"},
+		{network: "tcp6", addr: "v6.test:80", dialed: "tcp [::1]:80"},
+		{network: "tcp4", addr: "origin.example:80", dialed: "tcp 192.0.2.10:80"},
+		{network: "tcp6", addr: "target.test:80"},
+		{network: "tcp4", addr: "[::1]:80"},
+	} {
+		dialed = nil
+
+		_, err := dial(tc.network, tc.addr)
+
+		var resolveErr *ResolveError
+		if got := strings.Join(dialed, ","); got != tc.dialed || (tc.dialed == "") != errors.As(err, &resolveErr) {
+			t.Errorf("%v %v: expected %q to be dialed, got %q (%v)", tc.network, tc.addr, tc.dialed, got, err)
+		}
+	}
+}
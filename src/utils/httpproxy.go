@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ErrProxyAuthRequired is returned by http proxy dial functions when the proxy rejects the credentials with 407
+var ErrProxyAuthRequired = errors.New("proxy authentication required")
+
+const (
+	defaultHTTPProxyPort  = "80"
+	defaultHTTPSProxyPort = "443"
+)
+
+// httpProxyFunc tunnels every connection through the http (or https) proxy with CONNECT so that tls to the target
+// is negotiated end to end, the proxy only sees the target address. Plain forwarding of absolute-form requests is
+// never used since the dial functions don't know the requests, http targets are tunneled the same way
+func httpProxyFunc(u *url.URL, forward proxy.Dialer, timeout time.Duration) ProxyFunc {
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		port := defaultHTTPProxyPort
+		if u.Scheme == "https" {
+			port = defaultHTTPSProxyPort
+		}
+
+		proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var authorization string
+
+	if u.User != nil {
+		password, _ := u.User.Password()
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password))
+	}
+
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := forward.Dial(network, proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme == "https" {
+			conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+		}
+
+		if conn, err = connectTunnel(conn, addr, authorization, timeout); err != nil {
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
+
+// connectTunnel asks the proxy to connect to the address and returns the connection to the target once it has
+func connectTunnel(conn net.Conn, addr, authorization string, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			conn.Close()
+
+			return nil, err
+		}
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	if authorization != "" {
+		req.Header.Set("Proxy-Authorization", authorization)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusProxyAuthRequired:
+		conn.Close()
+
+		return nil, ErrProxyAuthRequired
+	default:
+		conn.Close()
+
+		return nil, fmt.Errorf("proxy refused to connect to %v: %v", addr, resp.Status)
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	// the target may have already sent something that has been read along with the response
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn reads what's left in the reader before reading from the connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
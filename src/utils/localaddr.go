@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+)
+
+// ResolveLocalAddr parses the source address to bind outgoing connections to, either an ip (a random port is used)
+// or ip:port, and makes sure it's available on this host by binding to it. Returns nil if the address is empty
+func ResolveLocalAddr(network, addr string) (net.Addr, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	host, port := addr, "0"
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		host, port = h, p
+	}
+
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("invalid local address %q: expected ip or ip:port", addr)
+	}
+
+	hostPort := net.JoinHostPort(host, port)
+
+	switch network {
+	case "udp", "udp4", "udp6":
+		udpAddr, err := net.ResolveUDPAddr(network, hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local address %q: %w", addr, err)
+		}
+
+		conn, err := net.ListenUDP(network, udpAddr)
+		if err != nil {
+			return nil, fmt.Errorf("local address %q is not available: %w", addr, err)
+		}
+
+		conn.Close()
+
+		return udpAddr, nil
+	default:
+		tcpAddr, err := net.ResolveTCPAddr(network, hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local address %q: %w", addr, err)
+		}
+
+		listener, err := net.ListenTCP(network, tcpAddr)
+		if err != nil {
+			return nil, fmt.Errorf("local address %q is not available: %w", addr, err)
+		}
+
+		listener.Close()
+
+		return tcpAddr, nil
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestResolveLocalAddr(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		network string
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", network: "tcp"},
+		{name: "ip", network: "tcp", addr: "127.0.0.2", want: "127.0.0.2:0"},
+		{name: "ip and port", network: "udp", addr: "127.0.0.2:0", want: "127.0.0.2:0"},
+		{name: "udp ip", network: "udp", addr: "127.0.0.3", want: "127.0.0.3:0"},
+		{name: "hostname", network: "tcp", addr: "localhost", wantErr: true},
+		{name: "garbage", network: "tcp", addr: "127.0.0.1:port", wantErr: true},
+		{name: "unavailable tcp", network: "tcp", addr: "192.0.2.1", wantErr: true},
+		{name: "unavailable udp", network: "udp", addr: "192.0.2.1", wantErr: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ResolveLocalAddr(tc.network, tc.addr)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			switch {
+			case tc.wantErr:
+			case tc.want == "":
+				if got != nil {
+					t.Errorf("expected nil address, got %v", got)
+				}
+			case got == nil || got.String() != tc.want || got.Network() != tc.network:
+				t.Errorf("expected %v address %v, got %v", tc.network, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetProxyFuncFrom(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	localAddr, err := ResolveLocalAddr("tcp", "127.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := GetProxyFuncFrom("", 0, localAddr)("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	accepted, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer accepted.Close()
+
+	if host, _, _ := net.SplitHostPort(accepted.RemoteAddr().String()); host != "127.0.0.2" {
+		t.Errorf("expected connection from 127.0.0.2, got %v", accepted.RemoteAddr())
+	}
+}
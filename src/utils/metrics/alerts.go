@@ -0,0 +1,302 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Arriven/db1000n/src/utils"
+	"github.com/Arriven/db1000n/src/utils/templates"
+)
+
+// Metrics the alert rules can watch
+const (
+	AlertSuccessRate       = "success_rate"        // share of the successful http requests since the previous evaluation, between 0 and 1
+	AlertRequestsPerSecond = "requests_per_second" // rate of the http requests since the previous evaluation
+	AlertLiveProxies       = "live_proxies"        // health checked proxies that are selected for requests
+	AlertDeadProxies       = "dead_proxies"        // health checked proxies that keep failing the checks
+)
+
+// Supported values for AlertRule.Condition
+const (
+	AlertBelow = "below"
+	AlertAbove = "above"
+)
+
+const (
+	DefaultAlertInterval = 30 * time.Second // between the evaluations of the rules unless configured otherwise
+
+	defaultAlertCooldown = 10 * time.Minute
+	alertWebhookTimeout  = 10 * time.Second
+)
+
+// AlertRule fires a webhook when the metric crosses the threshold
+type AlertRule struct {
+	Name      string        `mapstructure:"name"`
+	Metric    string        `mapstructure:"metric"`
+	Condition string        `mapstructure:"condition"` // whether the rule fires when the metric is "below" or "above" the threshold
+	Threshold float64       `mapstructure:"threshold"`
+	Webhook   string        `mapstructure:"webhook"`  // url the alert is posted to
+	Payload   string        `mapstructure:"payload"`  // template of the request body executed with the Alert, json encoded Alert if empty
+	Cooldown  time.Duration `mapstructure:"cooldown"` // of the rule after it has fired, defaultAlertCooldown if zero
+}
+
+// Alert is sent to the webhook of the rule that has fired
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	Condition string    `json:"condition"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	Time      time.Time `json:"time"`
+}
+
+type alertRule struct {
+	AlertRule
+	payload *template.Template
+
+	lastFired time.Time
+}
+
+func (r *alertRule) breached(value float64) bool {
+	if r.Condition == AlertBelow {
+		return value < r.Threshold
+	}
+
+	return value > r.Threshold
+}
+
+type requestCounts struct {
+	success, total uint64
+}
+
+// Alerter periodically evaluates the alert rules against the storage. A rule that keeps being breached fires again
+// once its cooldown is over so that a single outage doesn't flood the webhook
+type Alerter struct {
+	rules    []*alertRule
+	interval time.Duration
+	storage  *Storage
+	client   *http.Client
+
+	previous     requestCounts
+	previousTime time.Time
+
+	now func() time.Time
+}
+
+// LoadAlertRules reads the rules from the yaml (or json) file with a list of rules under the "rules" key
+func LoadAlertRules(path string) ([]AlertRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading alert rules: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing alert rules: %w", err)
+	}
+
+	var config struct {
+		Rules []AlertRule `mapstructure:"rules"`
+	}
+
+	if err := utils.Decode(raw, &config); err != nil {
+		return nil, fmt.Errorf("error parsing alert rules: %w", err)
+	}
+
+	return config.Rules, nil
+}
+
+// NewAlerter validates the rules and creates an alerter evaluating them against Default storage every interval
+func NewAlerter(rules []AlertRule, interval time.Duration) (*Alerter, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid alert interval %v", interval)
+	}
+
+	alerter := &Alerter{
+		interval: interval,
+		storage:  &Default,
+		client:   &http.Client{Timeout: alertWebhookTimeout},
+		now:      time.Now,
+	}
+
+	for i := range rules {
+		rule, err := newAlertRule(rules[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule %q: %w", rules[i].Name, err)
+		}
+
+		alerter.rules = append(alerter.rules, rule)
+	}
+
+	alerter.previous, alerter.previousTime = alerter.storage.requestCounts(), alerter.now()
+
+	return alerter, nil
+}
+
+func newAlertRule(c AlertRule) (*alertRule, error) {
+	switch c.Metric {
+	case AlertSuccessRate, AlertRequestsPerSecond, AlertLiveProxies, AlertDeadProxies:
+	default:
+		return nil, fmt.Errorf("unsupported metric %q, expected one of [%q, %q, %q, %q]",
+			c.Metric, AlertSuccessRate, AlertRequestsPerSecond, AlertLiveProxies, AlertDeadProxies)
+	}
+
+	switch c.Condition {
+	case AlertBelow, AlertAbove:
+	default:
+		return nil, fmt.Errorf("unsupported condition %q, expected one of [%q, %q]", c.Condition, AlertBelow, AlertAbove)
+	}
+
+	if u, err := url.Parse(c.Webhook); err != nil || u.Host == "" {
+		return nil, errors.New("webhook has to be an absolute url")
+	}
+
+	if c.Cooldown == 0 {
+		c.Cooldown = defaultAlertCooldown
+	}
+
+	rule := &alertRule{AlertRule: c}
+
+	if c.Payload != "" {
+		var err error
+		if rule.payload, err = templates.Parse(c.Payload); err != nil {
+			return nil, fmt.Errorf("error parsing payload: %w", err)
+		}
+	}
+
+	return rule, nil
+}
+
+// Run evaluates the rules every interval until the context is done
+func (a *Alerter) Run(ctx context.Context, logger *zap.Logger) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Evaluate(ctx, logger)
+		}
+	}
+}
+
+// Evaluate checks all the rules once and fires the breached ones that aren't cooling down
+func (a *Alerter) Evaluate(ctx context.Context, logger *zap.Logger) {
+	now := a.now()
+	values := a.values(now)
+
+	for _, rule := range a.rules {
+		value, ok := values[rule.Metric]
+		if !ok || !rule.breached(value) {
+			continue
+		}
+
+		if !rule.lastFired.IsZero() && now.Sub(rule.lastFired) < rule.Cooldown {
+			logger.Debug("alert rule is still breached, cooling down", zap.String("rule", rule.Name), zap.Float64("value", value))
+
+			continue
+		}
+
+		alert := Alert{Rule: rule.Name, Metric: rule.Metric, Condition: rule.Condition, Threshold: rule.Threshold, Value: value, Time: now.UTC()}
+
+		// failed deliveries are retried on the next evaluation if the rule is still breached
+		if err := a.fire(ctx, logger, rule, alert); err != nil {
+			logger.Warn("error sending alert", zap.String("rule", rule.Name), zap.Error(err))
+
+			continue
+		}
+
+		logger.Info("alert fired", zap.String("rule", rule.Name), zap.Float64("value", value))
+
+		rule.lastFired = now
+	}
+}
+
+// values returns the current values of the metrics, the ones that can't be calculated (i.e. success rate
+// without requests or proxy counts without health checks) are missing so that the rules watching them don't fire
+func (a *Alerter) values(now time.Time) map[string]float64 {
+	values := make(map[string]float64)
+
+	current := a.storage.requestCounts()
+
+	// the storage is reset when a new config is applied
+	delta := current
+	if current.total >= a.previous.total && current.success >= a.previous.success {
+		delta = requestCounts{success: current.success - a.previous.success, total: current.total - a.previous.total}
+	}
+
+	if elapsed := now.Sub(a.previousTime).Seconds(); elapsed > 0 {
+		values[AlertRequestsPerSecond] = float64(delta.total) / elapsed
+	}
+
+	if delta.total > 0 {
+		values[AlertSuccessRate] = float64(delta.success) / float64(delta.total)
+	}
+
+	a.previous, a.previousTime = current, now
+
+	if live, ok := a.storage.proxyStates.get(ProxyStateLive); ok {
+		dead, _ := a.storage.proxyStates.get(ProxyStateDead)
+		values[AlertLiveProxies], values[AlertDeadProxies] = float64(live), float64(dead)
+	}
+
+	return values
+}
+
+func (ms *Storage) requestCounts() requestCounts {
+	var counts requestCounts
+
+	for _, summary := range ms.LatencySummaries() {
+		counts.total += summary.Count
+		if summary.Status == StatusSuccess {
+			counts.success += summary.Count
+		}
+	}
+
+	return counts
+}
+
+func (a *Alerter) fire(ctx context.Context, logger *zap.Logger, rule *alertRule, alert Alert) error {
+	var body []byte
+
+	if rule.payload != nil {
+		body = []byte(templates.Execute(logger, rule.payload, alert))
+	} else {
+		var err error
+		if body, err = json.Marshal(alert); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rule.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startWebhook starts a fake webhook receiver collecting the request bodies
+func startWebhook(t *testing.T) (url string, received func() []string) {
+	t.Helper()
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		bodies = append(bodies, string(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), bodies...)
+	}
+}
+
+func TestAlerter(t *testing.T) {
+	t.Parallel()
+
+	webhook, received := startWebhook(t)
+
+	var storage Storage
+
+	now := time.Unix(1000, 0)
+
+	alerter, err := NewAlerter([]AlertRule{
+		{Name: "low success rate", Metric: AlertSuccessRate, Condition: AlertBelow, Threshold: 0.5, Webhook: webhook, Cooldown: time.Minute},
+		{Name: "few proxies", Metric: AlertLiveProxies, Condition: AlertBelow, Threshold: 2, Webhook: webhook,
+			Payload: `{"text": "{{ .Rule }}: {{ .Value }} live"}`},
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alerter.storage = &storage
+	alerter.previous, alerter.previousTime = storage.requestCounts(), now
+	alerter.now = func() time.Time { return now }
+
+	evaluate := func(success, fail int) {
+		for i := 0; i < success; i++ {
+			storage.ObserveLatency("host", StatusSuccess, time.Millisecond)
+		}
+
+		for i := 0; i < fail; i++ {
+			storage.ObserveLatency("host", StatusFail, time.Millisecond)
+		}
+
+		now = now.Add(10 * time.Second)
+		alerter.Evaluate(context.Background(), zap.NewNop())
+	}
+
+	evaluate(9, 1)
+
+	if got := received(); len(got) != 0 {
+		t.Fatalf("expected no alerts while healthy and without health checked proxies, got %v", got)
+	}
+
+	// the rate is calculated over the interval so the earlier successes don't mask the failures
+	evaluate(1, 9)
+
+	got := received()
+	if len(got) != 1 {
+		t.Fatalf("expected an alert on threshold breach, got %v", got)
+	}
+
+	var alert Alert
+	if err := json.Unmarshal([]byte(got[0]), &alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if alert.Rule != "low success rate" || alert.Value != 0.1 || alert.Threshold != 0.5 || !alert.Time.Equal(now) {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+
+	evaluate(0, 10)
+
+	if got := received(); len(got) != 1 {
+		t.Fatalf("expected the alert to be suppressed during cooldown, got %v", got)
+	}
+
+	now = now.Add(time.Minute)
+	evaluate(0, 10)
+
+	if got := received(); len(got) != 2 {
+		t.Fatalf("expected the alert to fire again after cooldown, got %v", got)
+	}
+
+	storage.proxyStates.add(ProxyStateLive, 1)
+	evaluate(10, 0)
+
+	if got := received(); len(got) != 3 || got[2] != `{"text": "few proxies: 1 live"}` {
+		t.Errorf("expected a templated proxy alert, got %v", got)
+	}
+}
+
+func TestAlerterWebhookFailure(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var storage Storage
+
+	alerter, err := NewAlerter([]AlertRule{
+		{Name: "no requests", Metric: AlertRequestsPerSecond, Condition: AlertBelow, Threshold: 1, Webhook: server.URL},
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alerter.storage = &storage
+
+	// the failed delivery doesn't start the cooldown
+	for i := 0; i < 3; i++ {
+		alerter.Evaluate(context.Background(), zap.NewNop())
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected the alert to be retried once, got %d calls", got)
+	}
+}
+
+func TestLoadAlertRules(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "alerts.yaml")
+
+	content := `
+rules:
+  - name: low success rate
+    metric: success_rate
+    condition: below
+    threshold: 0.5
+    webhook: https://hooks.example.com/alert
+    cooldown: 5m
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadAlertRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := AlertRule{
+		Name: "low success rate", Metric: AlertSuccessRate, Condition: AlertBelow, Threshold: 0.5,
+		Webhook: "https://hooks.example.com/alert", Cooldown: 5 * time.Minute,
+	}
+	if len(rules) != 1 || rules[0] != want {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	for _, rule := range []AlertRule{
+		{Metric: "latency", Condition: AlertBelow, Webhook: want.Webhook},
+		{Metric: AlertSuccessRate, Condition: "equal", Webhook: want.Webhook},
+		{Metric: AlertSuccessRate, Condition: AlertBelow, Webhook: "hooks"},
+		{Metric: AlertSuccessRate, Condition: AlertBelow, Webhook: want.Webhook, Payload: "{{ .Rule "},
+	} {
+		if _, err := NewAlerter([]AlertRule{rule}, time.Second); err == nil {
+			t.Errorf("expected an error for %+v", rule)
+		}
+	}
+}
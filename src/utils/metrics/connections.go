@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ConnectionSummary holds amounts of http requests sent to a single address over new and reused connections
+type ConnectionSummary struct {
+	Address     string
+	New, Reused uint64
+}
+
+type connectionCounts struct {
+	new, reused uint64
+}
+
+// connections are stored by address (host:port) as that's what a connection pool is keyed by
+type connections struct {
+	counts sync.Map // map by address
+}
+
+func (c *connections) get(address string) *connectionCounts {
+	if counts, ok := c.counts.Load(address); ok {
+		return counts.(*connectionCounts)
+	}
+
+	counts, _ := c.counts.LoadOrStore(address, &connectionCounts{})
+
+	return counts.(*connectionCounts)
+}
+
+// ObserveConnection records whether a request to the address was sent over a freshly dialed or a pooled connection
+func (ms *Storage) ObserveConnection(address string, reused bool) {
+	counts := ms.connections.get(address)
+
+	if reused {
+		atomic.AddUint64(&counts.reused, 1)
+	} else {
+		atomic.AddUint64(&counts.new, 1)
+	}
+}
+
+// ConnectionSummaries returns connection reuse counters for all the addresses sorted by address
+func (ms *Storage) ConnectionSummaries() []ConnectionSummary {
+	var summaries []ConnectionSummary
+
+	ms.connections.counts.Range(func(k, v interface{}) bool {
+		counts := v.(*connectionCounts)
+
+		summaries = append(summaries, ConnectionSummary{
+			Address: k.(string),
+			New:     atomic.LoadUint64(&counts.new),
+			Reused:  atomic.LoadUint64(&counts.reused),
+		})
+
+		return true
+	})
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Address < summaries[j].Address })
+
+	return summaries
+}
@@ -0,0 +1,247 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+)
+
+// Supported FileSink modes
+const (
+	FileSinkAppend = "append" // keep all the snapshots in a single json array
+	FileSinkRotate = "rotate" // write every snapshot to a separate file named after its time
+)
+
+// NewFileSinkOptionsWithFlags returns file sink options initialized with command line flags.
+func NewFileSinkOptionsWithFlags() (path, mode *string, interval *time.Duration) {
+	const defaultInterval = time.Minute
+
+	return flag.String("metrics_file", utils.GetEnvStringDefault("METRICS_FILE", ""),
+			"Path to the json file to periodically write metrics snapshots to (disabled if empty)"),
+		flag.String("metrics_file_mode", utils.GetEnvStringDefault("METRICS_FILE_MODE", FileSinkAppend),
+			"How to store metrics snapshots: append to a json array in metrics_file or rotate to a new file next to it every time"),
+		flag.Duration("metrics_file_interval", utils.GetEnvDurationDefault("METRICS_FILE_INTERVAL", defaultInterval),
+			"How often to write metrics snapshots to metrics_file")
+}
+
+// Snapshot is the state of the storage at some point in time
+type Snapshot struct {
+	Time             time.Time            `json:"time"`
+	Traffic          uint64               `json:"traffic"`
+	ProcessedTraffic uint64               `json:"processed_traffic"`
+	HTTP             []HTTPSnapshot       `json:"http"`
+	Connections      []ConnectionSnapshot `json:"connections"`
+}
+
+// HTTPSnapshot holds the amount and latencies of requests to a single host with the same status
+type HTTPSnapshot struct {
+	Host   string  `json:"host"`
+	Status string  `json:"status"`
+	Count  uint64  `json:"count"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+}
+
+// ConnectionSnapshot holds the amount of new and reused connections to a single address
+type ConnectionSnapshot struct {
+	Address string `json:"address"`
+	New     uint64 `json:"new"`
+	Reused  uint64 `json:"reused"`
+}
+
+// Snapshot returns the current state of the storage
+func (ms *Storage) Snapshot() Snapshot {
+	snapshot := Snapshot{
+		Time:             time.Now().UTC(),
+		Traffic:          ms.Read(Traffic),
+		ProcessedTraffic: ms.Read(ProcessedTraffic),
+		HTTP:             []HTTPSnapshot{},
+		Connections:      []ConnectionSnapshot{},
+	}
+
+	for _, summary := range ms.LatencySummaries() {
+		snapshot.HTTP = append(snapshot.HTTP, HTTPSnapshot{
+			Host: summary.Host, Status: summary.Status, Count: summary.Count,
+			P50Ms: milliseconds(summary.P50), P90Ms: milliseconds(summary.P90), P99Ms: milliseconds(summary.P99),
+		})
+	}
+
+	for _, summary := range ms.ConnectionSummaries() {
+		snapshot.Connections = append(snapshot.Connections, ConnectionSnapshot(summary))
+	}
+
+	return snapshot
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// jsonArrayEnd is written after every appended snapshot so that the file is a valid json array between the writes
+const (
+	jsonArrayEnd   = "\n]\n"
+	emptyJSONArray = "[" + jsonArrayEnd
+)
+
+// FileSink periodically writes snapshots of the storage to json files
+type FileSink struct {
+	mu       sync.Mutex
+	path     string
+	mode     string
+	interval time.Duration
+	storage  *Storage
+	file     *os.File // only used in append mode
+	empty    bool     // whether the array in the file has no snapshots yet
+}
+
+// NewFileSink creates a sink writing snapshots of Default storage every interval. In append mode snapshots are added
+// to the json array in the file (continuing the one left by the previous run), in rotate mode every snapshot is written
+// to a new file next to path with the snapshot time added to its name
+func NewFileSink(path string, interval time.Duration, mode string) (*FileSink, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid metrics file interval %v", interval)
+	}
+
+	sink := &FileSink{path: path, mode: mode, interval: interval, storage: &Default}
+
+	switch mode {
+	case FileSinkRotate:
+		return sink, nil
+	case FileSinkAppend:
+	default:
+		return nil, fmt.Errorf("unsupported metrics file mode %q, expected one of [%q, %q]", mode, FileSinkAppend, FileSinkRotate)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600) //nolint:gosec // The path is configured by the user
+	if err != nil {
+		return nil, fmt.Errorf("error opening metrics file: %w", err)
+	}
+
+	if sink.empty, err = prepareJSONArray(file); err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("error reading metrics file %q: %w", path, err)
+	}
+
+	sink.file = file
+
+	return sink, nil
+}
+
+// prepareJSONArray starts a new array in an empty file or checks that the file ends with an array written by the sink
+func prepareJSONArray(file *os.File) (empty bool, err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if info.Size() == 0 {
+		_, err = file.WriteString(emptyJSONArray)
+
+		return true, err
+	}
+
+	end := make([]byte, len(jsonArrayEnd))
+	if info.Size() >= int64(len(end)) {
+		if _, err = file.ReadAt(end, info.Size()-int64(len(end))); err != nil && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+	}
+
+	if string(end) != jsonArrayEnd {
+		return false, errors.New("expected a json array written by the previous run")
+	}
+
+	return info.Size() == int64(len(emptyJSONArray)), nil
+}
+
+// Run writes snapshots every interval until the context is done
+func (s *FileSink) Run(ctx context.Context, logger *zap.Logger) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				logger.Debug("error writing metrics file", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Close writes the final snapshot and closes the file
+func (s *FileSink) Close() error {
+	flushErr := s.Flush()
+
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+	}
+
+	return flushErr
+}
+
+// Flush writes the current snapshot
+func (s *FileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.storage.Snapshot()
+
+	if s.mode == FileSinkRotate {
+		data, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(s.rotatedPath(snapshot.Time), append(data, '\n'), 0o600)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n  "
+	if s.empty {
+		separator = "\n  "
+	}
+
+	// overwrite the end of the array so that the file stays valid
+	if _, err = s.file.WriteAt([]byte(separator+string(data)+jsonArrayEnd), info.Size()-int64(len(jsonArrayEnd))); err != nil {
+		return err
+	}
+
+	s.empty = false
+
+	return nil
+}
+
+// rotatedPath adds the time to the file name before the extension, i.e. metrics-20220102T150405.000Z.json
+func (s *FileSink) rotatedPath(t time.Time) string {
+	ext := filepath.Ext(s.path)
+
+	return strings.TrimSuffix(s.path, ext) + "-" + t.Format("20060102T150405.000Z") + ext
+}
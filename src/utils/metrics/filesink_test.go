@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestFileSinkStorage() *Storage {
+	storage := &Storage{trackers: map[string]*metricTracker{Traffic: {}, ProcessedTraffic: {}}}
+
+	storage.Write(Traffic, "job", 100)
+	storage.Write(ProcessedTraffic, "job", 50)
+	storage.ObserveLatency("example.com", StatusSuccess, 3*time.Millisecond)
+	storage.ObserveLatency("example.com", StatusFail, time.Second)
+	storage.ObserveConnection("example.com:443", false)
+	storage.ObserveConnection("example.com:443", true)
+
+	return storage
+}
+
+func checkSnapshot(t *testing.T, snapshot Snapshot) {
+	t.Helper()
+
+	if snapshot.Time.IsZero() || snapshot.Traffic != 100 || snapshot.ProcessedTraffic != 50 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+
+	if want := []HTTPSnapshot{
+		{Host: "example.com", Status: StatusSuccess, Count: 1, P50Ms: 5, P90Ms: 5, P99Ms: 5},
+		{Host: "example.com", Status: StatusFail, Count: 1, P50Ms: 1000, P90Ms: 1000, P99Ms: 1000},
+	}; len(snapshot.HTTP) != len(want) || snapshot.HTTP[0] != want[0] || snapshot.HTTP[1] != want[1] {
+		t.Errorf("expected http snapshots %+v, got %+v", want, snapshot.HTTP)
+	}
+
+	if want := (ConnectionSnapshot{Address: "example.com:443", New: 1, Reused: 1}); len(snapshot.Connections) != 1 || snapshot.Connections[0] != want {
+		t.Errorf("expected connection snapshots %+v, got %+v", want, snapshot.Connections)
+	}
+}
+
+func TestFileSinkAppend(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	sink, err := NewFileSink(path, 10*time.Millisecond, FileSinkAppend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink.storage = newTestFileSinkStorage()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+
+	sink.Run(ctx, zap.NewNop())
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshots []Snapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		t.Fatalf("invalid json %q: %v", data, err)
+	}
+
+	// snapshots from the intervals and the final one on close
+	if len(snapshots) < 3 {
+		t.Fatalf("expected at least 3 snapshots, got %d", len(snapshots))
+	}
+
+	for _, snapshot := range snapshots {
+		checkSnapshot(t, snapshot)
+	}
+
+	// the next run continues the same array
+	sink, err = NewFileSink(path, time.Minute, FileSinkAppend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink.storage = newTestFileSinkStorage()
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var continued []Snapshot
+
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(data, &continued); err != nil || len(continued) != len(snapshots)+1 {
+		t.Errorf("expected %d snapshots after the second run, got %d (%v)", len(snapshots)+1, len(continued), err)
+	}
+}
+
+func TestFileSinkRotate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	sink, err := NewFileSink(filepath.Join(dir, "metrics.json"), time.Minute, FileSinkRotate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink.storage = newTestFileSinkStorage()
+
+	const flushes = 3
+
+	for i := 0; i < flushes; i++ {
+		if err := sink.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// keep the file names unique
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "metrics-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != flushes {
+		t.Fatalf("expected %d files, got %v", flushes, files)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			t.Fatalf("invalid json in %v: %v", file, err)
+		}
+
+		checkSnapshot(t, snapshot)
+	}
+}
+
+func TestFileSinkErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	notArray := filepath.Join(dir, "other.json")
+	if err := os.WriteFile(notArray, []byte(`{"some":"file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path     string
+		interval time.Duration
+		mode     string
+	}{
+		{path: filepath.Join(dir, "a.json"), interval: time.Second, mode: "unknown"},
+		{path: filepath.Join(dir, "b.json"), mode: FileSinkAppend},
+		{path: filepath.Join(dir, "missing", "c.json"), interval: time.Second, mode: FileSinkAppend},
+		{path: notArray, interval: time.Second, mode: FileSinkAppend},
+	} {
+		if _, err := NewFileSink(tc.path, tc.interval, tc.mode); err == nil {
+			t.Errorf("expected an error for %+v", tc)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are upper bounds of latency histogram buckets, the last bucket holds everything above them
+var LatencyBuckets = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// Histogram is a lock-free latency histogram with fixed buckets
+type Histogram struct {
+	counts []uint64 // len(LatencyBuckets)+1 buckets
+}
+
+// NewHistogram returns an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, len(LatencyBuckets)+1)}
+}
+
+// Observe records a single measurement
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+	atomic.AddUint64(&h.counts[i], 1)
+}
+
+// BucketCounts returns amount of measurements in each of the buckets
+func (h *Histogram) BucketCounts() []uint64 {
+	counts := make([]uint64, len(h.counts))
+	for i := range h.counts {
+		counts[i] = atomic.LoadUint64(&h.counts[i])
+	}
+
+	return counts
+}
+
+// Quantile returns the upper bound of the bucket the q-th quantile falls into,
+// measurements above the last bucket are reported as twice its bound
+func (h *Histogram) Quantile(q float64) time.Duration {
+	counts := h.BucketCounts()
+
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(total))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var cumulative uint64
+
+	for i, c := range counts[:len(LatencyBuckets)] {
+		if cumulative += c; cumulative >= rank {
+			return LatencyBuckets[i]
+		}
+	}
+
+	return 2 * LatencyBuckets[len(LatencyBuckets)-1]
+}
+
+// LatencySummary holds latency percentiles of requests to a single host with the same status
+type LatencySummary struct {
+	Host          string
+	Status        string
+	Count         uint64
+	P50, P90, P99 time.Duration
+}
+
+type latencyKey struct {
+	host   string
+	status string
+}
+
+// latencies are stored by host and status so that time-to-error doesn't skew response times
+type latencies struct {
+	histograms sync.Map // map by latencyKey
+}
+
+func (l *latencies) histogram(host, status string) *Histogram {
+	key := latencyKey{host: host, status: status}
+
+	if h, ok := l.histograms.Load(key); ok {
+		return h.(*Histogram)
+	}
+
+	h, _ := l.histograms.LoadOrStore(key, NewHistogram())
+
+	return h.(*Histogram)
+}
+
+// ObserveLatency records the time it took to get a response (or an error when status is StatusFail) from the host
+func (ms *Storage) ObserveLatency(host, status string, d time.Duration) {
+	ms.latencies.histogram(host, status).Observe(d)
+}
+
+// Latency returns the latency histogram of the host for the status
+func (ms *Storage) Latency(host, status string) *Histogram {
+	return ms.latencies.histogram(host, status)
+}
+
+// LatencySummaries returns p50/p90/p99 latencies for all the hosts sorted by host and status
+func (ms *Storage) LatencySummaries() []LatencySummary {
+	const (
+		p50 = 0.5
+		p90 = 0.9
+		p99 = 0.99
+	)
+
+	var summaries []LatencySummary
+
+	ms.latencies.histograms.Range(func(k, v interface{}) bool {
+		key, h := k.(latencyKey), v.(*Histogram)
+
+		var count uint64
+		for _, c := range h.BucketCounts() {
+			count += c
+		}
+
+		summaries = append(summaries, LatencySummary{
+			Host: key.host, Status: key.status, Count: count,
+			P50: h.Quantile(p50), P90: h.Quantile(p90), P99: h.Quantile(p99),
+		})
+
+		return true
+	})
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Host != summaries[j].Host {
+			return summaries[i].Host < summaries[j].Host
+		}
+
+		return summaries[i].Status > summaries[j].Status // success before fail
+	})
+
+	return summaries
+}
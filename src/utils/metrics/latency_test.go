@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	t.Parallel()
+
+	h := NewHistogram()
+
+	if h.Quantile(0.5) != 0 {
+		t.Error("empty histogram should report zero latency")
+	}
+
+	for i := 0; i < 90; i++ {
+		h.Observe(3 * time.Millisecond)
+	}
+
+	for i := 0; i < 9; i++ {
+		h.Observe(200 * time.Millisecond)
+	}
+
+	h.Observe(time.Minute)
+
+	for _, tc := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{q: 0.5, want: 5 * time.Millisecond},
+		{q: 0.9, want: 5 * time.Millisecond},
+		{q: 0.99, want: 250 * time.Millisecond},
+		{q: 1, want: 20 * time.Second},
+	} {
+		if got := h.Quantile(tc.q); got != tc.want {
+			t.Errorf("expected quantile %v to be %v, got %v", tc.q, tc.want, got)
+		}
+	}
+}
+
+func TestLatencySummaries(t *testing.T) {
+	t.Parallel()
+
+	var storage Storage
+
+	storage.ObserveLatency("b", StatusFail, time.Second)
+	storage.ObserveLatency("b", StatusSuccess, time.Millisecond)
+	storage.ObserveLatency("a", StatusSuccess, time.Millisecond)
+
+	summaries := storage.LatencySummaries()
+	if len(summaries) != 3 {
+		t.Fatalf("expected 3 summaries, got %v", summaries)
+	}
+
+	if summaries[0].Host != "a" || summaries[1].Status != StatusSuccess || summaries[2].Status != StatusFail || summaries[2].P99 != time.Second {
+		t.Errorf("unexpected summaries %+v", summaries)
+	}
+
+	storage.ResetAll()
+
+	if len(storage.LatencySummaries()) != 0 {
+		t.Error("latencies should be reset with the rest of the metrics")
+	}
+}
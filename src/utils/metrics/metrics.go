@@ -26,6 +26,7 @@ package metrics
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -37,7 +38,13 @@ const (
 
 // Storage is a general struct to store custom metrics
 type Storage struct {
-	trackers map[string]*metricTracker // map by metric type
+	trackers    map[string]*metricTracker // map by metric type
+	latencies   latencies
+	connections connections
+	proxies     proxies
+	slaBreaches slaBreaches
+
+	proxyStates proxyStates // not reset with the other metrics as the proxy pools outlive the configs
 }
 
 type metricTracker struct {
@@ -76,9 +83,38 @@ func (ms *Storage) Read(name string) uint64 {
 }
 
 func (ms *Storage) ResetAll() {
-	for k := range ms.trackers {
-		ms.trackers[k] = &metricTracker{}
+	// reset in place as trackers can be read concurrently (i.e. by prometheus collectors)
+	for _, tracker := range ms.trackers {
+		tracker.metrics.Range(func(k, _ interface{}) bool {
+			tracker.metrics.Delete(k)
+
+			return true
+		})
 	}
+
+	ms.latencies.histograms.Range(func(k, _ interface{}) bool {
+		ms.latencies.histograms.Delete(k)
+
+		return true
+	})
+
+	ms.connections.counts.Range(func(k, _ interface{}) bool {
+		ms.connections.counts.Delete(k)
+
+		return true
+	})
+
+	ms.proxies.stats.Range(func(k, _ interface{}) bool {
+		ms.proxies.stats.Delete(k)
+
+		return true
+	})
+
+	ms.slaBreaches.counts.Range(func(k, _ interface{}) bool {
+		ms.slaBreaches.counts.Delete(k)
+
+		return true
+	})
 }
 
 // NewWriter creates a writer for accumulated writes to the storage
@@ -86,7 +122,8 @@ func (ms *Storage) NewWriter(name, jobID string) *Writer {
 	return &Writer{ms: ms, jobID: jobID, name: name, value: 0}
 }
 
-// Writer is a helper to accumulate writes to a storage on a regular basis
+// Writer is a helper to accumulate writes to a storage on a regular basis, value is accessed atomically
+// as it's flushed from a separate goroutine
 type Writer struct {
 	ms    *Storage
 	jobID string
@@ -96,17 +133,22 @@ type Writer struct {
 
 // Add used to increase metric value by a specific amount
 func (w *Writer) Add(value uint64) {
-	w.value += value
+	atomic.AddUint64(&w.value, value)
 }
 
 // Set used to set metric to a specific value
 func (w *Writer) Set(value uint64) {
-	w.value = value
+	atomic.StoreUint64(&w.value, value)
+}
+
+// Value returns the accumulated value
+func (w *Writer) Value() uint64 {
+	return atomic.LoadUint64(&w.value)
 }
 
 // Flush used to flush pending metrics updates to the storage
 func (w *Writer) Flush() {
-	w.ms.Write(w.name, w.jobID, w.value)
+	w.ms.Write(w.name, w.jobID, atomic.LoadUint64(&w.value))
 }
 
 // Update updates writer with a set uint64erval
@@ -46,9 +46,11 @@ import (
 
 // common values for prometheus metrics
 const (
-	StatusLabel   = `status`
-	StatusSuccess = `success`
-	StatusFail    = `fail`
+	StatusLabel     = `status`
+	StatusSuccess   = `success`
+	StatusFail      = `fail`
+	StatusProxyFail = `proxy_fail` // the request didn't reach the target because of the proxy
+	StatusDNSFail   = `dns_fail`   // the target host couldn't be resolved with the configured resolver
 )
 
 // DNS Blast related values and labels for prometheus metrics
@@ -62,6 +64,11 @@ const (
 const (
 	HTTPDestinationHostLabel = `destination_host`
 	HTTPMethodLabel          = `method`
+	HTTPPathLabel            = `path`
+	HTTPAddressLabel         = `address`
+	HTTPConnectionLabel      = `connection`
+	HTTPConnectionNew        = `new`
+	HTTPConnectionReused     = `reused`
 )
 
 // Packetgen related values and labels
@@ -83,6 +90,79 @@ const (
 	RawnetProtocolLabel = `protocol`
 )
 
+// ICMP related values and labels
+const (
+	ICMPAddressLabel = `address`
+)
+
+// UDP replies related values and labels
+const (
+	UDPReplyAddressLabel  = `address`
+	UDPReplyProtocolLabel = `protocol`
+	UDPReplyStatusTimeout = `timeout` // no reply to the packet within the timeout
+)
+
+// Websocket related values and labels
+const (
+	WebsocketAddressLabel = `address`
+)
+
+// MQTT related values and labels
+const (
+	MQTTAddressLabel = `address`
+)
+
+// SMTP related values and labels
+const (
+	SMTPAddressLabel = `address`
+)
+
+// GRPC related values and labels
+const (
+	GRPCAddressLabel = `address`
+	GRPCMethodLabel  = `method`
+)
+
+// TLS handshake related values and labels
+const (
+	TLSHandshakeAddressLabel = `address`
+)
+
+// NTP related values and labels
+const (
+	NTPServerLabel = `server`
+)
+
+// Throughput related values and labels
+const (
+	ThroughputDirectionLabel = `direction`
+)
+
+// Proxy related values and labels
+const (
+	ProxyAddressLabel = `proxy`
+	ProxyStateLabel   = `state`
+	ProxyStateLive    = `live`
+	ProxyStateDead    = `dead`
+)
+
+// Circuit breaker related values and labels
+const (
+	CircuitBreakerAddressLabel = `address`
+	CircuitBreakerStateLabel   = `state`
+)
+
+// Job progress related values and labels
+const (
+	JobIDLabel             = `job_id`
+	JobNameLabel           = `job_name`
+	JobIterationsLabel     = `iterations`
+	JobIterationsCompleted = `completed`
+	JobIterationsTotal     = `total`
+	JobLabelKeyLabel       = `label`
+	JobLabelValueLabel     = `value`
+)
+
 // Client related values and labels
 const (
 	ClientIDLabel = `id`
@@ -91,24 +171,52 @@ const (
 
 // registered metrics
 var (
-	dnsBlastCounter  *prometheus.CounterVec
-	httpCounter      *prometheus.CounterVec
-	packetgenCounter *prometheus.CounterVec
-	slowlorisCounter *prometheus.CounterVec
-	rawnetCounter    *prometheus.CounterVec
-	clientCounter    *prometheus.CounterVec
+	dnsBlastCounter   *prometheus.CounterVec
+	httpCounter       *prometheus.CounterVec
+	validationCounter *prometheus.CounterVec
+	connectionCounter *prometheus.CounterVec
+	handshakeCounter  *prometheus.CounterVec
+	packetgenCounter  *prometheus.CounterVec
+	slowlorisCounter  *prometheus.CounterVec
+	rawnetCounter     *prometheus.CounterVec
+	icmpReplyCounter  *prometheus.CounterVec
+	udpReplyCounter   *prometheus.CounterVec
+	websocketCounter  *prometheus.CounterVec
+	mqttCounter       *prometheus.CounterVec
+	smtpCounter       *prometheus.CounterVec
+	grpcCounter       *prometheus.CounterVec
+	tlsCounter        *prometheus.CounterVec
+	breakerCounter    *prometheus.CounterVec
+	clientCounter     *prometheus.CounterVec
+	proxyErrorCounter *prometheus.CounterVec
+	proxyCounter      *prometheus.CounterVec
+	slaCounter        *prometheus.CounterVec
+
+	ntpAmplificationGauge *prometheus.GaugeVec
+	proxiesGauge          *prometheus.GaugeVec
+	httpConcurrencyGauge  *prometheus.GaugeVec
+	jobIterationsGauge    *prometheus.GaugeVec
+	jobRateGauge          *prometheus.GaugeVec
+	jobETAGauge           *prometheus.GaugeVec
+	jobLabelsGauge        *prometheus.GaugeVec
+	throughputGauge       *prometheus.GaugeVec
+
+	trafficGauge          prometheus.GaugeFunc
+	processedTrafficGauge prometheus.GaugeFunc
 )
 
 // NewOptionsWithFlags returns metrics options initialized with command line flags.
-func NewOptionsWithFlags() (prometheusOn *bool, prometheusPushGateways *string) {
+func NewOptionsWithFlags() (prometheusOn *bool, prometheusPushGateways, prometheusListenAddress *string) {
 	return flag.Bool("prometheus_on", utils.GetEnvBoolDefault("PROMETHEUS_ON", true),
 			"Start metrics exporting via HTTP and pushing to gateways (specified via <prometheus_gateways>)"),
 		flag.String("prometheus_gateways",
 			utils.GetEnvStringDefault("PROMETHEUS_GATEWAYS", "https://178.62.78.144:9091,https://46.101.26.43:9091,https://178.62.33.149:9091"),
-			"Comma separated list of prometheus push gateways")
+			"Comma separated list of prometheus push gateways"),
+		flag.String("prometheus_listen", utils.GetEnvStringDefault("PROMETHEUS_LISTEN", "0.0.0.0:9090"),
+			"Address to expose prometheus metrics at (<prometheus_listen>/metrics)")
 }
 
-func InitOrFail(ctx context.Context, logger *zap.Logger, prometheusOn bool, prometheusPushGateways, clientID, country string) {
+func InitOrFail(ctx context.Context, logger *zap.Logger, prometheusOn bool, prometheusPushGateways, prometheusListenAddress, clientID, country string) {
 	if !ValidatePrometheusPushGateways(prometheusPushGateways) {
 		log.Fatal("Invalid value for --prometheus_gateways")
 	}
@@ -116,7 +224,7 @@ func InitOrFail(ctx context.Context, logger *zap.Logger, prometheusOn bool, prom
 	if prometheusOn {
 		Init(clientID, country)
 
-		go ExportPrometheusMetrics(ctx, logger, clientID, prometheusPushGateways)
+		go ExportPrometheusMetrics(ctx, logger, clientID, prometheusPushGateways, prometheusListenAddress)
 	}
 }
 
@@ -138,7 +246,25 @@ func Init(clientID, country string) {
 			Name:        "db1000n_http_request_total",
 			Help:        "Number of http queries",
 			ConstLabels: constLabels,
-		}, []string{HTTPDestinationHostLabel, HTTPMethodLabel, StatusLabel})
+		}, []string{JobIDLabel, HTTPDestinationHostLabel, HTTPMethodLabel, StatusLabel})
+	validationCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_http_validation_total",
+			Help:        "Number of http responses checked against the expected ones",
+			ConstLabels: constLabels,
+		}, []string{JobIDLabel, HTTPDestinationHostLabel, StatusLabel})
+	connectionCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_http_connection_total",
+			Help:        "Number of http queries sent over new and reused connections",
+			ConstLabels: constLabels,
+		}, []string{HTTPAddressLabel, HTTPConnectionLabel})
+	handshakeCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_http_fresh_handshake_total",
+			Help:        "Number of connections dialed by http clients forced to use a fresh connection per request",
+			ConstLabels: constLabels,
+		}, []string{HTTPAddressLabel})
 	packetgenCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name:        "db1000n_packetgen_total",
@@ -157,20 +283,168 @@ func Init(clientID, country string) {
 			Help:        "Number of sent raw tcp/udp packets",
 			ConstLabels: constLabels,
 		}, []string{RawnetAddressLabel, RawnetProtocolLabel, StatusLabel})
+	icmpReplyCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_icmp_replies_total",
+			Help:        "Number of received icmp echo replies",
+			ConstLabels: constLabels,
+		}, []string{ICMPAddressLabel})
+	udpReplyCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_udp_replies_total",
+			Help:        "Number of udp packets that got a reply (success) or didn't get one in time (timeout)",
+			ConstLabels: constLabels,
+		}, []string{UDPReplyAddressLabel, UDPReplyProtocolLabel, StatusLabel})
+	websocketCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_websocket_total",
+			Help:        "Number of sent websocket messages",
+			ConstLabels: constLabels,
+		}, []string{WebsocketAddressLabel, StatusLabel})
+	mqttCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_mqtt_publish_total",
+			Help:        "Number of published mqtt messages",
+			ConstLabels: constLabels,
+		}, []string{MQTTAddressLabel, StatusLabel})
+	smtpCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_smtp_session_total",
+			Help:        "Number of smtp sessions",
+			ConstLabels: constLabels,
+		}, []string{SMTPAddressLabel, StatusLabel})
+	grpcCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_grpc_request_total",
+			Help:        "Number of grpc requests",
+			ConstLabels: constLabels,
+		}, []string{GRPCAddressLabel, GRPCMethodLabel, StatusLabel})
+	tlsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_tls_handshake_total",
+			Help:        "Number of tls handshakes",
+			ConstLabels: constLabels,
+		}, []string{TLSHandshakeAddressLabel, StatusLabel})
+	breakerCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_circuit_breaker_transitions_total",
+			Help:        "Number of circuit breaker state transitions",
+			ConstLabels: constLabels,
+		}, []string{CircuitBreakerAddressLabel, CircuitBreakerStateLabel})
+	ntpAmplificationGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_ntp_amplification_ratio",
+			Help:        "Ratio of bytes received from ntp server to bytes sent to it",
+			ConstLabels: constLabels,
+		}, []string{NTPServerLabel})
+	proxiesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_proxies",
+			Help:        "Number of health checked proxies by state",
+			ConstLabels: constLabels,
+		}, []string{ProxyStateLabel})
+	httpConcurrencyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_http_concurrency",
+			Help:        "Number of concurrent request loops of http jobs with adaptive concurrency",
+			ConstLabels: constLabels,
+		}, []string{HTTPPathLabel})
+	jobIterationsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_job_iterations",
+			Help:        "Number of completed iterations of the job and their total for jobs with a limited count",
+			ConstLabels: constLabels,
+		}, []string{JobIDLabel, JobNameLabel, JobIterationsLabel})
+	jobRateGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_job_iteration_rate",
+			Help:        "Number of iterations of the job per second over the last 30 seconds",
+			ConstLabels: constLabels,
+		}, []string{JobIDLabel, JobNameLabel})
+	jobETAGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_job_eta_seconds",
+			Help:        "Estimated time left until the job with a limited count is done at its current rate",
+			ConstLabels: constLabels,
+		}, []string{JobIDLabel, JobNameLabel})
+	jobLabelsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_job_labels",
+			Help:        "Labels set in the config of the running jobs, always 1. Join on job_id to slice the job progress and http request metrics by the labels",
+			ConstLabels: constLabels,
+		}, []string{JobIDLabel, JobNameLabel, JobLabelKeyLabel, JobLabelValueLabel})
+	throughputGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "db1000n_throughput_megabytes_per_second",
+			Help:        "Average throughput of the current stream of the throughput job",
+			ConstLabels: constLabels,
+		}, []string{HTTPDestinationHostLabel, ThroughputDirectionLabel})
+	proxyErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_proxy_errors_total",
+			Help:        "Number of requests that failed to connect through the proxy",
+			ConstLabels: constLabels,
+		}, []string{ProxyAddressLabel})
+	proxyCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_proxy_requests_total",
+			Help:        "Number of http requests sent through the proxy",
+			ConstLabels: constLabels,
+		}, []string{JobIDLabel, ProxyAddressLabel, StatusLabel})
+	slaCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "db1000n_http_sla_breaches_total",
+			Help:        "Number of http requests that took longer than sla_threshold of the job",
+			ConstLabels: constLabels,
+		}, []string{JobIDLabel, HTTPDestinationHostLabel})
 	clientCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name:        "db1000n_client_total",
 		Help:        "Number of clients",
 		ConstLabels: constLabels,
 	}, []string{})
+	trafficGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "db1000n_traffic_bytes",
+		Help:        "Amount of generated traffic since the last config update",
+		ConstLabels: constLabels,
+	}, func() float64 { return float64(Default.Read(Traffic)) })
+	processedTrafficGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "db1000n_processed_traffic_bytes",
+		Help:        "Amount of traffic processed by targets since the last config update",
+		ConstLabels: constLabels,
+	}, func() float64 { return float64(Default.Read(ProcessedTraffic)) })
 }
 
-func registerMetrics() {
-	prometheus.MustRegister(dnsBlastCounter)
-	prometheus.MustRegister(httpCounter)
-	prometheus.MustRegister(packetgenCounter)
-	prometheus.MustRegister(slowlorisCounter)
-	prometheus.MustRegister(rawnetCounter)
-	prometheus.MustRegister(clientCounter)
+func registerMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(dnsBlastCounter)
+	registerer.MustRegister(httpCounter)
+	registerer.MustRegister(validationCounter)
+	registerer.MustRegister(connectionCounter)
+	registerer.MustRegister(handshakeCounter)
+	registerer.MustRegister(packetgenCounter)
+	registerer.MustRegister(slowlorisCounter)
+	registerer.MustRegister(rawnetCounter)
+	registerer.MustRegister(icmpReplyCounter)
+	registerer.MustRegister(udpReplyCounter)
+	registerer.MustRegister(websocketCounter)
+	registerer.MustRegister(mqttCounter)
+	registerer.MustRegister(smtpCounter)
+	registerer.MustRegister(grpcCounter)
+	registerer.MustRegister(tlsCounter)
+	registerer.MustRegister(breakerCounter)
+	registerer.MustRegister(clientCounter)
+	registerer.MustRegister(proxyErrorCounter)
+	registerer.MustRegister(proxyCounter)
+	registerer.MustRegister(slaCounter)
+	registerer.MustRegister(ntpAmplificationGauge)
+	registerer.MustRegister(proxiesGauge)
+	registerer.MustRegister(httpConcurrencyGauge)
+	registerer.MustRegister(jobIterationsGauge)
+	registerer.MustRegister(jobRateGauge)
+	registerer.MustRegister(jobETAGauge)
+	registerer.MustRegister(jobLabelsGauge)
+	registerer.MustRegister(throughputGauge)
+	registerer.MustRegister(trafficGauge)
+	registerer.MustRegister(processedTrafficGauge)
 }
 
 // ValidatePrometheusPushGateways split value into list of comma separated values and validate that each value
@@ -191,16 +465,16 @@ func ValidatePrometheusPushGateways(gatewayURLsCSV string) bool {
 	return true
 }
 
-// ExportPrometheusMetrics starts http server and export metrics at address <ip>:9090/metrics, also pushes metrics
+// ExportPrometheusMetrics starts http server and export metrics at address <listenAddress>/metrics, also pushes metrics
 // to gateways randomly
-func ExportPrometheusMetrics(ctx context.Context, logger *zap.Logger, clientID, gateways string) {
-	registerMetrics()
+func ExportPrometheusMetrics(ctx context.Context, logger *zap.Logger, clientID, gateways, listenAddress string) {
+	registerMetrics(prometheus.DefaultRegisterer)
 
 	if gateways != "" {
 		go pushMetrics(ctx, logger, clientID, strings.Split(gateways, ","))
 	}
 
-	serveMetrics(ctx)
+	ServePrometheus(ctx, listenAddress)
 }
 
 // BasicAuth client's credentials for push gateway encrypted with utils/crypto.go#EncryptionKeys[0] key
@@ -323,19 +597,91 @@ func IncDNSBlast(rootDomain, seedDomain, protocol, status string) {
 	}).Inc()
 }
 
-// IncHTTP increments counter of sent http queries
-func IncHTTP(host, method, status string) {
+// IncHTTP increments counter of sent http queries, jobID is empty for the requests sent outside of the jobs run by the runner
+func IncHTTP(jobID, host, method, status string) {
 	if httpCounter == nil {
 		return
 	}
 
 	httpCounter.With(prometheus.Labels{
+		JobIDLabel:               jobID,
 		HTTPMethodLabel:          method,
 		HTTPDestinationHostLabel: host,
 		StatusLabel:              status,
 	}).Inc()
 }
 
+// IncProxyError increments counter of requests that failed to connect through the proxy
+func IncProxyError(proxy string) {
+	if proxyErrorCounter == nil {
+		return
+	}
+
+	proxyErrorCounter.With(prometheus.Labels{ProxyAddressLabel: proxy}).Inc()
+}
+
+// IncHTTPValidation increments counter of http responses checked against the expected ones
+func IncHTTPValidation(jobID, host, status string) {
+	if validationCounter == nil {
+		return
+	}
+
+	validationCounter.With(prometheus.Labels{
+		JobIDLabel:               jobID,
+		HTTPDestinationHostLabel: host,
+		StatusLabel:              status,
+	}).Inc()
+}
+
+// IncProxyRequest increments counter of http requests sent through the proxy
+func IncProxyRequest(jobID, proxy string, success bool) {
+	if proxyCounter == nil {
+		return
+	}
+
+	status := StatusFail
+	if success {
+		status = StatusSuccess
+	}
+
+	proxyCounter.With(prometheus.Labels{JobIDLabel: jobID, ProxyAddressLabel: proxy, StatusLabel: status}).Inc()
+}
+
+// IncSLABreach increments counter of http requests to the host that took longer than the threshold
+func IncSLABreach(jobID, host string) {
+	if slaCounter == nil {
+		return
+	}
+
+	slaCounter.With(prometheus.Labels{JobIDLabel: jobID, HTTPDestinationHostLabel: host}).Inc()
+}
+
+// IncHTTPConnection increments counter of http queries sent over new or reused connections
+func IncHTTPConnection(address string, reused bool) {
+	if connectionCounter == nil {
+		return
+	}
+
+	connection := HTTPConnectionNew
+	if reused {
+		connection = HTTPConnectionReused
+	}
+
+	connectionCounter.With(prometheus.Labels{
+		HTTPAddressLabel:    address,
+		HTTPConnectionLabel: connection,
+	}).Inc()
+}
+
+// IncHTTPFreshHandshake increments counter of connections dialed for requests forced to use a fresh connection
+func IncHTTPFreshHandshake(address string) {
+	if handshakeCounter == nil {
+		return
+	}
+
+	handshakeCounter.With(prometheus.Labels{HTTPAddressLabel: address}).Inc()
+}
+
 // IncPacketgen increments counter of sent raw packets
 func IncPacketgen(host, hostPort, protocol, status, id string) {
 	if packetgenCounter == nil {
@@ -390,6 +736,208 @@ func IncRawnetUDP(address, status string) {
 	}).Inc()
 }
 
+// IncRawnetICMP increments counter of sent icmp echo requests
+func IncRawnetICMP(address, status string) {
+	if rawnetCounter == nil {
+		return
+	}
+
+	rawnetCounter.With(prometheus.Labels{
+		RawnetAddressLabel:  address,
+		RawnetProtocolLabel: "icmp",
+		StatusLabel:         status,
+	}).Inc()
+}
+
+// IncICMPReply increments counter of received icmp echo replies
+func IncICMPReply(address string) {
+	if icmpReplyCounter == nil {
+		return
+	}
+
+	icmpReplyCounter.With(prometheus.Labels{ICMPAddressLabel: address}).Inc()
+}
+
+// IncUDPReply increments counter of udp packets that got a reply or timed out waiting for it
+func IncUDPReply(address, protocol, status string) {
+	if udpReplyCounter == nil {
+		return
+	}
+
+	udpReplyCounter.With(prometheus.Labels{
+		UDPReplyAddressLabel:  address,
+		UDPReplyProtocolLabel: protocol,
+		StatusLabel:           status,
+	}).Inc()
+}
+
+// IncWebsocket increments counter of sent websocket messages
+func IncWebsocket(address, status string) {
+	if websocketCounter == nil {
+		return
+	}
+
+	websocketCounter.With(prometheus.Labels{
+		WebsocketAddressLabel: address,
+		StatusLabel:           status,
+	}).Inc()
+}
+
+// IncMQTT increments counter of published mqtt messages
+func IncMQTT(address, status string) {
+	if mqttCounter == nil {
+		return
+	}
+
+	mqttCounter.With(prometheus.Labels{
+		MQTTAddressLabel: address,
+		StatusLabel:      status,
+	}).Inc()
+}
+
+// IncSMTP increments counter of smtp sessions
+func IncSMTP(address, status string) {
+	if smtpCounter == nil {
+		return
+	}
+
+	smtpCounter.With(prometheus.Labels{
+		SMTPAddressLabel: address,
+		StatusLabel:      status,
+	}).Inc()
+}
+
+// IncGRPC increments counter of sent grpc requests
+func IncGRPC(address, method, status string) {
+	if grpcCounter == nil {
+		return
+	}
+
+	grpcCounter.With(prometheus.Labels{
+		GRPCAddressLabel: address,
+		GRPCMethodLabel:  method,
+		StatusLabel:      status,
+	}).Inc()
+}
+
+// IncTLSHandshake increments counter of tls handshakes
+func IncTLSHandshake(address, status string) {
+	if tlsCounter == nil {
+		return
+	}
+
+	tlsCounter.With(prometheus.Labels{
+		TLSHandshakeAddressLabel: address,
+		StatusLabel:              status,
+	}).Inc()
+}
+
+// SetNTPAmplification sets amplification factor measured for ntp server
+func SetNTPAmplification(server string, ratio float64) {
+	if ntpAmplificationGauge == nil {
+		return
+	}
+
+	ntpAmplificationGauge.With(prometheus.Labels{NTPServerLabel: server}).Set(ratio)
+}
+
+// SetThroughput sets the throughput to the host in the direction in megabytes per second
+func SetThroughput(host, direction string, mbps float64) {
+	if throughputGauge == nil {
+		return
+	}
+
+	throughputGauge.With(prometheus.Labels{HTTPDestinationHostLabel: host, ThroughputDirectionLabel: direction}).Set(mbps)
+}
+
+// AddProxies changes the number of health checked proxies in the state
+func AddProxies(state string, delta int) {
+	Default.proxyStates.add(state, delta)
+
+	if proxiesGauge == nil {
+		return
+	}
+
+	proxiesGauge.With(prometheus.Labels{ProxyStateLabel: state}).Add(float64(delta))
+}
+
+// AddHTTPConcurrency changes the number of concurrent request loops of the http job
+func AddHTTPConcurrency(path string, delta int) {
+	if httpConcurrencyGauge == nil {
+		return
+	}
+
+	httpConcurrencyGauge.With(prometheus.Labels{HTTPPathLabel: path}).Add(float64(delta))
+}
+
+// SetJobProgress exports the progress of the job, total and eta are only exported when they are not nil
+func SetJobProgress(id, name string, completed uint64, total *uint64, rate float64, eta *float64) {
+	if jobIterationsGauge == nil {
+		return
+	}
+
+	labels := prometheus.Labels{JobIDLabel: id, JobNameLabel: name}
+
+	jobIterationsGauge.With(prometheus.Labels{JobIDLabel: id, JobNameLabel: name, JobIterationsLabel: JobIterationsCompleted}).Set(float64(completed))
+	jobRateGauge.With(labels).Set(rate)
+
+	if total != nil {
+		jobIterationsGauge.With(prometheus.Labels{JobIDLabel: id, JobNameLabel: name, JobIterationsLabel: JobIterationsTotal}).Set(float64(*total))
+	}
+
+	if eta != nil {
+		jobETAGauge.With(labels).Set(*eta)
+	}
+}
+
+// DeleteJobProgress stops exporting the progress of the job once it's no longer running
+func DeleteJobProgress(id, name string) {
+	if jobIterationsGauge == nil {
+		return
+	}
+
+	labels := prometheus.Labels{JobIDLabel: id, JobNameLabel: name}
+
+	jobIterationsGauge.Delete(prometheus.Labels{JobIDLabel: id, JobNameLabel: name, JobIterationsLabel: JobIterationsCompleted})
+	jobIterationsGauge.Delete(prometheus.Labels{JobIDLabel: id, JobNameLabel: name, JobIterationsLabel: JobIterationsTotal})
+	jobRateGauge.Delete(labels)
+	jobETAGauge.Delete(labels)
+}
+
+// SetJobLabels exports the labels of the job, one series per label
+func SetJobLabels(id, name string, labels map[string]string) {
+	if jobLabelsGauge == nil {
+		return
+	}
+
+	for key, value := range labels {
+		jobLabelsGauge.With(prometheus.Labels{JobIDLabel: id, JobNameLabel: name, JobLabelKeyLabel: key, JobLabelValueLabel: value}).Set(1)
+	}
+}
+
+// DeleteJobLabels stops exporting the labels of the job once it's no longer running
+func DeleteJobLabels(id, name string, labels map[string]string) {
+	if jobLabelsGauge == nil {
+		return
+	}
+
+	for key, value := range labels {
+		jobLabelsGauge.Delete(prometheus.Labels{JobIDLabel: id, JobNameLabel: name, JobLabelKeyLabel: key, JobLabelValueLabel: value})
+	}
+}
+
+// IncCircuitBreaker increments counter of circuit breaker transitions to the state
+func IncCircuitBreaker(address, state string) {
+	if breakerCounter == nil {
+		return
+	}
+
+	breakerCounter.With(prometheus.Labels{
+		CircuitBreakerAddressLabel: address,
+		CircuitBreakerStateLabel:   state,
+	}).Inc()
+}
+
 // IncClient increments counter of calls from the current client ID
 func IncClient() {
 	if clientCounter == nil {
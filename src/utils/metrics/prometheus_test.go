@@ -0,0 +1,77 @@
+//go:build !encrypted
+// +build !encrypted
+
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	t.Parallel()
+
+	Init("test-client", "")
+
+	// a registry of its own so that the test doesn't touch the global one other tests may use
+	registry := prometheus.NewRegistry()
+	registerMetrics(registry)
+
+	IncHTTP("1", "example.com", http.MethodGet, StatusSuccess)
+	IncHTTP("1", "example.com", http.MethodGet, StatusSuccess)
+	IncHTTP("1", "example.com", http.MethodPost, StatusFail)
+	IncHTTP("1", "example.com", http.MethodPost, StatusProxyFail)
+	IncHTTP("1", "example.com", http.MethodPost, StatusDNSFail)
+	IncHTTP("", "example.com", http.MethodGet, StatusSuccess)
+	IncHTTPValidation("1", "example.com", StatusSuccess)
+	IncProxyRequest("1", "127.0.0.1:1080", true)
+	IncSLABreach("1", "example.com")
+	IncProxyError("127.0.0.1:1080")
+	Default.Write(Traffic, "test-job", 1024)
+	SetJobLabels("1", "flood", map[string]string{"campaign": "spring", "team": "a"})
+	SetJobLabels("2", "other", map[string]string{"campaign": "autumn"})
+	DeleteJobLabels("2", "other", map[string]string{"campaign": "autumn"})
+
+	server := httptest.NewServer(metricsHandler(registry))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics") //nolint:noctx // Test request
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, series := range []string{
+		`db1000n_http_request_total{destination_host="example.com",job_id="1",method="GET",status="success"} 2`,
+		`db1000n_http_request_total{destination_host="example.com",job_id="1",method="POST",status="fail"} 1`,
+		`db1000n_http_request_total{destination_host="example.com",job_id="1",method="POST",status="proxy_fail"} 1`,
+		`db1000n_http_request_total{destination_host="example.com",job_id="1",method="POST",status="dns_fail"} 1`,
+		`db1000n_http_request_total{destination_host="example.com",job_id="",method="GET",status="success"} 1`,
+		`db1000n_http_validation_total{destination_host="example.com",job_id="1",status="success"} 1`,
+		`db1000n_proxy_requests_total{job_id="1",proxy="127.0.0.1:1080",status="success"} 1`,
+		`db1000n_http_sla_breaches_total{destination_host="example.com",job_id="1"} 1`,
+		`db1000n_proxy_errors_total{proxy="127.0.0.1:1080"} 1`,
+		`db1000n_traffic_bytes 1024`,
+		`db1000n_job_labels{job_id="1",job_name="flood",label="campaign",value="spring"} 1`,
+		`db1000n_job_labels{job_id="1",job_name="flood",label="team",value="a"} 1`,
+	} {
+		if !strings.Contains(string(body), series) {
+			t.Errorf("expected exposition to contain %q, got:\n%s", series, body)
+		}
+	}
+
+	if strings.Contains(string(body), `job_id="2"`) {
+		t.Errorf("expected the labels of the deleted job to be gone, got:\n%s", body)
+	}
+}
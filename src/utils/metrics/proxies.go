@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxySummary holds the results of the requests sent through a single proxy
+type ProxySummary struct {
+	Proxy         string
+	Success, Fail uint64
+	BytesSent     uint64
+	BytesReceived uint64
+	P50, P90, P99 time.Duration // of the successful requests
+	SuccessRate   float64       // share of the successful requests, between 0 and 1
+}
+
+type proxyStats struct {
+	success, fail            uint64
+	bytesSent, bytesReceived uint64
+	latency                  *Histogram
+}
+
+// proxies are stored by the proxy address without credentials, the same one ProxyError reports
+type proxies struct {
+	stats sync.Map // map by proxy
+}
+
+func (p *proxies) get(proxy string) *proxyStats {
+	if stats, ok := p.stats.Load(proxy); ok {
+		return stats.(*proxyStats)
+	}
+
+	stats, _ := p.stats.LoadOrStore(proxy, &proxyStats{latency: NewHistogram()})
+
+	return stats.(*proxyStats)
+}
+
+// ObserveProxy records the result of a request sent through the proxy, requests sent directly (with an empty proxy) are ignored
+func (ms *Storage) ObserveProxy(proxy string, success bool, sent, received uint64, d time.Duration) {
+	if proxy == "" {
+		return
+	}
+
+	stats := ms.proxies.get(proxy)
+
+	if success {
+		atomic.AddUint64(&stats.success, 1)
+		stats.latency.Observe(d)
+	} else {
+		atomic.AddUint64(&stats.fail, 1)
+	}
+
+	atomic.AddUint64(&stats.bytesSent, sent)
+	atomic.AddUint64(&stats.bytesReceived, received)
+}
+
+// ProxyLeaderboard returns the stats of all the proxies, the best performing ones first: sorted by success rate,
+// then by median latency and then by address
+func (ms *Storage) ProxyLeaderboard() []ProxySummary {
+	const (
+		p50 = 0.5
+		p90 = 0.9
+		p99 = 0.99
+	)
+
+	var summaries []ProxySummary
+
+	ms.proxies.stats.Range(func(k, v interface{}) bool {
+		stats := v.(*proxyStats)
+		summary := ProxySummary{
+			Proxy:         k.(string),
+			Success:       atomic.LoadUint64(&stats.success),
+			Fail:          atomic.LoadUint64(&stats.fail),
+			BytesSent:     atomic.LoadUint64(&stats.bytesSent),
+			BytesReceived: atomic.LoadUint64(&stats.bytesReceived),
+			P50:           stats.latency.Quantile(p50),
+			P90:           stats.latency.Quantile(p90),
+			P99:           stats.latency.Quantile(p99),
+		}
+
+		if total := summary.Success + summary.Fail; total > 0 {
+			summary.SuccessRate = float64(summary.Success) / float64(total)
+		}
+
+		summaries = append(summaries, summary)
+
+		return true
+	})
+
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+
+		switch {
+		case a.SuccessRate != b.SuccessRate:
+			return a.SuccessRate > b.SuccessRate
+		case a.P50 != b.P50:
+			return a.P50 < b.P50
+		default:
+			return a.Proxy < b.Proxy
+		}
+	})
+
+	return summaries
+}
+
+// proxyStates counts the health checked proxies by state, see AddProxies
+type proxyStates struct {
+	counts sync.Map // map by state
+}
+
+func (p *proxyStates) add(state string, delta int) {
+	count, _ := p.counts.LoadOrStore(state, new(int64))
+	atomic.AddInt64(count.(*int64), int64(delta))
+}
+
+// get returns the number of proxies in the state and whether any proxy has ever been health checked
+func (p *proxyStates) get(state string) (count int64, ok bool) {
+	if v, ok := p.counts.Load(state); ok {
+		return atomic.LoadInt64(v.(*int64)), true
+	}
+
+	_, live := p.counts.Load(ProxyStateLive)
+
+	return 0, live
+}
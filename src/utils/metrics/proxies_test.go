@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProxyLeaderboard(t *testing.T) {
+	t.Parallel()
+
+	var storage Storage
+
+	storage.ObserveProxy("", true, 1, 1, time.Millisecond)
+	storage.ObserveProxy("flaky", true, 10, 100, time.Millisecond)
+	storage.ObserveProxy("flaky", false, 10, 0, time.Second)
+	storage.ObserveProxy("slow", true, 10, 100, time.Second)
+	storage.ObserveProxy("fast", true, 10, 100, time.Millisecond)
+	storage.ObserveProxy("fast", true, 10, 100, time.Millisecond)
+
+	leaderboard := storage.ProxyLeaderboard()
+	if len(leaderboard) != 3 {
+		t.Fatalf("expected direct requests to be ignored, got %+v", leaderboard)
+	}
+
+	for i, want := range []string{"fast", "slow", "flaky"} {
+		if leaderboard[i].Proxy != want {
+			t.Errorf("expected %v at position %d, got %+v", want, i, leaderboard)
+		}
+	}
+
+	if fast := leaderboard[0]; fast.Success != 2 || fast.Fail != 0 || fast.BytesSent != 20 || fast.BytesReceived != 200 || fast.P50 != time.Millisecond {
+		t.Errorf("unexpected stats %+v", fast)
+	}
+
+	if flaky := leaderboard[2]; flaky.Success != 1 || flaky.Fail != 1 || flaky.SuccessRate != 0.5 || flaky.P99 != time.Millisecond {
+		t.Errorf("expected failures to only affect the success rate, got %+v", flaky)
+	}
+
+	storage.ResetAll()
+
+	if leaderboard := storage.ProxyLeaderboard(); len(leaderboard) != 0 {
+		t.Errorf("expected reset to clear the proxies, got %+v", leaderboard)
+	}
+}
@@ -14,13 +14,30 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func serveMetrics(ctx context.Context) {
+// ServePrometheus exposes registered metrics at <addr>/metrics until the context is cancelled
+func ServePrometheus(ctx context.Context, addr string) {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: metricsHandler(prometheus.DefaultGatherer),
+	}
+	go func(ctx context.Context, server *http.Server) {
+		<-ctx.Done()
+
+		if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("failure shutting down prometheus server:", err)
+		}
+	}(ctx, server)
+
+	log.Println(server.ListenAndServe())
+}
+
+func metricsHandler(gatherer prometheus.Gatherer) http.Handler {
 	// We don't expect that rendering metrics should take a lot of time and needs long timeout
 	const timeout = 30 * time.Second
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.HandlerFor(
-		prometheus.DefaultGatherer,
+		gatherer,
 		promhttp.HandlerOpts{
 			// Opt into OpenMetrics to support exemplars.
 			EnableOpenMetrics: true,
@@ -28,17 +45,5 @@ func serveMetrics(ctx context.Context) {
 		},
 	))
 
-	server := &http.Server{
-		Addr:    "0.0.0.0:9090",
-		Handler: mux,
-	}
-	go func(ctx context.Context, server *http.Server) {
-		<-ctx.Done()
-
-		if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Println("failure shutting down prometheus server:", err)
-		}
-	}(ctx, server)
-
-	log.Println(server.ListenAndServe())
+	return mux
 }
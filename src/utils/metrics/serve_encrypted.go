@@ -7,6 +7,7 @@ import (
 	"context"
 )
 
-func serveMetrics(ctx context.Context) {
+// ServePrometheus is disabled in encrypted builds, it only blocks until the context is cancelled
+func ServePrometheus(ctx context.Context, _ string) {
 	<-ctx.Done()
 }
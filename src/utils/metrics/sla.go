@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// SLABreachSummary holds the amount of requests to a single host that took longer than the threshold of their job
+type SLABreachSummary struct {
+	Host     string
+	Breaches uint64
+}
+
+// slaBreaches are stored by host, same as latencies
+type slaBreaches struct {
+	counts sync.Map // map by host to *uint64
+}
+
+// ObserveSLABreach records a request to the host that took longer than the threshold
+func (ms *Storage) ObserveSLABreach(host string) {
+	count, ok := ms.slaBreaches.counts.Load(host)
+	if !ok {
+		count, _ = ms.slaBreaches.counts.LoadOrStore(host, new(uint64))
+	}
+
+	atomic.AddUint64(count.(*uint64), 1)
+}
+
+// SLABreaches returns the amount of requests to the host that took longer than the threshold
+func (ms *Storage) SLABreaches(host string) uint64 {
+	if count, ok := ms.slaBreaches.counts.Load(host); ok {
+		return atomic.LoadUint64(count.(*uint64))
+	}
+
+	return 0
+}
+
+// SLABreachSummaries returns breach counters for all the hosts sorted by host
+func (ms *Storage) SLABreachSummaries() []SLABreachSummary {
+	var summaries []SLABreachSummary
+
+	ms.slaBreaches.counts.Range(func(k, v interface{}) bool {
+		summaries = append(summaries, SLABreachSummary{Host: k.(string), Breaches: atomic.LoadUint64(v.(*uint64))})
+
+		return true
+	})
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Host < summaries[j].Host })
+
+	return summaries
+}
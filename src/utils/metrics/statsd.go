@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Arriven/db1000n/src/utils"
+)
+
+// statsDMaxPacketSize keeps packets within a typical MTU so that they are not fragmented
+const statsDMaxPacketSize = 1432
+
+// NewStatsDOptionsWithFlags returns statsd options initialized with command line flags.
+func NewStatsDOptionsWithFlags() (statsDAddress, statsDPrefix *string, statsDInterval *time.Duration) {
+	const defaultInterval = 10 * time.Second
+
+	return flag.String("statsd_address", utils.GetEnvStringDefault("STATSD_ADDRESS", ""),
+			"StatsD server address (host:port) to send metrics to over udp (disabled if empty)"),
+		flag.String("statsd_prefix", utils.GetEnvStringDefault("STATSD_PREFIX", "db1000n"),
+			"Prefix for the names of metrics sent to StatsD"),
+		flag.Duration("statsd_interval", utils.GetEnvDurationDefault("STATSD_INTERVAL", defaultInterval),
+			"How often to flush metrics to StatsD")
+}
+
+// StatsDSink periodically sends metrics aggregated in the storage to a statsd server
+type StatsDSink struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	prefix  string
+	storage *Storage
+	sent    map[string]uint64 // counter values already reported, by metric name
+}
+
+// NewStatsDSink creates a sink sending metrics from Default storage to addr over udp
+func NewStatsDSink(addr, prefix string) (*StatsDSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to statsd: %w", err)
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	return &StatsDSink{conn: conn, prefix: prefix, storage: &Default, sent: make(map[string]uint64)}, nil
+}
+
+// Run flushes metrics every interval until the context is done
+func (s *StatsDSink) Run(ctx context.Context, logger *zap.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				logger.Debug("error sending metrics to statsd", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Close flushes the final metrics snapshot and closes the connection
+func (s *StatsDSink) Close() error {
+	flushErr := s.Flush()
+
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+
+	return flushErr
+}
+
+// Flush sends current traffic gauges and http counters increments since the previous flush
+func (s *StatsDSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines := []string{
+		fmt.Sprintf("%s%s:%d|g", s.prefix, Traffic, s.storage.Read(Traffic)),
+		fmt.Sprintf("%s%s:%d|g", s.prefix, ProcessedTraffic, s.storage.Read(ProcessedTraffic)),
+	}
+
+	for _, summary := range s.storage.LatencySummaries() {
+		lines = s.appendCounter(lines, fmt.Sprintf("%shttp.%s.%s", s.prefix, statsDName(summary.Host), summary.Status), summary.Count)
+	}
+
+	for _, summary := range s.storage.ConnectionSummaries() {
+		name := fmt.Sprintf("%shttp_connection.%s.", s.prefix, statsDName(summary.Address))
+		lines = s.appendCounter(lines, name+HTTPConnectionNew, summary.New)
+		lines = s.appendCounter(lines, name+HTTPConnectionReused, summary.Reused)
+	}
+
+	return s.send(lines)
+}
+
+// appendCounter appends the increment of the counter since the previous flush to lines unless it's zero
+func (s *StatsDSink) appendCounter(lines []string, name string, count uint64) []string {
+	delta := count - s.sent[name]
+	if count < s.sent[name] {
+		delta = count // storage has been reset since the previous flush
+	}
+
+	s.sent[name] = count
+
+	if delta > 0 {
+		lines = append(lines, fmt.Sprintf("%s:%d|c", name, delta))
+	}
+
+	return lines
+}
+
+// send batches lines into as few packets as possible
+func (s *StatsDSink) send(lines []string) error {
+	var packet bytes.Buffer
+
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > statsDMaxPacketSize {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+
+			packet.Reset()
+		}
+
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+
+		packet.WriteString(line)
+	}
+
+	if packet.Len() == 0 {
+		return nil
+	}
+
+	_, err := s.conn.Write(packet.Bytes())
+
+	return err
+}
+
+// statsDName replaces characters that have special meaning in statsd protocol or graphite paths
+func statsDName(s string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_", "\n", "_").Replace(s)
+}
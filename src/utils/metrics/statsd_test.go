@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startStatsDReceiver(t *testing.T) (addr string, packets <-chan string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	received := make(chan string, 100)
+
+	go func() {
+		buf := make([]byte, 2*statsDMaxPacketSize)
+
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			received <- string(buf[:n])
+		}
+	}()
+
+	return conn.LocalAddr().String(), received
+}
+
+func receiveLines(t *testing.T, packets <-chan string) (lines []string, count int) {
+	t.Helper()
+
+	for {
+		select {
+		case packet := <-packets:
+			if len(packet) > statsDMaxPacketSize {
+				t.Errorf("packet of %d bytes exceeds the limit", len(packet))
+			}
+
+			lines = append(lines, strings.Split(packet, "\n")...)
+			count++
+		case <-time.After(100 * time.Millisecond):
+			sort.Strings(lines)
+
+			return lines, count
+		}
+	}
+}
+
+func TestStatsDSink(t *testing.T) {
+	t.Parallel()
+
+	addr, packets := startStatsDReceiver(t)
+
+	sink, err := NewStatsDSink(addr, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.conn.Close()
+
+	storage := &Storage{trackers: map[string]*metricTracker{Traffic: {}, ProcessedTraffic: {}}}
+	sink.storage = storage
+
+	storage.Write(Traffic, "job", 100)
+	storage.Write(ProcessedTraffic, "job", 50)
+	storage.ObserveLatency("example.com", StatusSuccess, time.Millisecond)
+	storage.ObserveLatency("example.com", StatusSuccess, time.Millisecond)
+	storage.ObserveLatency("example.com", StatusFail, time.Millisecond)
+	storage.ObserveConnection("example.com:443", false)
+	storage.ObserveConnection("example.com:443", true)
+	storage.ObserveConnection("example.com:443", true)
+
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, count := receiveLines(t, packets)
+	if count != 1 {
+		t.Errorf("expected metrics to be batched into a single packet, got %d", count)
+	}
+
+	if want := []string{
+		"test.http.example_com.fail:1|c",
+		"test.http.example_com.success:2|c",
+		"test.http_connection.example_com_443.new:1|c",
+		"test.http_connection.example_com_443.reused:2|c",
+		"test.processed_traffic:50|g",
+		"test.traffic:100|g",
+	}; strings.Join(lines, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected lines %v, expected %v", lines, want)
+	}
+
+	// only increments are sent for counters
+	storage.ObserveLatency("example.com", StatusSuccess, time.Millisecond)
+
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, _ = receiveLines(t, packets)
+	if want := []string{
+		"test.http.example_com.success:1|c",
+		"test.processed_traffic:50|g",
+		"test.traffic:100|g",
+	}; strings.Join(lines, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected lines %v, expected %v", lines, want)
+	}
+}
+
+func TestStatsDSinkBatching(t *testing.T) {
+	t.Parallel()
+
+	addr, packets := startStatsDReceiver(t)
+
+	sink, err := NewStatsDSink(addr, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.conn.Close()
+
+	const linesCount = 200
+
+	lines := make([]string, linesCount)
+	for i := range lines {
+		lines[i] = "test.some.long.metric.name:1|c"
+	}
+
+	if err := sink.send(lines); err != nil {
+		t.Fatal(err)
+	}
+
+	received, count := receiveLines(t, packets)
+	if len(received) != linesCount {
+		t.Errorf("expected %d lines, got %d", linesCount, len(received))
+	}
+
+	if maxCount := linesCount * len(lines[0]) / statsDMaxPacketSize; count < 2 || count > maxCount+1 {
+		t.Errorf("unexpected amount of packets: %d", count)
+	}
+}
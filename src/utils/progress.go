@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressRateWindow     = 30 * time.Second // the rate is derived from the iterations done during the window
+	progressSampleInterval = time.Second
+)
+
+// Progress tracks how many iterations of a job are done out of the total and how fast they are done.
+// The zero value is ready to use and it's safe to share between goroutines
+type Progress struct {
+	completed uint64 // atomic
+
+	mu        sync.Mutex
+	total     uint64
+	unbounded bool // some of the iterations aren't limited so there is no total
+	samples   []progressSample
+
+	now func() time.Time // time.Now if nil
+}
+
+type progressSample struct {
+	at        time.Time
+	completed uint64
+}
+
+// ProgressSnapshot is the state of the progress at some point, Total and ETA are only set when the amount of iterations is limited
+type ProgressSnapshot struct {
+	Completed uint64   `json:"completed"`
+	Total     uint64   `json:"total,omitempty"`
+	Rate      float64  `json:"rate"`                  // iterations per second
+	ETA       *float64 `json:"eta_seconds,omitempty"` // seconds left at the current rate
+}
+
+// AddTotal adds the iterations of a job instance to the total, count that isn't positive means the instance is not limited
+func (p *Progress) AddTotal(count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if count <= 0 {
+		p.unbounded = true
+	} else {
+		p.total += uint64(count)
+	}
+
+	p.sample()
+}
+
+// Done counts a completed iteration
+func (p *Progress) Done() {
+	atomic.AddUint64(&p.completed, 1)
+}
+
+// Completed returns the amount of completed iterations
+func (p *Progress) Completed() uint64 {
+	return atomic.LoadUint64(&p.completed)
+}
+
+// Snapshot returns the current progress with the rate of the recent iterations and the time left at that rate
+func (p *Progress) Snapshot() ProgressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	latest := p.sample()
+	oldest := p.samples[0]
+
+	snapshot := ProgressSnapshot{Completed: latest.completed}
+	if elapsed := latest.at.Sub(oldest.at).Seconds(); elapsed > 0 {
+		snapshot.Rate = float64(latest.completed-oldest.completed) / elapsed
+	}
+
+	if p.unbounded || p.total == 0 {
+		return snapshot
+	}
+
+	snapshot.Total = p.total
+
+	var eta float64
+
+	switch {
+	case snapshot.Completed >= snapshot.Total:
+	case snapshot.Rate > 0:
+		eta = float64(snapshot.Total-snapshot.Completed) / snapshot.Rate
+	default:
+		return snapshot // unknown until there is some throughput
+	}
+
+	snapshot.ETA = &eta
+
+	return snapshot
+}
+
+// sample records the current amount of completed iterations at most once per progressSampleInterval and drops
+// the samples that fell out of the rate window, the last one before the window is kept as the baseline
+func (p *Progress) sample() progressSample {
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+
+	current := progressSample{at: now(), completed: atomic.LoadUint64(&p.completed)}
+
+	if n := len(p.samples); n == 0 || current.at.Sub(p.samples[n-1].at) >= progressSampleInterval {
+		p.samples = append(p.samples, current)
+	}
+
+	for len(p.samples) > 1 && !p.samples[1].at.After(current.at.Add(-progressRateWindow)) {
+		p.samples = p.samples[1:]
+	}
+
+	return current
+}
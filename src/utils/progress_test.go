@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	clock := time.Now()
+
+	p := &Progress{now: func() time.Time { return clock }}
+	p.AddTotal(10)
+	p.AddTotal(10)
+
+	if s := p.Snapshot(); s.Completed != 0 || s.Total != 20 || s.Rate != 0 || s.ETA != nil {
+		t.Errorf("expected no progress, rate and eta before the first iteration, got %+v", s)
+	}
+
+	// 2 iterations per second
+	for i := 0; i < 5; i++ {
+		clock = clock.Add(time.Second)
+		p.Done()
+		p.Done()
+		p.Snapshot()
+	}
+
+	s := p.Snapshot()
+	if s.Completed != 10 || s.Rate != 2 || s.ETA == nil || *s.ETA != 5 {
+		t.Errorf("expected 10 of 20 iterations at 2/s with 5s left, got %+v", s)
+	}
+
+	// the rate only accounts recent iterations: 5 of them after idling for most of the window
+	clock = clock.Add(progressRateWindow)
+
+	for i := 0; i < 5; i++ {
+		clock = clock.Add(time.Second)
+		p.Done()
+		p.Snapshot()
+	}
+
+	if s := p.Snapshot(); s.Rate <= 0 || s.Rate > 5.0/float64(progressRateWindow/time.Second) || s.ETA == nil {
+		t.Errorf("expected the rate to drop with the recent throughput, got %+v", s)
+	}
+
+	for i := 0; i < 5; i++ {
+		p.Done()
+	}
+
+	if s := p.Snapshot(); s.Completed != 20 || s.ETA == nil || *s.ETA != 0 {
+		t.Errorf("expected no time left once all the iterations are done, got %+v", s)
+	}
+}
+
+func TestProgressUnbounded(t *testing.T) {
+	t.Parallel()
+
+	clock := time.Now()
+
+	p := &Progress{now: func() time.Time { return clock }}
+	p.AddTotal(10)
+	p.AddTotal(0)
+
+	clock = clock.Add(2 * time.Second)
+	p.Done()
+	p.Done()
+
+	// jobs that aren't limited report the rate only
+	if s := p.Snapshot(); s.Completed != 2 || s.Total != 0 || s.Rate != 1 || s.ETA != nil {
+		t.Errorf("expected 2 iterations at 1/s without total and eta, got %+v", s)
+	}
+
+	var zero Progress
+
+	zero.Done()
+
+	if s := zero.Snapshot(); s.Completed != 1 || s.Total != 0 || s.ETA != nil {
+		t.Errorf("expected the zero value to track iterations, got %+v", s)
+	}
+}
@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"math/rand"
+	"fmt"
 	"net"
 	"net/url"
 	"strings"
@@ -12,23 +12,115 @@ import (
 
 type ProxyFunc func(network, addr string) (net.Conn, error)
 
+// ProxyError is returned by proxy dial functions when the connection couldn't be established through the proxy,
+// it tells failing proxies apart from failing targets
+type ProxyError struct {
+	Proxy string // proxy address without credentials
+	Err   error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("proxy %v: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+func wrapProxyErrors(proxyAddr string, dial ProxyFunc) ProxyFunc {
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := dial(network, addr)
+		if err != nil {
+			return nil, &ProxyError{Proxy: proxyAddr, Err: err}
+		}
+
+		return conn, nil
+	}
+}
+
+const defaultSOCKSPort = "1080"
+
+// GetProxyFunc returns a dial function that goes through a random proxy from the comma-separated list.
+// Both http(s) and socks5 proxies are supported and can be mixed in the same list
 func GetProxyFunc(proxyURLs string, timeout time.Duration) ProxyFunc {
-	direct := &net.Dialer{Timeout: timeout}
+	return GetProxyFuncFrom(proxyURLs, timeout, nil)
+}
+
+// GetProxyFuncFrom is like GetProxyFunc but binds outgoing connections (to the target or to the proxy) to the local address
+// when it's not nil, see ResolveLocalAddr
+func GetProxyFuncFrom(proxyURLs string, timeout time.Duration, localAddr net.Addr) ProxyFunc {
+	direct := &net.Dialer{Timeout: timeout, LocalAddr: localAddr}
 	if proxyURLs == "" {
 		return proxy.FromEnvironmentUsing(direct).Dial
 	}
 
-	proxies := strings.Split(proxyURLs, ",")
+	proxyURL := PickProxy(proxyURLs)
+	if proxyURL == "" {
+		return proxy.FromEnvironmentUsing(direct).Dial
+	}
 
-	u, err := url.Parse(proxies[rand.Intn(len(proxies))]) //nolint:gosec // Cryptographically secure random not required
+	u, err := url.Parse(proxyURL)
 	if err != nil {
 		return proxy.FromEnvironmentUsing(direct).Dial
 	}
 
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		return wrapProxyErrors(u.Host, socks5ProxyFunc(u, direct))
+	case "http", "https":
+		return wrapProxyErrors(u.Host, httpProxyFunc(u, direct, timeout))
+	}
+
 	client, err := proxy.FromURL(u, direct)
 	if err != nil {
 		return proxy.FromEnvironmentUsing(direct).Dial
 	}
 
-	return client.Dial
+	return wrapProxyErrors(u.Host, client.Dial)
+}
+
+// PickProxy returns a random proxy from the comma-separated list, empty if there is none or the list is invalid
+func PickProxy(proxyURLs string) string {
+	if proxyURLs == "" {
+		return ""
+	}
+
+	selector, err := NewProxySelector(proxyURLs, ProxySelectionRandom)
+	if err != nil {
+		return ""
+	}
+
+	return selector.Next()
+}
+
+// ProxyName returns the address of the proxy without the scheme and credentials as reported by ProxyError,
+// empty if the url can't be parsed
+func ProxyName(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+func socks5ProxyFunc(u *url.URL, forward proxy.Dialer) ProxyFunc {
+	var auth *proxy.Auth
+
+	if u.User != nil {
+		auth = &proxy.Auth{User: u.User.Username()}
+		auth.Password, _ = u.User.Password()
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultSOCKSPort)
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", addr, auth, forward)
+	if err != nil {
+		return func(network, addr string) (net.Conn, error) { return nil, err }
+	}
+
+	return dialer.Dial
 }
@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startSOCKS5Server starts a minimal socks5 server that requires username/password authentication
+func startSOCKS5Server(t *testing.T, user, password string) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSOCKS5(conn, user, password)
+		}
+	}()
+
+	return listener
+}
+
+func readString(r io.Reader) (string, error) {
+	size := make([]byte, 1)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, size[0])
+	_, err := io.ReadFull(r, buf)
+
+	return string(buf), err
+}
+
+func serveSOCKS5(conn net.Conn, user, password string) {
+	defer conn.Close()
+
+	// greeting: version, number of methods, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return
+	}
+
+	// select username/password auth
+	if _, err := conn.Write([]byte{5, 2}); err != nil {
+		return
+	}
+
+	// auth: version, username, password
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		return
+	}
+
+	gotUser, _ := readString(conn)
+	gotPassword, _ := readString(conn)
+
+	if gotUser != user || gotPassword != password {
+		_, _ = conn.Write([]byte{1, 1})
+
+		return
+	}
+
+	if _, err := conn.Write([]byte{1, 0}); err != nil {
+		return
+	}
+
+	// request: version, command, reserved, address type (only ipv4 is supported here), address, port
+	request := make([]byte, 10)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(net.IP(request[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(request[8:])))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+
+		return
+	}
+
+	defer target.Close()
+
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	go func() { _, _ = io.Copy(target, conn) }()
+	_, _ = io.Copy(conn, target)
+}
+
+func startEchoServer(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	t.Parallel()
+
+	echo := startEchoServer(t)
+	socks := startSOCKS5Server(t, "user", "pass")
+
+	testCases := []struct {
+		name      string
+		proxyURLs string
+		wantErr   bool
+	}{
+		{name: "valid credentials", proxyURLs: "socks5://user:pass@" + socks.Addr().String()},
+		{name: "surrounding whitespace", proxyURLs: " socks5://user:pass@" + socks.Addr().String() + " "},
+		{name: "invalid credentials", proxyURLs: "socks5://user:wrong@" + socks.Addr().String(), wantErr: true},
+		{name: "missing credentials", proxyURLs: "socks5://" + socks.Addr().String(), wantErr: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := GetProxyFunc(tc.proxyURLs, time.Second)("tcp", echo.Addr().String())
+			if tc.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer conn.Close()
+
+			payload := []byte("ping")
+			if _, err := conn.Write(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			response := make([]byte, len(payload))
+			if _, err := io.ReadFull(conn, response); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(response, payload) {
+				t.Errorf("expected %q to be echoed, got %q", payload, response)
+			}
+		})
+	}
+}
+
+func TestProxyError(t *testing.T) {
+	t.Parallel()
+
+	// nothing listens on the address once the listener is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := listener.Addr().String()
+	listener.Close()
+
+	echo := startEchoServer(t)
+
+	_, err = GetProxyFunc("socks5://user:pass@"+closed, time.Second)("tcp", echo.Addr().String())
+
+	var proxyErr *ProxyError
+	if !errors.As(err, &proxyErr) || proxyErr.Proxy != closed {
+		t.Errorf("expected proxy error for %v, got %v", closed, err)
+	}
+
+	_, err = GetProxyFunc("", time.Second)("tcp", closed)
+	if err == nil || errors.As(err, &proxyErr) {
+		t.Errorf("expected a regular error without proxy, got %v", err)
+	}
+}
+
+// startConnectProxy starts a minimal http proxy that only supports CONNECT, authorization is required if it's not empty
+func startConnectProxy(t *testing.T, authorization string) (listener net.Listener, connects *int32) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	connects = new(int32)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveConnect(conn, authorization, connects)
+		}
+	}()
+
+	return listener, connects
+}
+
+func serveConnect(conn net.Conn, authorization string, connects *int32) {
+	defer conn.Close()
+
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return
+	}
+
+	switch {
+	case req.Method != http.MethodConnect:
+		_, _ = io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+
+		return
+	case authorization != "" && req.Header.Get("Proxy-Authorization") != authorization:
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+
+		return
+	}
+
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+
+		return
+	}
+
+	defer target.Close()
+
+	atomic.AddInt32(connects, 1)
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	go func() { _, _ = io.Copy(target, conn) }()
+	_, _ = io.Copy(conn, target)
+}
+
+func TestHTTPConnectProxy(t *testing.T) {
+	t.Parallel()
+
+	echo := startEchoServer(t)
+	open, openConnects := startConnectProxy(t, "")
+	protected, _ := startConnectProxy(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")))
+
+	testCases := []struct {
+		name      string
+		proxyURLs string
+		wantErr   error
+	}{
+		{name: "without credentials", proxyURLs: "http://" + open.Addr().String()},
+		{name: "valid credentials", proxyURLs: "http://user:pass@" + protected.Addr().String()},
+		{name: "invalid credentials", proxyURLs: "http://user:wrong@" + protected.Addr().String(), wantErr: ErrProxyAuthRequired},
+		{name: "missing credentials", proxyURLs: "http://" + protected.Addr().String(), wantErr: ErrProxyAuthRequired},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := GetProxyFunc(tc.proxyURLs, time.Second)("tcp", echo.Addr().String())
+			if tc.wantErr != nil {
+				var proxyErr *ProxyError
+				if !errors.Is(err, tc.wantErr) || !errors.As(err, &proxyErr) {
+					t.Fatalf("expected a proxy error wrapping %v, got %v", tc.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer conn.Close()
+
+			payload := []byte("ping")
+			if _, err := conn.Write(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			response := make([]byte, len(payload))
+			if _, err := io.ReadFull(conn, response); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(response, payload) {
+				t.Errorf("expected %q to be echoed, got %q", payload, response)
+			}
+		})
+	}
+
+	t.Cleanup(func() {
+		if atomic.LoadInt32(openConnects) == 0 {
+			t.Error("expected the connection to be tunneled through the proxy")
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Supported proxy selection strategies
+const (
+	ProxySelectionRoundRobin = "round_robin"
+	ProxySelectionRandom     = "random"
+	ProxySelectionWeighted   = "weighted"
+)
+
+const proxyWeightSuffix = ";weight="
+
+// ProxySelector picks a proxy from the pool on each call
+type ProxySelector interface {
+	Next() string
+}
+
+// NewProxySelector creates a selector for a comma-separated list of proxies using the requested strategy.
+// Each proxy can have an optional weight suffix (i.e. http://p1;weight=5) that is only taken into account by the weighted strategy
+func NewProxySelector(proxyURLs, strategy string) (ProxySelector, error) {
+	proxies, weights, err := ParseProxyList(proxyURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(proxies) == 0 {
+		return nil, errors.New("proxy list is empty")
+	}
+
+	switch strategy {
+	case ProxySelectionRoundRobin:
+		return &roundRobinSelector{proxies: proxies}, nil
+	case ProxySelectionRandom:
+		return randomSelector(proxies), nil
+	case ProxySelectionWeighted:
+		return newWeightedSelector(proxies, weights), nil
+	default:
+		return nil, fmt.Errorf("unknown proxy selection strategy %q, expected one of [%q, %q, %q]", strategy,
+			ProxySelectionRoundRobin, ProxySelectionRandom, ProxySelectionWeighted)
+	}
+}
+
+// ParseProxyList splits the comma-separated list of proxies and strips their weights
+func ParseProxyList(proxyURLs string) (proxies []string, weights []int, err error) {
+	for _, entry := range strings.Split(proxyURLs, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		weight := 1
+
+		if i := strings.Index(entry, proxyWeightSuffix); i >= 0 {
+			if weight, err = strconv.Atoi(entry[i+len(proxyWeightSuffix):]); err != nil || weight <= 0 {
+				return nil, nil, fmt.Errorf("invalid proxy weight in %q, expected a positive number", entry)
+			}
+
+			entry = entry[:i]
+		}
+
+		proxies = append(proxies, entry)
+		weights = append(weights, weight)
+	}
+
+	return proxies, weights, nil
+}
+
+type roundRobinSelector struct {
+	proxies []string
+	next    uint64
+}
+
+func (s *roundRobinSelector) Next() string {
+	return s.proxies[(atomic.AddUint64(&s.next, 1)-1)%uint64(len(s.proxies))]
+}
+
+type randomSelector []string
+
+func (s randomSelector) Next() string {
+	return s[rand.Intn(len(s))] //nolint:gosec // Cryptographically secure random not required
+}
+
+type weightedSelector struct {
+	proxies []string
+	bounds  []int // cumulative weights
+}
+
+func newWeightedSelector(proxies []string, weights []int) *weightedSelector {
+	bounds := make([]int, len(weights))
+
+	var total int
+
+	for i, weight := range weights {
+		total += weight
+		bounds[i] = total
+	}
+
+	return &weightedSelector{proxies: proxies, bounds: bounds}
+}
+
+func (s *weightedSelector) Next() string {
+	r := rand.Intn(s.bounds[len(s.bounds)-1]) //nolint:gosec // Cryptographically secure random not required
+
+	return s.proxies[sort.SearchInts(s.bounds, r+1)]
+}
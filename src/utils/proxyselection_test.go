@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedProxySelection(t *testing.T) {
+	t.Parallel()
+
+	selector, err := NewProxySelector("http://p1;weight=1, http://p2;weight=3,socks5://p3;weight=6", ProxySelectionWeighted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const picks = 100000
+
+	counts := make(map[string]int)
+	for i := 0; i < picks; i++ {
+		counts[selector.Next()]++
+	}
+
+	expected := map[string]float64{"http://p1": 0.1, "http://p2": 0.3, "socks5://p3": 0.6}
+
+	if len(counts) != len(expected) {
+		t.Fatalf("unexpected proxies picked: %v", counts)
+	}
+
+	for proxy, share := range expected {
+		// binomial standard deviation for 100k picks is below 0.002 for all shares, 0.01 leaves plenty of room
+		const tolerance = 0.01
+
+		if got := float64(counts[proxy]) / picks; math.Abs(got-share) > tolerance {
+			t.Errorf("expected %v to be picked %.2f of the time, got %.4f", proxy, share, got)
+		}
+	}
+}
+
+func TestRoundRobinProxySelection(t *testing.T) {
+	t.Parallel()
+
+	selector, err := NewProxySelector("http://p1;weight=5,http://p2,,http://p3", ProxySelectionRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{"http://p1", "http://p2", "http://p3", "http://p1", "http://p2"} {
+		if got := selector.Next(); got != want {
+			t.Errorf("pick %d: expected %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestRandomProxySelection(t *testing.T) {
+	t.Parallel()
+
+	selector, err := NewProxySelector("http://p1,http://p2", ProxySelectionRandom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		seen[selector.Next()] = true
+	}
+
+	if !seen["http://p1"] || !seen["http://p2"] || len(seen) != 2 {
+		t.Errorf("expected both proxies to be picked, got %v", seen)
+	}
+}
+
+func TestProxySelectionErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct{ proxies, strategy string }{
+		{proxies: "http://p1", strategy: "fastest"},
+		{proxies: "", strategy: ProxySelectionRandom},
+		{proxies: "http://p1;weight=0", strategy: ProxySelectionWeighted},
+		{proxies: "http://p1;weight=heavy", strategy: ProxySelectionWeighted},
+	} {
+		if _, err := NewProxySelector(tc.proxies, tc.strategy); err == nil {
+			t.Errorf("expected error for proxies %q and strategy %q", tc.proxies, tc.strategy)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter spreads events evenly to keep their rate under the limit. It's safe to share between goroutines
+// and all methods are safe to call on a nil limiter which doesn't limit anything
+type RateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	interval  time.Duration // between two subsequent events
+	next      time.Time     // when the next event is allowed
+	ramp      *RampUpConfig
+	start     time.Time // of the ramp up, set on the first event
+
+	now func() time.Time
+}
+
+// RampUpConfig describes how the rate grows from zero to the limit so that targets aren't hit with the full rate at once
+type RampUpConfig struct {
+	Duration time.Duration `mapstructure:"duration"` // how long it takes to reach the full rate
+	Steps    int           `mapstructure:"steps"`    // the rate grows in as many equal steps, linearly if not positive
+}
+
+// NewRateLimiter returns a limiter allowing perSecond events per second (can be fractional) or nil if it's not positive
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{perSecond: perSecond, interval: time.Duration(float64(time.Second) / perSecond), now: time.Now}
+}
+
+// NewRampedRateLimiter is like NewRateLimiter but the rate grows from zero to perSecond during the ramp up
+// which starts with the first event
+func NewRampedRateLimiter(perSecond float64, ramp *RampUpConfig) *RateLimiter {
+	l := NewRateLimiter(perSecond)
+	if l != nil && ramp != nil && ramp.Duration > 0 {
+		l.ramp = ramp
+	}
+
+	return l
+}
+
+// Reserve books a slot for the next event and returns how long to wait before it's allowed
+func (l *RateLimiter) Reserve() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.start.IsZero() {
+		l.start = now
+	}
+
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	wait := l.next.Sub(now)
+	l.next = l.nextEvent(l.next)
+
+	return wait
+}
+
+// nextEvent returns the time of the event following the one at t so that exactly one event fits between them at the current rate
+func (l *RateLimiter) nextEvent(t time.Time) time.Time {
+	elapsed := t.Sub(l.start)
+	if l.ramp == nil || elapsed >= l.ramp.Duration {
+		return t.Add(l.interval)
+	}
+
+	at := func(seconds float64) time.Time { return l.start.Add(time.Duration(seconds * float64(time.Second))) }
+
+	var (
+		ramp      = l.ramp.Duration.Seconds()
+		x         = elapsed.Seconds()
+		remaining = 1.0 // part of the event that still has to fit
+	)
+
+	if steps := float64(l.ramp.Steps); steps > 0 {
+		stepLength := ramp / steps
+
+		for step := math.Floor(x / stepLength); step < steps; step++ {
+			rate, stepEnd := l.perSecond*(step+1)/steps, (step+1)*stepLength
+
+			capacity := rate * (stepEnd - x)
+			if capacity >= remaining {
+				return at(x + remaining/rate)
+			}
+
+			remaining -= capacity
+			x = stepEnd
+		}
+	} else {
+		// the rate grows linearly so the amount of events between x and y is slope*(y²-x²)/2
+		slope := l.perSecond / ramp
+		if y := math.Sqrt(x*x + 2*remaining/slope); y < ramp {
+			return at(y)
+		}
+
+		remaining -= slope * (ramp*ramp - x*x) / 2
+		x = ramp
+	}
+
+	return at(x + remaining/l.perSecond)
+}
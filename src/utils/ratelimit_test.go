@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(4)
+	clock := time.Now()
+	limiter.now = func() time.Time { return clock }
+
+	for i, want := range []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond} {
+		if got := limiter.Reserve(); got != want {
+			t.Errorf("reservation %d: expected wait %v, got %v", i, want, got)
+		}
+	}
+
+	// unused time is not accumulated so there are no bursts after idling
+	clock = clock.Add(time.Minute)
+
+	for i, want := range []time.Duration{0, 250 * time.Millisecond} {
+		if got := limiter.Reserve(); got != want {
+			t.Errorf("reservation %d after idle: expected wait %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestRateLimiterFractional(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(0.5)
+	limiter.now = func() time.Time { return time.Time{} }
+
+	limiter.Reserve()
+
+	if got := limiter.Reserve(); got != 2*time.Second {
+		t.Errorf("expected wait of 2s, got %v", got)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(0)
+	if limiter != nil {
+		t.Fatal("expected nil limiter for non-positive rate")
+	}
+
+	if got := limiter.Reserve(); got != 0 {
+		t.Errorf("nil limiter should not wait, got %v", got)
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const (
+		workers    = 10
+		iterations = 10
+	)
+
+	limiter := NewRateLimiter(100)
+	clock := time.Now()
+	limiter.now = func() time.Time { return clock }
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		waits []time.Duration
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				wait := limiter.Reserve()
+
+				mu.Lock()
+				waits = append(waits, wait)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+
+	// every worker got its own slot
+	for i, wait := range waits {
+		if want := time.Duration(i) * 10 * time.Millisecond; wait != want {
+			t.Fatalf("slot %d: expected wait %v, got %v", i, want, wait)
+		}
+	}
+}
+
+func TestRateLimiterRampUp(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		ramp RampUpConfig
+		// expected amount of events by the end of every second (including the one sent right away)
+		want map[int]int
+	}{
+		{
+			// n(t) = t²/2 during the ramp, then 10 per second
+			name: "linear",
+			ramp: RampUpConfig{Duration: 10 * time.Second},
+			want: map[int]int{2: 3, 4: 9, 6: 19, 8: 33, 10: 51, 12: 71},
+		},
+		{
+			// 5 per second during the first step, then 10 per second
+			name: "steps",
+			ramp: RampUpConfig{Duration: 10 * time.Second, Steps: 2},
+			want: map[int]int{2: 11, 5: 26, 8: 56, 10: 76, 12: 96},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			limiter := NewRampedRateLimiter(10, &tc.ramp)
+			clock := time.Time{}.Add(time.Hour)
+			limiter.now = func() time.Time { return clock }
+
+			// send events as soon as they are allowed and count them by the second
+			counts := make(map[int]int)
+
+			for clock.Before(time.Time{}.Add(time.Hour + 12*time.Second)) {
+				clock = clock.Add(limiter.Reserve())
+
+				for second := range tc.want {
+					if clock.Sub(time.Time{}.Add(time.Hour)) <= time.Duration(second)*time.Second {
+						counts[second]++
+					}
+				}
+			}
+
+			for second, want := range tc.want {
+				if got := counts[second]; got < want-1 || got > want+1 {
+					t.Errorf("expected about %d events after %ds, got %d", want, second, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterRampUpRestart(t *testing.T) {
+	t.Parallel()
+
+	clock := time.Time{}.Add(time.Hour)
+
+	for i := 0; i < 2; i++ {
+		// every job run creates its own limiter so the ramp starts over
+		limiter := NewRampedRateLimiter(10, &RampUpConfig{Duration: 10 * time.Second})
+		limiter.now = func() time.Time { return clock }
+
+		if got := limiter.Reserve(); got != 0 {
+			t.Errorf("expected the first event to be sent right away, got %v", got)
+		}
+
+		if got := limiter.Reserve(); got < time.Second {
+			t.Errorf("expected a long wait at the start of the ramp, got %v", got)
+		}
+
+		clock = clock.Add(time.Minute)
+	}
+
+	if limiter := NewRampedRateLimiter(0, &RampUpConfig{Duration: time.Second}); limiter != nil {
+		t.Error("expected ramp up not to apply without the rate limit")
+	}
+}
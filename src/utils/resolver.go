@@ -0,0 +1,387 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ResolverConfig overrides the system resolver for the names of the targets
+type ResolverConfig struct {
+	Servers []string          `mapstructure:"servers"` // dns servers to query over udp, ip or ip:port (port 53 by default)
+	DoHURL  string            `mapstructure:"doh_url"` // DNS-over-HTTPS endpoint (RFC 8484), used when no servers are set
+	Hosts   map[string]string `mapstructure:"hosts"`   // static host to ip overrides, i.e. to reach a specific origin behind a CDN
+	Timeout *time.Duration    `mapstructure:"timeout"`
+
+	NegativeTTL *time.Duration `mapstructure:"negative_ttl"` // how long failed lookups are cached, defaultNegativeTTL if nil
+}
+
+const (
+	defaultDNSPort         = "53"
+	defaultResolverTimeout = 5 * time.Second
+	dohContentType         = "application/dns-message"
+	defaultNegativeTTL     = 5 * time.Second
+	maxResolverCacheSize   = 4096 // hosts, both resolved and failed, so that random subdomains don't grow the cache forever
+)
+
+// errNoRecords is returned when neither A nor AAAA records were found
+var errNoRecords = errors.New("no A or AAAA records found")
+
+// ResolveError is returned by the resolver dial functions when the host couldn't be resolved,
+// it tells the requests that haven't reached the target apart from the failing ones
+type ResolveError struct {
+	Host string
+	Err  error
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("error resolving %v: %v", e.Host, e.Err)
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+type resolverCacheEntry struct {
+	ips     []net.IP
+	err     error // of the failed lookup, cached for the negative ttl
+	expires time.Time
+}
+
+// Resolver resolves names with the configured dns servers or DoH endpoint and caches the results for their TTL
+type Resolver struct {
+	hosts    map[string]net.IP
+	exchange func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+	timeout  time.Duration
+	now      func() time.Time
+
+	negativeTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]resolverCacheEntry
+}
+
+// NewResolver creates a resolver from the config, returns nil if the config doesn't override anything
+func NewResolver(c *ResolverConfig) (*Resolver, error) {
+	if c == nil || (len(c.Servers) == 0 && c.DoHURL == "" && len(c.Hosts) == 0) {
+		return nil, nil
+	}
+
+	r := &Resolver{
+		hosts:   make(map[string]net.IP, len(c.Hosts)),
+		timeout: NonNilDurationOrDefault(c.Timeout, defaultResolverTimeout),
+		now:     time.Now,
+		cache:   make(map[string]resolverCacheEntry),
+
+		negativeTTL: NonNilDurationOrDefault(c.NegativeTTL, defaultNegativeTTL),
+	}
+
+	for host, addr := range c.Hosts {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %q for host %q in resolver hosts", addr, host)
+		}
+
+		r.hosts[strings.ToLower(host)] = ip
+	}
+
+	switch {
+	case len(c.Servers) > 0:
+		servers := make([]string, 0, len(c.Servers))
+
+		for _, server := range c.Servers {
+			addr := server
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				addr = net.JoinHostPort(server, defaultDNSPort)
+			}
+
+			if host, port, _ := net.SplitHostPort(addr); net.ParseIP(host) == nil || !isPort(port) {
+				return nil, fmt.Errorf("invalid dns server %q: expected ip or ip:port", server)
+			}
+
+			servers = append(servers, addr)
+		}
+
+		r.exchange = serversExchange(servers)
+	case c.DoHURL != "":
+		if !strings.HasPrefix(c.DoHURL, "https://") && !strings.HasPrefix(c.DoHURL, "http://") {
+			return nil, fmt.Errorf("invalid doh url %q", c.DoHURL)
+		}
+
+		r.exchange = dohExchange(c.DoHURL, &http.Client{Timeout: r.timeout})
+	}
+
+	return r, nil
+}
+
+func isPort(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 16)
+
+	return err == nil
+}
+
+// serversExchange queries the servers one by one until one of them answers
+func serversExchange(servers []string) func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{}
+
+	return func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		var err error
+
+		for _, server := range servers {
+			var resp *dns.Msg
+
+			if resp, _, err = client.ExchangeContext(ctx, msg, server); err == nil {
+				return resp, nil
+			}
+		}
+
+		return nil, err
+	}
+}
+
+func dohExchange(url string, client *http.Client) func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	return func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		packed, err := msg.Pack()
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(packed))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", dohContentType)
+		req.Header.Set("Accept", dohContentType)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("doh server responded with %v", resp.Status)
+		}
+
+		const maxDNSMessageSize = 65535
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxDNSMessageSize))
+		if err != nil {
+			return nil, err
+		}
+
+		result := &dns.Msg{}
+
+		return result, result.Unpack(body)
+	}
+}
+
+// LookupIP returns the addresses of the host, ip literals are returned as is. Failures are returned as *ResolveError
+// and the ones of the configured servers are cached for the negative ttl so that unresolvable hosts aren't queried
+// over and over again
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return r.lookupIP(ctx, host, "ip")
+}
+
+// lookupQueries are the record types queried for the network in order until there are some addresses
+var lookupQueries = map[string][]uint16{
+	"ip":  {dns.TypeA, dns.TypeAAAA},
+	"ip4": {dns.TypeA},
+	"ip6": {dns.TypeAAAA},
+}
+
+// lookupIP is LookupIP limited to the addresses of the network: "ip4" or "ip6", "ip" for any of them
+func (r *Resolver) lookupIP(ctx context.Context, host, network string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return filterIPs(host, []net.IP{ip}, network)
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip, ok := r.hosts[host]; ok {
+		return filterIPs(host, []net.IP{ip}, network)
+	}
+
+	if r.exchange == nil {
+		ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+		if err != nil {
+			return nil, &ResolveError{Host: host, Err: err}
+		}
+
+		return ips, nil
+	}
+
+	key := host
+	if network != "ip" {
+		key = network + ":" + host
+	}
+
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+
+	if ok && r.now().Before(entry.expires) {
+		if entry.err != nil {
+			return nil, &ResolveError{Host: host, Err: entry.err}
+		}
+
+		return entry.ips, nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var (
+		ips []net.IP
+		ttl time.Duration
+		err error
+	)
+
+	for _, qtype := range lookupQueries[network] {
+		if ips, ttl, err = r.query(queryCtx, host, qtype); err != nil || len(ips) > 0 {
+			break
+		}
+	}
+
+	if err == nil && len(ips) == 0 {
+		err = errNoRecords
+	}
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// the caller has given up, it doesn't tell anything about the host
+		return nil, &ResolveError{Host: host, Err: err}
+	case err != nil:
+		r.store(key, resolverCacheEntry{err: err, expires: r.now().Add(r.negativeTTL)})
+
+		return nil, &ResolveError{Host: host, Err: err}
+	case ttl > 0:
+		r.store(key, resolverCacheEntry{ips: ips, expires: r.now().Add(ttl)})
+	}
+
+	return ips, nil
+}
+
+// filterIPs returns the addresses of the network, the host has no records of the network if there are none
+func filterIPs(host string, ips []net.IP, network string) ([]net.IP, error) {
+	result := make([]net.IP, 0, len(ips))
+
+	for _, ip := range ips {
+		if network == "ip" || (network == "ip4") == (ip.To4() != nil) {
+			result = append(result, ip)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, &ResolveError{Host: host, Err: errNoRecords}
+	}
+
+	return result, nil
+}
+
+// store caches the entry of the host (qualified by the network unless it's "ip"), expired entries are dropped once the cache
+// is full and then arbitrary ones if it's still full
+func (r *Resolver) store(host string, entry resolverCacheEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.cache[host]; !ok && len(r.cache) >= maxResolverCacheSize {
+		now := r.now()
+		for cached, e := range r.cache {
+			if !now.Before(e.expires) {
+				delete(r.cache, cached)
+			}
+		}
+
+		for cached := range r.cache {
+			if len(r.cache) < maxResolverCacheSize {
+				break
+			}
+
+			delete(r.cache, cached)
+		}
+	}
+
+	r.cache[host] = entry
+}
+
+// query returns the addresses of the requested type along with the lowest TTL among them
+func (r *Resolver) query(ctx context.Context, host string, qtype uint16) (ips []net.IP, ttl time.Duration, err error) {
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(host), qtype)
+
+	resp, err := r.exchange(ctx, msg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, 0, fmt.Errorf("dns server responded with %v", dns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		var ip net.IP
+
+		switch record := rr.(type) {
+		case *dns.A:
+			ip = record.A
+		case *dns.AAAA:
+			ip = record.AAAA
+		default:
+			continue
+		}
+
+		if recordTTL := time.Duration(rr.Header().Ttl) * time.Second; len(ips) == 0 || recordTTL < ttl {
+			ttl = recordTTL
+		}
+
+		ips = append(ips, ip)
+	}
+
+	return ips, ttl, nil
+}
+
+// Dial returns a dial function that resolves the host of the address with the resolver and dials the resolved ips
+// of the network ("tcp4" or "tcp6", both for "tcp") one after another without waiting for the slow ones, see dialHappyEyeballs.
+// The original host is kept for tls server names as they are taken from the address requested by the client rather than
+// from the one actually dialed
+func (r *Resolver) Dial(dial ProxyFunc) ProxyFunc {
+	if r == nil {
+		return dial
+	}
+
+	return func(network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ipNetwork := "ip"
+		if strings.HasSuffix(network, "4") || strings.HasSuffix(network, "6") {
+			ipNetwork += network[len(network)-1:]
+		}
+
+		ips, err := r.lookupIP(context.Background(), host, ipNetwork)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs := make([]string, 0, len(ips))
+		for _, ip := range happyEyeballsOrder(ips) {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+		}
+
+		// the version is already told by the resolved ips, dials through proxies only accept the generic network
+		return dialHappyEyeballs(dial, strings.TrimRight(network, "46"), addrs, happyEyeballsDelay)
+	}
+}
@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const stubRecordTTL = 60
+
+// stubDNSHandler answers target.test with 127.0.0.1, v6.test with ::1 only and NXDOMAIN to anything else
+type stubDNSHandler struct {
+	queries int32
+}
+
+func (h *stubDNSHandler) answer(req *dns.Msg) *dns.Msg {
+	atomic.AddInt32(&h.queries, 1)
+
+	resp := &dns.Msg{}
+	resp.SetReply(req)
+
+	question := req.Question[0]
+	header := dns.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: dns.ClassINET, Ttl: stubRecordTTL}
+
+	switch {
+	case question.Name == "target.test." && question.Qtype == dns.TypeA:
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: header, A: net.ParseIP("127.0.0.1")})
+	case question.Name == "v6.test." && question.Qtype == dns.TypeAAAA:
+		resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: header, AAAA: net.ParseIP("::1")})
+	case question.Name != "target.test." && question.Name != "v6.test.":
+		resp.Rcode = dns.RcodeNameError
+	}
+
+	return resp
+}
+
+func (h *stubDNSHandler) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	_ = w.WriteMsg(h.answer(req))
+}
+
+func startStubDNSServer(t *testing.T, handler dns.Handler) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: conn, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+
+	go func() { _ = server.ActivateAndServe() }()
+
+	t.Cleanup(func() { _ = server.Shutdown() })
+	<-started
+
+	return conn.LocalAddr().String()
+}
+
+func TestResolverServers(t *testing.T) {
+	t.Parallel()
+
+	handler := &stubDNSHandler{}
+	addr := startStubDNSServer(t, handler)
+
+	// the first server doesn't respond and is skipped
+	timeout := 100 * time.Millisecond
+
+	resolver, err := NewResolver(&ResolverConfig{Servers: []string{"127.0.0.1:1", addr}, Timeout: &timeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ips, err := resolver.LookupIP(context.Background(), "target.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("expected 127.0.0.1, got %v", ips)
+	}
+
+	if _, err := resolver.LookupIP(context.Background(), "TARGET.test."); err != nil {
+		t.Fatal(err)
+	}
+
+	if queries := atomic.LoadInt32(&handler.queries); queries != 1 {
+		t.Errorf("expected the second lookup to be cached, got %d queries", queries)
+	}
+
+	// the cached record expires after its ttl
+	resolver.now = func() time.Time { return time.Now().Add(stubRecordTTL * time.Second) }
+
+	if _, err := resolver.LookupIP(context.Background(), "target.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if queries := atomic.LoadInt32(&handler.queries); queries != 2 {
+		t.Errorf("expected an expired record to be queried again, got %d queries", queries)
+	}
+
+	if ips, err := resolver.LookupIP(context.Background(), "v6.test"); err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("::1")) {
+		t.Errorf("expected ::1 from the AAAA fallback, got %v (%v)", ips, err)
+	}
+
+	if _, err := resolver.LookupIP(context.Background(), "missing.test"); err == nil {
+		t.Error("expected an error for a missing host")
+	}
+}
+
+func TestResolverNegativeCache(t *testing.T) {
+	t.Parallel()
+
+	handler := &stubDNSHandler{}
+	addr := startStubDNSServer(t, handler)
+	negativeTTL := time.Second
+
+	resolver, err := NewResolver(&ResolverConfig{Servers: []string{addr}, NegativeTTL: &negativeTTL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		var resolveErr *ResolveError
+		if _, err := resolver.LookupIP(context.Background(), "missing.test"); !errors.As(err, &resolveErr) || resolveErr.Host != "missing.test" {
+			t.Fatalf("expected a resolve error for a missing host, got %v", err)
+		}
+	}
+
+	if queries := atomic.LoadInt32(&handler.queries); queries != 1 {
+		t.Errorf("expected the failure to be cached, got %d queries", queries)
+	}
+
+	resolver.now = func() time.Time { return time.Now().Add(negativeTTL) }
+
+	if _, err := resolver.LookupIP(context.Background(), "missing.test"); err == nil {
+		t.Error("expected an error for a missing host")
+	}
+
+	if queries := atomic.LoadInt32(&handler.queries); queries != 2 {
+		t.Errorf("expected an expired failure to be queried again, got %d queries", queries)
+	}
+
+	// lookups given up by the caller aren't cached
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := resolver.LookupIP(ctx, "target.test"); err == nil {
+		t.Error("expected an error for a cancelled lookup")
+	}
+
+	if _, err := resolver.LookupIP(context.Background(), "target.test"); err != nil {
+		t.Errorf("expected a cancelled lookup not to be cached, got %v", err)
+	}
+}
+
+func TestResolverCacheBound(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(&ResolverConfig{Servers: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	resolver.store("expired.test", resolverCacheEntry{ips: []net.IP{net.ParseIP("127.0.0.1")}, expires: now.Add(-time.Second)})
+
+	for i := 0; i < 2*maxResolverCacheSize; i++ {
+		resolver.store(fmt.Sprintf("host-%d.test", i), resolverCacheEntry{err: errNoRecords, expires: now.Add(time.Minute)})
+
+		if len(resolver.cache) > maxResolverCacheSize {
+			t.Fatalf("expected at most %d cached hosts, got %d", maxResolverCacheSize, len(resolver.cache))
+		}
+	}
+
+	if _, ok := resolver.cache["expired.test"]; ok {
+		t.Error("expected the expired entry to be evicted")
+	}
+
+	if _, ok := resolver.cache[fmt.Sprintf("host-%d.test", 2*maxResolverCacheSize-1)]; !ok {
+		t.Error("expected the latest entry to be cached")
+	}
+}
+
+func TestResolverDoH(t *testing.T) {
+	t.Parallel()
+
+	handler := &stubDNSHandler{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != dohContentType {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		req := &dns.Msg{}
+		if err := req.Unpack(body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		packed, _ := handler.answer(req).Pack()
+
+		w.Header().Set("Content-Type", dohContentType)
+		_, _ = w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+
+	resolver, err := NewResolver(&ResolverConfig{DoHURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ips, err := resolver.LookupIP(context.Background(), "target.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("expected 127.0.0.1, got %v", ips)
+	}
+}
+
+func TestResolverDial(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(&ResolverConfig{Hosts: map[string]string{"origin.example": "192.0.2.10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dialed string
+
+	dial := resolver.Dial(func(network, addr string) (net.Conn, error) {
+		dialed = addr
+
+		return nil, io.EOF
+	})
+
+	if _, err := dial("tcp", "Origin.example:443"); err != io.EOF || dialed != "192.0.2.10:443" {
+		t.Errorf("expected a dial to 192.0.2.10:443, got %q (%v)", dialed, err)
+	}
+
+	if _, err := dial("tcp", "[::1]:80"); err != io.EOF || dialed != "[::1]:80" {
+		t.Errorf("expected ip literals to be dialed as is, got %q (%v)", dialed, err)
+	}
+
+	if resolver, err := NewResolver(&ResolverConfig{}); resolver != nil || err != nil {
+		t.Errorf("expected no resolver for an empty config, got %v (%v)", resolver, err)
+	}
+
+	var noResolver *Resolver
+	if _, err := noResolver.Dial(dial)("tcp", "origin.example:80"); err != io.EOF || dialed != "192.0.2.10:80" {
+		t.Errorf("expected a nil resolver to keep the dial function, got %q (%v)", dialed, err)
+	}
+}
+
+func TestResolverConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []ResolverConfig{
+		{Servers: []string{"dns.example"}},
+		{Servers: []string{"1.1.1.1:port"}},
+		{DoHURL: "dns.example/dns-query"},
+		{Hosts: map[string]string{"origin.example": "not-an-ip"}},
+	} {
+		c := c
+		if _, err := NewResolver(&c); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
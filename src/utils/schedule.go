@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule limits events to the time windows defined by a cron expression: every minute matched by the expression is active,
+// i.e. "0-9 * * * *" allows events during the first ten minutes of every hour. All methods are safe to call on a nil schedule
+// which is always active
+type Schedule struct {
+	schedule cron.Schedule
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+// ParseSchedule parses a standard 5-field cron expression (descriptors like "@hourly" and "TZ=" prefix are supported as well),
+// returns nil if the expression is empty
+func ParseSchedule(spec string) (*Schedule, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	return &Schedule{schedule: schedule, now: time.Now, after: time.After}, nil
+}
+
+// Active checks whether the minute of t is matched by the schedule
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	minute := t.Truncate(time.Minute)
+
+	return s.schedule.Next(minute.Add(-time.Nanosecond)).Equal(minute)
+}
+
+// Wait blocks until the schedule is active, returns false if the context is done or the stop channel is closed first
+func (s *Schedule) Wait(ctx context.Context, stop <-chan struct{}) bool {
+	if s == nil {
+		return true
+	}
+
+	for now := s.now(); !s.Active(now); now = s.now() {
+		select {
+		case <-s.after(s.schedule.Next(now).Sub(now)):
+		case <-stop:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	return true
+}
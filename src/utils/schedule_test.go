@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	s, err := ParseSchedule("0-4 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fast-forwardable clock, waiting just moves it forward
+	clock := time.Date(2022, 5, 1, 10, 58, 30, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+	s.after = func(d time.Duration) <-chan time.Time {
+		clock = clock.Add(d)
+
+		c := make(chan time.Time, 1)
+		c <- clock
+
+		return c
+	}
+
+	const (
+		iterations = 30
+		step       = 50 * time.Second
+	)
+
+	var fired []time.Time
+
+	for i := 0; i < iterations; i++ {
+		if !s.Wait(context.Background(), nil) {
+			t.Fatal("unexpected end of wait")
+		}
+
+		fired = append(fired, clock)
+		clock = clock.Add(step)
+	}
+
+	if want := time.Date(2022, 5, 1, 11, 0, 0, 0, time.UTC); !fired[0].Equal(want) {
+		t.Errorf("expected the first event at the start of the window %v, got %v", want, fired[0])
+	}
+
+	hours := make(map[int]bool)
+
+	for _, f := range fired {
+		if f.Minute() > 4 {
+			t.Errorf("event at %v is outside of the window", f)
+		}
+
+		hours[f.Hour()] = true
+	}
+
+	if len(hours) < 2 {
+		t.Errorf("expected events to span several windows, got %v", fired)
+	}
+}
+
+func TestScheduleStop(t *testing.T) {
+	t.Parallel()
+
+	s, err := ParseSchedule("@yearly")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.now = func() time.Time { return time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if s.Wait(ctx, nil) {
+		t.Error("expected wait to be cancelled together with the context")
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+
+	if s.Wait(context.Background(), stop) {
+		t.Error("expected wait to be cancelled by the stop channel")
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	t.Parallel()
+
+	if s, err := ParseSchedule(""); s != nil || err != nil {
+		t.Errorf("expected no schedule for empty expression, got %v, %v", s, err)
+	}
+
+	var s *Schedule
+	if !s.Active(time.Now()) || !s.Wait(context.Background(), nil) {
+		t.Error("expected nil schedule to be always active")
+	}
+
+	if _, err := ParseSchedule("not a schedule"); err == nil {
+		t.Error("expected invalid expression to fail")
+	}
+
+	s, err := ParseSchedule("TZ=Europe/Kiev 0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Active(time.Date(2022, 5, 1, 6, 0, 30, 0, time.UTC)) || s.Active(time.Date(2022, 5, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Error("expected the schedule to respect the time zone")
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedSemaphore limits the amount of concurrent holders of the same key. It's safe to share between goroutines
+type KeyedSemaphore struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewKeyedSemaphore returns an empty semaphore
+func NewKeyedSemaphore() *KeyedSemaphore {
+	return &KeyedSemaphore{slots: make(map[string]chan struct{})}
+}
+
+func noopRelease() {}
+
+// Acquire waits until there are less than limit holders of the key and returns a func to release the slot.
+// The limit is fixed on the first use of the key, non-positive limit doesn't limit anything.
+// Returns false without acquiring if the context is done first
+func (s *KeyedSemaphore) Acquire(ctx context.Context, key string, limit int) (release func(), ok bool) {
+	if limit <= 0 {
+		return noopRelease, true
+	}
+
+	s.mu.Lock()
+
+	slots, exists := s.slots[key]
+	if !exists {
+		slots = make(chan struct{}, limit)
+		s.slots[key] = slots
+	}
+
+	s.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	case <-ctx.Done():
+		return noopRelease, false
+	}
+}
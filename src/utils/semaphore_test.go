@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyedSemaphore(t *testing.T) {
+	t.Parallel()
+
+	const (
+		limit   = 3
+		holders = 20
+	)
+
+	semaphore := NewKeyedSemaphore()
+
+	var (
+		wg                 sync.WaitGroup
+		inFlight, observed int32
+	)
+
+	for i := 0; i < holders; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			release, ok := semaphore.Acquire(context.Background(), "host", limit)
+			if !ok {
+				t.Error("failed to acquire the semaphore")
+
+				return
+			}
+
+			defer release()
+
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				if seen := atomic.LoadInt32(&observed); current <= seen || atomic.CompareAndSwapInt32(&observed, seen, current) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+
+	wg.Wait()
+
+	if observed != limit {
+		t.Errorf("expected at most %d concurrent holders, got %d", limit, observed)
+	}
+}
+
+func TestKeyedSemaphoreKeysAreIndependent(t *testing.T) {
+	t.Parallel()
+
+	semaphore := NewKeyedSemaphore()
+
+	if _, ok := semaphore.Acquire(context.Background(), "a", 1); !ok {
+		t.Fatal("failed to acquire the semaphore")
+	}
+
+	release, ok := semaphore.Acquire(context.Background(), "b", 1)
+	if !ok {
+		t.Fatal("expected other keys not to be limited")
+	}
+
+	release()
+}
+
+func TestKeyedSemaphoreCancel(t *testing.T) {
+	t.Parallel()
+
+	semaphore := NewKeyedSemaphore()
+
+	release, ok := semaphore.Acquire(context.Background(), "host", 1)
+	if !ok {
+		t.Fatal("failed to acquire the semaphore")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, ok := semaphore.Acquire(ctx, "host", 1); ok {
+		t.Error("expected acquire to fail once the context is done")
+	}
+
+	release()
+
+	if release, ok := semaphore.Acquire(context.Background(), "host", 1); !ok {
+		t.Error("expected the slot to be free after release")
+	} else {
+		release()
+	}
+}
+
+func TestKeyedSemaphoreUnlimited(t *testing.T) {
+	t.Parallel()
+
+	semaphore := NewKeyedSemaphore()
+
+	for i := 0; i < 100; i++ {
+		if _, ok := semaphore.Acquire(context.Background(), "host", 0); !ok {
+			t.Fatal("expected non-positive limit not to limit anything")
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// Limiter is implemented by both RateLimiter and SharedRateLimiter
+type Limiter interface {
+	Reserve() time.Duration
+}
+
+const (
+	sharedRateLimitKeyPrefix = "db1000n:ratelimit:"
+	sharedRateLimitTimeout   = time.Second     // of a single reservation, it's taken from the local limiter once it's exceeded
+	sharedRateLimitRetry     = 5 * time.Second // how long to keep using the local limiter after the backend has failed
+)
+
+// reserveScript books the next slot of the key on the server clock so that the clocks of the instances don't have to be in sync.
+// The key holds the time the next event is allowed at in microseconds and returns how long to wait before the booked one
+var reserveScript = redis.NewScript(`
+redis.replicate_commands()
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local interval = tonumber(ARGV[1])
+local next = tonumber(redis.call("GET", KEYS[1]) or "0")
+if next < now then
+	next = now
+end
+redis.call("SET", KEYS[1], next + interval, "PX", math.ceil((next + interval - now) / 1000) + 1000)
+return next - now
+`)
+
+// NewCoordinationClient returns a client of the coordination backend, addr can be either a redis:// url or host:port
+func NewCoordinationClient(addr string) (*redis.Client, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "redis://" + addr
+	}
+
+	options, err := redis.ParseURL(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coordination backend address: %w", err)
+	}
+
+	return redis.NewClient(options), nil
+}
+
+// SharedRateLimiter keeps the combined rate of all the instances sharing the backend and the key under the limit.
+// Reservations fall back to a local limiter with the same rate while the backend is unavailable so that the jobs
+// don't stall on outages, the fleet can exceed the limit during that time as every instance limits separately.
+// All methods are safe to call on a nil limiter which doesn't limit anything
+type SharedRateLimiter struct {
+	client   *redis.Client
+	key      string
+	interval int64 // between two subsequent events in microseconds
+	fallback *RateLimiter
+	logger   *zap.Logger
+
+	mu            sync.Mutex
+	degradedUntil time.Time
+
+	now func() time.Time
+}
+
+// NewSharedRateLimiter returns a limiter allowing perSecond events per second across all the instances or nil if it's not positive
+func NewSharedRateLimiter(client *redis.Client, key string, perSecond float64, logger *zap.Logger) *SharedRateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+
+	return &SharedRateLimiter{
+		client:   client,
+		key:      sharedRateLimitKeyPrefix + key,
+		interval: int64(float64(time.Second/time.Microsecond) / perSecond),
+		fallback: NewRateLimiter(perSecond),
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Reserve books a slot for the next event on the backend and returns how long to wait before it's allowed
+func (l *SharedRateLimiter) Reserve() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	if l.degraded() {
+		return l.fallback.Reserve()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedRateLimitTimeout)
+	defer cancel()
+
+	wait, err := reserveScript.Run(ctx, l.client, []string{l.key}, l.interval).Int64()
+	if err != nil {
+		l.degrade(err)
+
+		return l.fallback.Reserve()
+	}
+
+	l.recover()
+
+	return time.Duration(wait) * time.Microsecond
+}
+
+func (l *SharedRateLimiter) degraded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.now().Before(l.degradedUntil)
+}
+
+func (l *SharedRateLimiter) degrade(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.degradedUntil.IsZero() {
+		l.logger.Warn("coordination backend is unavailable, limiting the rate locally", zap.String("key", l.key), zap.Error(err))
+	}
+
+	l.degradedUntil = l.now().Add(sharedRateLimitRetry)
+}
+
+func (l *SharedRateLimiter) recover() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.degradedUntil.IsZero() {
+		l.logger.Info("coordination backend is available again", zap.String("key", l.key))
+	}
+
+	l.degradedUntil = time.Time{}
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+)
+
+func TestSharedRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	backend := miniredis.RunT(t)
+	backend.SetTime(time.Now())
+
+	// two instances with clients of their own
+	newInstance := func() *SharedRateLimiter {
+		client, err := NewCoordinationClient(backend.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { _ = client.Close() })
+
+		return NewSharedRateLimiter(client, "target.example", 10, zap.NewNop())
+	}
+
+	first, second := newInstance(), newInstance()
+
+	// the reservations of both instances are spread over a single schedule
+	for i := 0; i < 10; i++ {
+		limiter := first
+		if i%2 == 1 {
+			limiter = second
+		}
+
+		if got, want := limiter.Reserve(), time.Duration(i)*100*time.Millisecond; got != want {
+			t.Errorf("reservation %d: expected wait %v, got %v", i, want, got)
+		}
+	}
+
+	// unused time is not accumulated
+	backend.SetTime(time.Now().Add(time.Minute))
+
+	if got := first.Reserve(); got != 0 {
+		t.Errorf("expected no wait after idling, got %v", got)
+	}
+
+	other := NewSharedRateLimiter(first.client, "other.example", 10, zap.NewNop())
+	if got := other.Reserve(); got != 0 {
+		t.Errorf("expected keys to be limited separately, got %v", got)
+	}
+}
+
+func TestSharedRateLimiterOutage(t *testing.T) {
+	t.Parallel()
+
+	backend := miniredis.RunT(t)
+
+	client, err := NewCoordinationClient("redis://" + backend.Addr() + "/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	limiter := NewSharedRateLimiter(client, "target.example", 4, zap.NewNop())
+	clock := time.Now()
+	limiter.now = func() time.Time { return clock }
+	limiter.fallback.now = limiter.now
+
+	backend.Close()
+
+	start := time.Now()
+
+	// the local limiter takes over without waiting for the backend on every reservation
+	for i, want := range []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond} {
+		if got := limiter.Reserve(); got != want {
+			t.Errorf("reservation %d: expected local wait %v, got %v", i, want, got)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > sharedRateLimitTimeout+time.Second/2 {
+		t.Errorf("expected the reservations not to stall on the outage, took %v", elapsed)
+	}
+
+	if err := backend.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the backend is retried once the retry period has passed
+	clock = clock.Add(sharedRateLimitRetry)
+
+	if got := limiter.Reserve(); got != 0 || limiter.degraded() {
+		t.Errorf("expected the backend to be used again, got wait %v", got)
+	}
+
+	if limiter := NewSharedRateLimiter(client, "target.example", 0, zap.NewNop()); limiter != nil || limiter.Reserve() != 0 {
+		t.Error("expected nil limiter for non-positive rate")
+	}
+
+	if _, err := NewCoordinationClient("redis://:invalid"); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
@@ -0,0 +1,133 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// RandomChoice returns a uniformly random element of a single slice argument or of the arguments themselves,
+// i.e. both {{ random_choice "a" "b" }} and {{ random_choice (split "a,b" ",") }} are supported
+func RandomChoice(args ...interface{}) (interface{}, error) {
+	items := args
+
+	if len(args) == 1 {
+		if v := reflect.ValueOf(args[0]); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			items = make([]interface{}, v.Len())
+			for i := range items {
+				items[i] = v.Index(i).Interface()
+			}
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("random_choice: empty list")
+	}
+
+	return items[random.Intn(len(items))], nil
+}
+
+// WeightedChoice takes value/weight pairs and returns a random value with the probability proportional to its weight,
+// i.e. {{ weighted_choice "/" 8 "/search" 2 }} picks the root path in 80% of the cases
+func WeightedChoice(pairs ...interface{}) (interface{}, error) {
+	return weightedChoice("weighted_choice", pairs)
+}
+
+// weightedChoice implements WeightedChoice, errors are prefixed with the name of the template function
+func weightedChoice(name string, pairs []interface{}) (interface{}, error) {
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("%v: expected value/weight pairs, got %d arguments", name, len(pairs))
+	}
+
+	weights := make([]float64, 0, len(pairs)/2)
+
+	var total float64
+
+	for i := 1; i < len(pairs); i += 2 {
+		weight, err := toWeight(pairs[i])
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid weight of %v: %w", name, pairs[i-1], err)
+		}
+
+		weights = append(weights, weight)
+		total += weight
+	}
+
+	if total <= 0 {
+		return nil, fmt.Errorf("%v: total weight has to be positive", name)
+	}
+
+	r := random.Float64() * total
+
+	for i, weight := range weights {
+		if r < weight {
+			return pairs[2*i], nil
+		}
+
+		r -= weight
+	}
+
+	// floating point rounding can only leave the last values unpicked
+	for i := len(weights) - 1; ; i-- {
+		if weights[i] > 0 {
+			return pairs[2*i], nil
+		}
+	}
+}
+
+var httpMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// RandomMethod is weighted_choice for the method field of requests: it takes method/weight pairs and returns
+// one of the methods in upper case, i.e. {{ random_method "GET" 8 "POST" 2 }} makes 20% of the requests writes.
+// Unknown methods are rejected so that a typo doesn't end up in the share of requests it's picked for
+func RandomMethod(pairs ...interface{}) (string, error) {
+	normalized := make([]interface{}, len(pairs))
+	copy(normalized, pairs)
+
+	// odd number of arguments is reported by weightedChoice
+	for i := 0; i < len(normalized); i += 2 {
+		name, ok := normalized[i].(string)
+		if !ok {
+			return "", fmt.Errorf("random_method: expected a method name, got %T", normalized[i])
+		}
+
+		if name = strings.ToUpper(name); !httpMethods[name] {
+			return "", fmt.Errorf("random_method: unknown method %q", normalized[i])
+		}
+
+		normalized[i] = name
+	}
+
+	method, err := weightedChoice("random_method", normalized)
+	if err != nil {
+		return "", err
+	}
+
+	return method.(string), nil
+}
+
+func toWeight(v interface{}) (float64, error) {
+	var weight float64
+
+	switch v := reflect.ValueOf(v); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		weight = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		weight = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		weight = v.Float()
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v.Interface())
+	}
+
+	if weight < 0 {
+		return 0, fmt.Errorf("negative weight %v", weight)
+	}
+
+	return weight, nil
+}
@@ -0,0 +1,140 @@
+package templates
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRandomChoice(t *testing.T) {
+	t.Parallel()
+
+	const iterations = 30000
+
+	testCases := []struct {
+		name     string
+		template string
+	}{
+		{name: "variadic", template: `{{ random_choice "a" "b" "c" }}`},
+		{name: "slice", template: `{{ random_choice (split "a,b,c" ",") }}`},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tpl, err := Parse(tc.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			counts := make(map[string]int)
+			for i := 0; i < iterations; i++ {
+				counts[Execute(zap.NewNop(), tpl, context.Background())]++
+			}
+
+			checkDistribution(t, counts, map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}, iterations)
+		})
+	}
+}
+
+func TestWeightedChoice(t *testing.T) {
+	t.Parallel()
+
+	const iterations = 30000
+
+	tpl, err := Parse(`{{ weighted_choice "/" 6 "/search" 3.0 "/login" 1 "/never" 0 }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < iterations; i++ {
+		counts[Execute(zap.NewNop(), tpl, context.Background())]++
+	}
+
+	checkDistribution(t, counts, map[string]float64{"/": 0.6, "/search": 0.3, "/login": 0.1}, iterations)
+}
+
+func TestRandomMethod(t *testing.T) {
+	t.Parallel()
+
+	const iterations = 30000
+
+	tpl, err := Parse(`{{ random_method "GET" 8 "post" 2 }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < iterations; i++ {
+		counts[Execute(zap.NewNop(), tpl, context.Background())]++
+	}
+
+	checkDistribution(t, counts, map[string]float64{"GET": 0.8, "POST": 0.2}, iterations)
+
+	for _, args := range [][]interface{}{
+		{},
+		{"GET"},
+		{"GETT", 1},
+		{1, 1},
+		{"GET", 0},
+	} {
+		if _, err := RandomMethod(args...); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestChoiceErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := RandomChoice(); err == nil {
+		t.Error("expected an error for no arguments")
+	}
+
+	if _, err := RandomChoice([]string{}); err == nil {
+		t.Error("expected an error for an empty slice")
+	}
+
+	for _, args := range [][]interface{}{
+		{},
+		{"a"},
+		{"a", "1"},
+		{"a", -1},
+		{"a", 0, "b", 0},
+	} {
+		if _, err := WeightedChoice(args...); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+
+	tpl, err := Parse(`{{ random_choice (split "" "") }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Execute(zap.NewNop(), tpl, context.Background()); got != "" {
+		t.Errorf("expected empty output for an empty list, got %q", got)
+	}
+}
+
+func checkDistribution(t *testing.T, counts map[string]int, want map[string]float64, total int) {
+	t.Helper()
+
+	const tolerance = 0.02
+
+	if len(counts) != len(want) {
+		t.Errorf("expected values %v, got %v", want, counts)
+	}
+
+	for value, share := range want {
+		if got := float64(counts[value]) / float64(total); math.Abs(got-share) > tolerance {
+			t.Errorf("expected %q to be picked with probability %v, got %v", value, share, got)
+		}
+	}
+}
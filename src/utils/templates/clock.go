@@ -0,0 +1,45 @@
+package templates
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeOrNow returns the optional time passed to the now_* functions (i.e. from now_offset) or the current time
+func timeOrNow(t []time.Time) time.Time {
+	if len(t) > 0 {
+		return t[len(t)-1].UTC()
+	}
+
+	return time.Now().UTC()
+}
+
+// Now returns the time in RFC3339 format
+func Now(t ...time.Time) string {
+	return timeOrNow(t).Format(time.RFC3339)
+}
+
+// NowUnix returns the time as unix seconds
+func NowUnix(t ...time.Time) int64 {
+	return timeOrNow(t).Unix()
+}
+
+// NowUnixMs returns the time as unix milliseconds
+func NowUnixMs(t ...time.Time) int64 {
+	return timeOrNow(t).UnixMilli()
+}
+
+// NowFormat returns the time formatted with the go layout, i.e. {{ now_format "2006-01-02" }}
+func NowFormat(layout string, t ...time.Time) string {
+	return timeOrNow(t).Format(layout)
+}
+
+// NowOffset returns the current time shifted by the duration, i.e. {{ now_offset "-5m" | now_unix }}
+func NowOffset(offset string) (time.Time, error) {
+	d, err := time.ParseDuration(offset)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("now_offset: %w", err)
+	}
+
+	return time.Now().UTC().Add(d), nil
+}
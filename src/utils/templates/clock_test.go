@@ -0,0 +1,118 @@
+package templates
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func executeClockTemplate(t *testing.T, text string) string {
+	t.Helper()
+
+	tpl, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Execute(zap.NewNop(), tpl, context.Background())
+}
+
+func checkTimeNear(t *testing.T, name string, got, want time.Time, tolerance time.Duration) {
+	t.Helper()
+
+	if diff := got.Sub(want); diff < -tolerance || diff > tolerance {
+		t.Errorf("%v: expected time near %v, got %v", name, want, got)
+	}
+}
+
+func TestNow(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+
+	parsed, err := time.Parse(time.RFC3339, executeClockTemplate(t, "{{ now }}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTimeNear(t, "now", parsed, start, time.Second)
+
+	unix, err := strconv.ParseInt(executeClockTemplate(t, "{{ now_unix }}"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTimeNear(t, "now_unix", time.Unix(unix, 0), start, time.Second)
+
+	unixMs, err := strconv.ParseInt(executeClockTemplate(t, "{{ now_unix_ms }}"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTimeNear(t, "now_unix_ms", time.UnixMilli(unixMs), start, time.Second)
+
+	const layout = "2006-01-02"
+
+	day := executeClockTemplate(t, `{{ now_format "2006-01-02" }}`)
+	if _, err := time.Parse(layout, day); err != nil {
+		t.Fatal(err)
+	}
+
+	// tolerate the test running across midnight
+	if day != start.UTC().Format(layout) && day != time.Now().UTC().Format(layout) {
+		t.Errorf("now_format: unexpected date %v", day)
+	}
+}
+
+func TestNowOffset(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		offset string
+		want   time.Duration
+	}{
+		{offset: "-5m", want: -5 * time.Minute},
+		{offset: "1h30m", want: 90 * time.Minute},
+		{offset: "-36h", want: -36 * time.Hour},
+		{offset: "0s"},
+	}
+
+	for _, tc := range testCases {
+		start := time.Now()
+
+		unix, err := strconv.ParseInt(executeClockTemplate(t, `{{ now_offset "`+tc.offset+`" | now_unix }}`), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkTimeNear(t, tc.offset, time.Unix(unix, 0), start.Add(tc.want), time.Second)
+
+		parsed, err := time.Parse(time.RFC3339, executeClockTemplate(t, `{{ now_offset "`+tc.offset+`" | now }}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkTimeNear(t, tc.offset, parsed, start.Add(tc.want), time.Second)
+
+		offset, err := NowOffset(tc.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkTimeNear(t, tc.offset, offset, start.Add(tc.want), 100*time.Millisecond)
+	}
+
+	formatted, err := time.Parse(time.RFC1123, executeClockTemplate(t, `{{ now_offset "-5m" | now_format "Mon, 02 Jan 2006 15:04:05 MST" }}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTimeNear(t, "now_format", formatted, time.Now().Add(-5*time.Minute), time.Second)
+
+	if _, err := NowOffset("5 minutes"); err == nil {
+		t.Error("expected an error for an invalid offset")
+	}
+}
@@ -0,0 +1,32 @@
+package templates
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// counters are shared by all the templates so that job instances using the same name don't repeat values
+var counters sync.Map // map by name to *int64 holding the next value
+
+// Counter returns the next value of the named counter. Optional arguments are start (0 by default),
+// which is only taken into account when the counter is used for the first time, and step (1 by default)
+func Counter(name string, args ...int) (int, error) {
+	start, step := 0, 1
+
+	switch len(args) {
+	case 0:
+	case 1:
+		start = args[0]
+	case 2: //nolint:gomnd // start and step
+		start, step = args[0], args[1]
+	default:
+		return 0, fmt.Errorf("counter accepts at most 2 arguments (start and step), got %d", len(args))
+	}
+
+	next := int64(start)
+
+	v, _ := counters.LoadOrStore(name, &next)
+
+	return int(atomic.AddInt64(v.(*int64), int64(step)) - int64(step)), nil
+}
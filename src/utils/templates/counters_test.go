@@ -0,0 +1,103 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestCounter(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{name: "default", template: `{{ counter "test-default-%[1]s" }}`, want: []string{"0", "1", "2"}},
+		{name: "start", template: `{{ counter "test-start-%[1]s" 10 }}`, want: []string{"10", "11", "12"}},
+		{name: "start and step", template: `{{ counter "test-step-%[1]s" 1000 2 }}`, want: []string{"1000", "1002", "1004"}},
+		{name: "isolated keys", template: `{{ counter "test-a-%[1]s" }}-{{ counter "test-b-%[1]s" 5 }}`, want: []string{"0-5", "1-6", "2-7"}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// counters are global, names unique to the run keep the values from the previous runs (i.e. with -count) out
+			tpl, err := Parse(fmt.Sprintf(tc.template, uuid.NewString()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(tc.want))
+			for range tc.want {
+				got = append(got, Execute(zap.NewNop(), tpl, context.Background()))
+			}
+
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCounterTooManyArgs(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Counter("test-args", 1, 2, 3); err == nil {
+		t.Error("expected an error for too many arguments")
+	}
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const (
+		workers    = 20
+		iterations = 100
+	)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int]bool)
+		name = "test-concurrent-" + uuid.NewString()
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				v, err := Counter(name)
+				if err != nil {
+					t.Error(err)
+
+					return
+				}
+
+				mu.Lock()
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for i := 0; i < workers*iterations; i++ {
+		if !seen[i] {
+			t.Fatalf("value %d was skipped or repeated, got %d unique values", i, len(seen))
+		}
+	}
+}
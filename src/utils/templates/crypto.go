@@ -0,0 +1,66 @@
+package templates
+
+import (
+	"crypto/hmac"
+	"crypto/md5"  //nolint:gosec // Weak hashes are still used by the signatures of some APIs
+	"crypto/sha1" //nolint:gosec // Weak hashes are still used by the signatures of some APIs
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// hmacDigest returns the digest as raw bytes so that it can be piped to the encoder the API expects,
+// i.e. {{ hmac_sha256 "key" "data" | hex_encode }} or {{ hmac_sha256 "key" "data" | base64_encode }}
+func hmacDigest(newHash func() hash.Hash, key, data string) string {
+	mac := hmac.New(newHash, []byte(key))
+	mac.Write([]byte(data))
+
+	return string(mac.Sum(nil))
+}
+
+// HMACSHA256 returns the raw HMAC-SHA256 of the data
+func HMACSHA256(key, data string) string {
+	return hmacDigest(sha256.New, key, data)
+}
+
+// HMACSHA1 returns the raw HMAC-SHA1 of the data
+func HMACSHA1(key, data string) string {
+	return hmacDigest(sha1.New, key, data)
+}
+
+// HMACMD5 returns the raw HMAC-MD5 of the data
+func HMACMD5(key, data string) string {
+	return hmacDigest(md5.New, key, data)
+}
+
+// SHA256 returns the raw SHA-256 digest of the data, i.e. for body hashes signed along with the request
+func SHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+
+	return string(sum[:])
+}
+
+// base64Encode accepts both bytes (i.e. from random_payload_byte) and strings (i.e. from hmac_sha256)
+func base64Encode(v interface{}) (string, error) {
+	switch data := v.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(data), nil
+	case string:
+		return base64.StdEncoding.EncodeToString([]byte(data)), nil
+	default:
+		return "", fmt.Errorf("base64_encode: unsupported type %T", v)
+	}
+}
+
+// hexDecode is hex.DecodeString returning a string
+func hexDecode(s string) (string, error) {
+	b, err := hex.DecodeString(s)
+
+	return string(b), err
+}
+
+func hexEncode(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
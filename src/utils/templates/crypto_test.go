@@ -0,0 +1,58 @@
+package templates
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestHMAC(t *testing.T) {
+	t.Parallel()
+
+	// reference values from RFC 4231 (test case 2) and RFC 2202 (test case 2)
+	testCases := []struct {
+		text string
+		want string
+	}{
+		{
+			text: `{{ hmac_sha256 "Jefe" "what do ya want for nothing?" | hex_encode }}`,
+			want: "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		},
+		{
+			text: `{{ "what do ya want for nothing?" | hmac_sha1 "Jefe" | hex_encode }}`,
+			want: "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79",
+		},
+		{
+			text: `{{ hmac_md5 "Jefe" "what do ya want for nothing?" | hex_encode }}`,
+			want: "750c783e6ab0b503eaa86e310a5db738",
+		},
+		{
+			text: `{{ hmac_sha256 "key" "The quick brown fox jumps over the lazy dog" | base64_encode }}`,
+			want: "97yD9DBThCSxMpjmqm+xQ+9NWaFJRhdZl0edvC0aPNg=",
+		},
+		{
+			text: `{{ sha256 "abc" | hex_encode }}`,
+			want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		},
+		{
+			text: `{{ hex_decode "6869" }}`,
+			want: "hi",
+		},
+		{
+			text: `{{ random_payload_byte 3 | base64_encode | len }}`,
+			want: "4",
+		},
+	}
+
+	for _, tc := range testCases {
+		tpl, err := Parse(tc.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := Execute(zap.NewNop(), tpl, context.Background()); got != tc.want {
+			t.Errorf("%v: expected %q, got %q", tc.text, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package templates
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Supported values for DataFileConfig.Format
+const (
+	DataFileCSV   = "csv"   // first record is the header with the column names
+	DataFileLines = "lines" // every non-empty line is a row with a single "line" column
+)
+
+// Supported values for DataFileConfig.OnEOF
+const (
+	DataFileCycle = "cycle"
+	DataFileStop  = "stop"
+)
+
+const dataFileLineColumn = "line"
+
+// DataFileConfig describes a file from the files directory (see SetFilesDir) which rows are fed into templates
+// with datarow and datacol functions
+type DataFileConfig struct {
+	Path   string `mapstructure:"path"`
+	Format string `mapstructure:"format"` // guessed from the extension if empty: csv for .csv files and lines otherwise
+	OnEOF  string `mapstructure:"on_eof"` // whether to start over ("cycle", default) or to stop after the last row ("stop")
+}
+
+type dataRows struct {
+	header []string
+	rows   [][]string
+}
+
+// parsed data files are cached by format and path so that job instances don't parse big files over and over again
+var (
+	dataFilesMu sync.Mutex
+	dataFiles   = make(map[string]*dataRows)
+)
+
+// DataCursor walks the rows of a data file, every job instance gets a cursor of its own
+type DataCursor struct {
+	data  *dataRows
+	cycle bool
+
+	mu      sync.Mutex
+	next    int
+	current []string
+}
+
+// OpenDataFile reads and parses the data file once and returns a cursor positioned before its first row,
+// returns nil if the config is nil
+func OpenDataFile(c *DataFileConfig) (*DataCursor, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	format := c.Format
+	if format == "" {
+		format = DataFileLines
+		if strings.EqualFold(filepath.Ext(c.Path), ".csv") {
+			format = DataFileCSV
+		}
+	}
+
+	var cycle bool
+
+	switch c.OnEOF {
+	case "", DataFileCycle:
+		cycle = true
+	case DataFileStop:
+	default:
+		return nil, fmt.Errorf("unsupported datafile on_eof %q, expected one of [%q, %q]", c.OnEOF, DataFileCycle, DataFileStop)
+	}
+
+	data, err := loadDataFile(c.Path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataCursor{data: data, cycle: cycle}, nil
+}
+
+func loadDataFile(name, format string) (*dataRows, error) {
+	reader := currentFileReader()
+
+	path, err := reader.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := format + ":" + path
+
+	dataFilesMu.Lock()
+	defer dataFilesMu.Unlock()
+
+	if data, ok := dataFiles[key]; ok {
+		return data, nil
+	}
+
+	content, err := reader.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var data *dataRows
+
+	switch format {
+	case DataFileCSV:
+		data, err = parseCSVDataFile(content)
+	case DataFileLines:
+		data = parseLinesDataFile(content)
+	default:
+		return nil, fmt.Errorf("unsupported datafile format %q, expected one of [%q, %q]", format, DataFileCSV, DataFileLines)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing datafile %q: %w", name, err)
+	}
+
+	if len(data.rows) == 0 {
+		return nil, fmt.Errorf("datafile %q has no rows", name)
+	}
+
+	dataFiles[key] = data
+
+	return data, nil
+}
+
+func parseCSVDataFile(content []byte) (*dataRows, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	return &dataRows{header: records[0], rows: records[1:]}, nil
+}
+
+func parseLinesDataFile(content []byte) *dataRows {
+	data := &dataRows{header: []string{dataFileLineColumn}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			data.rows = append(data.rows, []string{line})
+		}
+	}
+
+	return data
+}
+
+// Next moves the cursor to the next row, returns false when all the rows have been used and the cursor doesn't cycle
+func (c *DataCursor) Next() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next == len(c.data.rows) {
+		if !c.cycle {
+			return false
+		}
+
+		c.next = 0
+	}
+
+	c.current = c.data.rows[c.next]
+	c.next++
+
+	return true
+}
+
+// Row returns the values of the current row
+func (c *DataCursor) Row() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current
+}
+
+// Col returns the value of the current row in the column with the given name (from the csv header) or index
+func (c *DataCursor) Col(column interface{}) (string, error) {
+	row := c.Row()
+	if row == nil {
+		return "", errors.New("datacol: no current row")
+	}
+
+	index := -1
+
+	switch col := column.(type) {
+	case string:
+		for i, name := range c.data.header {
+			if name == col {
+				index = i
+
+				break
+			}
+		}
+	case int:
+		index = col
+	}
+
+	if index < 0 || index >= len(row) {
+		return "", fmt.Errorf("datacol: unknown column %v", column)
+	}
+
+	return row[index], nil
+}
+
+// Funcs returns datarow and datacol template functions bound to the cursor
+func (c *DataCursor) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"datarow": c.Row,
+		"datacol": c.Col,
+	}
+}
+
+func noDataRow() ([]string, error) {
+	return nil, errors.New("datarow: no datafile configured")
+}
+
+func noDataCol(interface{}) (string, error) {
+	return "", errors.New("datacol: no datafile configured")
+}
@@ -0,0 +1,123 @@
+package templates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDataFile(t *testing.T) { //nolint:paralleltest // Modifies the global files directory
+	baseDir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"users.csv":   "username,password\nalice,secret1\nbob,\"with,comma\"\ncarol,secret3\n",
+		"tokens.txt":  "first\r\n\nsecond\n",
+		"header.csv":  "username,password\n",
+		"broken.csv":  "a,b\n\"unterminated\n",
+		"users.lines": "alice\nbob\n",
+	} {
+		if err := os.WriteFile(filepath.Join(baseDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	SetFilesDir(baseDir)
+	defer SetFilesDir("")
+
+	testCases := []struct {
+		name     string
+		config   DataFileConfig
+		template string
+		want     []string
+	}{
+		{
+			name:     "csv cycle",
+			config:   DataFileConfig{Path: "users.csv"},
+			template: `{{ datacol "username" }}:{{ datacol "password" }}`,
+			want:     []string{"alice:secret1", "bob:with,comma", "carol:secret3", "alice:secret1"},
+		},
+		{
+			name:     "csv stop",
+			config:   DataFileConfig{Path: "users.csv", OnEOF: DataFileStop},
+			template: `{{ join datarow "|" }}`,
+			want:     []string{"alice|secret1", "bob|with,comma", "carol|secret3"},
+		},
+		{
+			name:     "lines by index",
+			config:   DataFileConfig{Path: "tokens.txt"},
+			template: `{{ datacol "line" }}-{{ datacol 0 }}`,
+			want:     []string{"first-first", "second-second", "first-first"},
+		},
+		{
+			name:     "explicit format",
+			config:   DataFileConfig{Path: "users.lines", Format: DataFileLines, OnEOF: DataFileStop},
+			template: `{{ index datarow 0 }}`,
+			want:     []string{"alice", "bob"},
+		},
+	}
+
+	for _, tc := range testCases {
+		cursor, err := OpenDataFile(&tc.config)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+
+		tpl, err := ParseWithFuncs(tc.template, cursor.Funcs())
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+
+		var got []string
+
+		for len(got) < len(tc.want)+1 && cursor.Next() {
+			got = append(got, Execute(zap.NewNop(), tpl, context.Background()))
+		}
+
+		if tc.config.OnEOF != DataFileStop {
+			got = got[:len(tc.want)]
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%v: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+
+	for _, c := range []DataFileConfig{
+		{Path: "missing.csv"},
+		{Path: "header.csv"},
+		{Path: "broken.csv"},
+		{Path: "users.csv", Format: "xml"},
+		{Path: "users.csv", OnEOF: "rewind"},
+		{Path: "../users.csv"},
+	} {
+		c := c
+		if _, err := OpenDataFile(&c); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+
+	cursor, err := OpenDataFile(&DataFileConfig{Path: "users.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cursor.Next()
+
+	if _, err := cursor.Col("email"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+
+	// the functions fail without a datafile bound to the template
+	tpl, err := Parse(`{{ datacol "username" }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Execute(zap.NewNop(), tpl, context.Background()); got != "" {
+		t.Errorf("expected an empty output without a datafile, got %q", got)
+	}
+}
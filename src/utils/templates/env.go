@@ -0,0 +1,61 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	envMu       sync.RWMutex
+	envPrefixes []string // all the variables are allowed if empty
+)
+
+// SetEnvPrefixes restricts env template function to the variables with one of the prefixes, all the variables are allowed if there are none
+func SetEnvPrefixes(prefixes []string) {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	envPrefixes = nil
+
+	for _, prefix := range prefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			envPrefixes = append(envPrefixes, prefix)
+		}
+	}
+}
+
+func envAllowed(name string) bool {
+	envMu.RLock()
+	defer envMu.RUnlock()
+
+	if len(envPrefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range envPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// env returns the value of the environment variable or the optional fallback if it's not set
+func env(name string, fallback ...string) (string, error) {
+	if !envAllowed(name) {
+		return "", fmt.Errorf("env: variable %q doesn't match any of the allowed prefixes", name)
+	}
+
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+
+	if len(fallback) > 0 {
+		return fallback[0], nil
+	}
+
+	return "", nil
+}
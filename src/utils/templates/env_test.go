@@ -0,0 +1,58 @@
+package templates
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestEnvTemplates(t *testing.T) { //nolint:paralleltest // Modifies the environment and the global env prefixes
+	t.Setenv("DB1000N_TEST_TARGET", "http://localhost/")
+	t.Setenv("DB1000N_TEST_EMPTY", "")
+	t.Setenv("SECRET_TEST_TOKEN", "secret")
+
+	tpl, err := ParseMapStruct(map[string]interface{}{
+		"path":    `{{ env "DB1000N_TEST_TARGET" }}`,
+		"headers": map[string]interface{}{"Authorization": `Bearer {{ env "SECRET_TEST_TOKEN" }}`},
+		"body":    `{{ env "DB1000N_TEST_MISSING" "fallback" }}|{{ env "DB1000N_TEST_EMPTY" "fallback" }}|{{ env "DB1000N_TEST_MISSING" }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := tpl.Execute(zap.NewNop(), context.Background())
+	if result["path"] != "http://localhost/" || result["body"] != "fallback||" {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	if headers, _ := result["headers"].(map[string]interface{}); headers["Authorization"] != "Bearer secret" {
+		t.Errorf("unexpected headers %v", result["headers"])
+	}
+
+	SetEnvPrefixes([]string{"DB1000N_", " "})
+	defer SetEnvPrefixes(nil)
+
+	allowed, err := Parse(`{{ env "DB1000N_TEST_TARGET" }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Execute(zap.NewNop(), allowed, nil); got != "http://localhost/" {
+		t.Errorf("expected the allowed variable to be resolved, got %q", got)
+	}
+
+	// fallback doesn't apply to the variables that aren't allowed
+	forbidden, err := Parse(`{{ env "SECRET_TEST_TOKEN" "fallback" }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env("SECRET_TEST_TOKEN", "fallback"); err == nil {
+		t.Error("expected an error reading a variable without the allowed prefix")
+	}
+
+	if got := Execute(zap.NewNop(), forbidden, nil); got != "" {
+		t.Errorf("expected the variable to be hidden, got %q", got)
+	}
+}
@@ -0,0 +1,112 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultFakeLocale is used by fake_name and fake_phone when no locale is provided
+const DefaultFakeLocale = "en"
+
+type fakeLocale struct {
+	firstNames  []string
+	lastNames   []string
+	phoneFormat string // '#' is replaced with a random digit
+}
+
+var fakeLocales = map[string]fakeLocale{
+	"en": {
+		firstNames:  []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "William", "Elizabeth", "David", "Susan"},
+		lastNames:   []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Miller", "Davis", "Wilson", "Anderson", "Taylor", "Thomas", "Moore"},
+		phoneFormat: "+1 (###) ###-####",
+	},
+	"uk": {
+		firstNames:  []string{"Олександр", "Олена", "Андрій", "Наталія", "Сергій", "Ірина", "Дмитро", "Оксана", "Михайло", "Тетяна"},
+		lastNames:   []string{"Шевченко", "Коваленко", "Бондаренко", "Ткаченко", "Кравченко", "Олійник", "Мельник", "Поліщук", "Бойко", "Лисенко"},
+		phoneFormat: "+380 ## ### ## ##",
+	},
+	"de": {
+		firstNames:  []string{"Lukas", "Anna", "Leon", "Lea", "Felix", "Laura", "Jonas", "Julia", "Paul", "Sophie"},
+		lastNames:   []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann"},
+		phoneFormat: "+49 ### #######",
+	},
+	"pl": {
+		firstNames:  []string{"Jakub", "Anna", "Kacper", "Zuzanna", "Szymon", "Julia", "Jan", "Maja", "Filip", "Zofia"},
+		lastNames:   []string{"Nowak", "Kowalski", "Wiśniewski", "Wójcik", "Kowalczyk", "Kamiński", "Lewandowski", "Zieliński", "Szymański", "Woźniak"},
+		phoneFormat: "+48 ### ### ###",
+	},
+}
+
+var fakeEmailDomains = []string{"gmail.com", "yahoo.com", "outlook.com", "hotmail.com", "icloud.com", "proton.me", "mail.com"}
+
+func getFakeLocale(locale []string) (fakeLocale, error) {
+	name := DefaultFakeLocale
+	if len(locale) > 0 {
+		name = locale[0]
+	}
+
+	l, ok := fakeLocales[name]
+	if !ok {
+		known := make([]string, 0, len(fakeLocales))
+		for k := range fakeLocales {
+			known = append(known, k)
+		}
+
+		sort.Strings(known)
+
+		return fakeLocale{}, fmt.Errorf("unknown locale %q, expected one of %q", name, known)
+	}
+
+	return l, nil
+}
+
+func randomElement(from []string) string {
+	return from[random.Intn(len(from))]
+}
+
+// FakeName returns a random "first last" name for the optional locale ("en", "uk", "de" or "pl")
+func FakeName(locale ...string) (string, error) {
+	l, err := getFakeLocale(locale)
+	if err != nil {
+		return "", err
+	}
+
+	return randomElement(l.firstNames) + " " + randomElement(l.lastNames), nil
+}
+
+// FakePhone returns a random phone number formatted according to the optional locale ("en", "uk", "de" or "pl")
+func FakePhone(locale ...string) (string, error) {
+	l, err := getFakeLocale(locale)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	for _, c := range l.phoneFormat {
+		if c == '#' {
+			b.WriteByte(randomChar("0123456789"))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// FakeEmail returns a random plausible looking email address
+func FakeEmail() string {
+	names := fakeLocales[DefaultFakeLocale]
+	separators := []string{"", ".", "_"}
+
+	local := strings.ToLower(randomElement(names.firstNames) + randomElement(separators) + randomElement(names.lastNames))
+
+	const maxSuffix = 1000
+	if suffix := random.Intn(maxSuffix); suffix%2 == 0 {
+		local += strconv.Itoa(suffix)
+	}
+
+	return local + "@" + randomElement(fakeEmailDomains)
+}
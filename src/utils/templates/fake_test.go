@@ -0,0 +1,72 @@
+package templates
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestFake(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		template string
+		valid    func(string) bool
+	}{
+		{name: "email", template: `{{ fake_email }}`, valid: regexp.MustCompile(`^[a-z._]+[0-9]*@[a-z]+\.[a-z]+$`).MatchString},
+		{name: "name", template: `{{ fake_name }}`, valid: regexp.MustCompile(`^[A-Z][a-z]+ [A-Z][a-z]+$`).MatchString},
+		{name: "uk name", template: `{{ fake_name "uk" }}`, valid: regexp.MustCompile(`^\p{Cyrillic}+ \p{Cyrillic}+$`).MatchString},
+		{name: "phone", template: `{{ fake_phone }}`, valid: regexp.MustCompile(`^\+1 \([0-9]{3}\) [0-9]{3}-[0-9]{4}$`).MatchString},
+		{name: "uk phone", template: `{{ fake_phone "uk" }}`, valid: regexp.MustCompile(`^\+380 [0-9]{2} [0-9]{3} [0-9]{2} [0-9]{2}$`).MatchString},
+		{name: "pl phone", template: `{{ fake_phone "pl" }}`, valid: regexp.MustCompile(`^\+48 [0-9]{3} [0-9]{3} [0-9]{3}$`).MatchString},
+		{name: "uuid", template: `{{ fake_uuid }}`, valid: func(s string) bool {
+			_, err := uuid.Parse(s)
+
+			return err == nil
+		}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tpl, err := Parse(tc.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const iterations = 50
+
+			seen := make(map[string]bool)
+
+			for i := 0; i < iterations; i++ {
+				got := Execute(zap.NewNop(), tpl, context.Background())
+				if !tc.valid(got) {
+					t.Fatalf("invalid %v %q", tc.name, got)
+				}
+
+				seen[got] = true
+			}
+
+			if len(seen) < 2 {
+				t.Errorf("expected values to vary across invocations, got %v", seen)
+			}
+		})
+	}
+}
+
+func TestFakeUnknownLocale(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{`{{ fake_name "xx" }}`, `{{ fake_phone "xx" }}`} {
+		if got := ParseAndExecute(zap.NewNop(), input, context.Background()); got != input {
+			t.Errorf("expected %q to fail and be returned as is, got %q", input, got)
+		}
+	}
+}
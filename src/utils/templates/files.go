@@ -0,0 +1,122 @@
+package templates
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fileReader reads files from the base directory only and caches their contents
+// as templates are executed on every job iteration
+type fileReader struct {
+	baseDir string
+	baseErr error // of resolving the real path of the base directory
+
+	mu    sync.Mutex
+	cache map[string][]byte // by the requested names, so that they are resolved and validated only once
+}
+
+var (
+	filesMu sync.RWMutex
+	files   = &fileReader{}
+)
+
+// SetFilesDir sets the directory that file and file_base64 template functions are allowed to read from.
+// Reading files is disabled when the directory is empty
+func SetFilesDir(dir string) {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+
+	files = newFileReader(dir)
+}
+
+func newFileReader(baseDir string) *fileReader {
+	r := &fileReader{baseDir: baseDir, cache: make(map[string][]byte)}
+
+	if baseDir != "" {
+		if realDir, err := filepath.EvalSymlinks(baseDir); err != nil {
+			r.baseErr = err
+		} else {
+			r.baseDir = realDir
+		}
+	}
+
+	return r
+}
+
+func currentFileReader() *fileReader {
+	filesMu.RLock()
+	defer filesMu.RUnlock()
+
+	return files
+}
+
+// ReadFile reads the file from the directory set with SetFilesDir
+func ReadFile(name string) ([]byte, error) {
+	return currentFileReader().read(name)
+}
+
+func readFile(name string) (string, error) {
+	content, err := currentFileReader().read(name)
+
+	return string(content), err
+}
+
+func readFileBase64(name string) (string, error) {
+	content, err := currentFileReader().read(name)
+
+	return base64.StdEncoding.EncodeToString(content), err
+}
+
+func (r *fileReader) read(name string) ([]byte, error) {
+	r.mu.Lock()
+	content, ok := r.cache[name]
+	r.mu.Unlock()
+
+	if ok {
+		return content, nil
+	}
+
+	// concurrent first reads of the same file may both read it, the lock isn't held for the filesystem access
+	path, err := r.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if content, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cache[name] = content
+
+	return content, nil
+}
+
+// resolve returns the real path of the file making sure it doesn't point outside of the base directory (including via symlinks)
+func (r *fileReader) resolve(name string) (string, error) {
+	if r.baseDir == "" {
+		return "", errors.New("reading files is disabled, set the directory to read files from to enable it")
+	}
+
+	if r.baseErr != nil {
+		return "", r.baseErr
+	}
+
+	path, err := filepath.EvalSymlinks(filepath.Join(r.baseDir, name))
+	if err != nil {
+		return "", err
+	}
+
+	if rel, err := filepath.Rel(r.baseDir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %q is outside of the allowed directory", name)
+	}
+
+	return path, nil
+}
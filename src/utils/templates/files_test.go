@@ -0,0 +1,84 @@
+package templates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFileReader(t *testing.T) {
+	t.Parallel()
+
+	outside := t.TempDir()
+	baseDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(baseDir, "payload.bin"), []byte{0, 1, 2}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(baseDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := newFileReader(baseDir)
+
+	content, err := reader.read("payload.bin")
+	if err != nil || string(content) != "\x00\x01\x02" {
+		t.Fatalf("unexpected content %q, err %v", content, err)
+	}
+
+	// the file is served from the cache even after it was modified
+	if err := os.WriteFile(filepath.Join(baseDir, "payload.bin"), []byte("changed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := reader.read("payload.bin"); string(content) != "\x00\x01\x02" {
+		t.Errorf("expected cached content, got %q", content)
+	}
+
+	// cached files aren't looked up again at all
+	if err := os.Remove(filepath.Join(baseDir, "payload.bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := reader.read("payload.bin"); err != nil || string(content) != "\x00\x01\x02" {
+		t.Errorf("expected cached content without touching the filesystem, got %q (%v)", content, err)
+	}
+
+	for _, name := range []string{"missing", "../" + filepath.Base(outside) + "/secret", filepath.Join(outside, "secret"), "link"} {
+		if _, err := reader.read(name); err == nil {
+			t.Errorf("expected error reading %q", name)
+		}
+	}
+
+	if _, err := newFileReader("").read("payload.bin"); err == nil {
+		t.Error("reading files should be disabled without the base directory")
+	}
+}
+
+func TestFileTemplates(t *testing.T) { //nolint:paralleltest // Modifies the global files directory
+	baseDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(baseDir, "body.txt"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	SetFilesDir(baseDir)
+	defer SetFilesDir("")
+
+	tpl, err := Parse(`{{ file "body.txt" }} {{ file_base64 "body.txt" }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Execute(zap.NewNop(), tpl, context.Background()); got != "hello aGVsbG8=" {
+		t.Errorf("unexpected template output %q", got)
+	}
+}
@@ -24,7 +24,6 @@ package templates
 
 import (
 	"fmt"
-	"math/rand"
 	"net"
 	"strings"
 )
@@ -37,7 +36,7 @@ func RandomPayload(length int) string {
 // RandomPayloadByte returns a byte slice to spoof ip packets with random payload in specified length.
 func RandomPayloadByte(length int) []byte {
 	payload := make([]byte, length)
-	rand.Read(payload) //nolint:gosec // Cryptographically secure random not required
+	random.Read(payload)
 
 	return payload
 }
@@ -46,9 +45,33 @@ func RandomPayloadByte(length int) []byte {
 func RandomIP() string {
 	const maxByte = 255
 
-	//nolint:gosec // Cryptographically secure random not required
-	return fmt.Sprintf("%d.%d.%d.%d", rand.Intn(maxByte)+1, rand.Intn(maxByte)+1,
-		rand.Intn(maxByte)+1, rand.Intn(maxByte)+1)
+	return fmt.Sprintf("%d.%d.%d.%d", random.Intn(maxByte)+1, random.Intn(maxByte)+1,
+		random.Intn(maxByte)+1, random.Intn(maxByte)+1)
+}
+
+// RandomIPv6 returns a random ipv6 address to spoof packets.
+func RandomIPv6() string {
+	ip := make(net.IP, net.IPv6len)
+	random.Read(ip)
+
+	return ip.String()
+}
+
+// RandomIPFromCIDR returns a random ip within the provided network, i.e. "10.0.0.0/8" or "2001:db8::/32".
+func RandomIPFromCIDR(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	ip := make(net.IP, len(network.IP))
+	random.Read(ip)
+
+	for i := range ip {
+		ip[i] = network.IP[i] | (ip[i] &^ network.Mask[i])
+	}
+
+	return ip.String(), nil
 }
 
 // RandomPort returns a random port to spoof packets.
@@ -58,7 +81,7 @@ func RandomPort() int {
 		maxPort = 65535
 	)
 
-	return rand.Intn(maxPort-minPort) + minPort //nolint:gosec // Cryptographically secure random not required
+	return random.Intn(maxPort-minPort) + minPort
 }
 
 // RandomMacAddr returns a random MAC address to spoof packets.
@@ -66,7 +89,7 @@ func RandomMacAddr() net.HardwareAddr {
 	const macSizeBytes = 6
 
 	addr := make(net.HardwareAddr, macSizeBytes)
-	rand.Read(addr) //nolint:gosec // Cryptographically secure random not required
+	random.Read(addr)
 
 	return addr
 }
@@ -0,0 +1,68 @@
+package templates
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRandomIP(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		template string
+		network  string
+		ipv4     bool
+	}{
+		{name: "ipv4", template: `{{ random_ip }}`, ipv4: true},
+		{name: "ipv6", template: `{{ random_ipv6 }}`},
+		{name: "ipv4 cidr", template: `{{ random_ip_from_cidr "10.0.0.0/8" }}`, network: "10.0.0.0/8", ipv4: true},
+		{name: "narrow ipv4 cidr", template: `{{ random_ip_from_cidr "192.168.1.0/30" }}`, network: "192.168.1.0/30", ipv4: true},
+		{name: "ipv6 cidr", template: `{{ random_ip_from_cidr "2001:db8::/32" }}`, network: "2001:db8::/32"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tpl, err := Parse(tc.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 100; i++ {
+				got := Execute(zap.NewNop(), tpl, context.Background())
+
+				ip := net.ParseIP(got)
+				if ip == nil {
+					t.Fatalf("%q is not a valid ip", got)
+				}
+
+				if tc.ipv4 != (ip.To4() != nil) {
+					t.Fatalf("unexpected ip version of %v", ip)
+				}
+
+				if tc.network == "" {
+					continue
+				}
+
+				if _, network, _ := net.ParseCIDR(tc.network); !network.Contains(ip) {
+					t.Fatalf("%v is not within %v", ip, network)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomIPFromInvalidCIDR(t *testing.T) {
+	t.Parallel()
+
+	if _, err := RandomIPFromCIDR("10.0.0.0"); err == nil {
+		t.Error("expected an error for invalid cidr")
+	}
+}
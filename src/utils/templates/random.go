@@ -0,0 +1,60 @@
+package templates
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedRand is a math/rand generator that is safe for concurrent use
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// random is used by all the random template functions except random_uuid, fake_uuid, and random_user_agent,
+// so that they can be replayed with SetRandomSeed
+var random = newLockedRand(time.Now().UnixNano())
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))} //nolint:gosec // Cryptographically secure random not required
+}
+
+// SetRandomSeed reseeds the generator used by the random template functions so that the same seed yields
+// the same sequence of values. The sequence is only reproducible when the templates are executed in the same order,
+// i.e. with the same jobs that don't run concurrently. random_uuid, fake_uuid, and random_user_agent ignore the seed
+func SetRandomSeed(seed int64) {
+	random.mu.Lock()
+	defer random.mu.Unlock()
+
+	random.r.Seed(seed)
+}
+
+func (r *lockedRand) Int() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.r.Int()
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.r.Intn(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.r.Float64()
+}
+
+func (r *lockedRand) Read(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// never fails
+	_, _ = r.r.Read(p)
+}
@@ -0,0 +1,45 @@
+package templates
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSetRandomSeed(t *testing.T) { //nolint:paralleltest // Reseeds the shared generator
+	const (
+		input = `{{ random_int_n 1000000 }} {{ random_alphanum 16 }} {{ random_payload 8 | printf "%x" }} {{ random_ip }} ` +
+			`{{ random_ipv6 }} {{ random_port }} {{ random_mac_addr }} {{ random_choice "a" "b" "c" }} ` +
+			`{{ weighted_choice "x" 1 "y" 2 }} {{ fake_email }} {{ fake_name "uk" }} {{ cache_buster "http://localhost/" }}`
+		iterations = 5
+	)
+
+	tpl, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(seed int64) []string {
+		SetRandomSeed(seed)
+
+		outputs := make([]string, 0, iterations)
+		for i := 0; i < iterations; i++ {
+			outputs = append(outputs, Execute(zap.NewNop(), tpl, context.Background()))
+		}
+
+		return outputs
+	}
+
+	first, second, other := run(42), run(42), run(43)
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("expected the same output for the same seed, got %q and %q", first[i], second[i])
+		}
+
+		if first[i] == other[i] {
+			t.Errorf("expected different output for different seeds, got %q", first[i])
+		}
+	}
+}
@@ -26,14 +26,13 @@ package templates
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"strings"
 	"text/template"
 	"time"
 
-	"github.com/corpix/uarand"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -71,24 +70,80 @@ func randomChar(from string) byte {
 		return 0
 	}
 
-	return from[rand.Intn(len(from))] //nolint:gosec // Cryptographically secure random not required
+	return from[random.Intn(len(from))]
 }
 
-func randomString(n int, from string) string {
+const (
+	alphaChars    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	alphaNumChars = alphaChars + "0123456789"
+)
+
+// randomString returns n random characters from the optional charset (alphanumeric by default)
+func randomString(n int, from ...string) string {
+	charset := alphaNumChars
+	if len(from) > 0 {
+		charset = from[0]
+	}
+
 	b := make([]byte, n)
 	for i := range b {
-		b[i] = randomChar(from)
+		b[i] = randomChar(charset)
 	}
 
 	return string(b)
 }
 
 func randomAlpha(n int) string {
-	return randomString(n, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	return randomString(n, alphaChars)
 }
 
 func randomAplhaNum(n int) string {
-	return randomString(n, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	return randomString(n, alphaNumChars)
+}
+
+// randomBytesRange returns random bytes with the length picked from [minLength, maxLength] range
+func randomBytesRange(minLength, maxLength int) (string, error) {
+	if minLength < 0 || maxLength < minLength {
+		return "", fmt.Errorf("invalid length range [%d, %d]", minLength, maxLength)
+	}
+
+	return RandomPayload(minLength + random.Intn(maxLength-minLength+1)), nil
+}
+
+// cacheBuster appends a random query parameter to the url (keeping the fragment at the end) so that caches are missed
+func cacheBuster(rawURL string) string {
+	const (
+		param  = "_cb"
+		length = 16
+	)
+
+	var fragment string
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+
+	separator := "?"
+
+	switch {
+	case strings.HasSuffix(rawURL, "?") || strings.HasSuffix(rawURL, "&"):
+		separator = ""
+	case strings.Contains(rawURL, "?"):
+		separator = "&"
+	}
+
+	return rawURL + separator + param + "=" + randomString(length) + fragment
+}
+
+// randomPathSegment returns a random path segment of the optional length (8 by default) that doesn't need escaping
+func randomPathSegment(length ...int) string {
+	const defaultLength = 8
+
+	n := defaultLength
+	if len(length) > 0 {
+		n = length[0]
+	}
+
+	return randomString(n, alphaNumChars)
 }
 
 func mod(lhs, rhs int) int {
@@ -108,6 +163,12 @@ func ctxKey(key string) ContextKey {
 
 // Parse a template
 func Parse(input string) (*template.Template, error) {
+	return ParseWithFuncs(input, nil)
+}
+
+// ParseWithFuncs is like Parse but adds the functions to the default ones (overriding them if the names match),
+// i.e. the ones bound to a job instance
+func ParseWithFuncs(input string, funcs template.FuncMap) (*template.Template, error) {
 	// TODO: consider adding ability to populate custom data
 	return template.New("tpl").Funcs(template.FuncMap{
 		"random_uuid":         randomUUID,
@@ -115,14 +176,33 @@ func Parse(input string) (*template.Template, error) {
 		"random_string":       randomString,
 		"random_alpha":        randomAlpha,
 		"random_alphanum":     randomAplhaNum,
-		"random_int_n":        rand.Intn,
-		"random_int":          rand.Int,
+		"random_int_n":        random.Intn,
+		"random_int":          random.Int,
 		"random_payload":      RandomPayload,
 		"random_payload_byte": RandomPayloadByte,
+		"random_bytes":        RandomPayload,
+		"random_bytes_range":  randomBytesRange,
 		"random_ip":           RandomIP,
+		"random_ipv6":         RandomIPv6,
+		"random_ip_from_cidr": RandomIPFromCIDR,
 		"random_port":         RandomPort,
 		"random_mac_addr":     RandomMacAddr,
-		"random_user_agent":   uarand.GetRandom,
+		"random_choice":       RandomChoice,
+		"weighted_choice":     WeightedChoice,
+		"random_method":       RandomMethod,
+		"random_user_agent":   RandomUserAgent,
+		"random_path_segment": randomPathSegment,
+		"cache_buster":        cacheBuster,
+		"fake_email":          FakeEmail,
+		"fake_name":           FakeName,
+		"fake_phone":          FakePhone,
+		"fake_uuid":           randomUUID,
+		"counter":             Counter,
+		"now":                 Now,
+		"now_unix":            NowUnix,
+		"now_unix_ms":         NowUnixMs,
+		"now_format":          NowFormat,
+		"now_offset":          NowOffset,
 		"local_ip":            LocalIPV4,
 		"local_ipv4":          LocalIPV4,
 		"local_ipv6":          LocalIPV6,
@@ -130,8 +210,14 @@ func Parse(input string) (*template.Template, error) {
 		"resolve_host":        ResolveHostIPV4,
 		"resolve_host_ipv4":   ResolveHostIPV4,
 		"resolve_host_ipv6":   ResolveHostIPV6,
-		"base64_encode":       base64.StdEncoding.EncodeToString,
+		"base64_encode":       base64Encode,
 		"base64_decode":       base64.StdEncoding.DecodeString,
+		"hex_encode":          hexEncode,
+		"hex_decode":          hexDecode,
+		"hmac_sha256":         HMACSHA256,
+		"hmac_sha1":           HMACSHA1,
+		"hmac_md5":            HMACMD5,
+		"sha256":              SHA256,
 		"to_yaml":             toYAML,
 		"from_yaml":           fromYAML,
 		"from_yaml_array":     fromYAMLArray,
@@ -142,11 +228,16 @@ func Parse(input string) (*template.Template, error) {
 		"join":                strings.Join,
 		"split":               strings.Split,
 		"get_url":             getURLContent,
+		"file":                readFile,
+		"file_base64":         readFileBase64,
+		"env":                 env,
 		"mod":                 mod,
 		"add":                 add,
 		"ctx_key":             ctxKey,
 		"cookie_string":       cookieString,
-	}).Parse(input)
+		"datarow":             noDataRow,
+		"datacol":             noDataCol,
+	}).Funcs(funcs).Parse(input)
 }
 
 // Execute template, returns empty string in case of errors
@@ -199,19 +290,24 @@ type MapStruct struct {
 
 // ParseMapStruct is like Parse but takes mapstructure as input
 func ParseMapStruct(input map[string]interface{}) (*MapStruct, error) {
+	return ParseMapStructWithFuncs(input, nil)
+}
+
+// ParseMapStructWithFuncs is like ParseWithFuncs but takes mapstructure as input
+func ParseMapStructWithFuncs(input map[string]interface{}, funcs template.FuncMap) (*MapStruct, error) {
 	result := make(map[string]interface{})
 
 	for key, value := range input {
 		switch v := value.(type) {
 		case string:
-			tpl, err := Parse(v)
+			tpl, err := ParseWithFuncs(v, funcs)
 			if err != nil {
 				return nil, err
 			}
 
 			result[key] = tpl
 		case map[string]interface{}:
-			tpl, err := ParseMapStruct(v)
+			tpl, err := ParseMapStructWithFuncs(v, funcs)
 			if err != nil {
 				return nil, err
 			}
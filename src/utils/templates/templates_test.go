@@ -0,0 +1,137 @@
+package templates
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRandomLength(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		template string
+		min, max int
+		charset  string
+	}{
+		{name: "bytes", template: `{{ random_bytes 64 }}`, min: 64, max: 64},
+		{name: "bytes range", template: `{{ random_bytes_range 16 256 }}`, min: 16, max: 256},
+		{name: "empty bytes range", template: `{{ random_bytes_range 0 0 }}`},
+		{name: "string", template: `{{ random_string 32 }}`, min: 32, max: 32, charset: alphaNumChars},
+		{name: "string with charset", template: `{{ random_string 32 "01" }}`, min: 32, max: 32, charset: "01"},
+		{name: "string of random length", template: `{{ random_string (add 8 (random_int_n 8)) }}`, min: 8, max: 15, charset: alphaNumChars},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tpl, err := Parse(tc.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const iterations = 50
+
+			seen := make(map[string]bool)
+
+			for i := 0; i < iterations; i++ {
+				got := Execute(zap.NewNop(), tpl, context.Background())
+				if len(got) < tc.min || len(got) > tc.max {
+					t.Fatalf("expected length within [%d, %d], got %d", tc.min, tc.max, len(got))
+				}
+
+				for _, c := range []byte(got) {
+					if tc.charset != "" && !containsByte(tc.charset, c) {
+						t.Fatalf("unexpected character %q in %q", c, got)
+					}
+				}
+
+				seen[got] = true
+			}
+
+			if tc.max > 0 && len(seen) < 2 {
+				t.Errorf("expected values to vary across invocations, got %v", seen)
+			}
+		})
+	}
+}
+
+func TestRandomBytesRangeInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{`{{ random_bytes_range 10 5 }}`, `{{ random_bytes_range -1 5 }}`} {
+		if got := ParseAndExecute(zap.NewNop(), input, context.Background()); got != input {
+			t.Errorf("expected %q to fail and be returned as is, got %q", input, got)
+		}
+	}
+}
+
+func TestCacheBuster(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input, prefix, suffix string
+	}{
+		{input: "http://localhost/path", prefix: "http://localhost/path?_cb="},
+		{input: "http://localhost/path?a=1", prefix: "http://localhost/path?a=1&_cb="},
+		{input: "http://localhost/path?", prefix: "http://localhost/path?_cb="},
+		{input: "http://localhost/path?a=1&", prefix: "http://localhost/path?a=1&_cb="},
+		{input: "http://localhost/path?a=1#top", prefix: "http://localhost/path?a=1&_cb=", suffix: "#top"},
+	}
+
+	for _, tc := range testCases {
+		seen := make(map[string]bool)
+
+		for i := 0; i < 10; i++ {
+			got := cacheBuster(tc.input)
+			if !strings.HasPrefix(got, tc.prefix) || !strings.HasSuffix(got, tc.suffix) {
+				t.Fatalf("unexpected url %q for %q", got, tc.input)
+			}
+
+			u, err := url.Parse(got)
+			if err != nil || len(u.Query().Get("_cb")) != 16 {
+				t.Fatalf("invalid url %q: %v", got, err)
+			}
+
+			seen[got] = true
+		}
+
+		if len(seen) != 10 {
+			t.Errorf("expected unique urls for %q, got %v", tc.input, seen)
+		}
+	}
+}
+
+func TestRandomPathSegment(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		template string
+		length   int
+	}{
+		{template: `{{ random_path_segment }}`, length: 8},
+		{template: `{{ random_path_segment 20 }}`, length: 20},
+	} {
+		got := ParseAndExecute(zap.NewNop(), tc.template, context.Background())
+		if len(got) != tc.length || url.PathEscape(got) != got {
+			t.Errorf("expected %d url-safe characters, got %q", tc.length, got)
+		}
+	}
+}
+
+func containsByte(s string, c byte) bool {
+	for i := range s {
+		if s[i] == c {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package templates
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRandomUserAgent(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		template string
+		markers  []string
+	}{
+		{name: "any", template: "{{ random_user_agent }}"},
+		{name: "desktop", template: `{{ random_user_agent "desktop" }}`, markers: []string{"Windows", "Macintosh", "Linux x86_64"}},
+		{name: "mobile", template: `{{ random_user_agent "mobile" }}`, markers: []string{"Mobile", "iPad"}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tpl, err := Parse(tc.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const iterations = 50
+
+			seen := make(map[string]bool)
+
+			for i := 0; i < iterations; i++ {
+				ua := Execute(zap.NewNop(), tpl, context.Background())
+				if ua == "" || strings.Contains(ua, "\n") {
+					t.Fatalf("implausible user agent %q", ua)
+				}
+
+				if tc.markers != nil && !containsAny(ua, tc.markers) {
+					t.Errorf("user agent %q doesn't look like %v", ua, tc.name)
+				}
+
+				seen[ua] = true
+			}
+
+			if len(seen) < 2 {
+				t.Errorf("expected user agents to vary across invocations, got %v", seen)
+			}
+		})
+	}
+}
+
+func TestRandomUserAgentUnknownCategory(t *testing.T) {
+	t.Parallel()
+
+	if _, err := RandomUserAgent("fridge"); err == nil {
+		t.Error("expected error for unknown category")
+	}
+}
+
+func TestBundledUserAgentsArePlausible(t *testing.T) {
+	t.Parallel()
+
+	for _, list := range [][]string{desktopUserAgents.UserAgents, mobileUserAgents.UserAgents} {
+		for _, ua := range list {
+			if !strings.HasPrefix(ua, "Mozilla/5.0 (") || !strings.Contains(ua, ")") {
+				t.Errorf("implausible user agent %q", ua)
+			}
+		}
+	}
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+// Package tracing [allows exporting client-side spans of the requests via OpenTelemetry]
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/Arriven/db1000n"
+
+// holder wraps the tracer as atomic.Value requires a consistent concrete type
+type holder struct {
+	tracer trace.Tracer
+}
+
+var (
+	current    atomic.Value // holder, tracing is disabled while the tracer is nil
+	propagator = propagation.TraceContext{}
+)
+
+// Setup starts exporting spans to the otlp http endpoint (i.e. http://localhost:4318), returned func flushes the remaining spans
+func Setup(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp endpoint: %w", err)
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+
+	if u.Path != "" && u.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(u.Path))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating otlp exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// SetTracerProvider enables tracing with the provider, nil disables it
+func SetTracerProvider(provider trace.TracerProvider) {
+	if provider == nil {
+		current.Store(holder{})
+
+		return
+	}
+
+	current.Store(holder{tracer: provider.Tracer(tracerName)})
+}
+
+func tracer() trace.Tracer {
+	h, _ := current.Load().(holder)
+
+	return h.tracer
+}
+
+// HTTPSpan is a span of a single http request, it's nil when tracing is disabled
+type HTTPSpan struct {
+	span trace.Span
+}
+
+// StartHTTPSpan starts a client span for the request and injects traceparent header into it
+func StartHTTPSpan(req *fasthttp.Request) *HTTPSpan {
+	t := tracer()
+	if t == nil {
+		return nil
+	}
+
+	method := string(req.Header.Method())
+
+	ctx, span := t.Start(context.Background(), "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.host", string(req.Host())),
+			attribute.String("http.method", method),
+		))
+
+	propagator.Inject(ctx, headerCarrier{header: &req.Header})
+
+	return &HTTPSpan{span: span}
+}
+
+// End records the outcome of the request and ends the span
+func (s *HTTPSpan) End(resp *fasthttp.Response, latency time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	s.span.SetAttributes(attribute.Int64("http.latency_ms", latency.Milliseconds()))
+
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	} else if resp != nil {
+		s.span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode()))
+	}
+
+	s.span.End()
+}
+
+// headerCarrier adapts fasthttp request headers to propagation.TextMapCarrier
+type headerCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.header.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+
+	c.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+
+	return keys
+}
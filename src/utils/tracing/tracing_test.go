@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHTTPSpan(t *testing.T) { //nolint:paralleltest // Modifies the global tracer
+	traceparents := make(chan string, 1)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		traceparents <- r.Header.Get("traceparent")
+
+		w.WriteHeader(nethttp.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	send := func() {
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+
+		req.SetRequestURI(server.URL)
+		req.Header.SetMethod(fasthttp.MethodPost)
+
+		span := StartHTTPSpan(req)
+		start := time.Now()
+		err := fasthttp.Do(req, resp)
+		span.End(resp, time.Since(start), err)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		SetTracerProvider(nil)
+		send()
+
+		if traceparent := <-traceparents; traceparent != "" {
+			t.Errorf("expected no traceparent header, got %q", traceparent)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		t.Cleanup(func() { SetTracerProvider(nil) })
+
+		send()
+
+		spans := exporter.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("expected a single span, got %d", len(spans))
+		}
+
+		span := spans[0]
+
+		if expected := "00-" + span.SpanContext.TraceID().String() + "-" + span.SpanContext.SpanID().String() + "-01"; <-traceparents != expected {
+			t.Errorf("expected traceparent %q to be propagated", expected)
+		}
+
+		attributes := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes {
+			attributes[kv.Key] = kv.Value
+		}
+
+		if got := attributes["http.host"].AsString(); got != server.Listener.Addr().String() {
+			t.Errorf("unexpected host %q", got)
+		}
+
+		if got := attributes["http.method"].AsString(); got != fasthttp.MethodPost {
+			t.Errorf("unexpected method %q", got)
+		}
+
+		if got := attributes["http.status_code"].AsInt64(); got != nethttp.StatusAccepted {
+			t.Errorf("unexpected status code %d", got)
+		}
+
+		if _, ok := attributes["http.latency_ms"]; !ok {
+			t.Error("expected latency to be recorded")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		t.Cleanup(func() { SetTracerProvider(nil) })
+
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+
+		StartHTTPSpan(req).End(nil, time.Millisecond, errors.New("connection refused"))
+
+		if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Status.Code != codes.Error {
+			t.Errorf("expected a failed span, got %v", spans)
+		}
+	})
+}
+
+func TestDisabledSpan(t *testing.T) {
+	t.Parallel()
+
+	// nil span is returned when tracing is disabled and has to be safe to use
+	var span *HTTPSpan
+
+	span.End(nil, 0, nil)
+}
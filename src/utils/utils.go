@@ -2,10 +2,12 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"reflect"
 	"runtime"
 	"strconv"
 	"time"
@@ -101,7 +103,12 @@ func NonNilIntOrDefault(i *int, dflt int) int {
 
 // Decode is an alias to a mapstructure.NewDecoder({Squash: true}).Decode()
 func Decode(input interface{}, output interface{}) error {
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{Squash: true, WeaklyTypedInput: true, Result: output})
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Squash:           true,
+		WeaklyTypedInput: true,
+		DecodeHook:       stringToDurationHook,
+		Result:           output,
+	})
 	if err != nil {
 		return err
 	}
@@ -109,6 +116,19 @@ func Decode(input interface{}, output interface{}) error {
 	return decoder.Decode(input)
 }
 
+// stringToDurationHook allows specifying durations like "10s" while still accepting plain nanoseconds
+func stringToDurationHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+
+	if d, err := time.ParseDuration(reflect.ValueOf(data).String()); err == nil {
+		return d, nil
+	}
+
+	return data, nil
+}
+
 func Unmarshal(input []byte, output interface{}, format string) error {
 	switch format {
 	case "", "json", "yaml":
@@ -132,3 +152,14 @@ func openBrowser(url string) {
 
 	log.Printf("Please open %s", url)
 }
+
+// DetachedContext keeps the values of the parent context but is never cancelled with it
+type DetachedContext struct {
+	context.Context
+}
+
+func (DetachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (DetachedContext) Done() <-chan struct{} { return nil }
+
+func (DetachedContext) Err() error { return nil }